@@ -0,0 +1,162 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"firebase.google.com/go/internal"
+)
+
+const (
+	appCheckIssuerPrefix = "https://firebaseappcheck.googleapis.com/"
+	appCheckJWKSURL      = "https://firebaseappcheck.googleapis.com/v1/jwks"
+)
+
+// AppCheckToken represents a decoded Firebase App Check token.
+//
+// AppID is the ID of the Firebase app to which the token belongs, and is the same as the Subject
+// (sub) of the token. Audience lists the resource names of the Firebase project for which the token
+// was issued (e.g. "projects/<project-number>" and "projects/<project-id>"). Any additional JWT
+// claims can be accessed via the Claims map.
+type AppCheckToken struct {
+	AppID    string
+	Issuer   string
+	Subject  string
+	Audience []string
+	Expires  int64
+	IssuedAt int64
+	Claims   map[string]interface{}
+}
+
+func (t *AppCheckToken) decodeFrom(s string) error {
+	payload, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	claims := make(map[string]interface{})
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return err
+	}
+	var std struct {
+		Issuer   string          `json:"iss"`
+		Subject  string          `json:"sub"`
+		Audience json.RawMessage `json:"aud"`
+		Expires  int64           `json:"exp"`
+		IssuedAt int64           `json:"iat"`
+	}
+	if err := json.Unmarshal(payload, &std); err != nil {
+		return err
+	}
+
+	// The audience may be a single string or an array of strings.
+	var aud []string
+	if len(std.Audience) > 0 && std.Audience[0] == '"' {
+		var single string
+		if err := json.Unmarshal(std.Audience, &single); err != nil {
+			return err
+		}
+		aud = []string{single}
+	} else if len(std.Audience) > 0 {
+		if err := json.Unmarshal(std.Audience, &aud); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range []string{"iss", "sub", "aud", "exp", "iat"} {
+		delete(claims, r)
+	}
+	*t = AppCheckToken{
+		Issuer:   std.Issuer,
+		Subject:  std.Subject,
+		Audience: aud,
+		Expires:  std.Expires,
+		IssuedAt: std.IssuedAt,
+		Claims:   claims,
+	}
+	return nil
+}
+
+// VerifyAppCheckToken verifies the signature and payload of the provided Firebase App Check token.
+//
+// VerifyAppCheckToken accepts a signed JWT token string, and verifies that it is current, issued for
+// the Firebase project of the Client, and signed by the Firebase App Check service. The public keys
+// used to verify the signature are fetched from the App Check service, and cached according to the
+// HTTP cache-control headers of the response. It returns an AppCheckToken containing the decoded
+// claims in the input JWT.
+//
+// The clock skew specified with WithClockSkew() is taken into account when checking the issue and
+// expiry times of the token.
+func (c *Client) VerifyAppCheckToken(ctx context.Context, token string) (*AppCheckToken, error) {
+	if c.projectID == "" {
+		return nil, errors.New("project id not available")
+	}
+	if token == "" {
+		return nil, errors.New("app check token must be a non-empty string")
+	}
+	if strings.Count(token, ".") != 2 {
+		return nil, internal.Error(malformedToken, "app check token must be a valid JWT with three segments")
+	}
+
+	h := &jwtHeader{}
+	p := &AppCheckToken{}
+	if err := decodeToken(ctx, token, c.appCheckKS, h, p); err != nil {
+		return nil, err
+	}
+
+	audience := "projects/" + c.projectID
+	hasAudience := false
+	for _, aud := range p.Audience {
+		if aud == audience {
+			hasAudience = true
+			break
+		}
+	}
+
+	now := c.clock.Now().Unix()
+	skew := int64(c.clockSkew / time.Second)
+	var err error
+	if h.KeyID == "" {
+		err = internal.Error(invalidToken, "app check token has no 'kid' header")
+	} else if h.Algorithm != "RS256" {
+		err = internal.Errorf(invalidToken, "app check token has invalid algorithm; expected 'RS256' but got %q",
+			h.Algorithm)
+	} else if !hasAudience {
+		err = internal.Errorf(invalidAudience,
+			"app check token has invalid 'aud' (audience) claim; expected %q but got %q", audience, p.Audience)
+	} else if !strings.HasPrefix(p.Issuer, appCheckIssuerPrefix) {
+		err = internal.Errorf(invalidIssuer,
+			"app check token has invalid 'iss' (issuer) claim; expected a value starting with %q but got %q",
+			appCheckIssuerPrefix, p.Issuer)
+	} else if p.IssuedAt > now+skew {
+		err = internal.Errorf(tokenUsedTooEarly, "app check token issued at future timestamp: %d", p.IssuedAt)
+	} else if p.Expires < now-skew {
+		err = internal.Errorf(tokenExpired, "app check token has expired at: %d", p.Expires)
+	} else if p.Subject == "" {
+		err = internal.Error(invalidToken, "app check token has empty 'sub' (subject) claim")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	p.AppID = p.Subject
+	return p, nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func getAppCheckToken(p mockIDTokenPayload) string {
+	return getAppCheckTokenWithHeader(jwtHeader{Algorithm: "RS256", Type: "JWT", KeyID: "mock-key-id-1"}, p)
+}
+
+func getAppCheckTokenWithHeader(h jwtHeader, p mockIDTokenPayload) string {
+	pCopy := mockIDTokenPayload{
+		"aud": []string{"projects/12345678", "projects/" + client.projectID},
+		"iss": "https://firebaseappcheck.googleapis.com/12345678",
+		"iat": time.Now().Unix() - 100,
+		"exp": time.Now().Unix() + 3600,
+		"sub": "1:12345678:android:abcdef",
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(pCopy, k)
+		} else {
+			pCopy[k] = v
+		}
+	}
+	token, err := encodeToken(ctx, client.snr, h, pCopy)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return token
+}
+
+func appCheckClient() *Client {
+	c := *client
+	c.appCheckKS = client.ks
+	return &c
+}
+
+func TestVerifyAppCheckToken(t *testing.T) {
+	c := appCheckClient()
+	token, err := c.VerifyAppCheckToken(ctx, getAppCheckToken(mockIDTokenPayload{"custom": "value"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "1:12345678:android:abcdef"; token.AppID != want || token.Subject != want {
+		t.Errorf("VerifyAppCheckToken() (AppID, Subject) = (%q, %q); want = (%q, %q)",
+			token.AppID, token.Subject, want, want)
+	}
+	if len(token.Audience) != 2 {
+		t.Errorf("VerifyAppCheckToken() Audience = %v; want = 2 entries", token.Audience)
+	}
+	if token.Claims["custom"] != "value" {
+		t.Errorf("VerifyAppCheckToken() Claims = %v; want = {custom: value}", token.Claims)
+	}
+	if _, ok := token.Claims["aud"]; ok {
+		t.Errorf("VerifyAppCheckToken() Claims contains standard claim 'aud'")
+	}
+}
+
+func TestVerifyAppCheckTokenSingleAudience(t *testing.T) {
+	c := appCheckClient()
+	token, err := c.VerifyAppCheckToken(ctx, getAppCheckToken(mockIDTokenPayload{
+		"aud": "projects/" + client.projectID,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token.Audience) != 1 || token.Audience[0] != "projects/"+client.projectID {
+		t.Errorf("VerifyAppCheckToken() Audience = %v; want = [projects/%s]", token.Audience, client.projectID)
+	}
+}
+
+func TestVerifyAppCheckTokenError(t *testing.T) {
+	now := time.Now().Unix()
+	cases := []struct {
+		name  string
+		token string
+		want  func(error) bool
+	}{
+		{"NoKid", getAppCheckTokenWithHeader(jwtHeader{Algorithm: "RS256", Type: "JWT"}, nil), IsInvalidToken},
+		{"WrongAudience", getAppCheckToken(mockIDTokenPayload{"aud": []string{"projects/other-project"}}), IsInvalidAudience},
+		{"NoAudience", getAppCheckToken(mockIDTokenPayload{"aud": nil}), IsInvalidAudience},
+		{"WrongIssuer", getAppCheckToken(mockIDTokenPayload{"iss": "https://securetoken.google.com/12345678"}), IsInvalidIssuer},
+		{"FutureToken", getAppCheckToken(mockIDTokenPayload{"iat": now + 1000}), IsTokenUsedTooEarly},
+		{"ExpiredToken", getAppCheckToken(mockIDTokenPayload{"iat": now - 10000, "exp": now - 3600}), IsTokenExpired},
+		{"EmptySubject", getAppCheckToken(mockIDTokenPayload{"sub": ""}), IsInvalidToken},
+		{"BadSignature", testIDToken[:len(testIDToken)-8] + "AAAAAAAA", IsInvalidSignature},
+		{"MalformedToken", "not.a.token", IsMalformedToken},
+		{"WrongSegmentCount", "one.two", IsMalformedToken},
+	}
+	c := appCheckClient()
+	for _, tc := range cases {
+		token, err := c.VerifyAppCheckToken(ctx, tc.token)
+		if token != nil || !tc.want(err) {
+			t.Errorf("VerifyAppCheckToken(%s) = (%v, %v); want = (nil, error)", tc.name, token, err)
+		}
+	}
+}
+
+func TestVerifyAppCheckTokenEmpty(t *testing.T) {
+	if token, err := appCheckClient().VerifyAppCheckToken(ctx, ""); token != nil || err == nil {
+		t.Errorf("VerifyAppCheckToken('') = (%v, %v); want = (nil, error)", token, err)
+	}
+}
+
+func TestVerifyAppCheckTokenNoProjectID(t *testing.T) {
+	c := appCheckClient()
+	c.projectID = ""
+	if token, err := c.VerifyAppCheckToken(ctx, getAppCheckToken(nil)); token != nil || err == nil {
+		t.Errorf("VerifyAppCheckToken() = (%v, %v); want = (nil, error)", token, err)
+	}
+}
+
+func TestVerifyAppCheckTokenClockSkew(t *testing.T) {
+	c := appCheckClient()
+	c.clockSkew = time.Minute
+	now := time.Now().Unix()
+	if _, err := c.VerifyAppCheckToken(ctx, getAppCheckToken(mockIDTokenPayload{"iat": now + 30})); err != nil {
+		t.Errorf("VerifyAppCheckToken() = %v; want = nil", err)
+	}
+}
@@ -16,81 +16,364 @@
 package auth
 
 import (
-	"crypto/rsa"
+	"crypto"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
 
 	"firebase.google.com/go/internal"
 	"google.golang.org/api/identitytoolkit/v3"
 	"google.golang.org/api/transport"
+	htransport "google.golang.org/api/transport/http"
 )
 
 const (
 	firebaseAudience = "https://identitytoolkit.googleapis.com/google.identity.identitytoolkit.v1.IdentityToolkit"
 	idTokenCertURL   = "https://www.googleapis.com/robot/v1/metadata/x509/securetoken@system.gserviceaccount.com"
+	idToolkitURL     = "https://identitytoolkit.googleapis.com/v1"
+	idToolkitV2URL   = "https://identitytoolkit.googleapis.com/v2"
 	issuerPrefix     = "https://securetoken.google.com/"
 	tokenExpSeconds  = 3600
+
+	sessionCookieCertURL      = "https://www.googleapis.com/identitytoolkit/v3/relyingparty/publicKeys"
+	sessionCookieIssuerPrefix = "https://session.firebase.google.com/"
+
+	// emulatorHostEnvVar is the environment variable that specifies the host:port of the Firebase
+	// Auth Emulator, when the Client is not configured with WithEmulatorHost().
+	emulatorHostEnvVar = "FIREBASE_AUTH_EMULATOR_HOST"
+	emulatorToken      = "owner"
+
+	// emulatedServiceAccount is the issuer of the custom tokens created for the Auth emulator.
+	emulatedServiceAccount = "firebase-auth-emulator@example.com"
 )
 
+// reservedClaims lists the top-level claim names that cannot be set as developer claims in a
+// custom token. Names are matched exactly, and are rejected regardless of the type of the value.
+// Claims nested inside other developer claims are not checked.
 var reservedClaims = []string{
 	"acr", "amr", "at_hash", "aud", "auth_time", "azp", "cnf", "c_hash",
 	"exp", "firebase", "iat", "iss", "jti", "nbf", "nonce", "sub",
 }
 
-var clk clock = &systemClock{}
-
 // Token represents a decoded Firebase ID token.
 //
 // Token provides typed accessors to the common JWT fields such as Audience (aud) and Expiry (exp).
 // Additionally it provides a UID field, which indicates the user ID of the account to which this token
 // belongs. Any additional JWT claims can be accessed via the Claims map of Token.
+//
+// AuthTime (auth_time) is the time at which the user authenticated, in seconds since the epoch. It
+// differs from IssuedAt, since ID tokens are refreshed without the user authenticating again. The
+// auth_time claim also remains available in the Claims map.
+//
+// KeyID and Algorithm are populated from the header of the JWT, and indicate the public key and the
+// algorithm that were used to verify the signature of the token.
 type Token struct {
-	Issuer   string                 `json:"iss"`
-	Audience string                 `json:"aud"`
-	Expires  int64                  `json:"exp"`
-	IssuedAt int64                  `json:"iat"`
-	Subject  string                 `json:"sub,omitempty"`
-	UID      string                 `json:"uid,omitempty"`
-	Claims   map[string]interface{} `json:"-"`
+	Issuer    string                 `json:"iss"`
+	Audience  string                 `json:"aud"`
+	Expires   int64                  `json:"exp"`
+	IssuedAt  int64                  `json:"iat"`
+	AuthTime  int64                  `json:"auth_time,omitempty"`
+	Subject   string                 `json:"sub,omitempty"`
+	UID       string                 `json:"uid,omitempty"`
+	KeyID     string                 `json:"-"`
+	Algorithm string                 `json:"-"`
+	Claims    map[string]interface{} `json:"-"`
+
+	payload []byte
+	clock   clock
+}
+
+// Decode unmarshals the JSON payload of the token into v, which allows decoding the claims into
+// a struct of the caller's choosing, instead of accessing them through the Claims map.
+//
+// Decode is only available for tokens returned by the verification functions of the Client
+// (e.g. VerifyIDToken()). It returns an error for a Token that was not decoded from a JWT.
+func (t *Token) Decode(v interface{}) error {
+	if len(t.payload) == 0 {
+		return errors.New("token payload not available")
+	}
+	return json.Unmarshal(t.payload, v)
 }
 
 func (t *Token) decodeFrom(s string) error {
+	payload, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return t.UnmarshalJSON(payload)
+}
+
+// standardClaims lists the claims that are decoded into the fields of Token, and are therefore
+// excluded from the Claims map.
+var standardClaims = []string{"iss", "aud", "exp", "iat", "sub", "uid"}
+
+// MarshalJSON marshals the token into a single JSON object, that contains both the standard claims
+// and the custom claims in the Claims map. This allows caching a verified Token as JSON without
+// losing its custom claims.
+//
+// KeyID and Algorithm describe the header of the JWT rather than its payload, and are not
+// included.
+func (t Token) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(t.Claims)+len(standardClaims))
+	for k, v := range t.Claims {
+		m[k] = v
+	}
+	m["iss"] = t.Issuer
+	m["aud"] = t.Audience
+	m["exp"] = t.Expires
+	m["iat"] = t.IssuedAt
+	if t.AuthTime != 0 {
+		m["auth_time"] = t.AuthTime
+	}
+	if t.Subject != "" {
+		m["sub"] = t.Subject
+	}
+	if t.UID != "" {
+		m["uid"] = t.UID
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON unmarshals a JSON object into the token. The standard claims are decoded into the
+// fields of Token, and all other claims are decoded into the Claims map.
+func (t *Token) UnmarshalJSON(b []byte) error {
 	// Decode into a regular map to access custom claims.
 	claims := make(map[string]interface{})
-	if err := decode(s, &claims); err != nil {
+	if err := json.Unmarshal(b, &claims); err != nil {
 		return err
 	}
 	// Now decode into Token to access the standard claims.
-	if err := decode(s, t); err != nil {
+	type token Token
+	if err := json.Unmarshal(b, (*token)(t)); err != nil {
 		return err
 	}
+	t.payload = append([]byte(nil), b...)
 
 	// Delete standard claims from the custom claims maps.
-	for _, r := range []string{"iss", "aud", "exp", "iat", "sub", "uid"} {
+	for _, r := range standardClaims {
 		delete(claims, r)
 	}
 	t.Claims = claims
 	return nil
 }
 
+// FirebaseInfo contains the details of the sign-in event recorded in the "firebase" claim of an
+// ID token.
+//
+// SignInProvider is the ID of the provider used to sign in the user (e.g. "password",
+// "google.com" or "anonymous"). Identities maps each provider linked to the user account to the
+// list of the user's identifiers at that provider. Tenant is the ID of the tenant the user belongs
+// to, and is only set for users of a multi-tenant Google Cloud Identity Platform project.
+type FirebaseInfo struct {
+	SignInProvider string              `json:"sign_in_provider"`
+	Identities     map[string][]string `json:"identities"`
+	Tenant         string              `json:"tenant"`
+}
+
+// Firebase returns the contents of the "firebase" claim of the token as a FirebaseInfo.
+//
+// The raw claim remains available in the Claims map. Returns a zero FirebaseInfo if the token does
+// not contain a "firebase" claim. If the claim is malformed, only the well-formed fields are
+// populated.
+func (t *Token) Firebase() FirebaseInfo {
+	var info FirebaseInfo
+	if fb, ok := t.Claims["firebase"]; ok {
+		if b, err := json.Marshal(fb); err == nil {
+			json.Unmarshal(b, &info) // ignore errors for partially malformed claims
+		}
+	}
+	return info
+}
+
+// Sign-in provider IDs that may be returned by Token.SignInProvider(). Tokens issued for OIDC and
+// SAML providers carry the ID of the corresponding provider configuration (e.g. "oidc.provider")
+// instead.
+const (
+	ProviderAnonymous = "anonymous"
+	ProviderCustom    = "custom"
+	ProviderFacebook  = "facebook.com"
+	ProviderGitHub    = "github.com"
+	ProviderGoogle    = "google.com"
+	ProviderPassword  = "password"
+	ProviderPhone     = "phone"
+	ProviderTwitter   = "twitter.com"
+)
+
+// SignInProvider returns the ID of the provider used to sign in the user to whom the token
+// belongs, as recorded in the "firebase" claim. Returns an empty string if the claim is absent.
+func (t *Token) SignInProvider() string {
+	return t.Firebase().SignInProvider
+}
+
+// EmailVerified returns the value of the "email_verified" claim of the token, which indicates
+// whether the email address of the user had been verified when the token was issued. Returns false
+// if the claim is absent or is not a boolean.
+//
+// Token claims reflect the state of the user account at the time the token was issued, and may be
+// out of date. Use GetUser() to obtain the current state of the account.
+func (t *Token) EmailVerified() bool {
+	verified, _ := t.Claims["email_verified"].(bool)
+	return verified
+}
+
+// HasClaim checks whether the claim of the token with the given key has the value want, or is an
+// array that contains want (e.g. a "roles" claim listing the roles of the user).
+//
+// Since numbers in JSON are decoded as float64, numeric values are compared by value, regardless
+// of their Go type: HasClaim("level", 2) matches a claim with the value 2.0. Returns false if the
+// claim is absent.
+func (t *Token) HasClaim(key string, want interface{}) bool {
+	return hasClaim(t.Claims, key, want)
+}
+
+// hasClaim checks whether the claim with the given key has the value want, or is an array that
+// contains want.
+func hasClaim(claims map[string]interface{}, key string, want interface{}) bool {
+	v, ok := claims[key]
+	if !ok {
+		return false
+	}
+	if claimEquals(v, want) {
+		return true
+	}
+	if values, ok := v.([]interface{}); ok {
+		for _, e := range values {
+			if claimEquals(e, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BoolClaim returns the value of the claim of the token with the given key. The second return
+// value is false if the claim is absent or is not a boolean.
+func (t *Token) BoolClaim(key string) (bool, bool) {
+	v, ok := t.Claims[key].(bool)
+	return v, ok
+}
+
+// StringClaim returns the value of the claim of the token with the given key. The second return
+// value is false if the claim is absent or is not a string.
+func (t *Token) StringClaim(key string) (string, bool) {
+	v, ok := t.Claims[key].(string)
+	return v, ok
+}
+
+// claimEquals compares a claim value with the value wanted by the caller. Numbers are compared as
+// float64 values.
+func claimEquals(v, want interface{}) bool {
+	if f, ok := toFloat64(v); ok {
+		w, ok := toFloat64(want)
+		return ok && f == w
+	}
+	return reflect.DeepEqual(v, want)
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// TimeUntilExpiry returns the time remaining until the token expires, as indicated by its "exp"
+// claim. The result is negative if the token has already expired.
+//
+// For tokens returned by the verification functions of the Client, the remaining time is computed
+// against the clock used during verification. Clock skew allowances are not taken into account.
+func (t *Token) TimeUntilExpiry() time.Duration {
+	return time.Unix(t.Expires, 0).Sub(t.now())
+}
+
+// Age returns the time elapsed since the token was issued, as indicated by its "iat" claim.
+func (t *Token) Age() time.Duration {
+	return t.now().Sub(time.Unix(t.IssuedAt, 0))
+}
+
+func (t *Token) now() time.Time {
+	if t.clock == nil {
+		return time.Now()
+	}
+	return t.clock.Now()
+}
+
 // Client is the interface for the Firebase auth service.
 //
 // Client facilitates generating custom JWT tokens for Firebase clients, and verifying ID tokens issued
 // by Firebase backend services.
 type Client struct {
-	is        *identitytoolkit.Service
-	ks        keySource
-	projectID string
-	snr       signer
-	version   string
+	acceptedProjectIDs []string
+	allowedClaims      map[string]bool
+	appName            string
+	appCheckKS         KeySource
+	clock              clock
+	clockSkew          time.Duration
+	hc                 *internal.HTTPClient
+	hook               ObservabilityHook
+	httpTimeout        time.Duration
+	is                 identitytoolkitService
+	issuedAtLeeway     time.Duration
+	ks                 KeySource
+	cookieKS           KeySource
+	keyCacheMinTTL     time.Duration
+	keyCacheMaxTTL     time.Duration
+	logf               func(format string, v ...interface{})
+	keyRefreshLogf     func(format string, v ...interface{})
+	customTokenIss     string
+	customTokenKid     string
+	emulatorHost       string
+	noCustomTokenCheck bool
+	maxResponseSize    int64
+	maxTokenAge        time.Duration
+	projectID          string
+	retryConfig        *RetryConfig
+	revocationTimeout  time.Duration
+	serviceAccountFile string
+	signingConcurrency int
+	snr                signer
+	tenantID           string
+	transport          http.RoundTripper
+	uidGenerator       func() string
+	url                string // to enable testing against arbitrary endpoints
+	v2URL              string
+	version            string
 }
 
 type signer interface {
+	Algorithm() string
 	Email(ctx context.Context) (string, error)
+	KeyID() string
 	Sign(ctx context.Context, b []byte) ([]byte, error)
 }
 
@@ -98,16 +381,61 @@ type signer interface {
 //
 // This function can only be invoked from within the SDK. Client applications should access the
 // Auth service through firebase.App.
-func NewClient(ctx context.Context, c *internal.AuthConfig) (*Client, error) {
+func NewClient(ctx context.Context, c *internal.AuthConfig, opts ...ClientOption) (*Client, error) {
+	client := &Client{
+		projectID: c.ProjectID,
+		url:       idToolkitURL,
+		v2URL:     idToolkitV2URL,
+		version:   "Go/Admin/" + c.Version,
+	}
+	for _, o := range opts {
+		o(client)
+	}
+	if client.clock == nil {
+		client.clock = systemClock{}
+	}
+	if client.customTokenIss != "" {
+		if err := validateEmail(client.customTokenIss); err != nil {
+			return nil, fmt.Errorf("invalid custom token issuer: %v", err)
+		}
+	}
+	if client.clockSkew < 0 {
+		return nil, errors.New("clock skew must not be negative")
+	}
+	if client.keyCacheMinTTL < 0 || client.keyCacheMaxTTL < 0 {
+		return nil, errors.New("key cache ttl must not be negative")
+	}
+	if client.keyCacheMaxTTL > 0 && client.keyCacheMinTTL > client.keyCacheMaxTTL {
+		return nil, fmt.Errorf("key cache min ttl %v must not exceed max ttl %v",
+			client.keyCacheMinTTL, client.keyCacheMaxTTL)
+	}
+	if client.maxResponseSize < 0 {
+		return nil, errors.New("max response size must not be negative")
+	}
+	if client.maxResponseSize == 0 {
+		client.maxResponseSize = defaultMaxResponseSize
+	}
+	if client.emulatorHost == "" {
+		client.emulatorHost = os.Getenv(emulatorHostEnvVar)
+	}
+	if client.appName != "" {
+		if strings.ContainsAny(client.appName, "\r\n") {
+			return nil, fmt.Errorf("invalid application name: %q", client.appName)
+		}
+		client.version += " " + client.appName
+	}
+
 	var (
 		err   error
 		email string
-		pk    *rsa.PrivateKey
+		keyID string
+		pk    crypto.Signer
 	)
 	if c.Creds != nil && len(c.Creds.JSON) > 0 {
 		var svcAcct struct {
-			ClientEmail string `json:"client_email"`
-			PrivateKey  string `json:"private_key"`
+			ClientEmail  string `json:"client_email"`
+			PrivateKey   string `json:"private_key"`
+			PrivateKeyID string `json:"private_key_id"`
 		}
 		if err := json.Unmarshal(c.Creds.JSON, &svcAcct); err != nil {
 			return nil, err
@@ -119,35 +447,120 @@ func NewClient(ctx context.Context, c *internal.AuthConfig) (*Client, error) {
 			}
 		}
 		email = svcAcct.ClientEmail
+		keyID = svcAcct.PrivateKeyID
 	}
 
-	var snr signer
-	if email != "" && pk != nil {
-		snr = serviceAcctSigner{email: email, pk: pk}
+	if client.serviceAccountFile != "" {
+		client.snr, err = newServiceAcctSignerFromFile(client.serviceAccountFile)
+		if err != nil {
+			return nil, err
+		}
+	} else if email != "" && pk != nil {
+		client.snr = serviceAcctSigner{email: email, keyID: keyID, pk: pk}
+	} else if client.emulatorHost != "" {
+		client.snr = emulatedSigner{}
 	} else {
-		snr, err = newSigner(ctx)
+		client.snr, err = newSigner(ctx)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	hc, _, err := transport.NewHTTPClient(ctx, c.Opts...)
-	if err != nil {
-		return nil, err
+	var hc *http.Client
+	if client.emulatorHost != "" {
+		// The emulator accepts any requests authorized with the "owner" token, and does not
+		// require Google credentials.
+		hc = &http.Client{
+			Transport: &oauth2.Transport{
+				Base:   client.transport,
+				Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: emulatorToken}),
+			},
+		}
+	} else if client.transport != nil {
+		rt, err := htransport.NewTransport(ctx, client.transport, c.Opts...)
+		if err != nil {
+			return nil, err
+		}
+		hc = &http.Client{Transport: rt}
+	} else {
+		hc, _, err = transport.NewHTTPClient(ctx, c.Opts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// The http.Client may be provided by the developer (e.g. with option.WithHTTPClient()), and shared
+	// with other services. Therefore only a copy of it is configured for the Client, and the
+	// transports below wrap the transport of the copy.
+	hcCopy := *hc
+	hc = &hcCopy
+	if client.httpTimeout > 0 {
+		hc.Timeout = client.httpTimeout
+	}
+	base := hc.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	hc.Transport = &limitedTransport{base: base, limit: client.maxResponseSize}
+	if client.logf != nil {
+		hc.Transport = &redactingTransport{base: hc.Transport, logf: client.logf}
+	}
+	if client.hook != nil {
+		hc.Transport = &observedTransport{base: hc.Transport, hook: client.hook}
 	}
 
 	is, err := identitytoolkit.New(hc)
 	if err != nil {
 		return nil, err
 	}
+	if client.emulatorHost != "" {
+		baseURL := "http://" + client.emulatorHost
+		is.BasePath = baseURL + "/www.googleapis.com/identitytoolkit/v3/relyingparty/"
+		client.url = baseURL + "/identitytoolkit.googleapis.com/v1"
+		client.v2URL = baseURL + "/identitytoolkit.googleapis.com/v2"
+	}
+	client.is = newIdentitytoolkitClient(is, client.version)
 
-	return &Client{
-		is:        is,
-		ks:        newHTTPKeySource(idTokenCertURL, hc),
-		projectID: c.ProjectID,
-		snr:       snr,
-		version:   "Go/Admin/" + c.Version,
-	}, nil
+	client.hc = &internal.HTTPClient{Client: hc}
+	if client.ks == nil {
+		client.ks = newHTTPKeySource(idTokenCertURL, hc)
+	}
+	if client.cookieKS == nil {
+		client.cookieKS = newHTTPKeySource(sessionCookieCertURL, hc)
+	}
+	if client.appCheckKS == nil {
+		client.appCheckKS = newJWKSKeySource(appCheckJWKSURL, hc)
+	}
+	if client.keyRefreshLogf == nil {
+		client.keyRefreshLogf = log.Printf
+	}
+	for _, ks := range []KeySource{client.ks, client.cookieKS, client.appCheckKS} {
+		if hks, ok := ks.(*httpKeySource); ok {
+			hks.MinTTL = client.keyCacheMinTTL
+			hks.MaxTTL = client.keyCacheMaxTTL
+			hks.Version = client.version
+			hks.Logf = client.keyRefreshLogf
+		}
+	}
+	return client, nil
+}
+
+// NewEmulatorClient creates a new instance of the Firebase Auth Client, which is connected to the
+// Firebase Auth Emulator running at the specified host:port (e.g. "localhost:9099").
+//
+// The Client requires no Google credentials, and is intended for use in tests. It is equivalent to
+// a Client created with the WithEmulatorHost() option: all calls are sent to the emulator, custom
+// tokens are not signed, and the signatures of ID tokens and session cookies are not verified,
+// while their claims are still validated against projectID. The project ID must match the project
+// that the emulator was started with.
+func NewEmulatorClient(ctx context.Context, projectID, emulatorHost string, opts ...ClientOption) (*Client, error) {
+	if projectID == "" {
+		return nil, errors.New("project id must be a non-empty string")
+	}
+	if emulatorHost == "" {
+		return nil, errors.New("emulator host must be a non-empty string")
+	}
+	conf := &internal.AuthConfig{ProjectID: projectID}
+	return NewClient(ctx, conf, append(opts, WithEmulatorHost(emulatorHost))...)
 }
 
 // CustomToken creates a signed custom authentication token with the specified user ID. The resulting
@@ -160,14 +573,113 @@ func (c *Client) CustomToken(ctx context.Context, uid string) (string, error) {
 
 // CustomTokenWithClaims is similar to CustomToken, but in addition to the user ID, it also encodes
 // all the key-value pairs in the provided map as claims in the resulting JWT.
+//
+// The top-level keys of devClaims must not be any of the claim names reserved by JWT or Firebase
+// (e.g. "sub", "exp" or "firebase"). Keys are case-sensitive, and a reserved key is rejected
+// regardless of the type of its value. The claims must be serializable to JSON, and the serialized
+// claims must not be longer than 1000 characters.
 func (c *Client) CustomTokenWithClaims(ctx context.Context, uid string, devClaims map[string]interface{}) (string, error) {
-	iss, err := c.snr.Email(ctx)
+	return c.CustomTokenWithClaimsAndExpiry(ctx, uid, devClaims, tokenExpSeconds*time.Second)
+}
+
+// CustomTokenWithClaimsAndExpiry is similar to CustomTokenWithClaims, but in addition it allows
+// specifying the lifetime of the resulting JWT.
+//
+// The expiry duration must be at least 1 minute, and may not exceed 1 hour, which is the maximum
+// lifetime of a custom token accepted by Firebase.
+func (c *Client) CustomTokenWithClaimsAndExpiry(ctx context.Context, uid string, devClaims map[string]interface{}, expiry time.Duration) (string, error) {
+	iss, err := c.customTokenIssuer(ctx)
 	if err != nil {
 		return "", err
 	}
+	token, _, err := c.customToken(ctx, iss, uid, devClaims, expiry)
+	return token, err
+}
+
+// CustomTokenWithExpiry is similar to CustomToken, but in addition to the token, it also returns the
+// time at which the token expires.
+//
+// Custom tokens must be exchanged for ID tokens before they expire. Services that hand out custom
+// tokens can use the expiry time to schedule minting a new token, instead of waiting for the
+// exchange to fail.
+func (c *Client) CustomTokenWithExpiry(ctx context.Context, uid string) (string, time.Time, error) {
+	iss, err := c.customTokenIssuer(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return c.customToken(ctx, iss, uid, nil, tokenExpSeconds*time.Second)
+}
 
+// CustomTokens creates a signed custom authentication token for each of the specified user IDs.
+//
+// The returned tokens are in the same order as uids. The service account email used as the issuer
+// of the tokens is resolved only once for the whole batch. Tokens are signed sequentially by
+// default; use the WithSigningConcurrency() option to sign several tokens at a time, which helps
+// when signing involves a remote call. If any token cannot be created, CustomTokens returns the
+// error of the first such token, along with a nil slice.
+func (c *Client) CustomTokens(ctx context.Context, uids []string) ([]string, error) {
+	iss, err := c.customTokenIssuer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := c.signingConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	tokens := make([]string, len(uids))
+	errs := make([]error, len(uids))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, uid := range uids {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, uid string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			tokens[i], _, errs[i] = c.customToken(ctx, iss, uid, nil, tokenExpSeconds*time.Second)
+		}(i, uid)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to create custom token for uid at index %d: %v", i, err)
+		}
+	}
+	return tokens, nil
+}
+
+// ServiceAccountEmail returns the email of the service account used to sign custom tokens, which
+// appears as the issuer ("iss") and the subject ("sub") of the tokens created by the Client, unless
+// a different issuer is specified with WithCustomTokenIssuer().
+//
+// When the Client is initialized with a service account key, the email is read from the key.
+// Otherwise it is looked up from the environment (e.g. the App Engine app identity service), which
+// may involve a remote call.
+func (c *Client) ServiceAccountEmail(ctx context.Context) (string, error) {
+	return c.snr.Email(ctx)
+}
+
+// customTokenIssuer returns the service account email to be used as the issuer and the subject of
+// custom tokens.
+func (c *Client) customTokenIssuer(ctx context.Context) (string, error) {
+	if c.customTokenIss != "" {
+		return c.customTokenIss, nil
+	}
+	return c.snr.Email(ctx)
+}
+
+// customToken creates a custom token with the given issuer, after validating the rest of the
+// arguments. Returns the token along with its expiry time.
+func (c *Client) customToken(ctx context.Context, iss, uid string, devClaims map[string]interface{}, expiry time.Duration) (string, time.Time, error) {
 	if len(uid) == 0 || len(uid) > 128 {
-		return "", errors.New("uid must be non-empty, and not longer than 128 characters")
+		return "", time.Time{}, errors.New("uid must be non-empty, and not longer than 128 characters")
+	}
+	if expiry < time.Minute || expiry > tokenExpSeconds*time.Second {
+		return "", time.Time{}, fmt.Errorf("expiry duration must be between 1 minute and %d seconds", tokenExpSeconds)
 	}
 
 	var disallowed []string
@@ -177,23 +689,55 @@ func (c *Client) CustomTokenWithClaims(ctx context.Context, uid string, devClaim
 		}
 	}
 	if len(disallowed) == 1 {
-		return "", fmt.Errorf("developer claim %q is reserved and cannot be specified", disallowed[0])
+		return "", time.Time{}, fmt.Errorf("developer claim %q is reserved and cannot be specified", disallowed[0])
 	} else if len(disallowed) > 1 {
-		return "", fmt.Errorf("developer claims %q are reserved and cannot be specified", strings.Join(disallowed, ", "))
+		return "", time.Time{}, fmt.Errorf("developer claims %q are reserved and cannot be specified", strings.Join(disallowed, ", "))
+	}
+	if c.allowedClaims != nil {
+		var notAllowed []string
+		for k := range devClaims {
+			if !c.allowedClaims[k] {
+				notAllowed = append(notAllowed, k)
+			}
+		}
+		sort.Strings(notAllowed)
+		if len(notAllowed) == 1 {
+			return "", time.Time{}, fmt.Errorf("developer claim %q is not in the allowed custom claims", notAllowed[0])
+		} else if len(notAllowed) > 1 {
+			return "", time.Time{}, fmt.Errorf("developer claims %q are not in the allowed custom claims", strings.Join(notAllowed, ", "))
+		}
+	}
+	if len(devClaims) > 0 {
+		b, err := json.Marshal(devClaims)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("custom claims are not JSON serializable: %v", err)
+		}
+		if len(b) > maxLenPayloadCC {
+			return "", time.Time{}, fmt.Errorf("serialized custom claims must not exceed %d characters; got %d",
+				maxLenPayloadCC, len(b))
+		}
 	}
 
-	now := clk.Now().Unix()
-	header := jwtHeader{Algorithm: "RS256", Type: "JWT"}
+	now := c.clock.Now().Unix()
+	header := jwtHeader{Algorithm: c.snr.Algorithm(), Type: "JWT", KeyID: c.customTokenKid}
+	if header.KeyID == "" {
+		header.KeyID = c.snr.KeyID()
+	}
 	payload := &customToken{
-		Iss:    iss,
-		Sub:    iss,
-		Aud:    firebaseAudience,
-		UID:    uid,
-		Iat:    now,
-		Exp:    now + tokenExpSeconds,
-		Claims: devClaims,
+		Iss:      iss,
+		Sub:      iss,
+		Aud:      firebaseAudience,
+		UID:      uid,
+		Iat:      now,
+		Exp:      now + int64(expiry/time.Second),
+		TenantID: c.tenantID,
+		Claims:   devClaims,
+	}
+	token, err := encodeToken(ctx, c.snr, header, payload)
+	if err != nil {
+		return "", time.Time{}, err
 	}
-	return encodeToken(ctx, c.snr, header, payload)
+	return token, time.Unix(payload.Exp, 0), nil
 }
 
 // RevokeRefreshTokens revokes all refresh tokens issued to a user.
@@ -203,11 +747,51 @@ func (c *Client) CustomTokenWithClaims(ctx context.Context, uid string, devClaim
 //
 // While this revokes all sessions for a specified user and disables any new ID tokens for existing sessions
 // from getting minted, existing ID tokens may remain active until their natural expiration (one hour).
-// To verify that ID tokens are revoked, use `verifyIdTokenAndCheckRevoked(ctx, idToken)`.
+// To verify that ID tokens are revoked, use `verifyIdTokenAndCheckRevoked(ctx, idToken)`. Session cookies
+// issued before the revocation are similarly rejected by VerifySessionCookieAndCheckRevoked().
 func (c *Client) RevokeRefreshTokens(ctx context.Context, uid string) error {
 	return c.updateUser(ctx, uid, (&UserToUpdate{}).revokeRefreshTokens())
 }
 
+// RefreshKeys fetches the public keys used to verify ID tokens and session cookies again from
+// Google servers, without waiting for the cached keys to expire.
+//
+// The keys are fetched synchronously, and any error encountered while fetching them is returned.
+// The cached keys are only replaced if the fetch succeeds, and are used as before otherwise.
+// Tokens verified concurrently with RefreshKeys wait for the new keys. If the Client was
+// configured with a custom KeySource via WithKeySource(), only the keys used to verify session
+// cookies are refreshed.
+func (c *Client) RefreshKeys(ctx context.Context) error {
+	for _, ks := range []KeySource{c.ks, c.cookieKS} {
+		if hks, ok := ks.(*httpKeySource); ok {
+			if err := hks.forceRefresh(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WarmUp loads the public keys used to verify ID tokens and session cookies into the key cache,
+// so that the first call to VerifyIDToken() or VerifySessionCookie() does not have to wait for
+// them to be fetched.
+//
+// WarmUp returns an error if the keys cannot be fetched, which makes it suitable for use during
+// service initialization and in readiness checks. Keys that are already cached and fresh are not
+// fetched again, which makes repeated calls cheap. WarmUp is a no-op when the Client is connected
+// to the Auth emulator, since tokens issued by the emulator are not signed.
+func (c *Client) WarmUp(ctx context.Context) error {
+	if c.emulatorHost != "" {
+		return nil
+	}
+	for _, ks := range []KeySource{c.ks, c.cookieKS} {
+		if _, err := ks.Keys(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // VerifyIDToken verifies the signature	and payload of the provided ID token.
 //
 // VerifyIDToken accepts a signed JWT token string, and verifies that it is current, issued for the
@@ -217,75 +801,541 @@ func (c *Client) RevokeRefreshTokens(ctx context.Context, uid string) error {
 // more details on how to obtain an ID token in a client app.
 // This does not check whether or not the token has been revoked. See `VerifyIDTokenAndCheckRevoked` below.
 func (c *Client) VerifyIDToken(ctx context.Context, idToken string) (*Token, error) {
-	if c.projectID == "" {
-		return nil, errors.New("project id not available")
+	return c.verifyIDToken(ctx, idToken, append([]string{c.projectID}, c.acceptedProjectIDs...))
+}
+
+// VerifyIDTokenForAudience verifies the signature and payload of an ID token issued for the
+// specified Firebase project.
+//
+// VerifyIDTokenForAudience performs the same checks as VerifyIDToken, but expects the 'aud'
+// (audience) claim of the token to be expectedAudience, and derives the expected issuer from it,
+// instead of the project ID of the Client. This allows verifying ID tokens minted for other Firebase
+// projects, without initializing a separate Client for each project.
+func (c *Client) VerifyIDTokenForAudience(ctx context.Context, idToken, expectedAudience string) (*Token, error) {
+	if expectedAudience == "" {
+		return nil, errors.New("expected audience must be a non-empty string")
 	}
-	if idToken == "" {
-		return nil, fmt.Errorf("id token must be a non-empty string")
+	return c.verifyIDToken(ctx, idToken, []string{expectedAudience})
+}
+
+// VerifyIDTokenFreshAuth performs the same checks as VerifyIDToken, and additionally checks that the
+// user authenticated no more than maxAge ago, according to the 'auth_time' claim of the token.
+//
+// This allows requiring a recent sign-in for sensitive operations (step-up authentication). A
+// token for which the user authenticated too long ago, or that has no 'auth_time' claim, is
+// rejected with an error that can be checked with IsAuthTimeTooOld(). The client app should then
+// prompt the user to sign in again, and send a new ID token.
+func (c *Client) VerifyIDTokenFreshAuth(ctx context.Context, idToken string, maxAge time.Duration) (*Token, error) {
+	if maxAge <= 0 {
+		return nil, errors.New("max age must be positive")
 	}
+	p, err := c.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		return nil, err
+	}
+	if p.AuthTime == 0 {
+		return nil, internal.Error(authTimeTooOld, "ID token has no 'auth_time' claim")
+	}
+	if age := c.clock.Now().Sub(time.Unix(p.AuthTime, 0)); age > maxAge {
+		return nil, internal.Errorf(authTimeTooOld,
+			"ID token has 'auth_time' claim %v ago, which exceeds the maximum of %v; the user must sign in again",
+			age-age%time.Second, maxAge)
+	}
+	return p, nil
+}
 
-	h := &jwtHeader{}
-	p := &Token{}
-	if err := decodeToken(ctx, idToken, c.ks, h, p); err != nil {
+func (c *Client) verifyIDToken(ctx context.Context, idToken string, projectIDs []string) (*Token, error) {
+	p, err := c.verifyToken(ctx, idToken, c.ks, idTokenInfo, projectIDs)
+	if err != nil {
 		return nil, err
 	}
+	if err := c.checkMaxTokenAge(p); err != nil {
+		return nil, err
+	}
+	if err := c.checkTenant(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// VerifyIDTokenAllowExpired performs the same checks as VerifyIDToken, except for the expiry of the
+// token, and reports whether the token has expired.
+//
+// An ID token that fails any check other than expiry is rejected with an error. Otherwise the
+// decoded Token is returned, along with true if the token has expired, or exceeds the maximum age
+// set with WithMaxTokenAge(). This helps long-lived connections decide between prompting the
+// client for a fresh ID token, and rejecting the client altogether. Expired tokens must not be
+// used to authorize requests.
+func (c *Client) VerifyIDTokenAllowExpired(ctx context.Context, idToken string) (*Token, bool, error) {
+	projectIDs := append([]string{c.projectID}, c.acceptedProjectIDs...)
+	p, err := c.verifyTokenIgnoringExpiry(ctx, idToken, c.ks, idTokenInfo, projectIDs)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := c.checkTenant(p); err != nil {
+		return nil, false, err
+	}
+	expired := c.checkExpiry(p, idTokenInfo) != nil || c.checkMaxTokenAge(p) != nil
+	return p, expired, nil
+}
 
-	projectIDMsg := "make sure the ID token comes from the same Firebase project as the credential used to" +
-		" authenticate this SDK"
-	verifyTokenMsg := "see https://firebase.google.com/docs/auth/admin/verify-id-tokens for details on how to " +
-		"retrieve a valid ID token"
-	issuer := issuerPrefix + c.projectID
+// ValidationError describes a check failed by an ID token with a valid signature, as reported by
+// DecodeVerifiedSignature().
+//
+// Claim is the name of the claim or header parameter that failed the check (e.g. "exp" or "aud").
+// Err is the error that VerifyIDToken() would report for the failure, and can be checked with
+// functions like IsTokenExpired() and IsInvalidAudience().
+type ValidationError struct {
+	Claim string
+	Err   error
+}
 
-	var err error
-	if h.KeyID == "" {
-		if p.Audience == firebaseAudience {
-			err = fmt.Errorf("expected an ID token but got a custom token")
-		} else {
-			err = fmt.Errorf("ID token has no 'kid' header")
-		}
-	} else if h.Algorithm != "RS256" {
-		err = fmt.Errorf("ID token has invalid algorithm; expected 'RS256' but got %q; %s",
-			h.Algorithm, verifyTokenMsg)
-	} else if p.Audience != c.projectID {
-		err = fmt.Errorf("ID token has invalid 'aud' (audience) claim; expected %q but got %q; %s; %s",
-			c.projectID, p.Audience, projectIDMsg, verifyTokenMsg)
-	} else if p.Issuer != issuer {
-		err = fmt.Errorf("ID token has invalid 'iss' (issuer) claim; expected %q but got %q; %s; %s",
-			issuer, p.Issuer, projectIDMsg, verifyTokenMsg)
-	} else if p.IssuedAt > clk.Now().Unix() {
-		err = fmt.Errorf("ID token issued at future timestamp: %d", p.IssuedAt)
-	} else if p.Expires < clk.Now().Unix() {
-		err = fmt.Errorf("ID token has expired at: %d", p.Expires)
-	} else if p.Subject == "" {
-		err = fmt.Errorf("ID token has empty 'sub' (subject) claim; %s", verifyTokenMsg)
-	} else if len(p.Subject) > 128 {
-		err = fmt.Errorf("ID token has a 'sub' (subject) claim longer than 128 characters; %s", verifyTokenMsg)
+func (e ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+// DecodeVerifiedSignature decodes the provided ID token, and verifies its signature, without
+// rejecting the token if any of the other checks performed by VerifyIDToken() fails.
+//
+// An error is returned only if the token is malformed, is a custom token, or its signature cannot
+// be verified with the public keys of Google (which can be checked with IsInvalidSignature()).
+// Otherwise the decoded Token is returned, along with the checks it failed, such as expiry,
+// audience or issuer. This is intended for diagnostic tools that display the claims of a token.
+// A token with one or more ValidationErrors must not be used to authorize requests.
+func (c *Client) DecodeVerifiedSignature(ctx context.Context, idToken string) (*Token, []ValidationError, error) {
+	projectIDs := append([]string{c.projectID}, c.acceptedProjectIDs...)
+	p, h, err := c.decodeVerifiedToken(ctx, idToken, c.ks, idTokenInfo, projectIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	errs := c.validateClaims(p, h, idTokenInfo, projectIDs)
+	if err := c.checkExpiry(p, idTokenInfo); err != nil {
+		errs = append(errs, ValidationError{Claim: "exp", Err: err})
+	}
+	if err := c.checkMaxTokenAge(p); err != nil {
+		errs = append(errs, ValidationError{Claim: "iat", Err: err})
 	}
+	if err := c.checkTenant(p); err != nil {
+		errs = append(errs, ValidationError{Claim: "firebase", Err: err})
+	}
+	return p, errs, nil
+}
 
+// checkMaxTokenAge checks whether the given verified ID token exceeds the maximum token age of the
+// Client, if one is set.
+func (c *Client) checkMaxTokenAge(p *Token) error {
+	if c.maxTokenAge > 0 {
+		if age := c.clock.Now().Unix() - p.IssuedAt; age > int64(c.maxTokenAge/time.Second) {
+			return internal.Errorf(tokenExpired,
+				"ID token issued at %d exceeds the maximum token age of %v", p.IssuedAt, c.maxTokenAge)
+		}
+	}
+	return nil
+}
+
+// checkTenant checks whether the given verified ID token belongs to the tenant of the Client, if
+// the Client is scoped to a tenant.
+func (c *Client) checkTenant(p *Token) error {
+	if c.tenantID != "" {
+		if tenant := p.Firebase().Tenant; tenant != c.tenantID {
+			return internal.Errorf(tenantIDMismatch,
+				"ID token has invalid tenant; expected %q but got %q", c.tenantID, tenant)
+		}
+	}
+	return nil
+}
+
+// VerifyRequest extracts the ID token from the Authorization header of the given HTTP request, and
+// verifies it using VerifyIDToken().
+//
+// The header must be of the form "Bearer <token>", where the scheme is matched case-insensitively.
+// If the header is missing or malformed, VerifyRequest returns an error that can be checked with
+// IsInvalidAuthorizationHeader().
+func (c *Client) VerifyRequest(ctx context.Context, r *http.Request) (*Token, error) {
+	idToken, err := bearerToken(r)
 	if err != nil {
 		return nil, err
 	}
-	p.UID = p.Subject
-	return p, nil
+	return c.VerifyIDToken(ctx, idToken)
+}
+
+func bearerToken(r *http.Request) (string, error) {
+	const scheme = "bearer "
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", internal.Error(invalidAuthHeader, "request does not have an authorization header")
+	}
+	if len(header) <= len(scheme) || !strings.EqualFold(header[:len(scheme)], scheme) {
+		return "", internal.Error(invalidAuthHeader, "authorization header must be of the form 'Bearer <token>'")
+	}
+	idToken := strings.TrimSpace(header[len(scheme):])
+	if idToken == "" {
+		return "", internal.Error(invalidAuthHeader, "authorization header must be of the form 'Bearer <token>'")
+	}
+	return idToken, nil
 }
 
 // VerifyIDTokenAndCheckRevoked verifies the provided ID token and checks it has not been revoked.
 //
 // VerifyIDTokenAndCheckRevoked verifies the signature and payload of the provided ID token and
 // checks that it wasn't revoked. Uses VerifyIDToken() internally to verify the ID token JWT.
+//
+// If the revocation check exceeds the timeout set with WithRevocationCheckTimeout(), the verified
+// Token is returned along with an error that can be checked with IsRevocationCheckTimeout(). See
+// WithRevocationCheckTimeout() for how such errors should be treated.
 func (c *Client) VerifyIDTokenAndCheckRevoked(ctx context.Context, idToken string) (*Token, error) {
 	p, err := c.VerifyIDToken(ctx, idToken)
 	if err != nil {
 		return nil, err
 	}
+	if err := c.checkRevoked(ctx, p, idTokenInfo); err != nil {
+		if IsRevocationCheckTimeout(err) {
+			return p, err
+		}
+		return nil, err
+	}
+	return p, nil
+}
 
-	user, err := c.GetUser(ctx, p.UID)
+// VerifyIDTokensAndCheckRevoked verifies a batch of ID tokens, and checks that none of them has
+// been revoked.
+//
+// Each token is verified locally using VerifyIDToken(). The revocation status of the tokens that
+// pass verification is then checked by looking up all the corresponding users at once, instead of
+// making a separate GetUser() call for each token. The returned slices are in the same order as
+// idTokens. For each index i, either tokens[i] contains the decoded token, or errs[i] contains the
+// reason why idTokens[i] was rejected.
+func (c *Client) VerifyIDTokensAndCheckRevoked(ctx context.Context, idTokens []string) ([]*Token, []error) {
+	tokens := make([]*Token, len(idTokens))
+	errs := make([]error, len(idTokens))
+	var uids []string
+	seen := make(map[string]bool)
+	for i, idToken := range idTokens {
+		tokens[i], errs[i] = c.VerifyIDToken(ctx, idToken)
+		if errs[i] == nil && !seen[tokens[i].UID] {
+			seen[tokens[i].UID] = true
+			uids = append(uids, tokens[i].UID)
+		}
+	}
+	if len(uids) == 0 {
+		return tokens, errs
+	}
+
+	validAfter, err := c.tokensValidAfterMillis(ctx, uids)
+	for i, p := range tokens {
+		if p == nil {
+			continue
+		}
+		millis, ok := validAfter[p.UID]
+		if err != nil {
+			errs[i] = err
+		} else if !ok {
+			errs[i] = internal.Errorf(userNotFound, "cannot find user from uid: %q", p.UID)
+		} else {
+			errs[i] = checkValidAfter(p, millis, idTokenInfo)
+		}
+		if errs[i] != nil {
+			tokens[i] = nil
+		}
+	}
+	return tokens, errs
+}
+
+// CheckRevokedWithValidAfter checks whether the given ID token has been revoked, using a
+// TokensValidAfterMillis value already known to the caller.
+//
+// The token must have been verified with VerifyIDToken() beforehand. validAfterMillis is typically
+// the TokensValidAfterMillis of the user to whom the token belongs, as previously obtained from
+// GetUser() and cached by the caller. This performs the same check as
+// VerifyIDTokenAndCheckRevoked(), without looking up the user. If the token was issued before
+// validAfterMillis, CheckRevokedWithValidAfter returns an error that can be checked with
+// IsIDTokenRevoked().
+func CheckRevokedWithValidAfter(token *Token, validAfterMillis int64) error {
+	if token == nil {
+		return errors.New("token must not be nil")
+	}
+	return checkValidAfter(token, validAfterMillis, idTokenInfo)
+}
+
+// SessionCookie creates a new Firebase session cookie from the given ID token and expiry
+// duration.
+//
+// The returned JWT can be set as a server-side session cookie with a custom cookie policy.
+// Expiry duration must be at least 5 minutes but may not exceed 14 days. See
+// https://firebase.google.com/docs/auth/admin/manage-cookies for more details on how to use
+// session cookies.
+func (c *Client) SessionCookie(ctx context.Context, idToken string, expiresIn time.Duration) (string, error) {
+	if idToken == "" {
+		return "", errors.New("id token must not be empty")
+	}
+	if expiresIn < 5*time.Minute || expiresIn > 14*24*time.Hour {
+		return "", errors.New("expiry duration must be between 5 minutes and 14 days")
+	}
+
+	payload := map[string]interface{}{
+		"idToken":       idToken,
+		"validDuration": int64(expiresIn.Seconds()),
+	}
+	var resp struct {
+		SessionCookie string `json:"sessionCookie"`
+	}
+	if err := c.makeHTTPCall(ctx, http.MethodPost, ":createSessionCookie", payload, &resp); err != nil {
+		return "", err
+	}
+	return resp.SessionCookie, nil
+}
+
+// VerifySessionCookie verifies the signature and payload of the provided Firebase session cookie.
+//
+// VerifySessionCookie accepts a session cookie string created by SessionCookie(), and verifies
+// that it is current, issued for the correct Firebase project, and signed by the Google Firebase
+// services in the cloud. It returns a Token containing the decoded claims in the input JWT.
+// This does not check whether or not the cookie has been revoked. See
+// `VerifySessionCookieAndCheckRevoked` below.
+func (c *Client) VerifySessionCookie(ctx context.Context, sessionCookie string) (*Token, error) {
+	return c.verifyToken(ctx, sessionCookie, c.cookieKS, sessionCookieInfo, []string{c.projectID})
+}
+
+// VerifySessionCookieAndCheckRevoked verifies the provided session cookie, and additionally checks
+// that the cookie has not been revoked.
+//
+// Verifies the signature and payload of the session cookie using VerifySessionCookie(), and then
+// checks that the cookie was issued after the user's TokensValidAfterMillis. Revocation check
+// timeouts are reported the same way as in VerifyIDTokenAndCheckRevoked().
+func (c *Client) VerifySessionCookieAndCheckRevoked(ctx context.Context, sessionCookie string) (*Token, error) {
+	p, err := c.VerifySessionCookie(ctx, sessionCookie)
 	if err != nil {
 		return nil, err
 	}
+	if err := c.checkRevoked(ctx, p, sessionCookieInfo); err != nil {
+		if IsRevocationCheckTimeout(err) {
+			return p, err
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// tokenInfo describes a type of JWT issued by Firebase, that can be verified by the Client.
+type tokenInfo struct {
+	shortName         string
+	articledShortName string
+	docURL            string
+	issuerPrefix      string
+	revokedCode       string
+}
+
+var (
+	idTokenInfo = &tokenInfo{
+		shortName:         "ID token",
+		articledShortName: "an ID token",
+		docURL:            "https://firebase.google.com/docs/auth/admin/verify-id-tokens",
+		issuerPrefix:      issuerPrefix,
+		revokedCode:       idTokenRevoked,
+	}
 
-	if p.IssuedAt*1000 < user.TokensValidAfterMillis {
-		return nil, internal.Error(idTokenRevoked, "ID token has been revoked")
+	sessionCookieInfo = &tokenInfo{
+		shortName:         "session cookie",
+		articledShortName: "a session cookie",
+		docURL:            "https://firebase.google.com/docs/auth/admin/manage-cookies",
+		issuerPrefix:      sessionCookieIssuerPrefix,
+		revokedCode:       sessionCookieRevoked,
+	}
+)
+
+// verifyToken verifies the given token, and checks that it was issued for one of the specified
+// project IDs. The first project ID is the expected one, and is the one reported in errors when the
+// token was issued for none of them.
+func (c *Client) verifyToken(ctx context.Context, token string, ks KeySource, info *tokenInfo, projectIDs []string) (*Token, error) {
+	p, err := c.verifyTokenIgnoringExpiry(ctx, token, ks, info, projectIDs)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkExpiry(p, info); err != nil {
+		return nil, err
 	}
 	return p, nil
 }
+
+// verifyTokenIgnoringExpiry performs all the checks of verifyToken, except for checking whether
+// the token has expired.
+func (c *Client) verifyTokenIgnoringExpiry(
+	ctx context.Context, token string, ks KeySource, info *tokenInfo, projectIDs []string) (*Token, error) {
+	p, h, err := c.decodeVerifiedToken(ctx, token, ks, info, projectIDs)
+	if err != nil {
+		return nil, err
+	}
+	if errs := c.validateClaims(p, h, info, projectIDs); len(errs) > 0 {
+		return nil, errs[0].Err
+	}
+	return p, nil
+}
+
+// decodeVerifiedToken decodes the given JWT, and verifies its signature, unless the Client is
+// connected to the Auth emulator. The claims of the token are not validated.
+func (c *Client) decodeVerifiedToken(
+	ctx context.Context, token string, ks KeySource, info *tokenInfo, projectIDs []string) (*Token, *jwtHeader, error) {
+	if len(projectIDs) == 0 || projectIDs[0] == "" {
+		return nil, nil, errors.New("project id not available")
+	}
+	if token == "" {
+		return nil, nil, fmt.Errorf("%s must be a non-empty string", info.shortName)
+	}
+	if strings.Count(token, ".") != 2 {
+		return nil, nil, internal.Errorf(malformedToken, "%s must be a valid JWT with three segments", info.shortName)
+	}
+
+	h := &jwtHeader{}
+	p := &Token{}
+	s, err := decodeUnverifiedToken(token, h, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Custom tokens are signed by a service account, and not by Google. Detect them before
+	// verifying the signature, so that they are not reported as having an invalid signature.
+	if p.Audience == firebaseAudience && !c.noCustomTokenCheck {
+		return nil, nil, internal.Errorf(customTokenUsed, "expected %s but got a custom token", info.articledShortName)
+	}
+	// Tokens issued by the emulator are not signed.
+	if c.emulatorHost == "" {
+		if h.KeyID == "" && c.noCustomTokenCheck {
+			return nil, nil, internal.Errorf(invalidToken, "%s has no 'kid' header", info.shortName)
+		}
+		if err := verifyTokenSignature(ctx, s, ks, h); err != nil {
+			return nil, nil, err
+		}
+	}
+	p.UID = p.Subject
+	p.KeyID = h.KeyID
+	p.Algorithm = h.Algorithm
+	p.clock = c.clock
+	return p, h, nil
+}
+
+// validateClaims checks the header and the claims of a decoded token, except for its expiry.
+// Returns all the checks that failed, in the order in which they are performed.
+func (c *Client) validateClaims(p *Token, h *jwtHeader, info *tokenInfo, projectIDs []string) []ValidationError {
+	projectIDMsg := fmt.Sprintf("make sure the %s comes from the same Firebase project as the credential "+
+		"used to authenticate this SDK", info.shortName)
+	verifyTokenMsg := fmt.Sprintf("see %s for details on how to retrieve a valid %s",
+		info.docURL, info.shortName)
+	projectID := projectIDs[0]
+	for _, id := range projectIDs {
+		if p.Audience == id {
+			projectID = id
+			break
+		}
+	}
+	expected := fmt.Sprintf("%q", projectID)
+	if len(projectIDs) > 1 && p.Audience != projectID {
+		expected = fmt.Sprintf("one of %q", projectIDs)
+	}
+	issuer := info.issuerPrefix + projectID
+
+	now := c.clock.Now().Unix()
+	leeway := int64((c.clockSkew + c.issuedAtLeeway) / time.Second)
+	emulated := c.emulatorHost != ""
+	var errs []ValidationError
+	add := func(claim string, err error) {
+		errs = append(errs, ValidationError{Claim: claim, Err: err})
+	}
+	if h.KeyID == "" && !emulated {
+		add("kid", internal.Errorf(invalidToken, "%s has no 'kid' header", info.shortName))
+	}
+	if h.Algorithm != "RS256" && !emulated {
+		add("alg", internal.Errorf(invalidToken, "%s has invalid algorithm; expected 'RS256' but got %q; %s",
+			info.shortName, h.Algorithm, verifyTokenMsg))
+	}
+	if p.Audience != projectID {
+		add("aud", internal.Errorf(invalidAudience,
+			"%s has invalid 'aud' (audience) claim; expected %s but got %q; %s; %s",
+			info.shortName, expected, p.Audience, projectIDMsg, verifyTokenMsg))
+	}
+	if p.Issuer != issuer {
+		add("iss", internal.Errorf(invalidIssuer,
+			"%s has invalid 'iss' (issuer) claim; expected %q but got %q; %s; %s",
+			info.shortName, issuer, p.Issuer, projectIDMsg, verifyTokenMsg))
+	}
+	if p.IssuedAt > now+leeway {
+		add("iat", internal.Errorf(tokenUsedTooEarly, "%s issued at future timestamp: %d", info.shortName, p.IssuedAt))
+	}
+	if p.Subject == "" {
+		add("sub", internal.Errorf(invalidToken, "%s has empty 'sub' (subject) claim; %s", info.shortName, verifyTokenMsg))
+	} else if len(p.Subject) > 128 {
+		add("sub", internal.Errorf(invalidToken, "%s has a 'sub' (subject) claim longer than 128 characters; %s",
+			info.shortName, verifyTokenMsg))
+	}
+	return errs
+}
+
+// checkExpiry checks whether the given verified token has expired, allowing for the configured
+// clock skew.
+func (c *Client) checkExpiry(p *Token, info *tokenInfo) error {
+	if p.Expires < c.clock.Now().Unix()-int64(c.clockSkew/time.Second) {
+		return internal.Errorf(tokenExpired, "%s has expired at: %d", info.shortName, p.Expires)
+	}
+	return nil
+}
+
+// maxLookupBatchSize is the maximum number of users that can be looked up in a single
+// getAccountInfo call.
+const maxLookupBatchSize = 100
+
+// tokensValidAfterMillis looks up the given users, and returns the TokensValidAfterMillis of
+// each user that exists, keyed by user ID. Users are looked up in batches of up to
+// maxLookupBatchSize.
+func (c *Client) tokensValidAfterMillis(ctx context.Context, uids []string) (map[string]int64, error) {
+	result := make(map[string]int64)
+	for start := 0; start < len(uids); start += maxLookupBatchSize {
+		end := start + maxLookupBatchSize
+		if end > len(uids) {
+			end = len(uids)
+		}
+		request := &identitytoolkit.IdentitytoolkitRelyingpartyGetAccountInfoRequest{
+			LocalId: uids[start:end],
+		}
+		resp, err := c.getAccountInfo(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range resp.Users {
+			result[u.LocalId] = u.ValidSince * 1000
+		}
+	}
+	return result, nil
+}
+
+// checkRevoked checks whether the given verified token was issued before the tokens of the
+// corresponding user were last revoked.
+func (c *Client) checkRevoked(ctx context.Context, p *Token, info *tokenInfo) error {
+	lookupCtx := ctx
+	if c.revocationTimeout > 0 {
+		var cancel context.CancelFunc
+		lookupCtx, cancel = context.WithTimeout(ctx, c.revocationTimeout)
+		defer cancel()
+	}
+	user, err := c.GetUser(lookupCtx, p.UID)
+	if err != nil {
+		// Only report a timeout when the derived deadline expired, and not the deadline of ctx.
+		if ctx.Err() == nil && lookupCtx.Err() == context.DeadlineExceeded {
+			return internal.Errorf(revocationCheckTimeout,
+				"failed to check whether the %s has been revoked within %v: %v",
+				info.shortName, c.revocationTimeout, err)
+		}
+		return err
+	}
+	return checkValidAfter(p, user.TokensValidAfterMillis, info)
+}
+
+// checkValidAfter checks whether the given verified token was issued before validAfterMillis.
+//
+// The error message includes both timestamps, so that revocations caused by clock skew between the
+// servers that minted and revoked the tokens are easy to diagnose.
+func checkValidAfter(p *Token, validAfterMillis int64, info *tokenInfo) error {
+	if p.IssuedAt*1000 < validAfterMillis {
+		return internal.Errorf(info.revokedCode,
+			"%s has been revoked; issued at %d (seconds since epoch), but tokens are only valid after %d "+
+				"(milliseconds since epoch)", info.shortName, p.IssuedAt, validAfterMillis)
+	}
+	return nil
+}
@@ -17,23 +17,52 @@
 package auth
 
 import (
+	"sync"
+
 	"golang.org/x/net/context"
 
 	"google.golang.org/appengine"
 )
 
+// aeSigner signs custom tokens with the service account of the App Engine app, by making remote
+// calls to the App Engine app identity service.
 type aeSigner struct {
+	mu    sync.Mutex
+	email string
 }
 
 func newSigner(ctx context.Context) (signer, error) {
-	return aeSigner{}, nil
+	return &aeSigner{}, nil
+}
+
+func (s *aeSigner) Algorithm() string {
+	return "RS256"
+}
+
+// Email returns the service account email of the App Engine app. The email does not change during
+// the lifetime of the app, and hence it is only resolved on the first successful call, and cached
+// for subsequent calls.
+func (s *aeSigner) Email(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.email == "" {
+		email, err := appengine.ServiceAccount(ctx)
+		if err != nil {
+			return "", err
+		}
+		s.email = email
+	}
+	return s.email, nil
 }
 
-func (s aeSigner) Email(ctx context.Context) (string, error) {
-	return appengine.ServiceAccount(ctx)
+// KeyID returns an empty string. The App Engine app identity service reports the name of the key
+// used for signing only along with the signature, which is too late to include it in the header
+// of the token.
+func (s *aeSigner) KeyID() string {
+	return ""
 }
 
-func (s aeSigner) Sign(ctx context.Context, ss []byte) ([]byte, error) {
+func (s *aeSigner) Sign(ctx context.Context, ss []byte) ([]byte, error) {
 	_, sig, err := appengine.SignBytes(ctx, ss)
 	return sig, err
 }
@@ -15,13 +15,25 @@
 package auth
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/big"
+	"net/http"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -29,6 +41,7 @@ import (
 
 	"golang.org/x/oauth2/google"
 
+	"google.golang.org/api/identitytoolkit/v3"
 	"google.golang.org/api/option"
 	"google.golang.org/api/transport"
 	"google.golang.org/appengine"
@@ -52,7 +65,7 @@ var defaultTestOpts = []option.ClientOption{
 func TestMain(m *testing.M) {
 	var (
 		err   error
-		ks    keySource
+		ks    KeySource
 		creds *google.DefaultCredentials
 		opts  []option.ClientOption
 	)
@@ -87,6 +100,7 @@ func TestMain(m *testing.M) {
 		log.Fatalln(err)
 	}
 	client.ks = ks
+	client.cookieKS = ks
 
 	testGetUserResponse, err = ioutil.ReadFile("../testdata/get_user.json")
 	if err != nil {
@@ -177,6 +191,278 @@ func TestCustomTokenError(t *testing.T) {
 	}
 }
 
+func TestCustomTokenReservedClaims(t *testing.T) {
+	values := []interface{}{
+		"value",
+		1234,
+		true,
+		nil,
+		map[string]interface{}{"key": "value"},
+	}
+	for _, k := range reservedClaims {
+		for _, v := range values {
+			claims := map[string]interface{}{k: v}
+			token, err := client.CustomTokenWithClaims(ctx, "user1", claims)
+			want := fmt.Sprintf("developer claim %q is reserved and cannot be specified", k)
+			if token != "" || err == nil || err.Error() != want {
+				t.Errorf("CustomTokenWithClaims(%q: %v) = (%q, %v); want = (\"\", %q)", k, v, token, err, want)
+			}
+		}
+	}
+}
+
+func TestCustomTokenNonReservedClaims(t *testing.T) {
+	claims := map[string]interface{}{
+		"Firebase": "value",
+		"SUB":      "value",
+		"nested": map[string]interface{}{
+			"firebase": "value",
+			"sub":      "value",
+		},
+	}
+	token, err := client.CustomTokenWithClaims(ctx, "user1", claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyCustomToken(ctx, token, map[string]interface{}{"Firebase": "value", "SUB": "value"}, t)
+}
+
+func TestCustomTokenWithClaimsAndExpiry(t *testing.T) {
+	claims := map[string]interface{}{"premium": true}
+	expiry := 5 * time.Minute
+	token, err := client.CustomTokenWithClaimsAndExpiry(ctx, "user1", claims, expiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyCustomToken(ctx, token, claims, t)
+
+	h := &jwtHeader{}
+	p := &customToken{}
+	if err := decodeToken(ctx, token, client.ks, h, p); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Exp - p.Iat; got != 300 {
+		t.Errorf("Exp - Iat = %d; want = 300", got)
+	}
+}
+
+func TestCustomTokenWithClaimsAndExpiryError(t *testing.T) {
+	cases := []time.Duration{
+		0,
+		-time.Minute,
+		59 * time.Second,
+		time.Hour + time.Second,
+		2 * time.Hour,
+	}
+	for _, tc := range cases {
+		token, err := client.CustomTokenWithClaimsAndExpiry(ctx, "user1", nil, tc)
+		if token != "" || err == nil {
+			t.Errorf("CustomTokenWithClaimsAndExpiry(%v) = (%q, %v); want = (\"\", error)", tc, token, err)
+		}
+	}
+}
+
+func TestCustomTokenInvalidClaims(t *testing.T) {
+	cases := []struct {
+		name   string
+		claims map[string]interface{}
+		want   string
+	}{
+		{"Channel", map[string]interface{}{"ch": make(chan int)}, "custom claims are not JSON serializable: "},
+		{"Func", map[string]interface{}{"f": func() {}}, "custom claims are not JSON serializable: "},
+		{"TooLarge", map[string]interface{}{"a": strings.Repeat("a", 1000)},
+			"serialized custom claims must not exceed 1000 characters; got 1008"},
+	}
+	for _, tc := range cases {
+		token, err := client.CustomTokenWithClaims(ctx, "user1", tc.claims)
+		if token != "" || err == nil || !strings.HasPrefix(err.Error(), tc.want) {
+			t.Errorf("CustomTokenWithClaims(%s) = (%q, %v); want = (\"\", %q)", tc.name, token, err, tc.want)
+		}
+	}
+
+	claims := map[string]interface{}{"a": strings.Repeat("a", 992)}
+	if _, err := client.CustomTokenWithClaims(ctx, "user1", claims); err != nil {
+		t.Errorf("CustomTokenWithClaims(1000 characters) = %v; want = nil", err)
+	}
+}
+
+func TestCustomTokenWithExpiry(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	c := *client
+	c.clock = &mockClock{now: now}
+	token, expiry, err := c.CustomTokenWithExpiry(ctx, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(time.Hour); !expiry.Equal(want) {
+		t.Errorf("CustomTokenWithExpiry() expiry = %v; want = %v", expiry, want)
+	}
+
+	p := &customToken{}
+	if err := decodeToken(ctx, token, client.ks, &jwtHeader{}, p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Exp != expiry.Unix() || p.UID != "user1" {
+		t.Errorf("CustomTokenWithExpiry() (exp, uid) = (%d, %q); want = (%d, %q)", p.Exp, p.UID, expiry.Unix(), "user1")
+	}
+}
+
+func TestCustomTokenWithExpiryError(t *testing.T) {
+	token, expiry, err := client.CustomTokenWithExpiry(ctx, "")
+	if token != "" || !expiry.IsZero() || err == nil {
+		t.Errorf("CustomTokenWithExpiry('') = (%q, %v, %v); want = (\"\", zero, error)", token, expiry, err)
+	}
+}
+
+func TestCustomTokens(t *testing.T) {
+	for _, concurrency := range []int{0, 1, 3} {
+		snr := &countingSigner{signer: client.snr}
+		c := *client
+		c.snr = snr
+		c.signingConcurrency = concurrency
+
+		uids := []string{"uid0", "uid1", "uid2", "uid3", "uid4", "uid5", "uid6"}
+		tokens, err := c.CustomTokens(ctx, uids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tokens) != len(uids) {
+			t.Fatalf("CustomTokens(%d) = %d tokens; want = %d", concurrency, len(tokens), len(uids))
+		}
+		for i, token := range tokens {
+			verifyCustomToken(ctx, token, nil, t)
+			p := &customToken{}
+			if err := decodeToken(ctx, token, client.ks, &jwtHeader{}, p); err != nil {
+				t.Fatal(err)
+			}
+			if p.UID != uids[i] {
+				t.Errorf("CustomTokens(%d)[%d] uid = %q; want = %q", concurrency, i, p.UID, uids[i])
+			}
+		}
+
+		want := concurrency
+		if want < 1 {
+			want = 1
+		}
+		if snr.emails != 1 {
+			t.Errorf("CustomTokens(%d) Email() calls = %d; want = 1", concurrency, snr.emails)
+		}
+		if snr.maxActive > want {
+			t.Errorf("CustomTokens(%d) concurrent Sign() calls = %d; want <= %d", concurrency, snr.maxActive, want)
+		}
+	}
+}
+
+func TestCustomTokensError(t *testing.T) {
+	tokens, err := client.CustomTokens(ctx, []string{"uid1", "", "uid2"})
+	if tokens != nil || err == nil {
+		t.Errorf("CustomTokens() = (%v, %v); want = (nil, error)", tokens, err)
+	}
+
+	c := *client
+	c.snr = &serviceAcctSigner{}
+	tokens, err = c.CustomTokens(ctx, []string{"uid1"})
+	if tokens != nil || err == nil {
+		t.Errorf("CustomTokens() = (%v, %v); want = (nil, error)", tokens, err)
+	}
+}
+
+// countingSigner is a signer that records the number of calls made to Email(), and the maximum
+// number of concurrent calls made to Sign().
+type countingSigner struct {
+	signer
+	mu        sync.Mutex
+	emails    int
+	active    int
+	maxActive int
+}
+
+func (s *countingSigner) Email(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	s.emails++
+	s.mu.Unlock()
+	return s.signer.Email(ctx)
+}
+
+func (s *countingSigner) Sign(ctx context.Context, b []byte) ([]byte, error) {
+	s.mu.Lock()
+	s.active++
+	if s.active > s.maxActive {
+		s.maxActive = s.active
+	}
+	s.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+	defer func() {
+		s.mu.Lock()
+		s.active--
+		s.mu.Unlock()
+	}()
+	return s.signer.Sign(ctx, b)
+}
+
+func TestCustomTokenES256(t *testing.T) {
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(map[string]interface{}{
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})),
+		"client_email": "ec-account@test.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &internal.AuthConfig{
+		Creds: &google.DefaultCredentials{JSON: b},
+		Opts:  defaultTestOpts,
+	}
+	c, err := NewClient(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := c.CustomToken(ctx, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("CustomToken() = %d segments; want = 3", len(parts))
+	}
+	var h jwtHeader
+	if err := decode(parts[0], &h); err != nil {
+		t.Fatal(err)
+	}
+	if h.Algorithm != "ES256" {
+		t.Errorf("Algorithm: %q; want: 'ES256'", h.Algorithm)
+	}
+	var p customToken
+	if err := p.decodeFrom(parts[1]); err != nil {
+		t.Fatal(err)
+	}
+	if p.Iss != "ec-account@test.com" || p.UID != "user1" {
+		t.Errorf("CustomToken() = (%q, %q); want = (%q, %q)", p.Iss, p.UID, "ec-account@test.com", "user1")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sig) != 64 {
+		t.Fatalf("len(signature) = %d; want = 64", len(sig))
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(&pk.PublicKey, digest[:], r, s) {
+		t.Errorf("ecdsa.Verify() = false; want = true")
+	}
+}
+
 func TestCustomTokenInvalidCredential(t *testing.T) {
 	// AuthConfig with nil Creds
 	conf := &internal.AuthConfig{Opts: defaultTestOpts}
@@ -204,54 +490,656 @@ func TestVerifyIDTokenAndCheckRevokedValid(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if ft.Claims["admin"] != true {
-		t.Errorf("Claims['admin'] = %v; want = true", ft.Claims["admin"])
+	if ft.Claims["admin"] != true {
+		t.Errorf("Claims['admin'] = %v; want = true", ft.Claims["admin"])
+	}
+	if ft.UID != ft.Subject {
+		t.Errorf("UID = %q; Sub = %q; want UID = Sub", ft.UID, ft.Subject)
+	}
+}
+
+func TestVerifyIDTokenAndCheckRevokedDoNotCheck(t *testing.T) {
+	s := echoServer(testGetUserResponse, t)
+	defer s.Close()
+	tok := getIDToken(mockIDTokenPayload{"uid": "uid", "iat": 1970}) // old token
+
+	ft, err := s.Client.VerifyIDToken(ctx, tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ft.Claims["admin"] != true {
+		t.Errorf("Claims['admin'] = %v; want = true", ft.Claims["admin"])
+	}
+	if ft.UID != ft.Subject {
+		t.Errorf("UID = %q; Sub = %q; want UID = Sub", ft.UID, ft.Subject)
+	}
+}
+
+func TestVerifyIDTokenAndCheckRevokedInvalidated(t *testing.T) {
+	s := echoServer(testGetUserResponse, t)
+	defer s.Close()
+	tok := getIDToken(mockIDTokenPayload{"uid": "uid", "iat": 1970}) // old token
+
+	p, err := s.Client.VerifyIDTokenAndCheckRevoked(ctx, tok)
+	we := "ID token has been revoked; issued at 1970 (seconds since epoch), but tokens are only valid " +
+		"after 1494364393000 (milliseconds since epoch)"
+	if p != nil || err == nil || err.Error() != we || !IsIDTokenRevoked(err) {
+		t.Errorf("VerifyIDTokenAndCheckRevoked(ctx, token) =(%v, %v); want = (%v, %v)",
+			p, err, nil, we)
+	}
+}
+
+// slowIdentitytoolkit is a fake identitytoolkitService whose user lookups block until the context
+// of the call is done.
+type slowIdentitytoolkit struct {
+	identitytoolkitService
+}
+
+func (s *slowIdentitytoolkit) getAccountInfo(
+	ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartyGetAccountInfoRequest) (*identitytoolkit.GetAccountInfoResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestVerifyIDTokenAndCheckRevokedTimeout(t *testing.T) {
+	c := *client
+	c.is = &slowIdentitytoolkit{}
+	WithRevocationCheckTimeout(10 * time.Millisecond)(&c)
+
+	p, err := c.VerifyIDTokenAndCheckRevoked(ctx, testIDToken)
+	if p == nil || err == nil || !IsRevocationCheckTimeout(err) {
+		t.Fatalf("VerifyIDTokenAndCheckRevoked() = (%v, %v); want = (token, revocation-check-timeout)", p, err)
+	}
+	if p.UID != "1234567890" {
+		t.Errorf("UID = %q; want = %q", p.UID, "1234567890")
+	}
+	if IsIDTokenRevoked(err) {
+		t.Errorf("IsIDTokenRevoked(%v) = true; want = false", err)
+	}
+}
+
+func TestVerifyIDTokenAndCheckRevokedContextDeadline(t *testing.T) {
+	c := *client
+	c.is = &slowIdentitytoolkit{}
+	WithRevocationCheckTimeout(time.Hour)(&c)
+
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	p, err := c.VerifyIDTokenAndCheckRevoked(cctx, testIDToken)
+	if p != nil || err == nil || IsRevocationCheckTimeout(err) {
+		t.Errorf("VerifyIDTokenAndCheckRevoked() = (%v, %v); want = (nil, context error)", p, err)
+	}
+}
+
+func TestCheckRevokedWithValidAfter(t *testing.T) {
+	ft, err := client.VerifyIDToken(ctx, testIDToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iat := ft.IssuedAt * 1000
+	if err := CheckRevokedWithValidAfter(ft, iat); err != nil {
+		t.Errorf("CheckRevokedWithValidAfter(iat) = %v; want = nil", err)
+	}
+	if err := CheckRevokedWithValidAfter(ft, 0); err != nil {
+		t.Errorf("CheckRevokedWithValidAfter(0) = %v; want = nil", err)
+	}
+	err = CheckRevokedWithValidAfter(ft, iat+1000)
+	if we := "ID token has been revoked"; err == nil || !strings.HasPrefix(err.Error(), we) || !IsIDTokenRevoked(err) {
+		t.Errorf("CheckRevokedWithValidAfter(iat + 1s) = %v; want = %q", err, we)
+	}
+	if err := CheckRevokedWithValidAfter(nil, 0); err == nil {
+		t.Errorf("CheckRevokedWithValidAfter(nil) = nil; want = error")
+	}
+}
+
+func TestVerifyIDTokensAndCheckRevoked(t *testing.T) {
+	resp := map[string]interface{}{
+		"kind": "identitytoolkit#GetAccountInfoResponse",
+		"users": []map[string]interface{}{
+			{"localId": "uid1", "validSince": "1494364393"},
+			{"localId": "uid2", "validSince": "1494364393"},
+		},
+	}
+	s := echoServer(resp, t)
+	defer s.Close()
+
+	idTokens := []string{
+		getIDToken(mockIDTokenPayload{"sub": "uid1"}),
+		getIDToken(mockIDTokenPayload{"sub": "uid2", "iat": 1970}), // old token
+		"",
+		getIDToken(mockIDTokenPayload{"sub": "uid3"}),
+		getIDToken(mockIDTokenPayload{"sub": "uid1"}),
+	}
+	tokens, errs := s.Client.VerifyIDTokensAndCheckRevoked(ctx, idTokens)
+	if len(tokens) != len(idTokens) || len(errs) != len(idTokens) {
+		t.Fatalf("VerifyIDTokensAndCheckRevoked() = (%d, %d); want = (%d, %d)",
+			len(tokens), len(errs), len(idTokens), len(idTokens))
+	}
+	for _, i := range []int{0, 4} {
+		if errs[i] != nil || tokens[i] == nil || tokens[i].UID != "uid1" {
+			t.Errorf("VerifyIDTokensAndCheckRevoked()[%d] = (%v, %v); want = (uid1, nil)", i, tokens[i], errs[i])
+		}
+	}
+	if tokens[1] != nil || !IsIDTokenRevoked(errs[1]) {
+		t.Errorf("VerifyIDTokensAndCheckRevoked()[1] = (%v, %v); want = (nil, revoked)", tokens[1], errs[1])
+	}
+	if tokens[2] != nil || errs[2] == nil {
+		t.Errorf("VerifyIDTokensAndCheckRevoked()[2] = (%v, %v); want = (nil, error)", tokens[2], errs[2])
+	}
+	if tokens[3] != nil || !IsUserNotFound(errs[3]) {
+		t.Errorf("VerifyIDTokensAndCheckRevoked()[3] = (%v, %v); want = (nil, user-not-found)", tokens[3], errs[3])
+	}
+
+	if len(s.Req) != 1 {
+		t.Fatalf("Requests = %d; want = 1", len(s.Req))
+	}
+	var req struct {
+		LocalID []string `json:"localId"`
+	}
+	if err := json.Unmarshal(s.Rbody, &req); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"uid1", "uid2", "uid3"}
+	if !reflect.DeepEqual(req.LocalID, want) {
+		t.Errorf("getAccountInfo() localId = %v; want = %v", req.LocalID, want)
+	}
+}
+
+func TestVerifyIDTokensAndCheckRevokedLookupError(t *testing.T) {
+	s := echoServer([]byte("{}"), t)
+	defer s.Close()
+	s.Status = http.StatusInternalServerError
+
+	tokens, errs := s.Client.VerifyIDTokensAndCheckRevoked(ctx, []string{testIDToken, ""})
+	for i := range tokens {
+		if tokens[i] != nil || errs[i] == nil {
+			t.Errorf("VerifyIDTokensAndCheckRevoked()[%d] = (%v, %v); want = (nil, error)", i, tokens[i], errs[i])
+		}
+	}
+}
+
+func TestVerifyIDTokensAndCheckRevokedNoValidTokens(t *testing.T) {
+	s := echoServer(testGetUserResponse, t)
+	defer s.Close()
+
+	tokens, errs := s.Client.VerifyIDTokensAndCheckRevoked(ctx, []string{"", "invalid"})
+	for i := range tokens {
+		if tokens[i] != nil || errs[i] == nil {
+			t.Errorf("VerifyIDTokensAndCheckRevoked()[%d] = (%v, %v); want = (nil, error)", i, tokens[i], errs[i])
+		}
+	}
+	if len(s.Req) != 0 {
+		t.Errorf("Requests = %d; want = 0", len(s.Req))
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	ft, err := client.VerifyIDToken(ctx, testIDToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ft.Claims["admin"] != true {
+		t.Errorf("Claims['admin'] = %v; want = true", ft.Claims["admin"])
+	}
+	if ft.UID != ft.Subject {
+		t.Errorf("UID = %q; Sub = %q; want UID = Sub", ft.UID, ft.Subject)
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	for _, scheme := range []string{"Bearer", "bearer", "BEARER"} {
+		r, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Authorization", scheme+" "+testIDToken)
+		ft, err := client.VerifyRequest(ctx, r)
+		if err != nil {
+			t.Errorf("VerifyRequest(%q) = %v; want = nil", scheme, err)
+		} else if ft.UID != ft.Subject {
+			t.Errorf("UID = %q; Sub = %q; want UID = Sub", ft.UID, ft.Subject)
+		}
+	}
+}
+
+func TestVerifyRequestInvalidHeader(t *testing.T) {
+	cases := []string{
+		"",
+		"Bearer",
+		"Bearer ",
+		"Basic dXNlcjpwYXNzd29yZA==",
+		testIDToken,
+	}
+	for _, tc := range cases {
+		r, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc != "" {
+			r.Header.Set("Authorization", tc)
+		}
+		ft, err := client.VerifyRequest(ctx, r)
+		if ft != nil || !IsInvalidAuthorizationHeader(err) {
+			t.Errorf("VerifyRequest(%q) = (%v, %v); want = (nil, invalid-authorization-header)", tc, ft, err)
+		}
+	}
+}
+
+func TestVerifyRequestInvalidToken(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Authorization", "Bearer "+getIDToken(mockIDTokenPayload{"aud": "bad-audience"}))
+	ft, err := client.VerifyRequest(ctx, r)
+	if ft != nil || !IsInvalidAudience(err) {
+		t.Errorf("VerifyRequest() = (%v, %v); want = (nil, invalid-audience)", ft, err)
+	}
+}
+
+func TestVerifyIDTokenForAudience(t *testing.T) {
+	tok := getIDToken(mockIDTokenPayload{
+		"aud": "other-project",
+		"iss": "https://securetoken.google.com/other-project",
+	})
+	ft, err := client.VerifyIDTokenForAudience(ctx, tok, "other-project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ft.Audience != "other-project" {
+		t.Errorf("Audience = %q; want = %q", ft.Audience, "other-project")
+	}
+	if _, err := client.VerifyIDToken(ctx, tok); !IsInvalidAudience(err) {
+		t.Errorf("VerifyIDToken() = %v; want = invalid-audience", err)
+	}
+}
+
+func TestVerifyIDTokenForAudienceError(t *testing.T) {
+	cases := []struct {
+		name      string
+		token     string
+		audience  string
+		predicate func(error) bool
+	}{
+		{"EmptyAudience", testIDToken, "", nil},
+		{"OwnProjectToken", testIDToken, "other-project", IsInvalidAudience},
+		{"BadIssuer", getIDToken(mockIDTokenPayload{
+			"aud": "other-project",
+			"iss": "https://securetoken.google.com/" + client.projectID,
+		}), "other-project", IsInvalidIssuer},
+	}
+	for _, tc := range cases {
+		ft, err := client.VerifyIDTokenForAudience(ctx, tc.token, tc.audience)
+		if ft != nil || err == nil || (tc.predicate != nil && !tc.predicate(err)) {
+			t.Errorf("VerifyIDTokenForAudience(%s) = (%v, %v); want = (nil, error)", tc.name, ft, err)
+		}
+	}
+}
+
+func TestSignInProvider(t *testing.T) {
+	cases := []struct {
+		claims map[string]interface{}
+		want   string
+	}{
+		{map[string]interface{}{"firebase": map[string]interface{}{"sign_in_provider": "google.com"}}, ProviderGoogle},
+		{map[string]interface{}{"firebase": map[string]interface{}{"sign_in_provider": "anonymous"}}, ProviderAnonymous},
+		{map[string]interface{}{"firebase": map[string]interface{}{"identities": map[string]interface{}{}}}, ""},
+		{map[string]interface{}{"firebase": "not-a-map"}, ""},
+		{map[string]interface{}{}, ""},
+		{nil, ""},
+	}
+	for _, tc := range cases {
+		token := &Token{Claims: tc.claims}
+		if got := token.SignInProvider(); got != tc.want {
+			t.Errorf("SignInProvider(%v) = %q; want = %q", tc.claims, got, tc.want)
+		}
+	}
+}
+
+func TestServiceAccountEmail(t *testing.T) {
+	email, err := client.ServiceAccountEmail(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "mock-email@mock-project.iam.gserviceaccount.com"; email != want {
+		t.Errorf("ServiceAccountEmail() = %q; want = %q", email, want)
+	}
+
+	c := *client
+	c.snr = &serviceAcctSigner{}
+	if email, err := c.ServiceAccountEmail(ctx); email != "" || err == nil {
+		t.Errorf("ServiceAccountEmail() = (%q, %v); want = (\"\", error)", email, err)
+	}
+}
+
+func TestTokenDecode(t *testing.T) {
+	idToken := getIDToken(mockIDTokenPayload{"roles": []string{"admin", "editor"}, "level": 3})
+	ft, err := client.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var claims struct {
+		Subject string   `json:"sub"`
+		Roles   []string `json:"roles"`
+		Level   int      `json:"level"`
+	}
+	if err := ft.Decode(&claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != ft.UID {
+		t.Errorf("Decode() sub = %q; want = %q", claims.Subject, ft.UID)
+	}
+	if !reflect.DeepEqual(claims.Roles, []string{"admin", "editor"}) {
+		t.Errorf("Decode() roles = %v; want = %v", claims.Roles, []string{"admin", "editor"})
+	}
+	if claims.Level != 3 {
+		t.Errorf("Decode() level = %d; want = 3", claims.Level)
+	}
+}
+
+func TestTokenDecodeUnavailable(t *testing.T) {
+	var claims map[string]interface{}
+	if err := (&Token{UID: "uid"}).Decode(&claims); err == nil {
+		t.Errorf("Decode() = nil; want = error")
+	}
+}
+
+func TestEmailVerified(t *testing.T) {
+	cases := []struct {
+		claims map[string]interface{}
+		want   bool
+	}{
+		{map[string]interface{}{"email_verified": true}, true},
+		{map[string]interface{}{"email_verified": false}, false},
+		{map[string]interface{}{"email_verified": "true"}, false},
+		{map[string]interface{}{}, false},
+		{nil, false},
+	}
+	for _, tc := range cases {
+		token := &Token{Claims: tc.claims}
+		if got := token.EmailVerified(); got != tc.want {
+			t.Errorf("EmailVerified(%v) = %v; want = %v", tc.claims, got, tc.want)
+		}
+	}
+
+	ft, err := client.VerifyIDToken(ctx, getIDToken(mockIDTokenPayload{"email_verified": true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ft.EmailVerified() {
+		t.Errorf("EmailVerified() = false; want = true")
+	}
+}
+
+func TestVerifyIDTokenFreshAuth(t *testing.T) {
+	now := time.Now().Unix()
+	token := getIDToken(mockIDTokenPayload{"auth_time": now - 60})
+	ft, err := client.VerifyIDTokenFreshAuth(ctx, token, 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ft.AuthTime != now-60 {
+		t.Errorf("AuthTime = %d; want = %d", ft.AuthTime, now-60)
+	}
+	if _, ok := ft.Claims["auth_time"]; !ok {
+		t.Errorf("Claims[auth_time] not set")
+	}
+
+	tc := tenantClient(client, t)
+	tenantToken := getIDToken(mockIDTokenPayload{
+		"auth_time": now - 60,
+		"firebase":  map[string]interface{}{"tenant": testTenantID},
+	})
+	if _, err := tc.VerifyIDTokenFreshAuth(ctx, tenantToken, 5*time.Minute); err != nil {
+		t.Errorf("VerifyIDTokenFreshAuth(tenant) = %v; want = nil", err)
+	}
+	if _, err := tc.VerifyIDTokenFreshAuth(ctx, token, 5*time.Minute); !IsTenantIDMismatch(err) {
+		t.Errorf("VerifyIDTokenFreshAuth(other tenant) = %v; want = IsTenantIDMismatch", err)
+	}
+}
+
+func TestVerifyIDTokenFreshAuthError(t *testing.T) {
+	now := time.Now().Unix()
+	cases := []struct {
+		name  string
+		token string
+	}{
+		{"StaleAuth", getIDToken(mockIDTokenPayload{"auth_time": now - 600})},
+		{"NoAuthTime", getIDToken(nil)},
+	}
+	for _, tc := range cases {
+		ft, err := client.VerifyIDTokenFreshAuth(ctx, tc.token, 5*time.Minute)
+		if ft != nil || !IsAuthTimeTooOld(err) {
+			t.Errorf("VerifyIDTokenFreshAuth(%s) = (%v, %v); want = (nil, IsAuthTimeTooOld)", tc.name, ft, err)
+		}
+	}
+
+	expired := getIDToken(mockIDTokenPayload{"auth_time": now - 60, "exp": now - 10})
+	if _, err := client.VerifyIDTokenFreshAuth(ctx, expired, 5*time.Minute); !IsTokenExpired(err) {
+		t.Errorf("VerifyIDTokenFreshAuth(Expired) = %v; want = IsTokenExpired", err)
+	}
+	if _, err := client.VerifyIDTokenFreshAuth(ctx, getIDToken(mockIDTokenPayload{"auth_time": now}), 0); err == nil {
+		t.Errorf("VerifyIDTokenFreshAuth(maxAge = 0) = nil; want = error")
+	}
+}
+
+func TestHasClaim(t *testing.T) {
+	token := &Token{Claims: map[string]interface{}{
+		"admin":  true,
+		"level":  float64(2),
+		"role":   "editor",
+		"roles":  []interface{}{"reader", "writer", float64(7)},
+		"nested": map[string]interface{}{"key": "value"},
+	}}
+	cases := []struct {
+		key  string
+		want interface{}
+		has  bool
+	}{
+		{"admin", true, true},
+		{"admin", false, false},
+		{"admin", "true", false},
+		{"level", 2, true},
+		{"level", int64(2), true},
+		{"level", 2.0, true},
+		{"level", 3, false},
+		{"level", "2", false},
+		{"role", "editor", true},
+		{"role", "admin", false},
+		{"roles", "writer", true},
+		{"roles", 7, true},
+		{"roles", "admin", false},
+		{"nested", map[string]interface{}{"key": "value"}, true},
+		{"missing", nil, false},
+	}
+	for _, tc := range cases {
+		if got := token.HasClaim(tc.key, tc.want); got != tc.has {
+			t.Errorf("HasClaim(%q, %v) = %v; want = %v", tc.key, tc.want, got, tc.has)
+		}
+	}
+	if (&Token{}).HasClaim("admin", true) {
+		t.Errorf("HasClaim() on empty token = true; want = false")
+	}
+
+	ft, err := client.VerifyIDToken(ctx, getIDToken(mockIDTokenPayload{"level": 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ft.HasClaim("level", 2) {
+		t.Errorf("HasClaim(level, 2) = false; want = true")
+	}
+}
+
+func TestBoolAndStringClaim(t *testing.T) {
+	token := &Token{Claims: map[string]interface{}{
+		"admin": true,
+		"role":  "editor",
+	}}
+	if v, ok := token.BoolClaim("admin"); !v || !ok {
+		t.Errorf("BoolClaim(admin) = (%v, %v); want = (true, true)", v, ok)
+	}
+	if v, ok := token.BoolClaim("role"); v || ok {
+		t.Errorf("BoolClaim(role) = (%v, %v); want = (false, false)", v, ok)
+	}
+	if v, ok := token.BoolClaim("missing"); v || ok {
+		t.Errorf("BoolClaim(missing) = (%v, %v); want = (false, false)", v, ok)
+	}
+	if v, ok := token.StringClaim("role"); v != "editor" || !ok {
+		t.Errorf("StringClaim(role) = (%q, %v); want = (%q, true)", v, ok, "editor")
+	}
+	if v, ok := token.StringClaim("admin"); v != "" || ok {
+		t.Errorf("StringClaim(admin) = (%q, %v); want = (\"\", false)", v, ok)
+	}
+	if v, ok := (&Token{}).StringClaim("role"); v != "" || ok {
+		t.Errorf("StringClaim() on empty token = (%q, %v); want = (\"\", false)", v, ok)
+	}
+}
+
+func TestTokenTimeUntilExpiryAndAge(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	c := *client
+	c.clock = &mockClock{now: now}
+	ft, err := c.VerifyIDToken(ctx, getIDToken(mockIDTokenPayload{
+		"iat": now.Unix() - 100,
+		"exp": now.Unix() + 3600,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ft.TimeUntilExpiry(), time.Hour; got != want {
+		t.Errorf("TimeUntilExpiry() = %v; want = %v", got, want)
+	}
+	if got, want := ft.Age(), 100*time.Second; got != want {
+		t.Errorf("Age() = %v; want = %v", got, want)
+	}
+
+	ft, expired, err := c.VerifyIDTokenAllowExpired(ctx, getIDToken(mockIDTokenPayload{
+		"iat": now.Unix() - 3700,
+		"exp": now.Unix() - 100,
+	}))
+	if err != nil || !expired {
+		t.Fatalf("VerifyIDTokenAllowExpired() = (%v, %v); want = (true, nil)", expired, err)
+	}
+	if got, want := ft.TimeUntilExpiry(), -100*time.Second; got != want {
+		t.Errorf("TimeUntilExpiry() = %v; want = %v", got, want)
+	}
+	if got, want := ft.Age(), 3700*time.Second; got != want {
+		t.Errorf("Age() = %v; want = %v", got, want)
+	}
+
+	token := &Token{Expires: time.Now().Unix() - 60}
+	if got := token.TimeUntilExpiry(); got >= 0 {
+		t.Errorf("TimeUntilExpiry() = %v; want < 0", got)
+	}
+}
+
+func TestTokenJSONRoundTrip(t *testing.T) {
+	ft, err := client.VerifyIDToken(ctx, getIDToken(mockIDTokenPayload{
+		"email":    "alice@example.com",
+		"firebase": map[string]interface{}{"sign_in_provider": "password"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(ft)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Token
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := *ft
+	want.KeyID = ""
+	want.Algorithm = ""
+	want.payload = got.payload
+	want.clock = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal(Marshal(Token)) = %#v; want = %#v", got, want)
+	}
+	if got.Claims["admin"] != true || got.Claims["email"] != "alice@example.com" {
+		t.Errorf("Claims = %v; want admin and email claims", got.Claims)
+	}
+	if got.SignInProvider() != "password" {
+		t.Errorf("SignInProvider() = %q; want = %q", got.SignInProvider(), "password")
+	}
+	var claims struct {
+		Email string `json:"email"`
+	}
+	if err := got.Decode(&claims); err != nil || claims.Email != "alice@example.com" {
+		t.Errorf("Decode() = (%q, %v); want = (%q, nil)", claims.Email, err, "alice@example.com")
+	}
+
+	// Values work the same as pointers, and standard claims are not duplicated in Claims.
+	b2, err := json.Marshal(*ft)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b2, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["sub"] != ft.Subject || m["uid"] != ft.UID || m["email"] != "alice@example.com" {
+		t.Errorf("Marshal(Token) = %s; want flattened claims", b2)
 	}
-	if ft.UID != ft.Subject {
-		t.Errorf("UID = %q; Sub = %q; want UID = Sub", ft.UID, ft.Subject)
+	if _, ok := got.Claims["sub"]; ok {
+		t.Errorf("Claims = %v; want no standard claims", got.Claims)
 	}
 }
 
-func TestVerifyIDTokenAndCheckRevokedDoNotCheck(t *testing.T) {
-	s := echoServer(testGetUserResponse, t)
-	defer s.Close()
-	tok := getIDToken(mockIDTokenPayload{"uid": "uid", "iat": 1970}) // old token
-
-	ft, err := s.Client.VerifyIDToken(ctx, tok)
+func TestVerifyIDTokenHeader(t *testing.T) {
+	ft, err := client.VerifyIDToken(ctx, getIDTokenWithKid("mock-key-id-1", nil))
 	if err != nil {
 		t.Fatal(err)
 	}
-	if ft.Claims["admin"] != true {
-		t.Errorf("Claims['admin'] = %v; want = true", ft.Claims["admin"])
+	if ft.KeyID != "mock-key-id-1" {
+		t.Errorf("KeyID = %q; want = %q", ft.KeyID, "mock-key-id-1")
 	}
-	if ft.UID != ft.Subject {
-		t.Errorf("UID = %q; Sub = %q; want UID = Sub", ft.UID, ft.Subject)
+	if ft.Algorithm != "RS256" {
+		t.Errorf("Algorithm = %q; want = %q", ft.Algorithm, "RS256")
 	}
 }
 
-func TestVerifyIDTokenAndCheckRevokedInvalidated(t *testing.T) {
-	s := echoServer(testGetUserResponse, t)
-	defer s.Close()
-	tok := getIDToken(mockIDTokenPayload{"uid": "uid", "iat": 1970}) // old token
-
-	p, err := s.Client.VerifyIDTokenAndCheckRevoked(ctx, tok)
-	we := "ID token has been revoked"
-	if p != nil || err == nil || err.Error() != we || !IsIDTokenRevoked(err) {
-		t.Errorf("VerifyIDTokenAndCheckRevoked(ctx, token) =(%v, %v); want = (%v, %v)",
-			p, err, nil, we)
+func TestVerifyIDTokenFirebaseInfo(t *testing.T) {
+	fb := map[string]interface{}{
+		"sign_in_provider": "google.com",
+		"identities": map[string]interface{}{
+			"google.com": []string{"1234567890"},
+			"email":      []string{"test@example.com"},
+		},
 	}
-}
-
-func TestVerifyIDToken(t *testing.T) {
-	ft, err := client.VerifyIDToken(ctx, testIDToken)
+	ft, err := client.VerifyIDToken(ctx, getIDToken(mockIDTokenPayload{"firebase": fb}))
 	if err != nil {
 		t.Fatal(err)
 	}
-	if ft.Claims["admin"] != true {
-		t.Errorf("Claims['admin'] = %v; want = true", ft.Claims["admin"])
+
+	want := FirebaseInfo{
+		SignInProvider: "google.com",
+		Identities: map[string][]string{
+			"google.com": {"1234567890"},
+			"email":      {"test@example.com"},
+		},
 	}
-	if ft.UID != ft.Subject {
-		t.Errorf("UID = %q; Sub = %q; want UID = Sub", ft.UID, ft.Subject)
+	if got := ft.Firebase(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Firebase() = %#v; want = %#v", got, want)
+	}
+	if _, ok := ft.Claims["firebase"]; !ok {
+		t.Errorf("Claims['firebase'] not found; want raw claim to be retained")
+	}
+}
+
+func TestFirebaseInfoMissing(t *testing.T) {
+	cases := []*Token{
+		{},
+		{Claims: map[string]interface{}{"admin": true}},
+		{Claims: map[string]interface{}{"firebase": "not-an-object"}},
+	}
+	for _, tc := range cases {
+		if got := tc.Firebase(); !reflect.DeepEqual(got, FirebaseInfo{}) {
+			t.Errorf("Firebase(%v) = %#v; want = zero value", tc.Claims, got)
+		}
 	}
 }
 
@@ -263,6 +1151,56 @@ func TestVerifyIDTokenInvalidSignature(t *testing.T) {
 	}
 }
 
+func TestVerifyIDTokenMalformed(t *testing.T) {
+	parts := strings.Split(testIDToken, ".")
+	cases := []struct {
+		name  string
+		token string
+	}{
+		{"NoSegments", "foobar"},
+		{"TwoSegments", parts[0] + "." + parts[1]},
+		{"FourSegments", testIDToken + ".extra"},
+		{"BadHeaderEncoding", "!!!." + parts[1] + "." + parts[2]},
+		{"BadHeaderJSON", base64.RawURLEncoding.EncodeToString([]byte("not json")) + "." + parts[1] + "." + parts[2]},
+		{"BadPayloadEncoding", parts[0] + ".!!!." + parts[2]},
+		{"BadPayloadJSON", parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte("not json")) + "." + parts[2]},
+	}
+	for _, tc := range cases {
+		ft, err := client.VerifyIDToken(ctx, tc.token)
+		if ft != nil || !IsMalformedToken(err) || IsInvalidSignature(err) {
+			t.Errorf("VerifyIDToken(%s) = (%v, %v); want = (nil, malformed-token)", tc.name, ft, err)
+		}
+	}
+}
+
+func TestVerifyTokenWrongSegmentCount(t *testing.T) {
+	for _, tc := range []string{"notajwt", "not.a", "not.a.jwt.token", "...."} {
+		want := "ID token must be a valid JWT with three segments"
+		if ft, err := client.VerifyIDToken(ctx, tc); ft != nil || err == nil || err.Error() != want {
+			t.Errorf("VerifyIDToken(%q) = (%v, %v); want = (nil, %q)", tc, ft, err, want)
+		}
+		want = "session cookie must be a valid JWT with three segments"
+		if ft, err := client.VerifySessionCookie(ctx, tc); ft != nil || err == nil || err.Error() != want {
+			t.Errorf("VerifySessionCookie(%q) = (%v, %v); want = (nil, %q)", tc, ft, err, want)
+		}
+	}
+}
+
+func TestVerifyIDTokenInvalidSignatureCode(t *testing.T) {
+	parts := strings.Split(testIDToken, ".")
+	cases := []string{
+		parts[0] + "." + parts[1] + ".invalidsignature",
+		parts[0] + "." + parts[1] + ".",
+		getIDTokenWithKid("foo", nil),
+	}
+	for _, tc := range cases {
+		ft, err := client.VerifyIDToken(ctx, tc)
+		if ft != nil || !IsInvalidSignature(err) || IsMalformedToken(err) {
+			t.Errorf("VerifyIDToken(%q) = (%v, %v); want = (nil, invalid-signature)", tc, ft, err)
+		}
+	}
+}
+
 func TestVerifyIDTokenError(t *testing.T) {
 	now := time.Now().Unix()
 	cases := []struct {
@@ -292,6 +1230,175 @@ func TestVerifyIDTokenError(t *testing.T) {
 	}
 }
 
+func TestVerifyIDTokenErrorCode(t *testing.T) {
+	now := time.Now().Unix()
+	cases := []struct {
+		name  string
+		token string
+		check func(error) bool
+	}{
+		{"NoKid", getIDTokenWithKid("", nil), IsInvalidToken},
+		{"BadAudience", getIDToken(mockIDTokenPayload{"aud": "bad-audience"}), IsInvalidAudience},
+		{"BadIssuer", getIDToken(mockIDTokenPayload{"iss": "bad-issuer"}), IsInvalidIssuer},
+		{"EmptySubject", getIDToken(mockIDTokenPayload{"sub": ""}), IsInvalidToken},
+		{"LongSubject", getIDToken(mockIDTokenPayload{"sub": strings.Repeat("a", 129)}), IsInvalidToken},
+		{"FutureToken", getIDToken(mockIDTokenPayload{"iat": now + 1000}), IsTokenUsedTooEarly},
+		{"ExpiredToken", getIDToken(mockIDTokenPayload{
+			"iat": now - 1000,
+			"exp": now - 100,
+		}), IsTokenExpired},
+	}
+
+	for _, tc := range cases {
+		_, err := client.VerifyIDToken(ctx, tc.token)
+		if err == nil || !tc.check(err) {
+			t.Errorf("VerifyIDToken(%q) = %v; want error with matching code", tc.name, err)
+		}
+	}
+
+	expired := getSessionCookie(mockIDTokenPayload{"iat": now - 1000, "exp": now - 100})
+	if _, err := client.VerifySessionCookie(ctx, expired); err == nil || !IsTokenExpired(err) {
+		t.Errorf("VerifySessionCookie('expired') = %v; want token-expired error", err)
+	}
+}
+
+func TestVerifyIDTokenAllowExpired(t *testing.T) {
+	now := time.Now().Unix()
+	cases := []struct {
+		name    string
+		token   string
+		expired bool
+	}{
+		{"ValidToken", testIDToken, false},
+		{"ExpiredToken", getIDToken(mockIDTokenPayload{"iat": now - 1000, "exp": now - 100}), true},
+	}
+
+	for _, tc := range cases {
+		p, expired, err := client.VerifyIDTokenAllowExpired(ctx, tc.token)
+		if p == nil || err != nil || expired != tc.expired {
+			t.Errorf("VerifyIDTokenAllowExpired(%q) = (%v, %v, %v); want = (token, %v, nil)",
+				tc.name, p, expired, err, tc.expired)
+			continue
+		}
+		if p.UID != "1234567890" || p.Claims["admin"] != true {
+			t.Errorf("VerifyIDTokenAllowExpired(%q) = %v; want UID and claims of the token", tc.name, p)
+		}
+	}
+}
+
+func TestVerifyIDTokenAllowExpiredMaxTokenAge(t *testing.T) {
+	c := *client
+	WithMaxTokenAge(5 * time.Minute)(&c)
+	now := time.Now().Unix()
+
+	token := getIDToken(mockIDTokenPayload{"iat": now - 600})
+	p, expired, err := c.VerifyIDTokenAllowExpired(ctx, token)
+	if p == nil || !expired || err != nil {
+		t.Errorf("VerifyIDTokenAllowExpired() = (%v, %v, %v); want = (token, true, nil)", p, expired, err)
+	}
+}
+
+func TestDecodeVerifiedSignature(t *testing.T) {
+	now := time.Now().Unix()
+	cases := []struct {
+		name   string
+		token  string
+		claims []string
+		checks []func(error) bool
+	}{
+		{"ValidToken", testIDToken, nil, nil},
+		{"Expired", getIDToken(mockIDTokenPayload{"iat": now - 1000, "exp": now - 100}),
+			[]string{"exp"}, []func(error) bool{IsTokenExpired}},
+		{"ExpiredBadAudienceAndIssuer", getIDToken(mockIDTokenPayload{
+			"aud": "other-project",
+			"iss": "https://securetoken.google.com/other-project",
+			"iat": now - 1000,
+			"exp": now - 100,
+		}), []string{"aud", "iss", "exp"}, []func(error) bool{IsInvalidAudience, IsInvalidIssuer, IsTokenExpired}},
+		{"FutureTokenEmptySubject", getIDToken(mockIDTokenPayload{"iat": now + 1000, "sub": ""}),
+			[]string{"iat", "sub"}, []func(error) bool{IsTokenUsedTooEarly, IsInvalidToken}},
+	}
+
+	for _, tc := range cases {
+		p, errs, err := client.DecodeVerifiedSignature(ctx, tc.token)
+		if p == nil || err != nil {
+			t.Errorf("DecodeVerifiedSignature(%s) = (%v, %v); want = (token, nil)", tc.name, p, err)
+			continue
+		}
+		if p.Claims["admin"] != true {
+			t.Errorf("DecodeVerifiedSignature(%s).Claims = %v; want claims of the token", tc.name, p.Claims)
+		}
+		if len(errs) != len(tc.claims) {
+			t.Errorf("DecodeVerifiedSignature(%s) = %v; want = %v", tc.name, errs, tc.claims)
+			continue
+		}
+		for i, e := range errs {
+			if e.Claim != tc.claims[i] || !tc.checks[i](e.Err) || e.Error() != e.Err.Error() {
+				t.Errorf("DecodeVerifiedSignature(%s)[%d] = %#v; want Claim = %q", tc.name, i, e, tc.claims[i])
+			}
+		}
+	}
+}
+
+func TestDecodeVerifiedSignatureMaxTokenAge(t *testing.T) {
+	c := *client
+	WithMaxTokenAge(5 * time.Minute)(&c)
+	token := getIDToken(mockIDTokenPayload{"iat": time.Now().Unix() - 600})
+	p, errs, err := c.DecodeVerifiedSignature(ctx, token)
+	if p == nil || err != nil || len(errs) != 1 || errs[0].Claim != "iat" || !IsTokenExpired(errs[0].Err) {
+		t.Errorf("DecodeVerifiedSignature() = (%v, %v, %v); want = (token, [iat], nil)", p, errs, err)
+	}
+}
+
+func TestDecodeVerifiedSignatureError(t *testing.T) {
+	cases := []struct {
+		name  string
+		token string
+		check func(error) bool
+	}{
+		{"BadSignature", testIDToken[:len(testIDToken)-8] + "AAAAAAAA", IsInvalidSignature},
+		{"BadFormatToken", "foobar", IsMalformedToken},
+	}
+	for _, tc := range cases {
+		p, errs, err := client.DecodeVerifiedSignature(ctx, tc.token)
+		if p != nil || errs != nil || !tc.check(err) {
+			t.Errorf("DecodeVerifiedSignature(%s) = (%v, %v, %v); want = (nil, nil, error)", tc.name, p, errs, err)
+		}
+	}
+}
+
+func TestVerifyIDTokenAllowExpiredError(t *testing.T) {
+	now := time.Now().Unix()
+	cases := []struct {
+		name  string
+		token string
+		check func(error) bool
+	}{
+		{"NoKid", getIDTokenWithKid("", nil), IsInvalidToken},
+		{"BadAudience", getIDToken(mockIDTokenPayload{"aud": "bad-audience"}), IsInvalidAudience},
+		{"ExpiredBadIssuer", getIDToken(mockIDTokenPayload{
+			"iss": "bad-issuer",
+			"iat": now - 1000,
+			"exp": now - 100,
+		}), IsInvalidIssuer},
+		{"ExpiredEmptySubject", getIDToken(mockIDTokenPayload{
+			"sub": "",
+			"iat": now - 1000,
+			"exp": now - 100,
+		}), IsInvalidToken},
+		{"FutureToken", getIDToken(mockIDTokenPayload{"iat": now + 1000}), IsTokenUsedTooEarly},
+		{"BadFormatToken", "foobar", IsMalformedToken},
+	}
+
+	for _, tc := range cases {
+		p, expired, err := client.VerifyIDTokenAllowExpired(ctx, tc.token)
+		if p != nil || expired || err == nil || !tc.check(err) {
+			t.Errorf("VerifyIDTokenAllowExpired(%q) = (%v, %v, %v); want error with matching code",
+				tc.name, p, expired, err)
+		}
+	}
+}
+
 func TestNoProjectID(t *testing.T) {
 	// AuthConfig with empty ProjectID
 	conf := &internal.AuthConfig{Opts: defaultTestOpts}
@@ -311,8 +1418,76 @@ func TestCustomTokenVerification(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if _, err := client.VerifyIDToken(ctx, token); err == nil {
-		t.Error("VeridyIDToken() = nil; want error")
+	_, err = client.VerifyIDToken(ctx, token)
+	if err == nil || !IsCustomTokenError(err) || !IsInvalidToken(err) {
+		t.Errorf("VerifyIDToken() = %v; want = CustomTokenError", err)
+	}
+	if _, err := client.VerifySessionCookie(ctx, token); !IsCustomTokenError(err) {
+		t.Errorf("VerifySessionCookie() = %v; want = CustomTokenError", err)
+	}
+	if _, err := client.VerifyIDToken(ctx, testIDToken); IsCustomTokenError(err) {
+		t.Errorf("IsCustomTokenError(%v) = true; want = false", err)
+	}
+	if err := errors.New("expected an ID token but got a custom token"); IsCustomTokenError(err) {
+		t.Errorf("IsCustomTokenError(%v) = true; want = false", err)
+	}
+}
+
+func TestCustomTokenVerificationWithoutDetection(t *testing.T) {
+	c := *client
+	c.noCustomTokenCheck = true
+	token, err := c.CustomToken(ctx, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.VerifyIDToken(ctx, token); IsCustomTokenError(err) || !IsInvalidAudience(err) {
+		t.Errorf("VerifyIDToken() = %v; want = InvalidAudience", err)
+	}
+
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.snr = serviceAcctSigner{email: "other@mock-project.iam.gserviceaccount.com", pk: pk}
+	if token, err = c.CustomToken(ctx, "user1"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.VerifyIDToken(ctx, token)
+	if IsCustomTokenError(err) || !IsInvalidToken(err) || !strings.Contains(err.Error(), "has no 'kid' header") {
+		t.Errorf("VerifyIDToken() = %v; want = no 'kid' header error", err)
+	}
+	if _, err := c.VerifySessionCookie(ctx, token); IsCustomTokenError(err) || !IsInvalidToken(err) {
+		t.Errorf("VerifySessionCookie() = %v; want = no 'kid' header error", err)
+	}
+
+	c.emulatorHost = "localhost:9099"
+	if _, err := c.VerifyIDToken(ctx, token); IsCustomTokenError(err) || !IsInvalidAudience(err) {
+		t.Errorf("VerifyIDToken() = %v; want = InvalidAudience", err)
+	}
+}
+
+func TestCustomTokenVerificationUnknownKey(t *testing.T) {
+	// Custom tokens are signed with a service account key, which is not among the public keys used
+	// to verify ID tokens.
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := *client
+	c.snr = serviceAcctSigner{email: "other@mock-project.iam.gserviceaccount.com", pk: pk}
+	token, err := c.CustomToken(ctx, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.VerifyIDToken(ctx, token); !IsCustomTokenError(err) || IsInvalidSignature(err) {
+		t.Errorf("VerifyIDToken() = %v; want = CustomTokenError", err)
+	}
+	if _, err := client.VerifySessionCookie(ctx, token); !IsCustomTokenError(err) {
+		t.Errorf("VerifySessionCookie() = %v; want = CustomTokenError", err)
+	}
+	if p, errs, err := client.DecodeVerifiedSignature(ctx, token); p != nil || errs != nil || !IsCustomTokenError(err) {
+		t.Errorf("DecodeVerifiedSignature() = (%v, %v, %v); want = (nil, nil, CustomTokenError)", p, errs, err)
 	}
 }
 
@@ -327,6 +1502,154 @@ func TestCertificateRequestError(t *testing.T) {
 	}
 }
 
+func TestSessionCookie(t *testing.T) {
+	resp := `{
+		"sessionCookie": "expectedCookie"
+	}`
+	s := echoServer([]byte(resp), t)
+	defer s.Close()
+
+	cookie, err := s.Client.SessionCookie(ctx, "idToken", 10*time.Minute)
+	if cookie != "expectedCookie" || err != nil {
+		t.Errorf("SessionCookie() = (%q, %v); want = (%q, nil)", cookie, err, "expectedCookie")
+	}
+
+	wantURL := "/projects/mock-project-id:createSessionCookie"
+	if s.Req[0].URL.Path != wantURL {
+		t.Errorf("SessionCookie() URL = %q; want = %q", s.Req[0].URL.Path, wantURL)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(s.Rbody, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"idToken":       "idToken",
+		"validDuration": float64(600),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SessionCookie() Req = %v; want = %v", got, want)
+	}
+}
+
+func TestSessionCookieError(t *testing.T) {
+	s := echoServer([]byte(`{"error":{"message":"INVALID_ID_TOKEN"}}`), t)
+	defer s.Close()
+	s.Status = http.StatusBadRequest
+
+	cookie, err := s.Client.SessionCookie(ctx, "idToken", 10*time.Minute)
+	if cookie != "" || err == nil {
+		t.Errorf("SessionCookie() = (%q, %v); want = (\"\", error)", cookie, err)
+	}
+}
+
+func TestSessionCookieInvalidArgs(t *testing.T) {
+	cases := []struct {
+		name      string
+		idToken   string
+		expiresIn time.Duration
+	}{
+		{"EmptyToken", "", 10 * time.Minute},
+		{"ShortDuration", "idToken", 299 * time.Second},
+		{"LongDuration", "idToken", 14*24*time.Hour + time.Second},
+	}
+	for _, tc := range cases {
+		if cookie, err := client.SessionCookie(ctx, tc.idToken, tc.expiresIn); cookie != "" || err == nil {
+			t.Errorf("SessionCookie(%q) = (%q, %v); want = (\"\", error)", tc.name, cookie, err)
+		}
+	}
+}
+
+func TestVerifySessionCookie(t *testing.T) {
+	ft, err := client.VerifySessionCookie(ctx, getSessionCookie(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ft.Claims["admin"] != true {
+		t.Errorf("Claims['admin'] = %v; want = true", ft.Claims["admin"])
+	}
+	if ft.UID != ft.Subject {
+		t.Errorf("UID = %q; Sub = %q; want UID = Sub", ft.UID, ft.Subject)
+	}
+}
+
+func TestVerifySessionCookieError(t *testing.T) {
+	now := time.Now().Unix()
+	cases := []struct {
+		name   string
+		cookie string
+	}{
+		{"IDToken", testIDToken},
+		{"NoKid", getSessionCookieWithKid("", nil)},
+		{"BadAudience", getSessionCookie(mockIDTokenPayload{"aud": "bad-audience"})},
+		{"EmptySubject", getSessionCookie(mockIDTokenPayload{"sub": ""})},
+		{"FutureCookie", getSessionCookie(mockIDTokenPayload{"iat": now + 1000})},
+		{"ExpiredCookie", getSessionCookie(mockIDTokenPayload{
+			"iat": now - 1000,
+			"exp": now - 100,
+		})},
+		{"EmptyCookie", ""},
+	}
+
+	for _, tc := range cases {
+		if _, err := client.VerifySessionCookie(ctx, tc.cookie); err == nil {
+			t.Errorf("VerifySessionCookie(%q) = nil; want error", tc.name)
+		}
+	}
+}
+
+func TestVerifyIDTokenWithSessionCookie(t *testing.T) {
+	if _, err := client.VerifyIDToken(ctx, getSessionCookie(nil)); err == nil {
+		t.Error("VerifyIDToken(sessionCookie) = nil; want error")
+	}
+}
+
+func TestVerifySessionCookieAndCheckRevoked(t *testing.T) {
+	s := echoServer(testGetUserResponse, t)
+	defer s.Close()
+
+	ft, err := s.Client.VerifySessionCookieAndCheckRevoked(ctx, getSessionCookie(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ft.UID != ft.Subject {
+		t.Errorf("UID = %q; Sub = %q; want UID = Sub", ft.UID, ft.Subject)
+	}
+
+	cookie := getSessionCookie(mockIDTokenPayload{"iat": 1970}) // old cookie
+	p, err := s.Client.VerifySessionCookieAndCheckRevoked(ctx, cookie)
+	we := "session cookie has been revoked"
+	if p != nil || err == nil || !strings.HasPrefix(err.Error(), we) || !IsSessionCookieRevoked(err) {
+		t.Errorf("VerifySessionCookieAndCheckRevoked() = (%v, %v); want = (nil, %q)", p, err, we)
+	}
+}
+
+func TestVerifySessionCookieAndCheckRevokedAfterRevoke(t *testing.T) {
+	uid := "1234567890"
+	c := *client
+	c.is = &mockIdentitytoolkit{
+		signupRequests: []*identitytoolkit.IdentitytoolkitRelyingpartySignupNewUserRequest{{LocalId: uid}},
+	}
+	iat := time.Now().Unix() - 100
+	cookie := getSessionCookie(mockIDTokenPayload{"iat": iat})
+	idToken := getIDToken(mockIDTokenPayload{"iat": iat})
+	if _, err := c.VerifySessionCookieAndCheckRevoked(ctx, cookie); err != nil {
+		t.Fatalf("VerifySessionCookieAndCheckRevoked() before revocation = %v; want = nil", err)
+	}
+
+	if err := c.RevokeRefreshTokens(ctx, uid); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := c.VerifySessionCookieAndCheckRevoked(ctx, cookie)
+	if p != nil || !IsSessionCookieRevoked(err) {
+		t.Errorf("VerifySessionCookieAndCheckRevoked() = (%v, %v); want = (nil, SessionCookieRevoked)", p, err)
+	}
+	p, err = c.VerifyIDTokenAndCheckRevoked(ctx, idToken)
+	if p != nil || !IsIDTokenRevoked(err) {
+		t.Errorf("VerifyIDTokenAndCheckRevoked() = (%v, %v); want = (nil, IDTokenRevoked)", p, err)
+	}
+}
+
 func verifyCustomToken(ctx context.Context, token string, expected map[string]interface{}, t *testing.T) {
 	h := &jwtHeader{}
 	p := &customToken{}
@@ -343,6 +1666,8 @@ func verifyCustomToken(ctx context.Context, token string, expected map[string]in
 		t.Errorf("Algorithm: %q; want: 'RS256'", h.Algorithm)
 	} else if h.Type != "JWT" {
 		t.Errorf("Type: %q; want: 'JWT'", h.Type)
+	} else if h.KeyID != client.snr.KeyID() {
+		t.Errorf("KeyID: %q; want: %q", h.KeyID, client.snr.KeyID())
 	} else if p.Aud != firebaseAudience {
 		t.Errorf("Audience: %q; want: %q", p.Aud, firebaseAudience)
 	} else if p.Iss != email {
@@ -383,6 +1708,20 @@ func getIDTokenWithKid(kid string, p mockIDTokenPayload) string {
 	return token
 }
 
+func getSessionCookie(p mockIDTokenPayload) string {
+	return getSessionCookieWithKid("mock-key-id-1", p)
+}
+
+func getSessionCookieWithKid(kid string, p mockIDTokenPayload) string {
+	pCopy := mockIDTokenPayload{
+		"iss": "https://session.firebase.google.com/" + client.projectID,
+	}
+	for k, v := range p {
+		pCopy[k] = v
+	}
+	return getIDTokenWithKid(kid, pCopy)
+}
+
 type mockIDTokenPayload map[string]interface{}
 
 func (p mockIDTokenPayload) decodeFrom(s string) error {
@@ -391,21 +1730,21 @@ func (p mockIDTokenPayload) decodeFrom(s string) error {
 
 // mockKeySource provides access to a set of in-memory public keys.
 type mockKeySource struct {
-	keys []*publicKey
+	keys []*PublicKey
 	err  error
 }
 
-func (k *mockKeySource) Keys(ctx context.Context) ([]*publicKey, error) {
+func (k *mockKeySource) Keys(ctx context.Context) ([]*PublicKey, error) {
 	return k.keys, k.err
 }
 
 // fileKeySource loads a set of public keys from the local file system.
 type fileKeySource struct {
 	FilePath   string
-	CachedKeys []*publicKey
+	CachedKeys []*PublicKey
 }
 
-func (f *fileKeySource) Keys(ctx context.Context) ([]*publicKey, error) {
+func (f *fileKeySource) Keys(ctx context.Context) ([]*PublicKey, error) {
 	if f.CachedKeys == nil {
 		certs, err := ioutil.ReadFile(f.FilePath)
 		if err != nil {
@@ -423,15 +1762,15 @@ func (f *fileKeySource) Keys(ctx context.Context) ([]*publicKey, error) {
 // is used in tests to verify custom tokens and mock ID tokens when they are signed with
 // App Engine private keys.
 type aeKeySource struct {
-	keys []*publicKey
+	keys []*PublicKey
 }
 
-func newAEKeySource(ctx context.Context) (keySource, error) {
+func newAEKeySource(ctx context.Context) (KeySource, error) {
 	certs, err := appengine.PublicCertificates(ctx)
 	if err != nil {
 		return nil, err
 	}
-	keys := make([]*publicKey, len(certs))
+	keys := make([]*PublicKey, len(certs))
 	for i, cert := range certs {
 		pk, err := parsePublicKey("mock-key-id-1", cert.Data)
 		if err != nil {
@@ -443,6 +1782,6 @@ func newAEKeySource(ctx context.Context) (keySource, error) {
 }
 
 // Keys returns the RSA Public Keys managed by App Engine.
-func (k aeKeySource) Keys(ctx context.Context) ([]*publicKey, error) {
+func (k aeKeySource) Keys(ctx context.Context) ([]*PublicKey, error) {
 	return k.keys, nil
 }
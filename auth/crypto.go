@@ -16,6 +16,8 @@ package auth
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -26,6 +28,8 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
+	"math/big"
 	"net/http"
 	"strconv"
 	"strings"
@@ -37,8 +41,15 @@ import (
 	"golang.org/x/net/context"
 )
 
-// publicKey represents a parsed RSA public key along with its unique key ID.
-type publicKey struct {
+// unknownKidRefreshInterval is the minimum time between two fetches of the public keys triggered by
+// tokens with a key ID that is not in the cache.
+const unknownKidRefreshInterval = time.Minute
+
+// PublicKey represents a parsed RSA public key along with its unique key ID.
+//
+// Kid is matched against the 'kid' header of a JWT to determine which key should be used to
+// verify its signature.
+type PublicKey struct {
 	Kid string
 	Key *rsa.PublicKey
 }
@@ -62,22 +73,33 @@ func (m *mockClock) Now() time.Time {
 	return m.now
 }
 
-// keySource is used to obtain a set of public keys, which can be used to verify cryptographic
+// KeySource is used to obtain a set of public keys, which can be used to verify cryptographic
 // signatures.
-type keySource interface {
-	Keys(context.Context) ([]*publicKey, error)
+//
+// Implementations must be safe for concurrent use by multiple goroutines.
+type KeySource interface {
+	Keys(context.Context) ([]*PublicKey, error)
 }
 
 // httpKeySource fetches RSA public keys from a remote HTTP server, and caches them in
 // memory. It also handles cache! invalidation and refresh based on the standard HTTP
 // cache-control headers.
+//
+// The response is parsed as a JSON object that maps key IDs to PEM-encoded X.509 certificates,
+// unless a different Parser is specified.
 type httpKeySource struct {
 	KeyURI     string
 	HTTPClient *http.Client
-	CachedKeys []*publicKey
+	CachedKeys []*PublicKey
 	ExpiryTime time.Time
 	Clock      clock
 	Mutex      *sync.Mutex
+	Parser     func([]byte) ([]*PublicKey, error)
+	MinTTL     time.Duration
+	MaxTTL     time.Duration
+	FetchTime  time.Time
+	Version    string
+	Logf       func(format string, v ...interface{})
 }
 
 func newHTTPKeySource(uri string, hc *http.Client) *httpKeySource {
@@ -89,9 +111,21 @@ func newHTTPKeySource(uri string, hc *http.Client) *httpKeySource {
 	}
 }
 
+// newJWKSKeySource creates an httpKeySource that fetches keys published as a JSON Web Key Set.
+func newJWKSKeySource(uri string, hc *http.Client) *httpKeySource {
+	ks := newHTTPKeySource(uri, hc)
+	ks.Parser = parseJWKS
+	return ks
+}
+
 // Keys returns the RSA Public Keys hosted at this key source's URI. Refreshes the data if
 // the cache is stale.
-func (k *httpKeySource) Keys(ctx context.Context) ([]*publicKey, error) {
+//
+// Concurrent callers are serialized, so that at most one refresh is in flight at any given time.
+// If a refresh fails while a previously fetched set of keys is available, the stale keys are
+// returned instead of an error, and the error is reported to Logf, if set. The refresh is then
+// attempted again on the next call.
+func (k *httpKeySource) Keys(ctx context.Context) ([]*PublicKey, error) {
 	k.Mutex.Lock()
 	defer k.Mutex.Unlock()
 	if len(k.CachedKeys) == 0 || k.hasExpired() {
@@ -99,6 +133,35 @@ func (k *httpKeySource) Keys(ctx context.Context) ([]*publicKey, error) {
 		if err != nil && len(k.CachedKeys) == 0 {
 			return nil, err
 		}
+		if err != nil && k.Logf != nil {
+			k.Logf("auth: failed to refresh public keys from %s; using stale keys: %v", k.KeyURI, err)
+		}
+	}
+	return k.CachedKeys, nil
+}
+
+// forceRefresh fetches a new set of keys from the remote server, regardless of the expiry time of
+// the cached keys. Concurrent calls to Keys() block until the fetch completes. The cached keys are
+// only replaced if the fetch succeeds, so that they can still be used when it fails.
+func (k *httpKeySource) forceRefresh(ctx context.Context) error {
+	k.Mutex.Lock()
+	defer k.Mutex.Unlock()
+	return k.refreshKeys(ctx)
+}
+
+// refreshForUnknownKid fetches the keys again if none of the cached keys has the given key ID,
+// and returns the resulting set of keys. This allows verifying tokens signed with a key that was
+// published after the keys were last fetched.
+//
+// The keys are not fetched again if they were fetched less than unknownKidRefreshInterval ago, so
+// that tokens with made-up key IDs cannot cause a fetch on every call.
+func (k *httpKeySource) refreshForUnknownKid(ctx context.Context, kid string) ([]*PublicKey, error) {
+	k.Mutex.Lock()
+	defer k.Mutex.Unlock()
+	if !hasKeyID(k.CachedKeys, kid) && k.Clock.Now().Sub(k.FetchTime) >= unknownKidRefreshInterval {
+		if err := k.refreshKeys(ctx); err != nil {
+			return nil, err
+		}
 	}
 	return k.CachedKeys, nil
 }
@@ -108,12 +171,18 @@ func (k *httpKeySource) hasExpired() bool {
 	return k.Clock.Now().After(k.ExpiryTime)
 }
 
+// refreshKeys fetches the public keys from the remote server, and updates the cache. The
+// previously cached keys are retained if the fetch fails. The request is bound to ctx, so that it
+// is aborted as soon as ctx is cancelled or its deadline expires.
 func (k *httpKeySource) refreshKeys(ctx context.Context) error {
-	k.CachedKeys = nil
+	k.FetchTime = k.Clock.Now()
 	req, err := http.NewRequest("GET", k.KeyURI, nil)
 	if err != nil {
 		return err
 	}
+	if k.Version != "" {
+		req.Header.Set("X-Client-Version", k.Version)
+	}
 
 	resp, err := ctxhttp.Do(ctx, k.HTTPClient, req)
 	if err != nil {
@@ -125,7 +194,11 @@ func (k *httpKeySource) refreshKeys(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	newKeys, err := parsePublicKeys(contents)
+	parse := parsePublicKeys
+	if k.Parser != nil {
+		parse = k.Parser
+	}
+	newKeys, err := parse(contents)
 	if err != nil {
 		return err
 	}
@@ -133,11 +206,32 @@ func (k *httpKeySource) refreshKeys(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	k.CachedKeys = append([]*publicKey(nil), newKeys...)
-	k.ExpiryTime = k.Clock.Now().Add(*maxAge)
+	k.CachedKeys = append([]*PublicKey(nil), newKeys...)
+	k.ExpiryTime = k.Clock.Now().Add(k.clampTTL(*maxAge))
 	return nil
 }
 
+// clampTTL bounds the cache duration advertised by the server to [MinTTL, MaxTTL]. A zero bound
+// is not enforced.
+func (k *httpKeySource) clampTTL(ttl time.Duration) time.Duration {
+	if k.MinTTL > 0 && ttl < k.MinTTL {
+		ttl = k.MinTTL
+	}
+	if k.MaxTTL > 0 && ttl > k.MaxTTL {
+		ttl = k.MaxTTL
+	}
+	return ttl
+}
+
+func hasKeyID(keys []*PublicKey, kid string) bool {
+	for _, k := range keys {
+		if k.Kid == kid {
+			return true
+		}
+	}
+	return false
+}
+
 func findMaxAge(resp *http.Response) (*time.Duration, error) {
 	cc := resp.Header.Get("cache-control")
 	for _, value := range strings.Split(cc, ",") {
@@ -155,14 +249,14 @@ func findMaxAge(resp *http.Response) (*time.Duration, error) {
 	return nil, errors.New("Could not find expiry time from HTTP headers")
 }
 
-func parsePublicKeys(keys []byte) ([]*publicKey, error) {
+func parsePublicKeys(keys []byte) ([]*PublicKey, error) {
 	m := make(map[string]string)
 	err := json.Unmarshal(keys, &m)
 	if err != nil {
 		return nil, err
 	}
 
-	var result []*publicKey
+	var result []*PublicKey
 	for kid, key := range m {
 		pubKey, err := parsePublicKey(kid, []byte(key))
 		if err != nil {
@@ -173,7 +267,55 @@ func parsePublicKeys(keys []byte) ([]*publicKey, error) {
 	return result, nil
 }
 
-func parsePublicKey(kid string, key []byte) (*publicKey, error) {
+// parseJWKS parses the RSA keys in a JSON Web Key Set (RFC 7517). Keys of other types are ignored.
+func parseJWKS(keys []byte) ([]*PublicKey, error) {
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(keys, &jwks); err != nil {
+		return nil, err
+	}
+
+	var result []*PublicKey
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode modulus of key %q: %v", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode exponent of key %q: %v", k.Kid, err)
+		}
+		if len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid exponent in key %q", k.Kid)
+		}
+		var exp uint64
+		for _, b := range e {
+			exp = exp<<8 | uint64(b)
+		}
+		if exp > math.MaxInt32 {
+			return nil, fmt.Errorf("invalid exponent in key %q", k.Kid)
+		}
+		result = append(result, &PublicKey{
+			Kid: k.Kid,
+			Key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp)},
+		})
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no RSA keys found in the key set")
+	}
+	return result, nil
+}
+
+func parsePublicKey(kid string, key []byte) (*PublicKey, error) {
 	block, _ := pem.Decode(key)
 	cert, err := x509.ParseCertificate(block.Bytes)
 	if err != nil {
@@ -183,30 +325,47 @@ func parsePublicKey(kid string, key []byte) (*publicKey, error) {
 	if !ok {
 		return nil, errors.New("Certificate is not a RSA key")
 	}
-	return &publicKey{kid, pk}, nil
+	return &PublicKey{kid, pk}, nil
 }
 
-func parsePrivateKey(key string) (*rsa.PrivateKey, error) {
+// parsePrivateKey parses a PEM-encoded private key. RSA keys are accepted in PKCS1 or PKCS8 form,
+// and ECDSA keys on the P-256 curve are accepted in SEC1 or PKCS8 form.
+func parsePrivateKey(key string) (crypto.Signer, error) {
 	block, _ := pem.Decode([]byte(key))
 	if block == nil {
 		return nil, fmt.Errorf("no private key data found in: %v", key)
 	}
 	k := block.Bytes
-	parsedKey, err := x509.ParsePKCS8PrivateKey(k)
-	if err != nil {
-		parsedKey, err = x509.ParsePKCS1PrivateKey(k)
+	var parsedKey interface{}
+	var err error
+	if block.Type == "EC PRIVATE KEY" {
+		parsedKey, err = x509.ParseECPrivateKey(k)
 		if err != nil {
-			return nil, fmt.Errorf("private key should be a PEM or plain PKSC1 or PKCS8; parse error: %v", err)
+			return nil, fmt.Errorf("failed to parse EC private key: %v", err)
+		}
+	} else {
+		parsedKey, err = x509.ParsePKCS8PrivateKey(k)
+		if err != nil {
+			parsedKey, err = x509.ParsePKCS1PrivateKey(k)
+			if err != nil {
+				return nil, fmt.Errorf("private key should be a PEM or plain PKSC1 or PKCS8; parse error: %v", err)
+			}
 		}
 	}
-	parsed, ok := parsedKey.(*rsa.PrivateKey)
-	if !ok {
-		return nil, errors.New("private key is not an RSA key")
+	switch parsed := parsedKey.(type) {
+	case *rsa.PrivateKey:
+		return parsed, nil
+	case *ecdsa.PrivateKey:
+		if parsed.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("EC private key must use the P-256 curve; got %q", parsed.Curve.Params().Name)
+		}
+		return parsed, nil
+	default:
+		return nil, errors.New("private key is not an RSA or EC key")
 	}
-	return parsed, nil
 }
 
-func verifySignature(parts []string, k *publicKey) error {
+func verifySignature(parts []string, k *PublicKey) error {
 	content := parts[0] + "." + parts[1]
 	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
 	if err != nil {
@@ -220,7 +379,45 @@ func verifySignature(parts []string, k *publicKey) error {
 
 type serviceAcctSigner struct {
 	email string
-	pk    *rsa.PrivateKey
+	keyID string
+	pk    crypto.Signer
+}
+
+// newServiceAcctSignerFromFile creates a serviceAcctSigner from the service account JSON key file
+// at the given path. The file must contain both the "client_email" and the "private_key" fields.
+func newServiceAcctSignerFromFile(path string) (serviceAcctSigner, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return serviceAcctSigner{}, fmt.Errorf("failed to read service account file: %v", err)
+	}
+	var svcAcct struct {
+		ClientEmail  string `json:"client_email"`
+		PrivateKey   string `json:"private_key"`
+		PrivateKeyID string `json:"private_key_id"`
+	}
+	if err := json.Unmarshal(b, &svcAcct); err != nil {
+		return serviceAcctSigner{}, fmt.Errorf("failed to parse service account file %q: %v", path, err)
+	}
+	if svcAcct.ClientEmail == "" {
+		return serviceAcctSigner{}, fmt.Errorf("service account file %q has no 'client_email' field", path)
+	}
+	if svcAcct.PrivateKey == "" {
+		return serviceAcctSigner{}, fmt.Errorf("service account file %q has no 'private_key' field", path)
+	}
+	pk, err := parsePrivateKey(svcAcct.PrivateKey)
+	if err != nil {
+		return serviceAcctSigner{}, err
+	}
+	return serviceAcctSigner{email: svcAcct.ClientEmail, keyID: svcAcct.PrivateKeyID, pk: pk}, nil
+}
+
+// Algorithm returns the JWT signing algorithm that corresponds to the type of the private key.
+// "RS256" is returned for RSA keys, and also when no private key is available.
+func (s serviceAcctSigner) Algorithm() string {
+	if _, ok := s.pk.(*ecdsa.PrivateKey); ok {
+		return "ES256"
+	}
+	return "RS256"
 }
 
 func (s serviceAcctSigner) Email(ctx context.Context) (string, error) {
@@ -230,11 +427,61 @@ func (s serviceAcctSigner) Email(ctx context.Context) (string, error) {
 	return s.email, nil
 }
 
+// KeyID returns the ID of the service account key, as specified by the "private_key_id" field of
+// the service account JSON key. Google publishes the public key of the service account under this
+// ID. Returns an empty string if the ID is not known.
+func (s serviceAcctSigner) KeyID() string {
+	return s.keyID
+}
+
 func (s serviceAcctSigner) Sign(ctx context.Context, ss []byte) ([]byte, error) {
 	if s.pk == nil {
 		return nil, errors.New("private key not available")
 	}
 	hash := sha256.New()
 	hash.Write([]byte(ss))
-	return rsa.SignPKCS1v15(rand.Reader, s.pk, crypto.SHA256, hash.Sum(nil))
+	digest := hash.Sum(nil)
+	switch pk := s.pk.(type) {
+	case *ecdsa.PrivateKey:
+		return signES256(pk, digest)
+	case *rsa.PrivateKey:
+		return rsa.SignPKCS1v15(rand.Reader, pk, crypto.SHA256, digest)
+	default:
+		return nil, fmt.Errorf("unsupported private key type: %T", s.pk)
+	}
+}
+
+// emulatedSigner is the signer used when the Client is connected to the Auth emulator, and no
+// service account key is available. The emulator accepts unsigned custom tokens.
+type emulatedSigner struct{}
+
+func (s emulatedSigner) Algorithm() string {
+	return "none"
+}
+
+func (s emulatedSigner) Email(ctx context.Context) (string, error) {
+	return emulatedServiceAccount, nil
+}
+
+func (s emulatedSigner) KeyID() string {
+	return ""
+}
+
+func (s emulatedSigner) Sign(ctx context.Context, b []byte) ([]byte, error) {
+	return []byte{}, nil
+}
+
+// signES256 signs the given digest, and encodes the signature as the fixed-length concatenation
+// of r and s required by JWS (RFC 7518, section 3.4).
+func signES256(pk *ecdsa.PrivateKey, digest []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, pk, digest)
+	if err != nil {
+		return nil, err
+	}
+	const size = 32
+	sig := make([]byte, 2*size)
+	rb, sb := r.Bytes(), s.Bytes()
+	copy(sig[size-len(rb):size], rb)
+	copy(sig[2*size-len(sb):], sb)
+	return sig, nil
 }
@@ -0,0 +1,198 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/context"
+
+	"firebase.google.com/go/internal"
+)
+
+// ActionCodeSettings specifies the required continue/state URL with optional Android and iOS
+// settings. Used when invoking the email action link generation APIs.
+//
+// URL is the continue URL, and is required. AndroidInstallApp and AndroidMinimumVersion may only
+// be specified along with AndroidPackageName.
+type ActionCodeSettings struct {
+	URL                   string
+	HandleCodeInApp       bool
+	IOSBundleID           string
+	AndroidPackageName    string
+	AndroidMinimumVersion string
+	AndroidInstallApp     bool
+	DynamicLinkDomain     string
+}
+
+func (settings *ActionCodeSettings) toMap() (map[string]interface{}, error) {
+	if settings.URL == "" {
+		return nil, errors.New("URL must not be empty")
+	}
+	if u, err := url.ParseRequestURI(settings.URL); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("malformed url string: %q", settings.URL)
+	}
+	if settings.AndroidPackageName == "" &&
+		(settings.AndroidMinimumVersion != "" || settings.AndroidInstallApp) {
+		return nil, errors.New("Android package name is required when specifying other Android settings")
+	}
+
+	result := map[string]interface{}{
+		"continueUrl":        settings.URL,
+		"canHandleCodeInApp": settings.HandleCodeInApp,
+	}
+	if settings.IOSBundleID != "" {
+		result["iOSBundleId"] = settings.IOSBundleID
+	}
+	if settings.AndroidPackageName != "" {
+		result["androidPackageName"] = settings.AndroidPackageName
+		result["androidInstallApp"] = settings.AndroidInstallApp
+		if settings.AndroidMinimumVersion != "" {
+			result["androidMinimumVersion"] = settings.AndroidMinimumVersion
+		}
+	}
+	if settings.DynamicLinkDomain != "" {
+		result["dynamicLinkDomain"] = settings.DynamicLinkDomain
+	}
+	return result, nil
+}
+
+type linkType string
+
+const (
+	emailLinkSignIn   linkType = "EMAIL_SIGNIN"
+	emailVerification linkType = "VERIFY_EMAIL"
+	passwordReset     linkType = "PASSWORD_RESET"
+)
+
+// EmailVerificationLink generates the out-of-band email action link for email verification flows
+// for the specified email address.
+//
+// The returned link can be sent to the user by any means, such as a custom email template.
+func (c *Client) EmailVerificationLink(ctx context.Context, email string) (string, error) {
+	return c.EmailVerificationLinkWithSettings(ctx, email, nil)
+}
+
+// EmailVerificationLinkWithSettings generates the out-of-band email action link for email
+// verification flows for the specified email address, using the action code settings provided.
+func (c *Client) EmailVerificationLinkWithSettings(ctx context.Context, email string, settings *ActionCodeSettings) (string, error) {
+	return c.generateEmailActionLink(ctx, emailVerification, email, settings)
+}
+
+// PasswordResetLink generates the out-of-band email action link for password reset flows for
+// the specified email address.
+//
+// The returned link can be sent to the user by any means, such as a custom email template.
+func (c *Client) PasswordResetLink(ctx context.Context, email string) (string, error) {
+	return c.PasswordResetLinkWithSettings(ctx, email, nil)
+}
+
+// PasswordResetLinkWithSettings generates the out-of-band email action link for password reset
+// flows for the specified email address, using the action code settings provided.
+func (c *Client) PasswordResetLinkWithSettings(ctx context.Context, email string, settings *ActionCodeSettings) (string, error) {
+	return c.generateEmailActionLink(ctx, passwordReset, email, settings)
+}
+
+// EmailSignInLink generates the out-of-band email action link for email link sign-in flows for the
+// specified email address, using the action code settings provided.
+//
+// Email link sign-in must be completed in the app, so settings are required, and must have
+// HandleCodeInApp set to true along with a non-empty URL.
+func (c *Client) EmailSignInLink(ctx context.Context, email string, settings *ActionCodeSettings) (string, error) {
+	if settings == nil {
+		return "", errors.New("ActionCodeSettings must not be nil when generating sign-in links")
+	}
+	if !settings.HandleCodeInApp {
+		return "", errors.New("HandleCodeInApp must be true when generating sign-in links")
+	}
+	return c.generateEmailActionLink(ctx, emailLinkSignIn, email, settings)
+}
+
+// VerifyEmailAndGenerateSignInLink marks the email address of the user account with the specified
+// email as verified, and then generates an out-of-band email action link for email link sign-in
+// flows for the same address, using the action code settings provided.
+//
+// The arguments are validated before any change is made to the user account. The two steps are
+// not atomic however: if generating the link fails, the email address remains marked as verified.
+// The returned error indicates which step failed. Errors returned by the backend retain their
+// error codes, and can be checked with functions such as IsUserNotFound().
+func (c *Client) VerifyEmailAndGenerateSignInLink(ctx context.Context, email string, settings *ActionCodeSettings) (string, error) {
+	if err := validateEmail(email); err != nil {
+		return "", err
+	}
+	if settings == nil {
+		return "", errors.New("ActionCodeSettings must not be nil when generating sign-in links")
+	}
+	if !settings.HandleCodeInApp {
+		return "", errors.New("HandleCodeInApp must be true when generating sign-in links")
+	}
+	if _, err := settings.toMap(); err != nil {
+		return "", err
+	}
+
+	user, err := c.GetUserByEmail(ctx, email)
+	if err != nil {
+		return "", stepError("failed to look up user", err)
+	}
+	if err := c.updateUser(ctx, user.UID, (&UserToUpdate{}).EmailVerified(true)); err != nil {
+		return "", stepError("failed to mark email as verified", err)
+	}
+	link, err := c.generateEmailActionLink(ctx, emailLinkSignIn, email, settings)
+	if err != nil {
+		return "", stepError("email marked as verified, but failed to generate sign-in link", err)
+	}
+	return link, nil
+}
+
+// stepError prefixes the message of err with the description of the step that failed, retaining
+// the error code of the original error.
+func stepError(step string, err error) error {
+	if fe, ok := err.(*internal.FirebaseError); ok {
+		return internal.Errorf(fe.Code, "%s: %s", step, fe.String)
+	}
+	return fmt.Errorf("%s: %v", step, err)
+}
+
+func (c *Client) generateEmailActionLink(ctx context.Context, linkType linkType, email string, settings *ActionCodeSettings) (string, error) {
+	if err := validateEmail(email); err != nil {
+		return "", err
+	}
+
+	payload := map[string]interface{}{
+		"requestType":   linkType,
+		"email":         email,
+		"returnOobLink": true,
+	}
+	if settings != nil {
+		settingsMap, err := settings.toMap()
+		if err != nil {
+			return "", err
+		}
+		for k, v := range settingsMap {
+			payload[k] = v
+		}
+	}
+
+	var resp struct {
+		OOBLink string `json:"oobLink"`
+	}
+	if err := c.makeHTTPCall(ctx, http.MethodPost, "/accounts:sendOobCode", payload, &resp); err != nil {
+		return "", err
+	}
+	return resp.OOBLink, nil
+}
@@ -0,0 +1,343 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	testActionLink = "https://test.link"
+	testEmail      = "user@domain.com"
+)
+
+var testActionLinkResponse = []byte(`{"oobLink": "https://test.link"}`)
+
+var testActionCodeSettings = &ActionCodeSettings{
+	URL:                   "https://example.dynamic.link",
+	HandleCodeInApp:       true,
+	DynamicLinkDomain:     "custom.page.link",
+	IOSBundleID:           "com.example.ios",
+	AndroidPackageName:    "com.example.android",
+	AndroidInstallApp:     true,
+	AndroidMinimumVersion: "6",
+}
+
+var testActionCodeSettingsMap = map[string]interface{}{
+	"continueUrl":           "https://example.dynamic.link",
+	"canHandleCodeInApp":    true,
+	"dynamicLinkDomain":     "custom.page.link",
+	"iOSBundleId":           "com.example.ios",
+	"androidPackageName":    "com.example.android",
+	"androidInstallApp":     true,
+	"androidMinimumVersion": "6",
+}
+
+var invalidActionCodeSettings = []struct {
+	name     string
+	settings *ActionCodeSettings
+}{
+	{"no-url", &ActionCodeSettings{}},
+	{"malformed-url", &ActionCodeSettings{URL: "not a url"}},
+	{"relative-url", &ActionCodeSettings{URL: "/path/only"}},
+	{"no-android-package-name-install", &ActionCodeSettings{
+		URL:               "https://example.dynamic.link",
+		AndroidInstallApp: true,
+	}},
+	{"no-android-package-name-version", &ActionCodeSettings{
+		URL:                   "https://example.dynamic.link",
+		AndroidMinimumVersion: "6",
+	}},
+}
+
+func TestPasswordResetLink(t *testing.T) {
+	s := echoServer(testActionLinkResponse, t)
+	defer s.Close()
+
+	link, err := s.Client.PasswordResetLink(context.Background(), testEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != testActionLink {
+		t.Errorf("PasswordResetLink() = %q; want = %q", link, testActionLink)
+	}
+
+	want := map[string]interface{}{
+		"requestType":   "PASSWORD_RESET",
+		"email":         testEmail,
+		"returnOobLink": true,
+	}
+	checkActionLinkRequest(want, s, t)
+}
+
+func TestPasswordResetLinkWithSettings(t *testing.T) {
+	s := echoServer(testActionLinkResponse, t)
+	defer s.Close()
+
+	link, err := s.Client.PasswordResetLinkWithSettings(context.Background(), testEmail, testActionCodeSettings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != testActionLink {
+		t.Errorf("PasswordResetLinkWithSettings() = %q; want = %q", link, testActionLink)
+	}
+
+	want := map[string]interface{}{
+		"requestType":   "PASSWORD_RESET",
+		"email":         testEmail,
+		"returnOobLink": true,
+	}
+	for k, v := range testActionCodeSettingsMap {
+		want[k] = v
+	}
+	checkActionLinkRequest(want, s, t)
+}
+
+func TestPasswordResetLinkInvalidSettings(t *testing.T) {
+	for _, tc := range invalidActionCodeSettings {
+		link, err := client.PasswordResetLinkWithSettings(context.Background(), testEmail, tc.settings)
+		if link != "" || err == nil {
+			t.Errorf("PasswordResetLinkWithSettings(%q) = (%q, %v); want = (\"\", error)", tc.name, link, err)
+		}
+	}
+}
+
+func TestPasswordResetLinkInvalidEmail(t *testing.T) {
+	for _, email := range []string{"", "not-an-email"} {
+		link, err := client.PasswordResetLink(context.Background(), email)
+		if link != "" || err == nil {
+			t.Errorf("PasswordResetLink(%q) = (%q, %v); want = (\"\", error)", email, link, err)
+		}
+	}
+}
+
+func TestPasswordResetLinkError(t *testing.T) {
+	s := echoServer([]byte(`{"error": {"message": "EMAIL_NOT_FOUND"}}`), t)
+	defer s.Close()
+	s.Status = http.StatusBadRequest
+
+	link, err := s.Client.PasswordResetLink(context.Background(), testEmail)
+	if link != "" || !IsUserNotFound(err) {
+		t.Errorf("PasswordResetLink() = (%q, %v); want = (\"\", user-not-found)", link, err)
+	}
+}
+
+func TestEmailVerificationLink(t *testing.T) {
+	s := echoServer(testActionLinkResponse, t)
+	defer s.Close()
+
+	link, err := s.Client.EmailVerificationLink(context.Background(), testEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != testActionLink {
+		t.Errorf("EmailVerificationLink() = %q; want = %q", link, testActionLink)
+	}
+
+	want := map[string]interface{}{
+		"requestType":   "VERIFY_EMAIL",
+		"email":         testEmail,
+		"returnOobLink": true,
+	}
+	checkActionLinkRequest(want, s, t)
+}
+
+func TestEmailVerificationLinkWithSettings(t *testing.T) {
+	s := echoServer(testActionLinkResponse, t)
+	defer s.Close()
+
+	link, err := s.Client.EmailVerificationLinkWithSettings(context.Background(), testEmail, testActionCodeSettings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != testActionLink {
+		t.Errorf("EmailVerificationLinkWithSettings() = %q; want = %q", link, testActionLink)
+	}
+
+	want := map[string]interface{}{
+		"requestType":   "VERIFY_EMAIL",
+		"email":         testEmail,
+		"returnOobLink": true,
+	}
+	for k, v := range testActionCodeSettingsMap {
+		want[k] = v
+	}
+	checkActionLinkRequest(want, s, t)
+}
+
+func TestEmailVerificationLinkInvalidSettings(t *testing.T) {
+	for _, tc := range invalidActionCodeSettings {
+		link, err := client.EmailVerificationLinkWithSettings(context.Background(), testEmail, tc.settings)
+		if link != "" || err == nil {
+			t.Errorf("EmailVerificationLinkWithSettings(%q) = (%q, %v); want = (\"\", error)", tc.name, link, err)
+		}
+	}
+}
+
+func TestEmailSignInLink(t *testing.T) {
+	s := echoServer(testActionLinkResponse, t)
+	defer s.Close()
+
+	link, err := s.Client.EmailSignInLink(context.Background(), testEmail, testActionCodeSettings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != testActionLink {
+		t.Errorf("EmailSignInLink() = %q; want = %q", link, testActionLink)
+	}
+
+	want := map[string]interface{}{
+		"requestType":   "EMAIL_SIGNIN",
+		"email":         testEmail,
+		"returnOobLink": true,
+	}
+	for k, v := range testActionCodeSettingsMap {
+		want[k] = v
+	}
+	checkActionLinkRequest(want, s, t)
+}
+
+func TestEmailSignInLinkInvalidSettings(t *testing.T) {
+	cases := append([]struct {
+		name     string
+		settings *ActionCodeSettings
+	}{
+		{"nil-settings", nil},
+		{"no-handle-code-in-app", &ActionCodeSettings{URL: "https://example.dynamic.link"}},
+	}, invalidActionCodeSettings...)
+	for _, tc := range cases {
+		if tc.settings != nil && tc.name != "no-handle-code-in-app" {
+			settings := *tc.settings
+			settings.HandleCodeInApp = true
+			tc.settings = &settings
+		}
+		link, err := client.EmailSignInLink(context.Background(), testEmail, tc.settings)
+		if link != "" || err == nil {
+			t.Errorf("EmailSignInLink(%q) = (%q, %v); want = (\"\", error)", tc.name, link, err)
+		}
+	}
+}
+
+func TestEmailSignInLinkInvalidEmail(t *testing.T) {
+	link, err := client.EmailSignInLink(context.Background(), "", testActionCodeSettings)
+	if link != "" || err == nil {
+		t.Errorf("EmailSignInLink('') = (%q, %v); want = (\"\", error)", link, err)
+	}
+}
+
+func TestVerifyEmailAndGenerateSignInLink(t *testing.T) {
+	resp := `{
+		"users": [{"localId": "testuser", "email": "user@domain.com"}],
+		"localId": "testuser",
+		"oobLink": "https://test.link"
+	}`
+	s := echoServer([]byte(resp), t)
+	defer s.Close()
+
+	link, err := s.Client.VerifyEmailAndGenerateSignInLink(context.Background(), testEmail, testActionCodeSettings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != testActionLink {
+		t.Errorf("VerifyEmailAndGenerateSignInLink() = %q; want = %q", link, testActionLink)
+	}
+
+	wantPaths := []string{"/getAccountInfo", "/setAccountInfo", "/projects/mock-project-id/accounts:sendOobCode"}
+	if len(s.Req) != len(wantPaths) {
+		t.Fatalf("VerifyEmailAndGenerateSignInLink() requests = %d; want = %d", len(s.Req), len(wantPaths))
+	}
+	for i, want := range wantPaths {
+		if s.Req[i].URL.Path != want {
+			t.Errorf("Request[%d] URL = %q; want = %q", i, s.Req[i].URL.Path, want)
+		}
+	}
+	want := map[string]interface{}{
+		"requestType":   "EMAIL_SIGNIN",
+		"email":         testEmail,
+		"returnOobLink": true,
+	}
+	for k, v := range testActionCodeSettingsMap {
+		want[k] = v
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(s.Rbody, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sendOobCode body = %v; want = %v", got, want)
+	}
+}
+
+func TestVerifyEmailAndGenerateSignInLinkUserNotFound(t *testing.T) {
+	s := echoServer([]byte(`{"users": []}`), t)
+	defer s.Close()
+
+	link, err := s.Client.VerifyEmailAndGenerateSignInLink(context.Background(), testEmail, testActionCodeSettings)
+	if link != "" || !IsUserNotFound(err) {
+		t.Errorf("VerifyEmailAndGenerateSignInLink() = (%q, %v); want = (\"\", user-not-found)", link, err)
+	}
+	if err != nil && !strings.HasPrefix(err.Error(), "failed to look up user: ") {
+		t.Errorf("VerifyEmailAndGenerateSignInLink() err = %q; want = step error", err.Error())
+	}
+	if len(s.Req) != 1 {
+		t.Errorf("VerifyEmailAndGenerateSignInLink() requests = %d; want = 1", len(s.Req))
+	}
+}
+
+func TestVerifyEmailAndGenerateSignInLinkInvalidArgs(t *testing.T) {
+	s := echoServer(testActionLinkResponse, t)
+	defer s.Close()
+
+	cases := []struct {
+		name     string
+		email    string
+		settings *ActionCodeSettings
+	}{
+		{"no-email", "", testActionCodeSettings},
+		{"nil-settings", testEmail, nil},
+		{"no-handle-code-in-app", testEmail, &ActionCodeSettings{URL: "https://example.dynamic.link"}},
+		{"no-url", testEmail, &ActionCodeSettings{HandleCodeInApp: true}},
+	}
+	for _, tc := range cases {
+		link, err := s.Client.VerifyEmailAndGenerateSignInLink(context.Background(), tc.email, tc.settings)
+		if link != "" || err == nil {
+			t.Errorf("VerifyEmailAndGenerateSignInLink(%q) = (%q, %v); want = (\"\", error)", tc.name, link, err)
+		}
+	}
+	if len(s.Req) != 0 {
+		t.Errorf("VerifyEmailAndGenerateSignInLink() requests = %d; want = 0", len(s.Req))
+	}
+}
+
+func checkActionLinkRequest(want map[string]interface{}, s *mockAuthServer, t *testing.T) {
+	wantURL := "/projects/mock-project-id/accounts:sendOobCode"
+	if s.Req[0].URL.Path != wantURL {
+		t.Errorf("sendOobCode URL = %q; want = %q", s.Req[0].URL.Path, wantURL)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(s.Rbody, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sendOobCode body = %v; want = %v", got, want)
+	}
+}
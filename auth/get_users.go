@@ -0,0 +1,196 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/api/identitytoolkit/v3"
+)
+
+// UserIdentifier identifies a user to be looked up by GetUsers().
+//
+// UserIdentifier is implemented by UIDIdentifier, EmailIdentifier, PhoneIdentifier and
+// ProviderIdentifier.
+type UserIdentifier interface {
+	validate() error
+	matches(u *UserRecord) bool
+	populate(req *getAccountInfoRequest)
+}
+
+// UIDIdentifier identifies a user by user ID.
+type UIDIdentifier struct {
+	UID string
+}
+
+func (id UIDIdentifier) validate() error {
+	return validateUID(id.UID)
+}
+
+func (id UIDIdentifier) matches(u *UserRecord) bool {
+	return id.UID == u.UID
+}
+
+func (id UIDIdentifier) populate(req *getAccountInfoRequest) {
+	req.LocalID = append(req.LocalID, id.UID)
+}
+
+// EmailIdentifier identifies a user by email address.
+type EmailIdentifier struct {
+	Email string
+}
+
+func (id EmailIdentifier) validate() error {
+	return validateEmail(id.Email)
+}
+
+// matches compares the emails case-insensitively, since the backend normalizes the case of the
+// emails it returns.
+func (id EmailIdentifier) matches(u *UserRecord) bool {
+	return strings.EqualFold(id.Email, u.Email)
+}
+
+func (id EmailIdentifier) populate(req *getAccountInfoRequest) {
+	req.Email = append(req.Email, id.Email)
+}
+
+// PhoneIdentifier identifies a user by phone number.
+type PhoneIdentifier struct {
+	PhoneNumber string
+}
+
+func (id PhoneIdentifier) validate() error {
+	return validatePhone(id.PhoneNumber)
+}
+
+func (id PhoneIdentifier) matches(u *UserRecord) bool {
+	return id.PhoneNumber == u.PhoneNumber
+}
+
+func (id PhoneIdentifier) populate(req *getAccountInfoRequest) {
+	req.PhoneNumber = append(req.PhoneNumber, id.PhoneNumber)
+}
+
+// ProviderIdentifier identifies a user by the user ID assigned to them by a federated identity
+// provider (e.g. google.com).
+type ProviderIdentifier struct {
+	ProviderID  string
+	ProviderUID string
+}
+
+func (id ProviderIdentifier) validate() error {
+	if id.ProviderID == "" {
+		return errors.New("provider id must be a non-empty string")
+	}
+	if id.ProviderUID == "" {
+		return errors.New("provider uid must be a non-empty string")
+	}
+	return nil
+}
+
+func (id ProviderIdentifier) matches(u *UserRecord) bool {
+	for _, info := range u.ProviderUserInfo {
+		if id.ProviderID == info.ProviderID && id.ProviderUID == info.UID {
+			return true
+		}
+	}
+	return false
+}
+
+func (id ProviderIdentifier) populate(req *getAccountInfoRequest) {
+	req.FederatedUserID = append(req.FederatedUserID, &federatedUserIdentifier{
+		ProviderID: id.ProviderID,
+		RawID:      id.ProviderUID,
+	})
+}
+
+// GetUsersResult represents the result of the GetUsers() API.
+//
+// Users contains the records of the users that were found. NotFound contains the identifiers that
+// did not match any user.
+type GetUsersResult struct {
+	Users    []*UserRecord
+	NotFound []UserIdentifier
+}
+
+type getAccountInfoRequest struct {
+	LocalID         []string                   `json:"localId,omitempty"`
+	Email           []string                   `json:"email,omitempty"`
+	PhoneNumber     []string                   `json:"phoneNumber,omitempty"`
+	FederatedUserID []*federatedUserIdentifier `json:"federatedUserId,omitempty"`
+}
+
+type federatedUserIdentifier struct {
+	ProviderID string `json:"providerId"`
+	RawID      string `json:"rawId"`
+}
+
+// GetUsers gets the user data corresponding to the specified identifiers.
+//
+// All the identifiers are resolved with a single call to the backend. At most 100 identifiers may
+// be specified. The order of the returned users is not guaranteed to match the order of the
+// identifiers. Identifiers that do not match any user are returned in GetUsersResult.NotFound,
+// instead of causing an error.
+func (c *Client) GetUsers(ctx context.Context, identifiers []UserIdentifier) (*GetUsersResult, error) {
+	if len(identifiers) == 0 {
+		return &GetUsersResult{}, nil
+	}
+	if len(identifiers) > maxLookupBatchSize {
+		return nil, fmt.Errorf("identifiers must not contain more than %d elements", maxLookupBatchSize)
+	}
+
+	request := &getAccountInfoRequest{}
+	for i, id := range identifiers {
+		if id == nil {
+			return nil, fmt.Errorf("identifier at index %d must not be nil", i)
+		}
+		if err := id.validate(); err != nil {
+			return nil, fmt.Errorf("identifier at index %d: %v", i, err)
+		}
+		id.populate(request)
+	}
+
+	var resp identitytoolkit.GetAccountInfoResponse
+	if err := c.makeHTTPCall(ctx, http.MethodPost, "/accounts:lookup", request, &resp); err != nil {
+		return nil, err
+	}
+
+	result := &GetUsersResult{}
+	for _, u := range resp.Users {
+		eu, err := makeExportedUser(u)
+		if err != nil {
+			return nil, err
+		}
+		result.Users = append(result.Users, eu.UserRecord)
+	}
+	for _, id := range identifiers {
+		found := false
+		for _, u := range result.Users {
+			if id.matches(u) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result.NotFound = append(result.NotFound, id)
+		}
+	}
+	return result, nil
+}
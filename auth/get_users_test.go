@@ -0,0 +1,171 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+var testGetUsersResponse = []byte(`{
+	"users": [
+		{"localId": "uid1", "email": "user1@example.com"},
+		{"localId": "uid2", "phoneNumber": "+15555550002"},
+		{
+			"localId": "uid3",
+			"providerUserInfo": [{"providerId": "google.com", "rawId": "google_uid3"}]
+		}
+	]
+}`)
+
+func TestGetUsers(t *testing.T) {
+	s := echoServer(testGetUsersResponse, t)
+	defer s.Close()
+
+	identifiers := []UserIdentifier{
+		UIDIdentifier{"uid1"},
+		EmailIdentifier{"user1@example.com"},
+		PhoneIdentifier{"+15555550002"},
+		ProviderIdentifier{"google.com", "google_uid3"},
+		UIDIdentifier{"uid4"},
+		EmailIdentifier{"user5@example.com"},
+	}
+	result, err := s.Client.GetUsers(ctx, identifiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var uids []string
+	for _, u := range result.Users {
+		uids = append(uids, u.UID)
+	}
+	if want := []string{"uid1", "uid2", "uid3"}; !reflect.DeepEqual(uids, want) {
+		t.Errorf("GetUsers() = %v; want = %v", uids, want)
+	}
+	wantNotFound := []UserIdentifier{UIDIdentifier{"uid4"}, EmailIdentifier{"user5@example.com"}}
+	if !reflect.DeepEqual(result.NotFound, wantNotFound) {
+		t.Errorf("GetUsers().NotFound = %v; want = %v", result.NotFound, wantNotFound)
+	}
+
+	if len(s.Req) != 1 {
+		t.Fatalf("Requests = %d; want = 1", len(s.Req))
+	}
+	checkRequest(s, http.MethodPost, "/projects/mock-project-id/accounts:lookup", t)
+	var got map[string]interface{}
+	if err := json.Unmarshal(s.Rbody, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"localId":     []interface{}{"uid1", "uid4"},
+		"email":       []interface{}{"user1@example.com", "user5@example.com"},
+		"phoneNumber": []interface{}{"+15555550002"},
+		"federatedUserId": []interface{}{
+			map[string]interface{}{"providerId": "google.com", "rawId": "google_uid3"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetUsers() request = %v; want = %v", got, want)
+	}
+}
+
+func TestGetUsersMixedCaseEmail(t *testing.T) {
+	s := echoServer([]byte(`{"users": [{"localId": "uid1", "email": "user1@example.com"}]}`), t)
+	defer s.Close()
+
+	identifiers := []UserIdentifier{EmailIdentifier{"User1@Example.com"}}
+	result, err := s.Client.GetUsers(ctx, identifiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Users) != 1 || result.Users[0].UID != "uid1" {
+		t.Errorf("GetUsers() = %v; want = [uid1]", result.Users)
+	}
+	if len(result.NotFound) != 0 {
+		t.Errorf("GetUsers().NotFound = %v; want = []", result.NotFound)
+	}
+}
+
+func TestGetUsersEmpty(t *testing.T) {
+	s := echoServer(testGetUsersResponse, t)
+	defer s.Close()
+
+	result, err := s.Client.GetUsers(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Users) != 0 || len(result.NotFound) != 0 {
+		t.Errorf("GetUsers() = %v; want = empty", result)
+	}
+	if len(s.Req) != 0 {
+		t.Errorf("Requests = %d; want = 0", len(s.Req))
+	}
+}
+
+func TestGetUsersNoneFound(t *testing.T) {
+	s := echoServer([]byte("{}"), t)
+	defer s.Close()
+
+	identifiers := []UserIdentifier{UIDIdentifier{"uid1"}, PhoneIdentifier{"+15555550001"}}
+	result, err := s.Client.GetUsers(ctx, identifiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Users) != 0 {
+		t.Errorf("GetUsers() = %d users; want = 0", len(result.Users))
+	}
+	if !reflect.DeepEqual(result.NotFound, identifiers) {
+		t.Errorf("GetUsers().NotFound = %v; want = %v", result.NotFound, identifiers)
+	}
+}
+
+func TestGetUsersInvalidIdentifiers(t *testing.T) {
+	var tooMany []UserIdentifier
+	for i := 0; i < 101; i++ {
+		tooMany = append(tooMany, UIDIdentifier{fmt.Sprintf("uid%d", i)})
+	}
+
+	cases := []struct {
+		name        string
+		identifiers []UserIdentifier
+	}{
+		{"TooMany", tooMany},
+		{"Nil", []UserIdentifier{nil}},
+		{"EmptyUID", []UserIdentifier{UIDIdentifier{""}}},
+		{"MalformedEmail", []UserIdentifier{EmailIdentifier{"not-an-email"}}},
+		{"MalformedPhone", []UserIdentifier{PhoneIdentifier{"not-a-phone"}}},
+		{"EmptyProviderID", []UserIdentifier{ProviderIdentifier{"", "uid"}}},
+		{"EmptyProviderUID", []UserIdentifier{ProviderIdentifier{"google.com", ""}}},
+	}
+	for _, tc := range cases {
+		result, err := client.GetUsers(ctx, tc.identifiers)
+		if result != nil || err == nil {
+			t.Errorf("GetUsers(%s) = (%v, %v); want = (nil, error)", tc.name, result, err)
+		}
+	}
+}
+
+func TestGetUsersHTTPError(t *testing.T) {
+	s := echoServer([]byte(`{"error":{"message":"INSUFFICIENT_PERMISSION"}}`), t)
+	defer s.Close()
+	s.Status = http.StatusUnauthorized
+
+	result, err := s.Client.GetUsers(ctx, []UserIdentifier{UIDIdentifier{"uid1"}})
+	if result != nil || !IsInsufficientPermission(err) {
+		t.Errorf("GetUsers() = (%v, %v); want = (nil, insufficient-permission)", result, err)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"golang.org/x/net/context"
+
+	"google.golang.org/api/identitytoolkit/v3"
+)
+
+// identitytoolkitService is the subset of the Identity Toolkit v3 relyingparty API used by the
+// Client.
+//
+// It allows the user management functions to be tested against a fake implementation, without
+// making calls to Google servers. Implementations return the errors of the underlying API as is;
+// the Client is responsible for translating them into SDK errors.
+type identitytoolkitService interface {
+	deleteAccount(ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartyDeleteAccountRequest) (*identitytoolkit.DeleteAccountResponse, error)
+	downloadAccount(ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartyDownloadAccountRequest) (*identitytoolkit.DownloadAccountResponse, error)
+	getAccountInfo(ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartyGetAccountInfoRequest) (*identitytoolkit.GetAccountInfoResponse, error)
+	setAccountInfo(ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartySetAccountInfoRequest) (*identitytoolkit.SetAccountInfoResponse, error)
+	signupNewUser(ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartySignupNewUserRequest) (*identitytoolkit.SignupNewUserResponse, error)
+	uploadAccount(ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) (*identitytoolkit.UploadAccountResponse, error)
+}
+
+// identitytoolkitClient is the default identitytoolkitService implementation, which calls the
+// Identity Toolkit service over HTTP.
+type identitytoolkitClient struct {
+	*identitytoolkit.Service
+	version string
+}
+
+func newIdentitytoolkitClient(is *identitytoolkit.Service, version string) *identitytoolkitClient {
+	return &identitytoolkitClient{Service: is, version: version}
+}
+
+func (ic *identitytoolkitClient) deleteAccount(ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartyDeleteAccountRequest) (*identitytoolkit.DeleteAccountResponse, error) {
+	call := ic.Relyingparty.DeleteAccount(req)
+	call.Header().Set("X-Client-Version", ic.version)
+	return call.Context(ctx).Do()
+}
+
+func (ic *identitytoolkitClient) downloadAccount(ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartyDownloadAccountRequest) (*identitytoolkit.DownloadAccountResponse, error) {
+	call := ic.Relyingparty.DownloadAccount(req)
+	call.Header().Set("X-Client-Version", ic.version)
+	return call.Context(ctx).Do()
+}
+
+func (ic *identitytoolkitClient) getAccountInfo(ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartyGetAccountInfoRequest) (*identitytoolkit.GetAccountInfoResponse, error) {
+	call := ic.Relyingparty.GetAccountInfo(req)
+	call.Header().Set("X-Client-Version", ic.version)
+	return call.Context(ctx).Do()
+}
+
+func (ic *identitytoolkitClient) setAccountInfo(ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartySetAccountInfoRequest) (*identitytoolkit.SetAccountInfoResponse, error) {
+	call := ic.Relyingparty.SetAccountInfo(req)
+	call.Header().Set("X-Client-Version", ic.version)
+	return call.Context(ctx).Do()
+}
+
+func (ic *identitytoolkitClient) signupNewUser(ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartySignupNewUserRequest) (*identitytoolkit.SignupNewUserResponse, error) {
+	call := ic.Relyingparty.SignupNewUser(req)
+	call.Header().Set("X-Client-Version", ic.version)
+	return call.Context(ctx).Do()
+}
+
+func (ic *identitytoolkitClient) uploadAccount(ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) (*identitytoolkit.UploadAccountResponse, error) {
+	call := ic.Relyingparty.UploadAccount(req)
+	call.Header().Set("X-Client-Version", ic.version)
+	return call.Context(ctx).Do()
+}
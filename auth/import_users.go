@@ -0,0 +1,511 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/api/identitytoolkit/v3"
+)
+
+const maxImportUsers = 1000
+
+// UserToImport represents a user account to be imported to Firebase Auth via the ImportUsers()
+// function.
+type UserToImport struct {
+	info   *identitytoolkit.UserInfo
+	claims map[string]interface{}
+	hash   []byte
+	salt   []byte
+}
+
+func (u *UserToImport) userInfo() *identitytoolkit.UserInfo {
+	if u.info == nil {
+		u.info = &identitytoolkit.UserInfo{}
+	}
+	return u.info
+}
+
+// UID setter. This field is required.
+func (u *UserToImport) UID(uid string) *UserToImport {
+	u.userInfo().LocalId = uid
+	return u
+}
+
+// Email setter.
+func (u *UserToImport) Email(email string) *UserToImport {
+	u.userInfo().Email = email
+	return u
+}
+
+// EmailVerified setter.
+func (u *UserToImport) EmailVerified(verified bool) *UserToImport {
+	u.userInfo().EmailVerified = verified
+	return u
+}
+
+// DisplayName setter.
+func (u *UserToImport) DisplayName(name string) *UserToImport {
+	u.userInfo().DisplayName = name
+	return u
+}
+
+// PhoneNumber setter.
+func (u *UserToImport) PhoneNumber(phone string) *UserToImport {
+	u.userInfo().PhoneNumber = phone
+	return u
+}
+
+// PhotoURL setter.
+func (u *UserToImport) PhotoURL(url string) *UserToImport {
+	u.userInfo().PhotoUrl = url
+	return u
+}
+
+// Disabled setter.
+func (u *UserToImport) Disabled(disabled bool) *UserToImport {
+	u.userInfo().Disabled = disabled
+	return u
+}
+
+// Metadata setter. Sets the creation and last sign-in timestamps of the user.
+func (u *UserToImport) Metadata(metadata *UserMetadata) *UserToImport {
+	info := u.userInfo()
+	info.CreatedAt = metadata.CreationTimestamp
+	info.LastLoginAt = metadata.LastLogInTimestamp
+	return u
+}
+
+// CustomClaims setter.
+func (u *UserToImport) CustomClaims(claims map[string]interface{}) *UserToImport {
+	u.claims = claims
+	return u
+}
+
+// PasswordHash setter. When set, a hash algorithm must be specified via the WithHash() option
+// of ImportUsers().
+func (u *UserToImport) PasswordHash(password []byte) *UserToImport {
+	u.hash = password
+	return u
+}
+
+// PasswordSalt setter.
+func (u *UserToImport) PasswordSalt(salt []byte) *UserToImport {
+	u.salt = salt
+	return u
+}
+
+func (u *UserToImport) validatedUserInfo() (*identitytoolkit.UserInfo, error) {
+	info := *u.userInfo()
+	if err := validateUID(info.LocalId); err != nil {
+		return nil, err
+	}
+	if info.Email != "" {
+		if err := validateEmail(info.Email); err != nil {
+			return nil, err
+		}
+	}
+	if info.PhoneNumber != "" {
+		if err := validatePhone(info.PhoneNumber); err != nil {
+			return nil, err
+		}
+	}
+	if len(u.claims) > 0 {
+		cc, err := marshalCustomClaims(u.claims)
+		if err != nil {
+			return nil, err
+		}
+		info.CustomAttributes = cc
+	}
+	if len(u.hash) > 0 {
+		info.PasswordHash = base64.RawURLEncoding.EncodeToString(u.hash)
+	}
+	if len(u.salt) > 0 {
+		info.Salt = base64.RawURLEncoding.EncodeToString(u.salt)
+	}
+	return &info, nil
+}
+
+// UserImportOption is an option for the ImportUsers() function.
+type UserImportOption func(*userImportConfig)
+
+type userImportConfig struct {
+	hash InternalHash
+}
+
+// WithHash returns a UserImportOption that specifies the hash algorithm used to generate the
+// password hashes of the imported users.
+func WithHash(hash InternalHash) UserImportOption {
+	return func(conf *userImportConfig) {
+		conf.hash = hash
+	}
+}
+
+// UserImportResult represents the result of an ImportUsers() call.
+type UserImportResult struct {
+	SuccessCount int
+	FailureCount int
+	Errors       []*UserImportErrorInfo
+}
+
+// UserImportErrorInfo represents an error encountered while importing a single user account.
+//
+// The Index field corresponds to the index of the failed user in the users slice that was passed
+// to ImportUsers().
+type UserImportErrorInfo struct {
+	Index  int
+	Reason string
+}
+
+// ImportUsers imports the given user accounts to Firebase Auth.
+//
+// No more than 1000 users can be imported in a single call. If any of the users has a password
+// hash, a hash algorithm must be specified via the WithHash() option. All users are validated
+// before any network call is made, so an invalid user in the input fails the entire operation.
+// Failures reported by the backend for individual users do not fail the operation, and are
+// instead included in the returned UserImportResult.
+func (c *Client) ImportUsers(ctx context.Context, users []*UserToImport, opts ...UserImportOption) (*UserImportResult, error) {
+	if len(users) == 0 {
+		return nil, errors.New("users list must not be empty")
+	}
+	if len(users) > maxImportUsers {
+		return nil, fmt.Errorf("users list must not contain more than %d elements; got %d",
+			maxImportUsers, len(users))
+	}
+
+	conf := &userImportConfig{}
+	for _, opt := range opts {
+		opt(conf)
+	}
+
+	request := &identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest{}
+	hashRequired := false
+	for i, u := range users {
+		if u == nil {
+			return nil, fmt.Errorf("user at index %d must not be nil", i)
+		}
+		info, err := u.validatedUserInfo()
+		if err != nil {
+			return nil, fmt.Errorf("invalid user at index %d: %v", i, err)
+		}
+		if info.PasswordHash != "" {
+			hashRequired = true
+		}
+		request.Users = append(request.Users, info)
+	}
+	if conf.hash != nil {
+		if err := conf.hash.applyTo(request); err != nil {
+			return nil, err
+		}
+	} else if hashRequired {
+		return nil, errors.New("hash algorithm option is required to import users with passwords")
+	}
+
+	resp, err := c.uploadAccount(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	result := &UserImportResult{}
+	for _, e := range resp.Error {
+		result.Errors = append(result.Errors, &UserImportErrorInfo{
+			Index:  int(e.Index),
+			Reason: e.Message,
+		})
+	}
+	result.FailureCount = len(result.Errors)
+	result.SuccessCount = len(users) - result.FailureCount
+	return result, nil
+}
+
+func (c *Client) uploadAccount(ctx context.Context, request *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) (*identitytoolkit.UploadAccountResponse, error) {
+	if c.tenantID != "" {
+		var resp identitytoolkit.UploadAccountResponse
+		if err := c.makeHTTPCall(ctx, http.MethodPost, "/accounts:batchCreate", request, &resp); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	}
+
+	var resp *identitytoolkit.UploadAccountResponse
+	err := c.retryGoogleAPI(ctx, false, func() (err error) {
+		resp, err = c.is.uploadAccount(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, handleServerError(err)
+	}
+	return resp, nil
+}
+
+// InternalHash represents a password hash algorithm supported by Firebase Auth for importing
+// users.
+//
+// The hash algorithms supported by the SDK are defined as types in this package, e.g. Scrypt,
+// StandardScrypt, Bcrypt, PBKDF2SHA256 and HMACSHA256.
+type InternalHash interface {
+	applyTo(req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) error
+}
+
+// Bcrypt represents the BCRYPT hash algorithm.
+type Bcrypt struct{}
+
+func (h Bcrypt) applyTo(req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) error {
+	req.HashAlgorithm = "BCRYPT"
+	return nil
+}
+
+// StandardScrypt represents the standard scrypt hash algorithm.
+type StandardScrypt struct {
+	BlockSize        int
+	DerivedKeyLength int
+	MemoryCost       int
+	Parallelization  int
+}
+
+func (h StandardScrypt) applyTo(req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) error {
+	req.HashAlgorithm = "STANDARD_SCRYPT"
+	req.BlockSize = int64(h.BlockSize)
+	req.DkLen = int64(h.DerivedKeyLength)
+	req.CpuMemCost = int64(h.MemoryCost)
+	req.Parallelization = int64(h.Parallelization)
+	return nil
+}
+
+// Scrypt represents the modified scrypt hash algorithm used by Firebase Auth.
+//
+// Key is the signer key used to hash the passwords, and is required. Rounds must be between 1
+// and 8, and MemoryCost between 1 and 14.
+type Scrypt struct {
+	Key           []byte
+	SaltSeparator []byte
+	Rounds        int
+	MemoryCost    int
+}
+
+func (h Scrypt) applyTo(req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) error {
+	if len(h.Key) == 0 {
+		return errors.New("signer key not specified")
+	}
+	if h.Rounds < 1 || h.Rounds > 8 {
+		return fmt.Errorf("rounds must be between 1 and 8; got %d", h.Rounds)
+	}
+	if h.MemoryCost < 1 || h.MemoryCost > 14 {
+		return fmt.Errorf("memory cost must be between 1 and 14; got %d", h.MemoryCost)
+	}
+	req.HashAlgorithm = "SCRYPT"
+	req.SignerKey = base64.RawURLEncoding.EncodeToString(h.Key)
+	req.SaltSeparator = base64.RawURLEncoding.EncodeToString(h.SaltSeparator)
+	req.Rounds = int64(h.Rounds)
+	req.MemoryCost = int64(h.MemoryCost)
+	return nil
+}
+
+// HMACMD5 represents the HMAC MD5 hash algorithm. Key is required.
+type HMACMD5 struct {
+	Key []byte
+}
+
+func (h HMACMD5) applyTo(req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) error {
+	return applyHMAC(req, "HMAC_MD5", h.Key)
+}
+
+// HMACSHA1 represents the HMAC SHA1 hash algorithm. Key is required.
+type HMACSHA1 struct {
+	Key []byte
+}
+
+func (h HMACSHA1) applyTo(req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) error {
+	return applyHMAC(req, "HMAC_SHA1", h.Key)
+}
+
+// HMACSHA256 represents the HMAC SHA256 hash algorithm. Key is required.
+type HMACSHA256 struct {
+	Key []byte
+}
+
+func (h HMACSHA256) applyTo(req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) error {
+	return applyHMAC(req, "HMAC_SHA256", h.Key)
+}
+
+// HMACSHA512 represents the HMAC SHA512 hash algorithm. Key is required.
+type HMACSHA512 struct {
+	Key []byte
+}
+
+func (h HMACSHA512) applyTo(req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) error {
+	return applyHMAC(req, "HMAC_SHA512", h.Key)
+}
+
+// MD5 represents the MD5 hash algorithm. Rounds must be between 0 and 8192.
+type MD5 struct {
+	Rounds int
+}
+
+func (h MD5) applyTo(req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) error {
+	return applyRounds(req, "MD5", h.Rounds, 0, 8192)
+}
+
+// SHA1 represents the SHA1 hash algorithm. Rounds must be between 1 and 8192.
+type SHA1 struct {
+	Rounds int
+}
+
+func (h SHA1) applyTo(req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) error {
+	return applyRounds(req, "SHA1", h.Rounds, 1, 8192)
+}
+
+// SHA256 represents the SHA256 hash algorithm. Rounds must be between 1 and 8192.
+type SHA256 struct {
+	Rounds int
+}
+
+func (h SHA256) applyTo(req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) error {
+	return applyRounds(req, "SHA256", h.Rounds, 1, 8192)
+}
+
+// SHA512 represents the SHA512 hash algorithm. Rounds must be between 1 and 8192.
+type SHA512 struct {
+	Rounds int
+}
+
+func (h SHA512) applyTo(req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) error {
+	return applyRounds(req, "SHA512", h.Rounds, 1, 8192)
+}
+
+// PBKDFSHA1 represents the PBKDF SHA1 hash algorithm. Rounds must be between 0 and 120000.
+type PBKDFSHA1 struct {
+	Rounds int
+}
+
+func (h PBKDFSHA1) applyTo(req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) error {
+	return applyRounds(req, "PBKDF_SHA1", h.Rounds, 0, 120000)
+}
+
+// PBKDF2SHA256 represents the PBKDF2 SHA256 hash algorithm. Rounds must be between 0 and 120000.
+type PBKDF2SHA256 struct {
+	Rounds int
+}
+
+func (h PBKDF2SHA256) applyTo(req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) error {
+	return applyRounds(req, "PBKDF2_SHA256", h.Rounds, 0, 120000)
+}
+
+func applyHMAC(req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest, name string, key []byte) error {
+	if len(key) == 0 {
+		return errors.New("signer key not specified")
+	}
+	req.HashAlgorithm = name
+	req.SignerKey = base64.RawURLEncoding.EncodeToString(key)
+	return nil
+}
+
+func applyRounds(req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest, name string, rounds, min, max int) error {
+	if rounds < min || rounds > max {
+		return fmt.Errorf("rounds must be between %d and %d; got %d", min, max, rounds)
+	}
+	req.HashAlgorithm = name
+	req.Rounds = int64(rounds)
+	return nil
+}
+
+// HashConfig describes the password hash algorithm that Firebase Auth uses for the user accounts
+// of a project, as returned by HashConfig().
+//
+// Together with the PasswordHash and PasswordSalt fields of an ExportedUserRecord, the parameters
+// allow verifying the passwords of exported user accounts outside of Firebase Auth.
+type HashConfig struct {
+	Algorithm     string
+	SignerKey     []byte
+	SaltSeparator []byte
+	Rounds        int
+	MemoryCost    int
+}
+
+// Scrypt returns the parameters of the HashConfig as a Scrypt hash, which can be used to import
+// the exported user accounts into another project with ImportUsers(). Returns an error if the
+// algorithm of the HashConfig is not "SCRYPT".
+func (h *HashConfig) Scrypt() (Scrypt, error) {
+	if h.Algorithm != "SCRYPT" {
+		return Scrypt{}, fmt.Errorf("hash algorithm is %q; want %q", h.Algorithm, "SCRYPT")
+	}
+	return Scrypt{
+		Key:           h.SignerKey,
+		SaltSeparator: h.SaltSeparator,
+		Rounds:        h.Rounds,
+		MemoryCost:    h.MemoryCost,
+	}, nil
+}
+
+type hashConfigDAO struct {
+	Algorithm     string `json:"algorithm"`
+	SignerKey     string `json:"signerKey"`
+	SaltSeparator string `json:"saltSeparator"`
+	Rounds        int    `json:"rounds"`
+	MemoryCost    int    `json:"memoryCost"`
+}
+
+func (dao *hashConfigDAO) toHashConfig() (*HashConfig, error) {
+	key, err := base64.StdEncoding.DecodeString(dao.SignerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signer key: %v", err)
+	}
+	sep, err := base64.StdEncoding.DecodeString(dao.SaltSeparator)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode salt separator: %v", err)
+	}
+	return &HashConfig{
+		Algorithm:     dao.Algorithm,
+		SignerKey:     key,
+		SaltSeparator: sep,
+		Rounds:        dao.Rounds,
+		MemoryCost:    dao.MemoryCost,
+	}, nil
+}
+
+// HashConfig returns the parameters of the password hash algorithm used for the user accounts of
+// the project.
+//
+// The parameters are read from the configuration of the project, which requires the credentials
+// of the Client to have permission to read it (e.g. the Firebase Authentication Admin role).
+func (c *Client) HashConfig(ctx context.Context) (*HashConfig, error) {
+	var resp struct {
+		SignIn struct {
+			HashConfig *hashConfigDAO `json:"hashConfig"`
+		} `json:"signIn"`
+		HashConfig *hashConfigDAO `json:"hashConfig"`
+	}
+	// Tenants carry their own hash config, while the config of a project is part of its sign-in
+	// settings.
+	path := "/config"
+	if c.tenantID != "" {
+		path = ""
+	}
+	if err := c.makeV2HTTPCall(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	dao := resp.SignIn.HashConfig
+	if c.tenantID != "" {
+		dao = resp.HashConfig
+	}
+	if dao == nil {
+		return nil, errors.New("hash config not available")
+	}
+	return dao.toHashConfig()
+}
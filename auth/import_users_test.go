@@ -0,0 +1,335 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestImportUsers(t *testing.T) {
+	s := echoServer([]byte("{}"), t)
+	defer s.Close()
+
+	users := []*UserToImport{
+		(&UserToImport{}).UID("user1"),
+		(&UserToImport{}).UID("user2"),
+	}
+	result, err := s.Client.ImportUsers(context.Background(), users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SuccessCount != 2 || result.FailureCount != 0 || result.Errors != nil {
+		t.Errorf("ImportUsers() = %#v; want = {SuccessCount: 2, FailureCount: 0}", result)
+	}
+
+	want := `{"users":[{"localId":"user1"},{"localId":"user2"}]}`
+	if string(s.Rbody) != want {
+		t.Errorf("ImportUsers() Req = %s; want = %s", string(s.Rbody), want)
+	}
+}
+
+func TestImportUsersError(t *testing.T) {
+	resp := `{
+		"error": [
+			{"index": 0, "message": "Some error occurred in user1"},
+			{"index": 2, "message": "Another error occurred in user3"}
+		]
+	}`
+	s := echoServer([]byte(resp), t)
+	defer s.Close()
+
+	users := []*UserToImport{
+		(&UserToImport{}).UID("user1"),
+		(&UserToImport{}).UID("user2"),
+		(&UserToImport{}).UID("user3"),
+	}
+	result, err := s.Client.ImportUsers(context.Background(), users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SuccessCount != 1 || result.FailureCount != 2 {
+		t.Errorf("ImportUsers() = (%d, %d); want = (1, 2)", result.SuccessCount, result.FailureCount)
+	}
+	want := []*UserImportErrorInfo{
+		{Index: 0, Reason: "Some error occurred in user1"},
+		{Index: 2, Reason: "Another error occurred in user3"},
+	}
+	if !reflect.DeepEqual(result.Errors, want) {
+		t.Errorf("ImportUsers() Errors = %#v; want = %#v", result.Errors, want)
+	}
+}
+
+func TestImportUsersWithHash(t *testing.T) {
+	s := echoServer([]byte("{}"), t)
+	defer s.Close()
+
+	users := []*UserToImport{
+		(&UserToImport{}).
+			UID("user1").
+			Email("user1@example.com").
+			EmailVerified(true).
+			DisplayName("Test User").
+			PhoneNumber("+11234567890").
+			PhotoURL("https://test.com/user1.png").
+			Disabled(true).
+			Metadata(&UserMetadata{CreationTimestamp: 100, LastLogInTimestamp: 150}).
+			CustomClaims(map[string]interface{}{"admin": true}).
+			PasswordHash([]byte("password")).
+			PasswordSalt([]byte("salt")),
+	}
+	scrypt := Scrypt{
+		Key:           []byte("key"),
+		SaltSeparator: []byte("sep"),
+		Rounds:        8,
+		MemoryCost:    14,
+	}
+	result, err := s.Client.ImportUsers(context.Background(), users, WithHash(scrypt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SuccessCount != 1 || result.FailureCount != 0 {
+		t.Errorf("ImportUsers() = (%d, %d); want = (1, 0)", result.SuccessCount, result.FailureCount)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(s.Rbody, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"hashAlgorithm": "SCRYPT",
+		"signerKey":     base64.RawURLEncoding.EncodeToString([]byte("key")),
+		"saltSeparator": base64.RawURLEncoding.EncodeToString([]byte("sep")),
+		"rounds":        float64(8),
+		"memoryCost":    float64(14),
+		"users": []interface{}{
+			map[string]interface{}{
+				"localId":          "user1",
+				"email":            "user1@example.com",
+				"emailVerified":    true,
+				"displayName":      "Test User",
+				"phoneNumber":      "+11234567890",
+				"photoUrl":         "https://test.com/user1.png",
+				"disabled":         true,
+				"createdAt":        "100",
+				"lastLoginAt":      "150",
+				"customAttributes": `{"admin":true}`,
+				"passwordHash":     base64.RawURLEncoding.EncodeToString([]byte("password")),
+				"salt":             base64.RawURLEncoding.EncodeToString([]byte("salt")),
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ImportUsers() Req = %v; want = %v", got, want)
+	}
+}
+
+func TestImportUsersMissingHash(t *testing.T) {
+	users := []*UserToImport{
+		(&UserToImport{}).UID("user1"),
+		(&UserToImport{}).UID("user2").PasswordHash([]byte("password")),
+	}
+	if result, err := client.ImportUsers(context.Background(), users); result != nil || err == nil {
+		t.Errorf("ImportUsers() = (%v, %v); want = (nil, error)", result, err)
+	}
+}
+
+func TestImportUsersInvalidInput(t *testing.T) {
+	tooMany := make([]*UserToImport, maxImportUsers+1)
+	for i := range tooMany {
+		tooMany[i] = (&UserToImport{}).UID("user")
+	}
+	cases := []struct {
+		name  string
+		users []*UserToImport
+	}{
+		{"NilUsers", nil},
+		{"EmptyUsers", []*UserToImport{}},
+		{"TooManyUsers", tooMany},
+		{"NilUser", []*UserToImport{nil}},
+		{"NoUID", []*UserToImport{{}}},
+		{"InvalidEmail", []*UserToImport{(&UserToImport{}).UID("user").Email("not-an-email")}},
+		{"InvalidPhone", []*UserToImport{(&UserToImport{}).UID("user").PhoneNumber("1234")}},
+		{"ReservedClaim", []*UserToImport{(&UserToImport{}).UID("user").CustomClaims(map[string]interface{}{"sub": "x"})}},
+	}
+	for _, tc := range cases {
+		if result, err := client.ImportUsers(context.Background(), tc.users); result != nil || err == nil {
+			t.Errorf("ImportUsers(%s) = (%v, %v); want = (nil, error)", tc.name, result, err)
+		}
+	}
+}
+
+func TestUserImportHash(t *testing.T) {
+	key := []byte("key")
+	encodedKey := base64.RawURLEncoding.EncodeToString(key)
+	cases := []struct {
+		hash InternalHash
+		want map[string]interface{}
+	}{
+		{Bcrypt{}, map[string]interface{}{"hashAlgorithm": "BCRYPT"}},
+		{
+			StandardScrypt{BlockSize: 8, DerivedKeyLength: 64, MemoryCost: 1024, Parallelization: 16},
+			map[string]interface{}{
+				"hashAlgorithm":   "STANDARD_SCRYPT",
+				"blockSize":       float64(8),
+				"dkLen":           float64(64),
+				"cpuMemCost":      float64(1024),
+				"parallelization": float64(16),
+			},
+		},
+		{HMACMD5{key}, map[string]interface{}{"hashAlgorithm": "HMAC_MD5", "signerKey": encodedKey}},
+		{HMACSHA1{key}, map[string]interface{}{"hashAlgorithm": "HMAC_SHA1", "signerKey": encodedKey}},
+		{HMACSHA256{key}, map[string]interface{}{"hashAlgorithm": "HMAC_SHA256", "signerKey": encodedKey}},
+		{HMACSHA512{key}, map[string]interface{}{"hashAlgorithm": "HMAC_SHA512", "signerKey": encodedKey}},
+		{MD5{10}, map[string]interface{}{"hashAlgorithm": "MD5", "rounds": float64(10)}},
+		{SHA1{10}, map[string]interface{}{"hashAlgorithm": "SHA1", "rounds": float64(10)}},
+		{SHA256{10}, map[string]interface{}{"hashAlgorithm": "SHA256", "rounds": float64(10)}},
+		{SHA512{10}, map[string]interface{}{"hashAlgorithm": "SHA512", "rounds": float64(10)}},
+		{PBKDFSHA1{10}, map[string]interface{}{"hashAlgorithm": "PBKDF_SHA1", "rounds": float64(10)}},
+		{PBKDF2SHA256{10}, map[string]interface{}{"hashAlgorithm": "PBKDF2_SHA256", "rounds": float64(10)}},
+	}
+
+	s := echoServer([]byte("{}"), t)
+	defer s.Close()
+	users := []*UserToImport{(&UserToImport{}).UID("user1").PasswordHash([]byte("password"))}
+	for _, tc := range cases {
+		if _, err := s.Client.ImportUsers(context.Background(), users, WithHash(tc.hash)); err != nil {
+			t.Errorf("ImportUsers(%T) = %v", tc.hash, err)
+			continue
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(s.Rbody, &got); err != nil {
+			t.Fatal(err)
+		}
+		delete(got, "users")
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ImportUsers(%T) Req = %v; want = %v", tc.hash, got, tc.want)
+		}
+	}
+}
+
+func TestInvalidUserImportHash(t *testing.T) {
+	cases := []InternalHash{
+		Scrypt{Rounds: 8, MemoryCost: 14},
+		Scrypt{Key: []byte("key"), Rounds: 0, MemoryCost: 14},
+		Scrypt{Key: []byte("key"), Rounds: 9, MemoryCost: 14},
+		Scrypt{Key: []byte("key"), Rounds: 8, MemoryCost: 0},
+		Scrypt{Key: []byte("key"), Rounds: 8, MemoryCost: 15},
+		HMACMD5{},
+		HMACSHA1{},
+		HMACSHA256{},
+		HMACSHA512{},
+		MD5{-1},
+		MD5{8193},
+		SHA1{0},
+		SHA256{0},
+		SHA512{8193},
+		PBKDFSHA1{-1},
+		PBKDF2SHA256{120001},
+	}
+	users := []*UserToImport{(&UserToImport{}).UID("user1").PasswordHash([]byte("password"))}
+	for _, hash := range cases {
+		if result, err := client.ImportUsers(context.Background(), users, WithHash(hash)); result != nil || err == nil {
+			t.Errorf("ImportUsers(%#v) = (%v, %v); want = (nil, error)", hash, result, err)
+		}
+	}
+}
+
+func TestHashConfig(t *testing.T) {
+	resp := map[string]interface{}{
+		"signIn": map[string]interface{}{
+			"hashConfig": map[string]interface{}{
+				"algorithm":     "SCRYPT",
+				"signerKey":     base64.StdEncoding.EncodeToString([]byte("key")),
+				"saltSeparator": base64.StdEncoding.EncodeToString([]byte("sep")),
+				"rounds":        8,
+				"memoryCost":    14,
+			},
+		},
+	}
+	s := echoServer(resp, t)
+	defer s.Close()
+
+	hc, err := s.Client.HashConfig(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &HashConfig{
+		Algorithm:     "SCRYPT",
+		SignerKey:     []byte("key"),
+		SaltSeparator: []byte("sep"),
+		Rounds:        8,
+		MemoryCost:    14,
+	}
+	if !reflect.DeepEqual(hc, want) {
+		t.Errorf("HashConfig() = %#v; want = %#v", hc, want)
+	}
+	checkRequest(s, "GET", "/projects/mock-project-id/config", t)
+
+	scrypt, err := hc.Scrypt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantScrypt := Scrypt{Key: []byte("key"), SaltSeparator: []byte("sep"), Rounds: 8, MemoryCost: 14}
+	if !reflect.DeepEqual(scrypt, wantScrypt) {
+		t.Errorf("Scrypt() = %#v; want = %#v", scrypt, wantScrypt)
+	}
+}
+
+func TestTenantHashConfig(t *testing.T) {
+	resp := map[string]interface{}{
+		"hashConfig": map[string]interface{}{
+			"algorithm": "SCRYPT",
+			"signerKey": base64.StdEncoding.EncodeToString([]byte("key")),
+			"rounds":    8,
+		},
+	}
+	s := echoServer(resp, t)
+	defer s.Close()
+
+	hc, err := tenantClient(s.Client, t).HashConfig(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hc.Algorithm != "SCRYPT" || string(hc.SignerKey) != "key" || hc.Rounds != 8 {
+		t.Errorf("HashConfig() = %#v; want = {Algorithm: SCRYPT, SignerKey: key, Rounds: 8}", hc)
+	}
+	checkRequest(s, "GET", "/projects/mock-project-id/tenants/"+testTenantID, t)
+}
+
+func TestHashConfigError(t *testing.T) {
+	cases := []map[string]interface{}{
+		{},
+		{"signIn": map[string]interface{}{"hashConfig": map[string]interface{}{"signerKey": "not base64"}}},
+	}
+	for _, resp := range cases {
+		s := echoServer(resp, t)
+		if hc, err := s.Client.HashConfig(context.Background()); hc != nil || err == nil {
+			t.Errorf("HashConfig(%v) = (%v, %v); want = (nil, error)", resp, hc, err)
+		}
+		s.Close()
+	}
+}
+
+func TestHashConfigScryptError(t *testing.T) {
+	hc := &HashConfig{Algorithm: "HMAC_SHA256"}
+	if _, err := hc.Scrypt(); err == nil {
+		t.Errorf("Scrypt() = nil; want = error")
+	}
+}
@@ -18,10 +18,10 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"strings"
 
+	"firebase.google.com/go/internal"
 	"golang.org/x/net/context"
 )
 
@@ -36,13 +36,14 @@ type jwtPayload interface {
 }
 
 type customToken struct {
-	Iss    string                 `json:"iss"`
-	Aud    string                 `json:"aud"`
-	Exp    int64                  `json:"exp"`
-	Iat    int64                  `json:"iat"`
-	Sub    string                 `json:"sub,omitempty"`
-	UID    string                 `json:"uid,omitempty"`
-	Claims map[string]interface{} `json:"claims,omitempty"`
+	Iss      string                 `json:"iss"`
+	Aud      string                 `json:"aud"`
+	Exp      int64                  `json:"exp"`
+	Iat      int64                  `json:"iat"`
+	Sub      string                 `json:"sub,omitempty"`
+	UID      string                 `json:"uid,omitempty"`
+	TenantID string                 `json:"tenant_id,omitempty"`
+	Claims   map[string]interface{} `json:"claims,omitempty"`
 }
 
 func (p *customToken) decodeFrom(s string) error {
@@ -83,23 +84,28 @@ func encodeToken(ctx context.Context, s signer, h jwtHeader, p jwtPayload) (stri
 	return fmt.Sprintf("%s.%s", ss, base64.RawURLEncoding.EncodeToString(sig)), nil
 }
 
-func decodeToken(ctx context.Context, token string, ks keySource, h *jwtHeader, p jwtPayload) error {
-	s := strings.Split(token, ".")
-	if len(s) != 3 {
-		return errors.New("incorrect number of segments")
-	}
-
-	if err := decode(s[0], h); err != nil {
-		return err
-	}
-	if err := p.decodeFrom(s[1]); err != nil {
+func decodeToken(ctx context.Context, token string, ks KeySource, h *jwtHeader, p jwtPayload) error {
+	s, err := decodeUnverifiedToken(token, h, p)
+	if err != nil {
 		return err
 	}
+	return verifyTokenSignature(ctx, s, ks, h)
+}
 
+// verifyTokenSignature verifies the signature of a JWT, given its segments and its decoded header,
+// with the public keys obtained from ks.
+func verifyTokenSignature(ctx context.Context, s []string, ks KeySource, h *jwtHeader) error {
 	keys, err := ks.Keys(ctx)
 	if err != nil {
 		return err
 	}
+	// The token may be signed with a key that was published after the keys were cached.
+	if hks, ok := ks.(*httpKeySource); ok && h.KeyID != "" && !hasKeyID(keys, h.KeyID) {
+		keys, err = hks.refreshForUnknownKid(ctx, h.KeyID)
+		if err != nil {
+			return err
+		}
+	}
 	verified := false
 	for _, k := range keys {
 		if h.KeyID == "" || h.KeyID == k.Kid {
@@ -111,7 +117,24 @@ func decodeToken(ctx context.Context, token string, ks keySource, h *jwtHeader,
 	}
 
 	if !verified {
-		return errors.New("failed to verify token signature")
+		return internal.Error(invalidSignature, "failed to verify token signature")
 	}
 	return nil
 }
+
+// decodeUnverifiedToken decodes the header and the payload of the given JWT, without verifying its
+// signature. Returns the segments of the token.
+func decodeUnverifiedToken(token string, h *jwtHeader, p jwtPayload) ([]string, error) {
+	s := strings.Split(token, ".")
+	if len(s) != 3 {
+		return nil, internal.Error(malformedToken, "incorrect number of segments")
+	}
+
+	if err := decode(s[0], h); err != nil {
+		return nil, internal.Errorf(malformedToken, "failed to decode token header: %v", err)
+	}
+	if err := p.decodeFrom(s[1]); err != nil {
+		return nil, internal.Errorf(malformedToken, "failed to decode token payload: %v", err)
+	}
+	return s, nil
+}
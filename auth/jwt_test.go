@@ -79,10 +79,18 @@ type mockSigner struct {
 	err error
 }
 
+func (s *mockSigner) Algorithm() string {
+	return "RS256"
+}
+
 func (s *mockSigner) Email(ctx context.Context) (string, error) {
 	return "", nil
 }
 
+func (s *mockSigner) KeyID() string {
+	return ""
+}
+
 func (s *mockSigner) Sign(ctx context.Context, b []byte) ([]byte, error) {
 	if s.err != nil {
 		return nil, s.err
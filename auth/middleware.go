@@ -0,0 +1,79 @@
+// +build go1.7
+
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"net/http"
+)
+
+type tokenContextKey struct{}
+
+// AuthMiddleware returns an http.Handler that verifies the ID token in the Authorization header of
+// each request, before passing the request on to next.
+//
+// Tokens are verified with VerifyRequest(). Requests without a valid ID token are rejected with
+// HTTP status 401 (Unauthorized), and are not passed on to next. Requests for which the token
+// cannot be verified for another reason (e.g. because the public keys cannot be fetched, or the
+// request context expires) are rejected with HTTP status 503 (Service Unavailable), so that an
+// outage is not reported to clients as an invalid token. For the requests that are passed on, the
+// verified Token can be obtained from the request context with TokenFromContext().
+func (c *Client) AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := c.VerifyRequest(r.Context(), r)
+		if err != nil {
+			status := http.StatusServiceUnavailable
+			if isTokenError(err) {
+				status = http.StatusUnauthorized
+			}
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenContextKey{}, token)))
+	})
+}
+
+// isTokenError checks if the given error returned by VerifyRequest() was due to a missing or
+// invalid ID token, as opposed to a failure to verify the token.
+func isTokenError(err error) bool {
+	checks := []func(error) bool{
+		IsInvalidAuthorizationHeader,
+		IsInvalidToken,
+		IsMalformedToken,
+		IsTokenExpired,
+		IsTokenUsedTooEarly,
+		IsInvalidAudience,
+		IsInvalidIssuer,
+		IsInvalidSignature,
+		IsTenantIDMismatch,
+		IsIDTokenRevoked,
+		IsAuthTimeTooOld,
+	}
+	for _, check := range checks {
+		if check(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenFromContext returns the Token stored in the given context by AuthMiddleware(), and whether
+// such a Token was found.
+func TokenFromContext(ctx context.Context) (*Token, bool) {
+	token, ok := ctx.Value(tokenContextKey{}).(*Token)
+	return token, ok
+}
@@ -0,0 +1,101 @@
+// +build go1.7
+
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	var got *Token
+	handler := client.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := TokenFromContext(r.Context())
+		if !ok {
+			t.Errorf("TokenFromContext() = (%v, %v); want = (token, true)", token, ok)
+		}
+		got = token
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "https://example.com", nil)
+	r.Header.Set("Authorization", "Bearer "+testIDToken)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Status = %d; want = %d", w.Code, http.StatusOK)
+	}
+	if got == nil || got.UID != got.Subject {
+		t.Errorf("Token = %v; want = verified token", got)
+	}
+}
+
+func TestAuthMiddlewareUnauthorized(t *testing.T) {
+	handler := client.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("handler called for unauthorized request")
+	}))
+
+	expired := getIDToken(mockIDTokenPayload{"exp": time.Now().Unix() - 100})
+	for _, header := range []string{"", "Bearer", "Bearer not.a.token", "Bearer " + expired} {
+		r := httptest.NewRequest(http.MethodGet, "https://example.com", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Status(%q) = %d; want = %d", header, w.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestAuthMiddlewareUnavailable(t *testing.T) {
+	c := *client
+	c.ks = newHTTPKeySource("http://mock.url", &http.Client{
+		Transport: &mockHTTPResponse{Err: errors.New("transport error")},
+	})
+	handler := c.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("handler called for unverified request")
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "https://example.com", nil)
+	r.Header.Set("Authorization", "Bearer "+testIDToken)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Status = %d; want = %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	cctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.ks = newHTTPKeySource("http://mock.url", http.DefaultClient)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r.WithContext(cctx))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Status(cancelled context) = %d; want = %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestTokenFromContextEmpty(t *testing.T) {
+	if token, ok := TokenFromContext(context.Background()); token != nil || ok {
+		t.Errorf("TokenFromContext() = (%v, %v); want = (nil, false)", token, ok)
+	}
+}
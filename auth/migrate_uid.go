@@ -0,0 +1,231 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+
+	"firebase.google.com/go/internal"
+	"golang.org/x/net/context"
+	"google.golang.org/api/identitytoolkit/v3"
+)
+
+// MigrateUIDOption is an option for the MigrateUID() function.
+type MigrateUIDOption func(*migrateUIDConfig)
+
+type migrateUIDConfig struct {
+	noRestore       bool
+	withoutPassword bool
+}
+
+// WithoutRestoreOnFailure returns a MigrateUIDOption that disables restoring the old user account,
+// if the new account cannot be created after the old one was deleted. The old account is then
+// lost, which callers that keep their own copy of it may prefer over a restored account.
+func WithoutRestoreOnFailure() MigrateUIDOption {
+	return func(conf *migrateUIDConfig) {
+		conf.noRestore = true
+	}
+}
+
+// WithoutPassword returns a MigrateUIDOption that allows migrating an account that signs in with a
+// password, when its password hash cannot be preserved. Without this option MigrateUID returns an
+// error in that case, before modifying any account. With it, the migrated account, and the old
+// account if it is restored, can no longer sign in with the password.
+func WithoutPassword() MigrateUIDOption {
+	return func(conf *migrateUIDConfig) {
+		conf.withoutPassword = true
+	}
+}
+
+// MigrateUIDResult represents the result of a MigrateUID() call.
+//
+// User is the migrated user account. UnpreservedFields lists the fields of the old account that
+// could not be carried over to the new one. Currently this can only be "password", when the
+// password hash of the old account cannot be read, or the hash parameters of the project are not
+// available, and the WithoutPassword() option is specified.
+type MigrateUIDResult struct {
+	User              *UserRecord
+	UnpreservedFields []string
+}
+
+// MigrateUID moves an existing user account from oldUID to newUID.
+//
+// Since the email, phone number and provider identities of a user must be unique within a project,
+// the account is migrated by deleting the old account, and then importing a copy of it under the
+// new UID. The email, phone number, display name, photo URL, disabled status, custom claims,
+// metadata and the federated provider identities of the account are preserved. The password of
+// the account is preserved when the credentials of the Client have permission to read password
+// hashes and the hash parameters of the project (see HashConfig()). Otherwise the migration of an
+// account with a password fails, unless the WithoutPassword() option is specified, and the
+// password is listed in the UnpreservedFields of the returned MigrateUIDResult. The tokens valid
+// after time and the multi-factor enrollments of the account are not preserved. Refresh tokens
+// issued for the old UID are not valid for the new one, so the user must sign in again.
+//
+// MigrateUID returns an error without modifying any account if the old account does not exist, if
+// an account with newUID already exists, or if the password of the account cannot be preserved.
+// If the new account cannot be created after deleting the old one, the old account is imported
+// back under its original UID, unless the WithoutRestoreOnFailure() option is specified. The
+// restored account loses the same fields as a migrated one. If the migrated account cannot be read back
+// after it was created, the migration is complete, and both the MigrateUIDResult (with a nil User)
+// and an error are returned.
+func (c *Client) MigrateUID(ctx context.Context, oldUID, newUID string, opts ...MigrateUIDOption) (*MigrateUIDResult, error) {
+	if err := validateUID(oldUID); err != nil {
+		return nil, err
+	}
+	if err := validateUID(newUID); err != nil {
+		return nil, err
+	}
+	if oldUID == newUID {
+		return nil, errors.New("old and new uid must not be the same")
+	}
+	conf := &migrateUIDConfig{}
+	for _, opt := range opts {
+		opt(conf)
+	}
+
+	old, err := c.lookupUserInfo(ctx, oldUID)
+	if err != nil {
+		return nil, err
+	}
+	if old == nil {
+		return nil, internal.Errorf(userNotFound, "cannot find user from uid: %q", oldUID)
+	}
+	existing, err := c.lookupUserInfo(ctx, newUID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, internal.Errorf(uidAlreadyExists, "user with uid %q already exists", newUID)
+	}
+
+	result := &MigrateUIDResult{}
+	var hash InternalHash
+	if old.PasswordHash != "" {
+		if hash = c.passwordHash(ctx); hash == nil {
+			old.PasswordHash = ""
+			old.Salt = ""
+		}
+	}
+	if old.PasswordHash == "" && hasPasswordProvider(old) {
+		if !conf.withoutPassword {
+			return nil, fmt.Errorf("password of user %q cannot be preserved; "+
+				"specify WithoutPassword() to migrate the user without it", oldUID)
+		}
+		result.UnpreservedFields = append(result.UnpreservedFields, "password")
+	}
+
+	if err := c.DeleteUser(ctx, oldUID); err != nil {
+		return nil, err
+	}
+	if err := c.uploadUserInfo(ctx, migratedUserInfo(old, newUID), hash); err != nil {
+		if conf.noRestore {
+			return nil, fmt.Errorf("failed to create user %q; old user %q was deleted: %v", newUID, oldUID, err)
+		}
+		if rerr := c.uploadUserInfo(ctx, migratedUserInfo(old, oldUID), hash); rerr != nil {
+			return nil, fmt.Errorf("failed to create user %q: %v; failed to restore user %q: %v", newUID, err, oldUID, rerr)
+		}
+		return nil, fmt.Errorf("failed to create user %q; old user %q was restored: %v", newUID, oldUID, err)
+	}
+
+	if result.User, err = c.GetUser(ctx, newUID); err != nil {
+		return result, fmt.Errorf("user %q was migrated to %q, but the new user could not be read: %v", oldUID, newUID, err)
+	}
+	return result, nil
+}
+
+// lookupUserInfo returns the account with the given UID as returned by the backend, or nil if
+// no such account exists.
+func (c *Client) lookupUserInfo(ctx context.Context, uid string) (*identitytoolkit.UserInfo, error) {
+	resp, err := c.getAccountInfo(ctx, &identitytoolkit.IdentitytoolkitRelyingpartyGetAccountInfoRequest{
+		LocalId: []string{uid},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Users) == 0 {
+		return nil, nil
+	}
+	return resp.Users[0], nil
+}
+
+// passwordHash returns the hash algorithm for importing the password hashes of the project, or nil
+// if the hash parameters are not available.
+func (c *Client) passwordHash(ctx context.Context) InternalHash {
+	hc, err := c.HashConfig(ctx)
+	if err != nil {
+		return nil
+	}
+	scrypt, err := hc.Scrypt()
+	if err != nil {
+		return nil
+	}
+	return scrypt
+}
+
+// uploadUserInfo imports a single account, reporting a failure to import it as an error.
+func (c *Client) uploadUserInfo(ctx context.Context, info *identitytoolkit.UserInfo, hash InternalHash) error {
+	request := &identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest{
+		Users: []*identitytoolkit.UserInfo{info},
+	}
+	if hash != nil {
+		if err := hash.applyTo(request); err != nil {
+			return err
+		}
+	}
+	resp, err := c.uploadAccount(ctx, request)
+	if err != nil {
+		return err
+	}
+	if len(resp.Error) > 0 {
+		return errors.New(resp.Error[0].Message)
+	}
+	return nil
+}
+
+// migratedUserInfo returns a copy of the importable fields of the given account, under the given
+// UID. The password and phone providers are derived from the other fields of the account, and are
+// therefore not copied.
+func migratedUserInfo(r *identitytoolkit.UserInfo, uid string) *identitytoolkit.UserInfo {
+	info := &identitytoolkit.UserInfo{
+		LocalId:          uid,
+		Email:            r.Email,
+		EmailVerified:    r.EmailVerified,
+		DisplayName:      r.DisplayName,
+		PhotoUrl:         r.PhotoUrl,
+		PhoneNumber:      r.PhoneNumber,
+		Disabled:         r.Disabled,
+		CustomAttributes: r.CustomAttributes,
+		CreatedAt:        r.CreatedAt,
+		LastLoginAt:      r.LastLoginAt,
+		PasswordHash:     r.PasswordHash,
+		Salt:             r.Salt,
+	}
+	for _, p := range r.ProviderUserInfo {
+		if p.ProviderId != ProviderPassword && p.ProviderId != ProviderPhone {
+			info.ProviderUserInfo = append(info.ProviderUserInfo, p)
+		}
+	}
+	return info
+}
+
+func hasPasswordProvider(r *identitytoolkit.UserInfo) bool {
+	for _, p := range r.ProviderUserInfo {
+		if p.ProviderId == ProviderPassword {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,277 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/identitytoolkit/v3"
+)
+
+// accountsIdentitytoolkit stores user accounts in memory, and fails to import the accounts whose
+// UIDs are listed in failUpload. If failGetAt is positive, the lookup with that 1-based index
+// fails.
+type accountsIdentitytoolkit struct {
+	identitytoolkitService
+	users      map[string]*identitytoolkit.UserInfo
+	uploads    []*identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest
+	failUpload map[string]bool
+	gets       int
+	failGetAt  int
+}
+
+func (m *accountsIdentitytoolkit) getAccountInfo(
+	ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartyGetAccountInfoRequest) (*identitytoolkit.GetAccountInfoResponse, error) {
+	m.gets++
+	if m.gets == m.failGetAt {
+		return nil, errors.New("lookup failed")
+	}
+	resp := &identitytoolkit.GetAccountInfoResponse{}
+	if u, ok := m.users[req.LocalId[0]]; ok {
+		resp.Users = append(resp.Users, u)
+	}
+	return resp, nil
+}
+
+func (m *accountsIdentitytoolkit) deleteAccount(
+	ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartyDeleteAccountRequest) (*identitytoolkit.DeleteAccountResponse, error) {
+	delete(m.users, req.LocalId)
+	return &identitytoolkit.DeleteAccountResponse{}, nil
+}
+
+func (m *accountsIdentitytoolkit) uploadAccount(
+	ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartyUploadAccountRequest) (*identitytoolkit.UploadAccountResponse, error) {
+	m.uploads = append(m.uploads, req)
+	resp := &identitytoolkit.UploadAccountResponse{}
+	for i, u := range req.Users {
+		if m.failUpload[u.LocalId] {
+			resp.Error = append(resp.Error, &identitytoolkit.UploadAccountResponseError{
+				Index:   int64(i),
+				Message: "DUPLICATE_EMAIL",
+			})
+			continue
+		}
+		m.users[u.LocalId] = u
+	}
+	return resp, nil
+}
+
+func newAccountsClient(users ...*identitytoolkit.UserInfo) (*Client, *accountsIdentitytoolkit) {
+	m := &accountsIdentitytoolkit{
+		users:      map[string]*identitytoolkit.UserInfo{},
+		failUpload: map[string]bool{},
+	}
+	for _, u := range users {
+		m.users[u.LocalId] = u
+	}
+	c := *client
+	c.is = m
+	return &c, m
+}
+
+var migrateUserInfo = &identitytoolkit.UserInfo{
+	LocalId:          "old",
+	Email:            "user@example.com",
+	EmailVerified:    true,
+	DisplayName:      "Test User",
+	PhoneNumber:      "+11234567890",
+	CustomAttributes: `{"admin":true}`,
+	CreatedAt:        1234,
+	LastLoginAt:      5678,
+	ProviderUserInfo: []*identitytoolkit.UserInfoProviderUserInfo{
+		{ProviderId: ProviderPhone, RawId: "+11234567890"},
+		{ProviderId: "google.com", RawId: "google_uid", Email: "user@gmail.com"},
+	},
+}
+
+func TestMigrateUID(t *testing.T) {
+	c, m := newAccountsClient(migrateUserInfo)
+
+	result, err := c.MigrateUID(context.Background(), "old", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.UnpreservedFields != nil {
+		t.Errorf("UnpreservedFields = %v; want = nil", result.UnpreservedFields)
+	}
+	u := result.User
+	if u.UID != "new" || u.Email != "user@example.com" || !u.EmailVerified || u.PhoneNumber != "+11234567890" {
+		t.Errorf("MigrateUID() = %#v; want = migrated user", u.UserInfo)
+	}
+	if !reflect.DeepEqual(u.CustomClaims, map[string]interface{}{"admin": true}) {
+		t.Errorf("CustomClaims = %v; want = {admin: true}", u.CustomClaims)
+	}
+	if u.UserMetadata.CreationTimestamp != 1234 || u.UserMetadata.LastLogInTimestamp != 5678 {
+		t.Errorf("UserMetadata = %#v; want = {1234, 5678}", u.UserMetadata)
+	}
+	if len(u.ProviderUserInfo) != 1 || u.ProviderUserInfo[0].ProviderID != "google.com" {
+		t.Errorf("ProviderUserInfo = %v; want = [google.com]", u.ProviderUserInfo)
+	}
+	if _, ok := m.users["old"]; ok {
+		t.Errorf("old user not deleted")
+	}
+	if m.uploads[0].HashAlgorithm != "" {
+		t.Errorf("HashAlgorithm = %q; want = %q", m.uploads[0].HashAlgorithm, "")
+	}
+}
+
+func TestMigrateUIDPassword(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"signIn": {"hashConfig": {
+			"algorithm": "SCRYPT",
+			"signerKey": "` + base64.StdEncoding.EncodeToString([]byte("key")) + `",
+			"rounds": 8,
+			"memoryCost": 14
+		}}}`))
+	}))
+	defer srv.Close()
+
+	info := *migrateUserInfo
+	info.PasswordHash = "aGFzaA"
+	info.Salt = "c2FsdA"
+	info.ProviderUserInfo = []*identitytoolkit.UserInfoProviderUserInfo{{ProviderId: ProviderPassword}}
+	c, m := newAccountsClient(&info)
+	c.v2URL = srv.URL
+
+	result, err := c.MigrateUID(context.Background(), "old", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.UnpreservedFields != nil {
+		t.Errorf("UnpreservedFields = %v; want = nil", result.UnpreservedFields)
+	}
+	req := m.uploads[0]
+	if req.HashAlgorithm != "SCRYPT" || req.Rounds != 8 || req.MemoryCost != 14 {
+		t.Errorf("upload hash = (%q, %d, %d); want = (SCRYPT, 8, 14)", req.HashAlgorithm, req.Rounds, req.MemoryCost)
+	}
+	if u := req.Users[0]; u.PasswordHash != "aGFzaA" || u.Salt != "c2FsdA" || u.ProviderUserInfo != nil {
+		t.Errorf("upload user = %#v; want = password hash and salt", u)
+	}
+}
+
+func TestMigrateUIDPasswordUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": {"message": "INSUFFICIENT_PERMISSION"}}`))
+	}))
+	defer srv.Close()
+
+	cases := []string{"aGFzaA", ""}
+	for _, hash := range cases {
+		info := *migrateUserInfo
+		info.PasswordHash = hash
+		info.ProviderUserInfo = []*identitytoolkit.UserInfoProviderUserInfo{{ProviderId: ProviderPassword}}
+		c, m := newAccountsClient(&info)
+		c.v2URL = srv.URL
+
+		if result, err := c.MigrateUID(context.Background(), "old", "new"); result != nil || err == nil {
+			t.Errorf("MigrateUID(%q) = (%v, %v); want = (nil, error)", hash, result, err)
+		}
+		if len(m.users) != 1 || len(m.uploads) != 0 {
+			t.Errorf("MigrateUID(%q) modified accounts", hash)
+		}
+
+		result, err := c.MigrateUID(context.Background(), "old", "new", WithoutPassword())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"password"}; !reflect.DeepEqual(result.UnpreservedFields, want) {
+			t.Errorf("UnpreservedFields(%q) = %v; want = %v", hash, result.UnpreservedFields, want)
+		}
+		if req := m.uploads[0]; req.HashAlgorithm != "" || req.Users[0].PasswordHash != "" {
+			t.Errorf("upload(%q) = %#v; want = no password hash", hash, req)
+		}
+	}
+}
+
+func TestMigrateUIDUploadFailure(t *testing.T) {
+	c, m := newAccountsClient(migrateUserInfo)
+	m.failUpload["new"] = true
+	opt := WithoutRestoreOnFailure()
+	if result, err := c.MigrateUID(context.Background(), "old", "new", opt); result != nil || err == nil {
+		t.Errorf("MigrateUID() = (%v, %v); want = (nil, error)", result, err)
+	}
+	if len(m.users) != 0 {
+		t.Errorf("users = %v; want = none", m.users)
+	}
+
+	c, m = newAccountsClient(migrateUserInfo)
+	m.failUpload["new"] = true
+	if result, err := c.MigrateUID(context.Background(), "old", "new"); result != nil || err == nil {
+		t.Errorf("MigrateUID() = (%v, %v); want = (nil, error)", result, err)
+	}
+	restored, ok := m.users["old"]
+	if !ok {
+		t.Fatalf("old user not restored")
+	}
+	if !reflect.DeepEqual(restored, migratedUserInfo(migrateUserInfo, "old")) {
+		t.Errorf("restored = %#v; want = %#v", restored, migrateUserInfo)
+	}
+}
+
+func TestMigrateUIDGetUserFailure(t *testing.T) {
+	c, m := newAccountsClient(migrateUserInfo)
+	m.failGetAt = 3
+
+	result, err := c.MigrateUID(context.Background(), "old", "new")
+	if result == nil || result.User != nil || err == nil {
+		t.Fatalf("MigrateUID() = (%v, %v); want = (result, error)", result, err)
+	}
+	if !strings.Contains(err.Error(), `user "old" was migrated to "new"`) {
+		t.Errorf("MigrateUID() = %v; want = migration completed error", err)
+	}
+	if _, ok := m.users["new"]; !ok {
+		t.Errorf("new user not created")
+	}
+	if _, ok := m.users["old"]; ok {
+		t.Errorf("old user not deleted")
+	}
+}
+
+func TestMigrateUIDError(t *testing.T) {
+	existing := &identitytoolkit.UserInfo{LocalId: "new"}
+	cases := []struct {
+		name     string
+		old, new string
+		check    func(error) bool
+	}{
+		{"InvalidOldUID", "", "new", nil},
+		{"InvalidNewUID", "old", "", nil},
+		{"SameUID", "old", "old", nil},
+		{"NotFound", "missing", "other", IsUserNotFound},
+		{"AlreadyExists", "old", "new", IsUIDAlreadyExists},
+	}
+	for _, tc := range cases {
+		c, m := newAccountsClient(migrateUserInfo, existing)
+		result, err := c.MigrateUID(context.Background(), tc.old, tc.new)
+		if result != nil || err == nil {
+			t.Errorf("MigrateUID(%s) = (%v, %v); want = (nil, error)", tc.name, result, err)
+		}
+		if tc.check != nil && !tc.check(err) {
+			t.Errorf("MigrateUID(%s) = %v; want = matching error code", tc.name, err)
+		}
+		if len(m.users) != 2 || len(m.uploads) != 0 {
+			t.Errorf("MigrateUID(%s) modified accounts", tc.name)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// ObservabilityHook receives a notification for each HTTP request made by the Client, which can be
+// used to record metrics, or to create trace spans around the calls made by the SDK.
+//
+// Observe is called after each request completes, with the context of the API call that made the
+// request (on Go 1.7 and later), the name of the operation, the time taken by the request and the
+// error, if any. A request completes when its response body is closed, so that the time taken
+// includes reading the response, and errors encountered while reading it (e.g. a response larger
+// than the limit set with WithMaxResponseSize()) are reported. The operation name is the HTTP method and the URL of the request without the
+// query string (e.g. "GET https://www.googleapis.com/robot/v1/metadata/x509/..."). This covers the
+// calls to the Identity Toolkit backend as well as the fetches of the public keys used to verify
+// ID tokens and session cookies. Responses with a status of 400 or above are reported as errors.
+// Each retry attempt is reported as a separate request.
+//
+// Observe is called synchronously, and may be called concurrently from multiple goroutines.
+type ObservabilityHook interface {
+	Observe(ctx context.Context, op string, d time.Duration, err error)
+}
+
+// ObservabilityHookFunc is an adapter that allows an ordinary function to be used as an
+// ObservabilityHook.
+type ObservabilityHookFunc func(ctx context.Context, op string, d time.Duration, err error)
+
+// Observe calls f(ctx, op, d, err).
+func (f ObservabilityHookFunc) Observe(ctx context.Context, op string, d time.Duration, err error) {
+	f(ctx, op, d, err)
+}
+
+// observedTransport is an http.RoundTripper that reports each request to an ObservabilityHook.
+type observedTransport struct {
+	base http.RoundTripper
+	hook ObservabilityHook
+}
+
+func (t *observedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+	u := *r.URL
+	u.RawQuery = ""
+	op := r.Method + " " + u.String()
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		t.hook.Observe(requestContext(r), op, time.Since(start), err)
+		return nil, err
+	}
+
+	var statusErr error
+	if resp.StatusCode >= http.StatusBadRequest {
+		statusErr = fmt.Errorf("http error status: %d", resp.StatusCode)
+	}
+	resp.Body = &observedBody{
+		rc: resp.Body,
+		observe: func(err error) {
+			if err == nil {
+				err = statusErr
+			}
+			t.hook.Observe(requestContext(r), op, time.Since(start), err)
+		},
+	}
+	return resp, nil
+}
+
+// observedBody is an io.ReadCloser that calls observe once it is closed, with the first error
+// encountered while reading from rc, if any.
+type observedBody struct {
+	rc      io.ReadCloser
+	observe func(err error)
+	err     error
+	once    sync.Once
+}
+
+func (b *observedBody) Read(p []byte) (int, error) {
+	n, err := b.rc.Read(p)
+	if err != nil && err != io.EOF && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *observedBody) Close() error {
+	err := b.rc.Close()
+	b.once.Do(func() { b.observe(b.err) })
+	return err
+}
@@ -0,0 +1,29 @@
+// +build !go1.7
+
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// requestContext returns a background context, since HTTP requests do not carry a context in
+// versions of Go prior to 1.7.
+func requestContext(r *http.Request) context.Context {
+	return context.Background()
+}
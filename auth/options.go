@@ -0,0 +1,380 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"net/http"
+	"time"
+)
+
+// ClientOption is an option for configuring the behavior of an auth Client.
+//
+// ClientOptions can be passed to firebase.App.Auth() when initializing a Client.
+type ClientOption func(*Client)
+
+// WithKeySource returns a ClientOption that specifies the KeySource used to obtain the public keys
+// for verifying ID tokens.
+//
+// By default the Client fetches the public keys from Google servers. A custom KeySource can be used
+// to serve the keys from a local mirror or an embedded bundle, in environments that cannot reach
+// Google servers. Callers that specify a custom KeySource take responsibility for keeping its keys
+// in sync with the keys rotated by Google. Session cookies and App Check tokens are signed with
+// different keys, and are not affected by this option. See WithSessionCookieKeySource() and
+// WithAppCheckKeySource().
+func WithKeySource(ks KeySource) ClientOption {
+	return func(c *Client) {
+		c.ks = ks
+	}
+}
+
+// WithSessionCookieKeySource returns a ClientOption that specifies the KeySource used to obtain the
+// public keys for verifying session cookies.
+//
+// By default the Client fetches the public keys used to sign session cookies from Google servers.
+// As with WithKeySource(), callers that specify a custom KeySource take responsibility for keeping
+// its keys in sync with the keys rotated by Google.
+func WithSessionCookieKeySource(ks KeySource) ClientOption {
+	return func(c *Client) {
+		c.cookieKS = ks
+	}
+}
+
+// WithAppCheckKeySource returns a ClientOption that specifies the KeySource used to obtain the
+// public keys for verifying App Check tokens.
+//
+// By default the Client fetches the public keys from the JWKS endpoint of the App Check service.
+// As with WithKeySource(), callers that specify a custom KeySource take responsibility for keeping
+// its keys in sync with the keys rotated by the App Check service.
+func WithAppCheckKeySource(ks KeySource) ClientOption {
+	return func(c *Client) {
+		c.appCheckKS = ks
+	}
+}
+
+// WithApplicationName returns a ClientOption that appends the specified name to the SDK version
+// reported by the Client.
+//
+// Every HTTP request made by the Client carries an X-Client-Version header, which identifies the
+// version of the SDK (e.g. "Go/Admin/3.0.0"). With this option the header ends with the name of
+// the application, separated by a space (e.g. "Go/Admin/3.0.0 billing-service"), which allows
+// proxies and request logs to attribute the traffic of multi-service deployments. NewClient()
+// returns an error if the name contains a line break.
+func WithApplicationName(name string) ClientOption {
+	return func(c *Client) {
+		c.appName = name
+	}
+}
+
+// WithCustomTokenIssuer returns a ClientOption that specifies the service account email used as the
+// issuer ("iss") and the subject ("sub") of the custom tokens created by the Client.
+//
+// By default the email of the service account that signs the tokens is used. This option allows
+// minting tokens on behalf of a different service account, when signing is delegated to a signer
+// that uses the key of that account (e.g. a proxy signer service). Firebase rejects custom tokens
+// whose signature does not match the key of the issuer. NewClient() returns an error if the issuer
+// is not a valid email address.
+func WithCustomTokenIssuer(email string) ClientOption {
+	return func(c *Client) {
+		c.customTokenIss = email
+	}
+}
+
+// WithAllowedCustomClaims returns a ClientOption that restricts the developer claims that can be
+// included in the custom tokens created by the Client to the specified keys.
+//
+// Functions like CustomTokenWithClaims() return an error if any of the developer claims is not in
+// the list, instead of creating a token. Keys are case-sensitive. An empty list allows custom
+// tokens without developer claims only. By default any developer claim that is not reserved is
+// allowed. The restriction does not apply to the claims set on user accounts with
+// SetCustomUserClaims().
+func WithAllowedCustomClaims(keys []string) ClientOption {
+	return func(c *Client) {
+		c.allowedClaims = make(map[string]bool, len(keys))
+		for _, k := range keys {
+			c.allowedClaims[k] = true
+		}
+	}
+}
+
+// WithCustomTokenKeyID returns a ClientOption that specifies the key ID set in the "kid" header of
+// the custom tokens created by the Client.
+//
+// By default the ID of the service account key that signs the tokens is used, as specified by the
+// "private_key_id" field of the service account JSON key. No "kid" header is set if the key ID is
+// not known, which is the case when signing with the App Engine app identity service. A fixed key
+// ID allows clients to pin the key that signs the tokens.
+func WithCustomTokenKeyID(kid string) ClientOption {
+	return func(c *Client) {
+		c.customTokenKid = kid
+	}
+}
+
+// WithEmulatorHost returns a ClientOption that connects the Client to the Firebase Auth Emulator
+// running at the specified host:port (e.g. "localhost:9099").
+//
+// When connected to the emulator, all user management calls are sent to the emulator instead of
+// Google servers, and no Google credentials are required to make them. Since the emulator issues
+// unsigned tokens, the signatures of ID tokens and session cookies are not verified. Custom tokens
+// are not signed either, unless a service account key is available. This option must never be
+// used in production. If not specified, the emulator host is read from the
+// FIREBASE_AUTH_EMULATOR_HOST environment variable.
+func WithEmulatorHost(host string) ClientOption {
+	return func(c *Client) {
+		c.emulatorHost = host
+	}
+}
+
+// WithoutCustomTokenDetection returns a ClientOption that disables the detection of custom tokens
+// passed where an ID token or a session cookie is expected.
+//
+// By default, such tokens are rejected with an error for which IsCustomTokenError() returns true.
+// With this option they are verified like any other token instead, and are rejected with the
+// generic errors of the checks they fail (e.g. a missing 'kid' header, or an invalid audience when
+// connected to the Auth emulator).
+func WithoutCustomTokenDetection() ClientOption {
+	return func(c *Client) {
+		c.noCustomTokenCheck = true
+	}
+}
+
+// WithServiceAccountFile returns a ClientOption that specifies a service account JSON key file, from
+// which the Client loads the private key and the email used to sign custom tokens.
+//
+// The file must contain both the "private_key" and the "client_email" fields, and is read when the
+// Client is created. The key takes precedence over any service account key in the credentials of
+// the App. It is only used for signing custom tokens; calls to the Firebase Auth backend are
+// still authorized with the credentials of the App.
+func WithServiceAccountFile(path string) ClientOption {
+	return func(c *Client) {
+		c.serviceAccountFile = path
+	}
+}
+
+// WithHTTPTimeout returns a ClientOption that specifies a time limit for each HTTP request made by
+// the Client, including the requests for fetching public keys.
+//
+// The timeout applies in addition to the deadline of the context passed to each function, and
+// guarantees that a request does not block indefinitely even when the context has no deadline.
+// When retries are enabled with WithRetryConfig(), the timeout applies to each attempt separately.
+// By default requests do not time out.
+func WithHTTPTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.httpTimeout = d
+	}
+}
+
+// WithKeyCacheTTL returns a ClientOption that bounds how long the public keys fetched from Google
+// servers are cached.
+//
+// By default the keys are cached for the duration advertised by the max-age directive of the
+// server response. With this option, the keys are cached for at least min and at most max, and
+// the server advertised duration is honored within those bounds. A lower max enforces faster
+// pick up of rotated keys, while a higher min reduces the dependency on Google servers being
+// reachable. A zero bound is not enforced. NewClient() returns an error if either bound is
+// negative, or if min exceeds a non-zero max.
+func WithKeyCacheTTL(min, max time.Duration) ClientOption {
+	return func(c *Client) {
+		c.keyCacheMinTTL = min
+		c.keyCacheMaxTTL = max
+	}
+}
+
+// WithMaxResponseSize returns a ClientOption that limits the size of the HTTP response bodies read
+// by the Client, including the responses containing public keys.
+//
+// A response body larger than n bytes is rejected with an error, instead of being read into memory
+// in full. This protects the Client from a misbehaving endpoint. By default response bodies are
+// limited to 10MB. A zero value restores the default, and NewClient() returns an error if n is
+// negative.
+func WithMaxResponseSize(n int64) ClientOption {
+	return func(c *Client) {
+		c.maxResponseSize = n
+	}
+}
+
+// WithRedactedLogging returns a ClientOption that logs a dump of each HTTP request made by the
+// Client and of its response, by calling logf (e.g. log.Printf) once for each.
+//
+// The dumps are redacted: the values of the fields containing passwords, password hashes, private
+// keys, tokens, session cookies, email action codes and custom claims are replaced, as are the
+// Authorization and cookie headers. Bodies that are not JSON are only logged by length, and query
+// strings are omitted. This allows debugging the calls made by the Client without having to log
+// the traffic at the transport level (e.g. via WithTransport()), which would expose credentials.
+// By default nothing is logged.
+func WithRedactedLogging(logf func(format string, v ...interface{})) ClientOption {
+	return func(c *Client) {
+		c.logf = logf
+	}
+}
+
+// WithKeyRefreshLogger returns a ClientOption that specifies the function to which the Client
+// reports failures to refresh the cached public keys used to verify tokens.
+//
+// When a refresh fails, the previously fetched keys remain in use until a later refresh succeeds,
+// and a warning is reported by calling logf once. By default the warnings are logged with
+// log.Printf(). Passing a function that does nothing disables them.
+func WithKeyRefreshLogger(logf func(format string, v ...interface{})) ClientOption {
+	return func(c *Client) {
+		c.keyRefreshLogf = logf
+	}
+}
+
+// WithObservabilityHook returns a ClientOption that specifies an ObservabilityHook to be notified of
+// each HTTP request made by the Client. By default no hook is set, and requests are not
+// instrumented.
+func WithObservabilityHook(hook ObservabilityHook) ClientOption {
+	return func(c *Client) {
+		c.hook = hook
+	}
+}
+
+// WithRevocationCheckTimeout returns a ClientOption that bounds the user lookup performed by
+// VerifyIDTokenAndCheckRevoked() and VerifySessionCookieAndCheckRevoked() to the given duration.
+//
+// The timeout is derived from the context passed to those functions, and only applies to the
+// revocation check, so that a slow lookup cannot consume the latency budget of the caller. When the
+// lookup times out, both functions return the verified Token along with an error that can be
+// checked with IsRevocationCheckTimeout(). Since the revocation status of such a token is unknown,
+// callers should reject it by default (fail closed), as they would any other error. Falling back
+// to the verified Token (fail open) means a revoked token may be accepted while the backend is
+// slow, and should only be done where that risk is acceptable. By default the check is only bound
+// by the context of the call.
+func WithRevocationCheckTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.revocationTimeout = d
+	}
+}
+
+// WithUIDGenerator returns a ClientOption that specifies a function for generating the UIDs of new
+// user accounts.
+//
+// The function is called by CreateUser() whenever the UserToCreate does not specify a UID, and must
+// return a string of 1 to 128 characters that is not already taken by another user. CreateUser()
+// returns an error without creating the user if the generated UID is invalid. By default no
+// generator is set, and the Firebase Auth backend assigns a random UID to each new user.
+func WithUIDGenerator(gen func() string) ClientOption {
+	return func(c *Client) {
+		c.uidGenerator = gen
+	}
+}
+
+// WithTransport returns a ClientOption that specifies the http.RoundTripper used by the Client to
+// send HTTP requests, e.g. to route them through a proxy, or to use a custom TLS configuration.
+//
+// The Client still authorizes its requests using the credentials of the App, by wrapping rt in a
+// transport that adds the necessary headers. The App must therefore not be initialized with an
+// HTTP client of its own (option.WithHTTPClient()) when this option is used.
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		c.transport = rt
+	}
+}
+
+// withClock returns a ClientOption that sets the clock used by the Client to determine the current
+// time when minting and verifying tokens. Only used in tests.
+func withClock(clk clock) ClientOption {
+	return func(c *Client) {
+		c.clock = clk
+	}
+}
+
+// WithAcceptedProjectIDs returns a ClientOption that specifies additional Firebase projects, for
+// which VerifyIDToken() and VerifyIDTokenAndCheckRevoked() accept ID tokens.
+//
+// By default the Client only accepts ID tokens issued for its own project. With this option, the
+// 'aud' (audience) and 'iss' (issuer) claims of an ID token may instead refer to any of the
+// specified projects, e.g. while migrating users from one project to another. The Audience field
+// of a verified Token indicates the project for which it was issued. Session cookies are not
+// affected by this option.
+func WithAcceptedProjectIDs(projectIDs ...string) ClientOption {
+	return func(c *Client) {
+		c.acceptedProjectIDs = projectIDs
+	}
+}
+
+// WithClockSkew returns a ClientOption that specifies the maximum clock skew tolerated when
+// verifying ID tokens and session cookies.
+//
+// Tokens issued up to skew in the future, or expired less than skew in the past, are accepted.
+// This helps in deployments where the clocks of the servers are not perfectly synchronized. By
+// default no skew is tolerated. NewClient returns an error if skew is negative.
+func WithClockSkew(skew time.Duration) ClientOption {
+	return func(c *Client) {
+		c.clockSkew = skew
+	}
+}
+
+// WithIssuedAtLeeway returns a ClientOption that specifies how far in the future the 'iat' (issued
+// at) claim of an ID token or session cookie may be, without the token being rejected.
+//
+// Unlike WithClockSkew(), the leeway does not apply to the expiry of the token, which helps accept
+// tokens minted by devices with clocks running slightly ahead, while keeping expiry strict. The
+// leeway is added to the clock skew, if any. By default the leeway is zero.
+func WithIssuedAtLeeway(leeway time.Duration) ClientOption {
+	return func(c *Client) {
+		c.issuedAtLeeway = leeway
+	}
+}
+
+// WithMaxTokenAge returns a ClientOption that specifies the maximum age of the ID tokens accepted by
+// the Client.
+//
+// When set, ID tokens issued more than maxAge ago are rejected, even if they have not expired yet.
+// Such tokens are reported with an error that can be checked with IsTokenExpired(). This limits the
+// window in which a stolen ID token can be used. By default the age of ID tokens is not checked.
+// Session cookies are not affected by this option.
+func WithMaxTokenAge(maxAge time.Duration) ClientOption {
+	return func(c *Client) {
+		c.maxTokenAge = maxAge
+	}
+}
+
+// WithProjectID returns a ClientOption that specifies the ID of the Firebase project, for which the
+// Client manages users and verifies tokens.
+//
+// By default the project ID of the App is used, which is discovered from its configuration or
+// credentials. This option allows using the Client when the project ID cannot be discovered, e.g.
+// with application default credentials that do not specify a project. The project ID must match
+// the Firebase project that issued the ID tokens and session cookies to be verified, or their
+// verification fails the audience check.
+func WithProjectID(projectID string) ClientOption {
+	return func(c *Client) {
+		c.projectID = projectID
+	}
+}
+
+// WithRetryConfig returns a ClientOption that specifies how the Client retries the calls it makes to
+// the Identity Toolkit backend (e.g. when creating, updating or deleting users).
+//
+// By default only the calls that fail with a QuotaExceededError are retried, up to 3 attempts with
+// a backoff starting at one second. A RetryConfig with a MaxAttempts less than 2 disables all
+// retries. See RetryConfig for the errors that are retried.
+func WithRetryConfig(rc *RetryConfig) ClientOption {
+	return func(c *Client) {
+		c.retryConfig = rc
+	}
+}
+
+// WithSigningConcurrency returns a ClientOption that specifies the maximum number of custom tokens
+// signed at the same time by CustomTokens().
+//
+// Signing several tokens concurrently reduces the time taken to create a large batch of tokens,
+// when the signer makes a remote call for each signature (e.g. on App Engine). By default tokens
+// are signed one at a time.
+func WithSigningConcurrency(n int) ClientOption {
+	return func(c *Client) {
+		c.signingConcurrency = n
+	}
+}
@@ -0,0 +1,1244 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"firebase.google.com/go/internal"
+	"google.golang.org/api/option"
+)
+
+func TestWithKeySource(t *testing.T) {
+	ks := &fileKeySource{FilePath: "../testdata/public_certs.json"}
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+
+	c, err := NewClient(ctx, conf, WithKeySource(ks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ks != ks {
+		t.Errorf("KeySource = %v; want = %v", c.ks, ks)
+	}
+	if _, err := c.VerifyIDToken(ctx, testIDToken); err != nil {
+		t.Errorf("VerifyIDToken() = %v; want = nil", err)
+	}
+}
+
+func TestWithKeySourceError(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+
+	ks := &mockKeySource{nil, errors.New("mock error")}
+	c, err := NewClient(ctx, conf, WithKeySource(ks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.VerifyIDToken(ctx, testIDToken); err == nil {
+		t.Error("VerifyIDToken() = nil; want error")
+	}
+}
+
+func TestWithSessionCookieKeySource(t *testing.T) {
+	ks := &fileKeySource{FilePath: "../testdata/public_certs.json"}
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+
+	c, err := NewClient(ctx, conf, WithSessionCookieKeySource(ks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.cookieKS != ks {
+		t.Errorf("cookieKS = %v; want = %v", c.cookieKS, ks)
+	}
+	if c.ks == KeySource(ks) || c.appCheckKS == KeySource(ks) {
+		t.Errorf("KeySource of other token types = %v; want = default", ks)
+	}
+	if _, err := c.VerifySessionCookie(ctx, getSessionCookie(nil)); err != nil {
+		t.Errorf("VerifySessionCookie() = %v; want = nil", err)
+	}
+}
+
+func TestWithAppCheckKeySource(t *testing.T) {
+	ks := &fileKeySource{FilePath: "../testdata/public_certs.json"}
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+
+	c, err := NewClient(ctx, conf, WithAppCheckKeySource(ks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.appCheckKS != ks {
+		t.Errorf("appCheckKS = %v; want = %v", c.appCheckKS, ks)
+	}
+	if c.ks == KeySource(ks) || c.cookieKS == KeySource(ks) {
+		t.Errorf("KeySource of other token types = %v; want = default", ks)
+	}
+	if _, err := c.VerifyAppCheckToken(ctx, getAppCheckToken(nil)); err != nil {
+		t.Errorf("VerifyAppCheckToken() = %v; want = nil", err)
+	}
+}
+
+func TestWithCustomTokenIssuer(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+	const issuer = "delegate@mock-project.iam.gserviceaccount.com"
+	c, err := NewClient(ctx, conf, WithCustomTokenIssuer(issuer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.snr = client.snr
+
+	token, err := c.CustomToken(ctx, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := c.CustomTokens(ctx, []string{"user2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tk := range append(tokens, token) {
+		p := &customToken{}
+		if err := decodeToken(ctx, tk, client.ks, &jwtHeader{}, p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Iss != issuer || p.Sub != issuer {
+			t.Errorf("CustomToken() (iss, sub) = (%q, %q); want = (%q, %q)", p.Iss, p.Sub, issuer, issuer)
+		}
+	}
+
+	email, err := c.ServiceAccountEmail(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "mock-email@mock-project.iam.gserviceaccount.com"; email != want {
+		t.Errorf("ServiceAccountEmail() = %q; want = %q", email, want)
+	}
+}
+
+func TestCustomTokenKeyID(t *testing.T) {
+	if kid := client.snr.KeyID(); kid != "mock-key-id-1" {
+		t.Errorf("KeyID() = %q; want = %q", kid, "mock-key-id-1")
+	}
+
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+	cases := []struct {
+		name string
+		opts []ClientOption
+		snr  signer
+		want string
+	}{
+		{"ServiceAccountKeyID", nil, client.snr, "mock-key-id-1"},
+		{"UnknownKeyID", nil, serviceAcctSigner{email: "a@b.c", pk: client.snr.(serviceAcctSigner).pk}, ""},
+		{"Override", []ClientOption{WithCustomTokenKeyID("pinned-key")}, client.snr, "pinned-key"},
+	}
+	for _, tc := range cases {
+		c, err := NewClient(ctx, conf, tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.snr = tc.snr
+		token, err := c.CustomToken(ctx, "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := &jwtHeader{}
+		if _, err := decodeUnverifiedToken(token, h, &customToken{}); err != nil {
+			t.Fatal(err)
+		}
+		if h.KeyID != tc.want {
+			t.Errorf("CustomToken(%s) kid = %q; want = %q", tc.name, h.KeyID, tc.want)
+		}
+	}
+}
+
+func TestWithServiceAccountFileKeyID(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf, WithServiceAccountFile("../testdata/service_account.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := c.snr.KeyID(); kid != "mock-key-id-1" {
+		t.Errorf("KeyID() = %q; want = %q", kid, "mock-key-id-1")
+	}
+}
+
+func TestWithCustomTokenIssuerInvalid(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+	if c, err := NewClient(ctx, conf, WithCustomTokenIssuer("not-an-email")); c != nil || err == nil {
+		t.Errorf("NewClient() = (%v, %v); want = (nil, error)", c, err)
+	}
+}
+
+func TestWithApplicationName(t *testing.T) {
+	var gh string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gh = r.Header.Get("X-Client-Version")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		http.ServeFile(w, r, "../testdata/public_certs.json")
+	}))
+	defer srv.Close()
+
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+		Version:   "1.2.3",
+	}
+	c, err := NewClient(ctx, conf, WithApplicationName("billing-service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Go/Admin/1.2.3 billing-service"
+	if c.version != want {
+		t.Errorf("version = %q; want = %q", c.version, want)
+	}
+	if v := c.is.(*identitytoolkitClient).version; v != want {
+		t.Errorf("identitytoolkitClient.version = %q; want = %q", v, want)
+	}
+
+	hks := c.ks.(*httpKeySource)
+	hks.KeyURI = srv.URL
+	if _, err := c.VerifyIDToken(ctx, testIDToken); err != nil {
+		t.Fatal(err)
+	}
+	if gh != want {
+		t.Errorf("X-Client-Version header = %q; want = %q", gh, want)
+	}
+}
+
+func TestWithApplicationNameInvalid(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+	for _, name := range []string{"foo\r\nX-Injected: bar", "foo\n"} {
+		if c, err := NewClient(ctx, conf, WithApplicationName(name)); c != nil || err == nil {
+			t.Errorf("NewClient(%q) = (%v, %v); want = (nil, error)", name, c, err)
+		}
+	}
+}
+
+func TestWithAllowedCustomClaims(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf, WithAllowedCustomClaims([]string{"role", "tier"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.snr = client.snr
+
+	claims := map[string]interface{}{"role": "admin", "tier": "gold"}
+	token, err := c.CustomTokenWithClaims(ctx, "user1", claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyCustomToken(ctx, token, claims, t)
+	if _, err := c.CustomToken(ctx, "user1"); err != nil {
+		t.Errorf("CustomToken() = %v; want = nil", err)
+	}
+
+	cases := []struct {
+		claims map[string]interface{}
+		want   string
+	}{
+		{
+			map[string]interface{}{"role": "admin", "ssn": "123"},
+			`developer claim "ssn" is not in the allowed custom claims`,
+		},
+		{
+			map[string]interface{}{"Role": "admin", "email": "a@b.c", "tier": 2},
+			`developer claims "Role, email" are not in the allowed custom claims`,
+		},
+	}
+	for _, tc := range cases {
+		token, err := c.CustomTokenWithClaims(ctx, "user1", tc.claims)
+		if token != "" || err == nil || err.Error() != tc.want {
+			t.Errorf("CustomTokenWithClaims(%v) = (%q, %v); want = (\"\", %q)", tc.claims, token, err, tc.want)
+		}
+	}
+
+	c, err = NewClient(ctx, conf, WithAllowedCustomClaims(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.snr = client.snr
+	if _, err := c.CustomTokenWithClaims(ctx, "user1", map[string]interface{}{"role": "admin"}); err == nil {
+		t.Errorf("CustomTokenWithClaims() with empty allow-list = nil; want = error")
+	}
+	if _, err := c.CustomToken(ctx, "user1"); err != nil {
+		t.Errorf("CustomToken() with empty allow-list = %v; want = nil", err)
+	}
+}
+
+func TestWithEmulatorHost(t *testing.T) {
+	var paths, auths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		auths = append(auths, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	conf := &internal.AuthConfig{ProjectID: "mock-project-id"}
+	c, err := NewClient(ctx, conf, WithEmulatorHost(strings.TrimPrefix(srv.URL, "http://")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteUser(ctx, "uid1"); err != nil {
+		t.Fatal(err)
+	}
+	tc := *c
+	tc.tenantID = testTenantID
+	if err := tc.DeleteUser(ctx, "uid1"); err != nil {
+		t.Fatal(err)
+	}
+
+	wantPaths := []string{
+		"/www.googleapis.com/identitytoolkit/v3/relyingparty/deleteAccount",
+		"/identitytoolkit.googleapis.com/v1/projects/mock-project-id/tenants/tenant-1/accounts:delete",
+	}
+	if len(paths) != len(wantPaths) {
+		t.Fatalf("requests = %v; want = %v", paths, wantPaths)
+	}
+	for i, want := range wantPaths {
+		if paths[i] != want {
+			t.Errorf("request[%d] path = %q; want = %q", i, paths[i], want)
+		}
+		if auths[i] != "Bearer owner" {
+			t.Errorf("request[%d] Authorization = %q; want = %q", i, auths[i], "Bearer owner")
+		}
+	}
+}
+
+func TestWithEmulatorHostVerifyIDToken(t *testing.T) {
+	conf := &internal.AuthConfig{ProjectID: "mock-project-id"}
+	c, err := NewClient(ctx, conf, WithEmulatorHost("localhost:9099"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	token := getUnsignedToken(t, map[string]interface{}{
+		"aud": "mock-project-id",
+		"iss": "https://securetoken.google.com/mock-project-id",
+		"iat": now - 100,
+		"exp": now + 3600,
+		"sub": "uid1",
+	})
+	ft, err := c.VerifyIDToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ft.UID != "uid1" {
+		t.Errorf("UID = %q; want = %q", ft.UID, "uid1")
+	}
+
+	customToken := getUnsignedToken(t, map[string]interface{}{
+		"aud": firebaseAudience,
+		"iat": now - 100,
+		"exp": now + 3600,
+		"uid": "uid1",
+	})
+	if _, err := c.VerifyIDToken(ctx, customToken); err == nil {
+		t.Error("VerifyIDToken(CustomToken) = nil; want = error")
+	}
+}
+
+func TestNewEmulatorClient(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	c, err := NewEmulatorClient(ctx, "mock-project-id", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteUser(ctx, "uid1"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/www.googleapis.com/identitytoolkit/v3/relyingparty/deleteAccount"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("requests = %v; want = %v", paths, want)
+	}
+
+	token, err := c.CustomToken(ctx, "uid1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 || segments[2] != "" {
+		t.Fatalf("CustomToken() = %q; want = unsigned token", token)
+	}
+	var h jwtHeader
+	var p customToken
+	if err := decode(segments[0], &h); err != nil {
+		t.Fatal(err)
+	}
+	if err := decode(segments[1], &p); err != nil {
+		t.Fatal(err)
+	}
+	if h.Algorithm != "none" || h.KeyID != "" {
+		t.Errorf("header = %#v; want = {Algorithm: none}", h)
+	}
+	if p.Iss != emulatedServiceAccount || p.UID != "uid1" {
+		t.Errorf("payload = %#v; want = {Iss: %q, UID: uid1}", p, emulatedServiceAccount)
+	}
+
+	now := time.Now().Unix()
+	idToken := getUnsignedToken(t, map[string]interface{}{
+		"aud": "mock-project-id",
+		"iss": "https://securetoken.google.com/mock-project-id",
+		"iat": now - 100,
+		"exp": now + 3600,
+		"sub": "uid1",
+	})
+	if _, err := c.VerifyIDToken(ctx, idToken); err != nil {
+		t.Errorf("VerifyIDToken() = %v; want = nil", err)
+	}
+	expired := getUnsignedToken(t, map[string]interface{}{
+		"aud": "mock-project-id",
+		"iss": "https://securetoken.google.com/mock-project-id",
+		"iat": now - 7200,
+		"exp": now - 3600,
+		"sub": "uid1",
+	})
+	if _, err := c.VerifyIDToken(ctx, expired); !IsTokenExpired(err) {
+		t.Errorf("VerifyIDToken(Expired) = %v; want = IsTokenExpired", err)
+	}
+}
+
+func TestNewEmulatorClientError(t *testing.T) {
+	cases := []struct {
+		projectID, host string
+	}{
+		{"", "localhost:9099"},
+		{"mock-project-id", ""},
+	}
+	for _, tc := range cases {
+		if c, err := NewEmulatorClient(ctx, tc.projectID, tc.host); c != nil || err == nil {
+			t.Errorf("NewEmulatorClient(%q, %q) = (%v, %v); want = (nil, error)", tc.projectID, tc.host, c, err)
+		}
+	}
+}
+
+func TestEmulatorHostFromEnv(t *testing.T) {
+	if err := os.Setenv(emulatorHostEnvVar, "localhost:9099"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(emulatorHostEnvVar)
+
+	conf := &internal.AuthConfig{ProjectID: "mock-project-id"}
+	c, err := NewClient(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.emulatorHost != "localhost:9099" {
+		t.Errorf("emulatorHost = %q; want = %q", c.emulatorHost, "localhost:9099")
+	}
+	if want := "http://localhost:9099/identitytoolkit.googleapis.com/v1"; c.url != want {
+		t.Errorf("url = %q; want = %q", c.url, want)
+	}
+}
+
+func getUnsignedToken(t *testing.T, payload map[string]interface{}) string {
+	var segments []string
+	for _, v := range []interface{}{jwtHeader{Algorithm: "none", Type: "JWT"}, payload} {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		segments = append(segments, base64.RawURLEncoding.EncodeToString(b))
+	}
+	return strings.Join(segments, ".") + "."
+}
+
+func TestWithoutCustomTokenDetection(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.noCustomTokenCheck {
+		t.Errorf("noCustomTokenCheck = true; want = false")
+	}
+
+	c, err = NewClient(ctx, conf, WithoutCustomTokenDetection())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.noCustomTokenCheck {
+		t.Errorf("noCustomTokenCheck = false; want = true")
+	}
+}
+
+func TestWithServiceAccountFile(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf, WithServiceAccountFile("../testdata/service_account.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	email, err := c.snr.Email(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "mock-email@mock-project.iam.gserviceaccount.com"; email != want {
+		t.Errorf("Email() = %q; want = %q", email, want)
+	}
+
+	token, err := c.CustomToken(ctx, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decodeToken(ctx, token, client.ks, &jwtHeader{}, &customToken{}); err != nil {
+		t.Errorf("CustomToken() = %v; want = valid token", err)
+	}
+}
+
+func TestWithServiceAccountFileError(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+	cases := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"NoEmail", `{"private_key": "key"}`, "'client_email'"},
+		{"NoPrivateKey", `{"client_email": "test@example.com"}`, "'private_key'"},
+		{"InvalidPrivateKey", `{"client_email": "test@example.com", "private_key": "key"}`, "no private key data"},
+		{"InvalidJSON", `not json`, "failed to parse"},
+	}
+	for _, tc := range cases {
+		f, err := ioutil.TempFile("", "service_account")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.WriteString(tc.content); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		c, err := NewClient(ctx, conf, WithServiceAccountFile(f.Name()))
+		if c != nil || err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("NewClient(%s) = (%v, %v); want = (nil, %q)", tc.name, c, err, tc.want)
+		}
+	}
+
+	if c, err := NewClient(ctx, conf, WithServiceAccountFile("../testdata/no_such_file.json")); c != nil || err == nil {
+		t.Errorf("NewClient(NoSuchFile) = (%v, %v); want = (nil, error)", c, err)
+	}
+}
+
+type countingTransport struct {
+	requests int
+}
+
+func (ct *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ct.requests++
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestWithTransport(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	conf := &internal.AuthConfig{
+		Opts: []option.ClientOption{
+			option.WithTokenSource(&internal.MockTokenSource{AccessToken: "test.token"}),
+		},
+		ProjectID: "mock-project-id",
+	}
+	rt := &countingTransport{}
+	c, err := NewClient(ctx, conf, WithTransport(rt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.is.(*identitytoolkitClient).BasePath = srv.URL + "/"
+	if err := c.DeleteUser(ctx, "uid1"); err != nil {
+		t.Fatal(err)
+	}
+	if rt.requests != 1 {
+		t.Errorf("RoundTrip() calls = %d; want = 1", rt.requests)
+	}
+	if auth != "Bearer test.token" {
+		t.Errorf("Authorization = %q; want = %q", auth, "Bearer test.token")
+	}
+}
+
+func TestWithTransportHTTPClientOption(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      []option.ClientOption{option.WithHTTPClient(http.DefaultClient)},
+		ProjectID: "mock-project-id",
+	}
+	if c, err := NewClient(ctx, conf, WithTransport(&countingTransport{})); c != nil || err == nil {
+		t.Errorf("NewClient() = (%v, %v); want = (nil, error)", c, err)
+	}
+}
+
+func TestWithHTTPTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	conf := &internal.AuthConfig{
+		Opts: []option.ClientOption{
+			option.WithTokenSource(&internal.MockTokenSource{AccessToken: "test.token"}),
+		},
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf, WithHTTPTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.url = srv.URL
+	c.tenantID = testTenantID
+	start := time.Now()
+	if err := c.DeleteUser(ctx, "uid1"); err == nil {
+		t.Errorf("DeleteUser() = nil; want = error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("DeleteUser() took %v; want timeout after 10ms", elapsed)
+	}
+}
+
+func TestWithHTTPTimeoutSharedClient(t *testing.T) {
+	shared := &http.Client{Timeout: 5 * time.Second}
+	conf := &internal.AuthConfig{
+		Opts:      []option.ClientOption{option.WithHTTPClient(shared)},
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf, WithHTTPTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared.Timeout != 5*time.Second {
+		t.Errorf("shared Timeout = %v; want = %v", shared.Timeout, 5*time.Second)
+	}
+	if c.hc.Client.Timeout != 10*time.Millisecond {
+		t.Errorf("Timeout = %v; want = %v", c.hc.Client.Timeout, 10*time.Millisecond)
+	}
+
+	if c, err = NewClient(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	if c.hc.Client.Timeout != 5*time.Second {
+		t.Errorf("Timeout = %v; want = %v", c.hc.Client.Timeout, 5*time.Second)
+	}
+}
+
+func TestWithKeyCacheTTL(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts: []option.ClientOption{
+			option.WithTokenSource(&internal.MockTokenSource{AccessToken: "test.token"}),
+		},
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf, WithKeyCacheTTL(time.Minute, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ks := range []KeySource{c.ks, c.cookieKS, c.appCheckKS} {
+		hks := ks.(*httpKeySource)
+		if hks.MinTTL != time.Minute || hks.MaxTTL != time.Hour {
+			t.Errorf("TTL(%s) = [%v, %v]; want = [%v, %v]", hks.KeyURI, hks.MinTTL, hks.MaxTTL, time.Minute, time.Hour)
+		}
+	}
+}
+
+func TestWithKeyCacheTTLInvalid(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts: []option.ClientOption{
+			option.WithTokenSource(&internal.MockTokenSource{AccessToken: "test.token"}),
+		},
+		ProjectID: "mock-project-id",
+	}
+	cases := []struct {
+		min, max time.Duration
+	}{
+		{-time.Second, 0},
+		{0, -time.Second},
+		{time.Hour, time.Minute},
+	}
+	for _, tc := range cases {
+		c, err := NewClient(ctx, conf, WithKeyCacheTTL(tc.min, tc.max))
+		if c != nil || err == nil {
+			t.Errorf("NewClient(min = %v, max = %v) = (%v, %v); want = (nil, error)", tc.min, tc.max, c, err)
+		}
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+	newClient := func(now time.Time) *Client {
+		c, err := NewClient(ctx, conf, withClock(&mockClock{now: now}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.ks = client.ks
+		c.snr = client.snr
+		return c
+	}
+
+	now := time.Now()
+	current := newClient(now)
+	future := newClient(now.Add(2 * time.Hour))
+	if _, err := current.VerifyIDToken(ctx, testIDToken); err != nil {
+		t.Errorf("VerifyIDToken() = %v; want = nil", err)
+	}
+	if _, err := future.VerifyIDToken(ctx, testIDToken); !IsTokenExpired(err) {
+		t.Errorf("VerifyIDToken() = %v; want = token-expired", err)
+	}
+
+	token, err := future.CustomToken(ctx, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &jwtHeader{}
+	p := &customToken{}
+	if err := decodeToken(ctx, token, client.ks, h, p); err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(2 * time.Hour).Unix(); p.Iat != want {
+		t.Errorf("CustomToken() iat = %d; want = %d", p.Iat, want)
+	}
+}
+
+func TestWithAcceptedProjectIDs(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf, WithAcceptedProjectIDs("old-project-id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ks = client.ks
+
+	for _, projectID := range []string{"mock-project-id", "old-project-id"} {
+		token := getIDToken(mockIDTokenPayload{
+			"aud": projectID,
+			"iss": "https://securetoken.google.com/" + projectID,
+		})
+		ft, err := c.VerifyIDToken(ctx, token)
+		if err != nil {
+			t.Errorf("VerifyIDToken(%s) = %v; want = nil", projectID, err)
+		} else if ft.Audience != projectID {
+			t.Errorf("VerifyIDToken(%s) Audience = %q; want = %q", projectID, ft.Audience, projectID)
+		}
+		if _, err := client.VerifyIDToken(ctx, token); projectID != "mock-project-id" && !IsInvalidAudience(err) {
+			t.Errorf("VerifyIDToken(%s) without option = %v; want = invalid-audience", projectID, err)
+		}
+	}
+
+	cases := []struct {
+		name    string
+		payload mockIDTokenPayload
+		want    func(error) bool
+	}{
+		{"UnknownProject", mockIDTokenPayload{
+			"aud": "other-project-id",
+			"iss": "https://securetoken.google.com/other-project-id",
+		}, IsInvalidAudience},
+		{"MismatchedIssuer", mockIDTokenPayload{
+			"aud": "old-project-id",
+			"iss": "https://securetoken.google.com/mock-project-id",
+		}, IsInvalidIssuer},
+	}
+	for _, tc := range cases {
+		if _, err := c.VerifyIDToken(ctx, getIDToken(tc.payload)); !tc.want(err) {
+			t.Errorf("VerifyIDToken(%s) = %v; want = error", tc.name, err)
+		}
+	}
+}
+
+func TestWithProjectID(t *testing.T) {
+	conf := &internal.AuthConfig{Opts: defaultTestOpts}
+	c, err := NewClient(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ks = client.ks
+	if _, err := c.VerifyIDToken(ctx, testIDToken); err == nil {
+		t.Errorf("VerifyIDToken() = nil; want = error")
+	}
+
+	c, err = NewClient(ctx, conf, WithProjectID("mock-project-id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ks = client.ks
+	if _, err := c.VerifyIDToken(ctx, testIDToken); err != nil {
+		t.Errorf("VerifyIDToken() = %v; want = nil", err)
+	}
+
+	c, err = NewClient(ctx, conf, WithProjectID("other-project-id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ks = client.ks
+	if _, err := c.VerifyIDToken(ctx, testIDToken); !IsInvalidAudience(err) {
+		t.Errorf("VerifyIDToken() = %v; want = invalid-audience", err)
+	}
+}
+
+func TestWithClockSkew(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf, withClock(&mockClock{now: time.Unix(10000, 0)}), WithClockSkew(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ks = client.ks
+
+	cases := []struct {
+		name    string
+		payload mockIDTokenPayload
+		want    func(error) bool
+	}{
+		{"IssuedWithinSkew", mockIDTokenPayload{"iat": 10060, "exp": 13600}, nil},
+		{"ExpiredWithinSkew", mockIDTokenPayload{"iat": 6000, "exp": 9940}, nil},
+		{"IssuedBeyondSkew", mockIDTokenPayload{"iat": 10061, "exp": 13600}, IsTokenUsedTooEarly},
+		{"ExpiredBeyondSkew", mockIDTokenPayload{"iat": 6000, "exp": 9939}, IsTokenExpired},
+	}
+	for _, tc := range cases {
+		_, err := c.VerifyIDToken(ctx, getIDToken(tc.payload))
+		if tc.want == nil && err != nil {
+			t.Errorf("VerifyIDToken(%s) = %v; want = nil", tc.name, err)
+		} else if tc.want != nil && !tc.want(err) {
+			t.Errorf("VerifyIDToken(%s) = %v; want = error", tc.name, err)
+		}
+	}
+}
+
+func TestWithClockSkewNegative(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+	if c, err := NewClient(ctx, conf, WithClockSkew(-time.Second)); c != nil || err == nil {
+		t.Errorf("NewClient(WithClockSkew(-1s)) = (%v, %v); want = (nil, error)", c, err)
+	}
+}
+
+func TestWithIssuedAtLeeway(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf, withClock(&mockClock{now: time.Unix(10000, 0)}), WithIssuedAtLeeway(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ks = client.ks
+
+	cases := []struct {
+		name    string
+		payload mockIDTokenPayload
+		want    func(error) bool
+	}{
+		{"IssuedWithinLeeway", mockIDTokenPayload{"iat": 10060, "exp": 13600}, nil},
+		{"IssuedBeyondLeeway", mockIDTokenPayload{"iat": 10061, "exp": 13600}, IsTokenUsedTooEarly},
+		{"ExpiredWithinLeeway", mockIDTokenPayload{"iat": 6000, "exp": 9999}, IsTokenExpired},
+	}
+	for _, tc := range cases {
+		_, err := c.VerifyIDToken(ctx, getIDToken(tc.payload))
+		if tc.want == nil && err != nil {
+			t.Errorf("VerifyIDToken(%s) = %v; want = nil", tc.name, err)
+		} else if tc.want != nil && !tc.want(err) {
+			t.Errorf("VerifyIDToken(%s) = %v; want = error", tc.name, err)
+		}
+	}
+
+	// The leeway is added to the clock skew.
+	WithClockSkew(time.Minute)(c)
+	if _, err := c.VerifyIDToken(ctx, getIDToken(mockIDTokenPayload{"iat": 10120, "exp": 13600})); err != nil {
+		t.Errorf("VerifyIDToken() = %v; want = nil", err)
+	}
+	if _, err := c.VerifyIDToken(ctx, getIDToken(mockIDTokenPayload{"iat": 10121, "exp": 13600})); !IsTokenUsedTooEarly(err) {
+		t.Errorf("VerifyIDToken() = %v; want = TokenUsedTooEarly", err)
+	}
+}
+
+func TestWithMaxTokenAge(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf, withClock(&mockClock{now: time.Unix(10000, 0)}), WithMaxTokenAge(5*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ks = client.ks
+
+	if _, err := c.VerifyIDToken(ctx, getIDToken(mockIDTokenPayload{"iat": 9700, "exp": 13300})); err != nil {
+		t.Errorf("VerifyIDToken(age = 5m) = %v; want = nil", err)
+	}
+	_, err = c.VerifyIDToken(ctx, getIDToken(mockIDTokenPayload{"iat": 9699, "exp": 13299}))
+	if !IsTokenExpired(err) {
+		t.Errorf("VerifyIDToken(age > 5m) = %v; want = token-expired", err)
+	}
+}
+
+func TestDefaultMaxTokenAge(t *testing.T) {
+	if client.maxTokenAge != 0 {
+		t.Errorf("Client.maxTokenAge = %v; want = 0", client.maxTokenAge)
+	}
+}
+
+func TestDefaultClockSkew(t *testing.T) {
+	if client.clockSkew != 0 {
+		t.Errorf("Client.clockSkew = %v; want = 0", client.clockSkew)
+	}
+}
+
+func TestDefaultClock(t *testing.T) {
+	if _, ok := client.clock.(systemClock); !ok {
+		t.Errorf("Client.clock = %T; want = systemClock", client.clock)
+	}
+}
+
+type observation struct {
+	op  string
+	err error
+}
+
+func TestWithMaxResponseSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		http.ServeFile(w, r, "../testdata/public_certs.json")
+	}))
+	defer srv.Close()
+
+	conf := &internal.AuthConfig{
+		Opts: []option.ClientOption{
+			option.WithTokenSource(&internal.MockTokenSource{AccessToken: "test.token"}),
+		},
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf, WithMaxResponseSize(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ks = newHTTPKeySource(srv.URL, c.hc.Client)
+	we := "http response body exceeds the maximum size of 100 bytes"
+	if _, err := c.VerifyIDToken(ctx, testIDToken); err == nil || !strings.HasSuffix(err.Error(), we) {
+		t.Errorf("VerifyIDToken() = %v; want = %q", err, we)
+	}
+
+	c, err = NewClient(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ks = newHTTPKeySource(srv.URL, c.hc.Client)
+	if _, err := c.VerifyIDToken(ctx, testIDToken); err != nil {
+		t.Errorf("VerifyIDToken() with default limit = %v; want = nil", err)
+	}
+}
+
+func TestWithMaxResponseSizeSharedClient(t *testing.T) {
+	rt := &http.Transport{}
+	shared := &http.Client{Transport: rt}
+	conf := &internal.AuthConfig{
+		Opts:      []option.ClientOption{option.WithHTTPClient(shared)},
+		ProjectID: "mock-project-id",
+	}
+	for i := 0; i < 2; i++ {
+		c, err := NewClient(ctx, conf, WithMaxResponseSize(100))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if shared.Transport != rt {
+			t.Fatalf("shared Transport = %#v; want = %#v", shared.Transport, rt)
+		}
+		lt, ok := c.hc.Client.Transport.(*limitedTransport)
+		if !ok || lt.base != rt {
+			t.Errorf("Transport = %#v; want = limitedTransport{base: %#v}", c.hc.Client.Transport, rt)
+		}
+	}
+}
+
+func TestWithMaxResponseSizeInvalid(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts: []option.ClientOption{
+			option.WithTokenSource(&internal.MockTokenSource{AccessToken: "test.token"}),
+		},
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf, WithMaxResponseSize(-1))
+	if c != nil || err == nil {
+		t.Errorf("NewClient(WithMaxResponseSize(-1)) = (%v, %v); want = (nil, error)", c, err)
+	}
+}
+
+func TestWithObservabilityHook(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	var observed []observation
+	hook := ObservabilityHookFunc(func(ctx context.Context, op string, d time.Duration, err error) {
+		observed = append(observed, observation{op, err})
+	})
+	conf := &internal.AuthConfig{
+		Opts: []option.ClientOption{
+			option.WithTokenSource(&internal.MockTokenSource{AccessToken: "test.token"}),
+		},
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf, WithObservabilityHook(hook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.is.(*identitytoolkitClient).BasePath = srv.URL + "/"
+	if err := c.DeleteUser(ctx, "uid1"); err != nil {
+		t.Fatal(err)
+	}
+	status = http.StatusInternalServerError
+	if err := c.DeleteUser(ctx, "uid1"); err == nil {
+		t.Fatal("DeleteUser() = nil; want = error")
+	}
+
+	if len(observed) != 2 {
+		t.Fatalf("Observe() calls = %d; want = 2", len(observed))
+	}
+	want := "POST " + srv.URL + "/deleteAccount"
+	for i, o := range observed {
+		if o.op != want {
+			t.Errorf("Observe(%d) op = %q; want = %q", i, o.op, want)
+		}
+	}
+	if observed[0].err != nil {
+		t.Errorf("Observe(0) err = %v; want = nil", observed[0].err)
+	}
+	if observed[1].err == nil {
+		t.Errorf("Observe(1) err = nil; want = error")
+	}
+}
+
+func TestWithObservabilityHookBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(50 * time.Millisecond)
+		b, _ := ioutil.ReadFile("../testdata/public_certs.json")
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	var durations []time.Duration
+	var errs []error
+	hook := ObservabilityHookFunc(func(ctx context.Context, op string, d time.Duration, err error) {
+		durations = append(durations, d)
+		errs = append(errs, err)
+	})
+	conf := &internal.AuthConfig{
+		Opts: []option.ClientOption{
+			option.WithTokenSource(&internal.MockTokenSource{AccessToken: "test.token"}),
+		},
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf, WithObservabilityHook(hook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ks = newHTTPKeySource(srv.URL, c.hc.Client)
+	if _, err := c.VerifyIDToken(ctx, testIDToken); err != nil {
+		t.Fatal(err)
+	}
+	if len(durations) != 1 || durations[0] < 50*time.Millisecond || errs[0] != nil {
+		t.Errorf("Observe() = (%v, %v); want = (>= 50ms, nil)", durations, errs)
+	}
+
+	durations, errs = nil, nil
+	if c, err = NewClient(ctx, conf, WithObservabilityHook(hook), WithMaxResponseSize(100)); err != nil {
+		t.Fatal(err)
+	}
+	c.ks = newHTTPKeySource(srv.URL, c.hc.Client)
+	if _, err := c.VerifyIDToken(ctx, testIDToken); err == nil {
+		t.Fatal("VerifyIDToken() = nil; want = error")
+	}
+	if len(errs) != 1 || errs[0] == nil || !strings.Contains(errs[0].Error(), "maximum size") {
+		t.Errorf("Observe() err = %v; want = response size error", errs)
+	}
+}
+
+func TestWithKeyRefreshLogger(t *testing.T) {
+	conf := &internal.AuthConfig{
+		Opts:      defaultTestOpts,
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ks := range []KeySource{c.ks, c.cookieKS, c.appCheckKS} {
+		hks := ks.(*httpKeySource)
+		if reflect.ValueOf(hks.Logf).Pointer() != reflect.ValueOf(log.Printf).Pointer() {
+			t.Errorf("Logf(%s) != log.Printf", hks.KeyURI)
+		}
+	}
+
+	var logs []string
+	logf := func(format string, v ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, v...))
+	}
+	if c, err = NewClient(ctx, conf, WithKeyRefreshLogger(logf)); err != nil {
+		t.Fatal(err)
+	}
+	for _, ks := range []KeySource{c.ks, c.cookieKS, c.appCheckKS} {
+		ks.(*httpKeySource).Logf("refresh failed")
+	}
+	if len(logs) != 3 {
+		t.Errorf("logs = %v; want = 3 entries", logs)
+	}
+}
+
+func TestWithObservabilityHookSharedClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		http.ServeFile(w, r, "../testdata/public_certs.json")
+	}))
+	defer srv.Close()
+
+	var ops []string
+	hook := ObservabilityHookFunc(func(ctx context.Context, op string, d time.Duration, err error) {
+		ops = append(ops, op)
+	})
+	shared := &http.Client{}
+	conf := &internal.AuthConfig{
+		Opts:      []option.ClientOption{option.WithHTTPClient(shared)},
+		ProjectID: "mock-project-id",
+	}
+	var c *Client
+	for i := 0; i < 2; i++ {
+		var err error
+		if c, err = NewClient(ctx, conf, WithObservabilityHook(hook)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, err := shared.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(ops) != 0 {
+		t.Errorf("Observe() ops = %v; want = []", ops)
+	}
+
+	c.ks = newHTTPKeySource(srv.URL+"/certs", c.hc.Client)
+	if _, err := c.VerifyIDToken(ctx, testIDToken); err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 1 {
+		t.Errorf("Observe() ops = %v; want = 1 op", ops)
+	}
+}
+
+func TestWithObservabilityHookKeySource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		http.ServeFile(w, r, "../testdata/public_certs.json")
+	}))
+	defer srv.Close()
+
+	var ops []string
+	hook := ObservabilityHookFunc(func(ctx context.Context, op string, d time.Duration, err error) {
+		ops = append(ops, op)
+	})
+	conf := &internal.AuthConfig{
+		Opts: []option.ClientOption{
+			option.WithTokenSource(&internal.MockTokenSource{AccessToken: "test.token"}),
+		},
+		ProjectID: "mock-project-id",
+	}
+	c, err := NewClient(ctx, conf, WithObservabilityHook(hook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ks = newHTTPKeySource(srv.URL+"/certs?v=1", c.hc.Client)
+	if _, err := c.VerifyIDToken(ctx, testIDToken); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"GET " + srv.URL + "/certs"}; len(ops) != 1 || ops[0] != want[0] {
+		t.Errorf("Observe() ops = %v; want = %v", ops, want)
+	}
+}
@@ -0,0 +1,798 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"firebase.google.com/go/internal"
+	"golang.org/x/net/context"
+
+	"google.golang.org/api/iterator"
+)
+
+const (
+	maxConfigPageSize = 100
+
+	oidcConfigsPath = "/oauthIdpConfigs"
+	samlConfigsPath = "/inboundSamlConfigs"
+
+	oidcConfigIDParam = "oauthIdpConfigId"
+	samlConfigIDParam = "inboundSamlConfigId"
+
+	oidcProviderIDPrefix = "oidc."
+	samlProviderIDPrefix = "saml."
+
+	clientIDKey    = "clientId"
+	issuerKey      = "issuer"
+	displayNameKey = "displayName"
+	enabledKey     = "enabled"
+	idpEntityIDKey = "idpConfig.idpEntityId"
+	ssoURLKey      = "idpConfig.ssoUrl"
+	signRequestKey = "idpConfig.signRequest"
+	idpCertsKey    = "idpConfig.idpCertificates"
+	rpEntityIDKey  = "spConfig.spEntityId"
+	callbackURLKey = "spConfig.callbackUri"
+)
+
+// nestedMap is a map of request parameters, where keys are dot-separated paths into nested JSON
+// objects. It serializes into the corresponding nested JSON structure.
+type nestedMap map[string]interface{}
+
+func (nm nestedMap) get(key string) (interface{}, bool) {
+	segments := strings.Split(key, ".")
+	curr := map[string]interface{}(nm)
+	for _, segment := range segments[:len(segments)-1] {
+		child, ok := curr[segment]
+		if !ok {
+			return nil, false
+		}
+		curr = child.(map[string]interface{})
+	}
+	val, ok := curr[segments[len(segments)-1]]
+	return val, ok
+}
+
+func (nm nestedMap) set(key string, value interface{}) {
+	segments := strings.Split(key, ".")
+	curr := map[string]interface{}(nm)
+	for _, segment := range segments[:len(segments)-1] {
+		child, ok := curr[segment]
+		if !ok {
+			child = make(map[string]interface{})
+			curr[segment] = child
+		}
+		curr = child.(map[string]interface{})
+	}
+	curr[segments[len(segments)-1]] = value
+}
+
+// updateMask returns the comma-separated, sorted list of the leaf paths in the map.
+func (nm nestedMap) updateMask() string {
+	var paths []string
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			if child, ok := v.(map[string]interface{}); ok {
+				walk(prefix+k+".", child)
+			} else {
+				paths = append(paths, prefix+k)
+			}
+		}
+	}
+	walk("", nm)
+	sort.Strings(paths)
+	return strings.Join(paths, ",")
+}
+
+// OIDCProviderConfig is the OIDC auth provider configuration.
+// See http://openid.net/specs/openid-connect-core-1_0-final.html.
+type OIDCProviderConfig struct {
+	ID          string
+	DisplayName string
+	Enabled     bool
+	ClientID    string
+	Issuer      string
+}
+
+// OIDCProviderConfigToCreate represents the options used to create a new OIDCProviderConfig.
+type OIDCProviderConfigToCreate struct {
+	id     string
+	params nestedMap
+}
+
+func (config *OIDCProviderConfigToCreate) set(key string, value interface{}) *OIDCProviderConfigToCreate {
+	if config.params == nil {
+		config.params = make(nestedMap)
+	}
+	config.params.set(key, value)
+	return config
+}
+
+// ID sets the provider ID of the new config. Must start with the "oidc." prefix.
+func (config *OIDCProviderConfigToCreate) ID(id string) *OIDCProviderConfigToCreate {
+	config.id = id
+	return config
+}
+
+// ClientID sets the client ID of the new config.
+func (config *OIDCProviderConfigToCreate) ClientID(clientID string) *OIDCProviderConfigToCreate {
+	return config.set(clientIDKey, clientID)
+}
+
+// Issuer sets the issuer of the new config. Must be a valid URL.
+func (config *OIDCProviderConfigToCreate) Issuer(issuer string) *OIDCProviderConfigToCreate {
+	return config.set(issuerKey, issuer)
+}
+
+// DisplayName sets the DisplayName field of the new config.
+func (config *OIDCProviderConfigToCreate) DisplayName(name string) *OIDCProviderConfigToCreate {
+	return config.set(displayNameKey, name)
+}
+
+// Enabled enables or disables the new config.
+func (config *OIDCProviderConfigToCreate) Enabled(enabled bool) *OIDCProviderConfigToCreate {
+	return config.set(enabledKey, enabled)
+}
+
+func (config *OIDCProviderConfigToCreate) buildRequest() (nestedMap, string, error) {
+	if err := validateOIDCConfigID(config.id); err != nil {
+		return nil, "", err
+	}
+	if len(config.params) == 0 {
+		return nil, "", errors.New("no parameters specified in the create request")
+	}
+	if val, ok := config.params.get(clientIDKey); !ok || val.(string) == "" {
+		return nil, "", errors.New("ClientID must not be empty")
+	}
+	if err := validateConfigURL(config.params, issuerKey, "Issuer", true); err != nil {
+		return nil, "", err
+	}
+	return config.params, config.id, nil
+}
+
+// OIDCProviderConfigToUpdate represents the options used to update an existing
+// OIDCProviderConfig.
+type OIDCProviderConfigToUpdate struct {
+	params nestedMap
+}
+
+func (config *OIDCProviderConfigToUpdate) set(key string, value interface{}) *OIDCProviderConfigToUpdate {
+	if config.params == nil {
+		config.params = make(nestedMap)
+	}
+	config.params.set(key, value)
+	return config
+}
+
+// ClientID updates the client ID of the config.
+func (config *OIDCProviderConfigToUpdate) ClientID(clientID string) *OIDCProviderConfigToUpdate {
+	return config.set(clientIDKey, clientID)
+}
+
+// Issuer updates the issuer of the config. Must be a valid URL.
+func (config *OIDCProviderConfigToUpdate) Issuer(issuer string) *OIDCProviderConfigToUpdate {
+	return config.set(issuerKey, issuer)
+}
+
+// DisplayName updates the DisplayName field of the config.
+func (config *OIDCProviderConfigToUpdate) DisplayName(name string) *OIDCProviderConfigToUpdate {
+	return config.set(displayNameKey, name)
+}
+
+// Enabled enables or disables the config.
+func (config *OIDCProviderConfigToUpdate) Enabled(enabled bool) *OIDCProviderConfigToUpdate {
+	return config.set(enabledKey, enabled)
+}
+
+func (config *OIDCProviderConfigToUpdate) buildRequest() (nestedMap, error) {
+	if config == nil || len(config.params) == 0 {
+		return nil, errors.New("no parameters specified in the update request")
+	}
+	if val, ok := config.params.get(clientIDKey); ok && val.(string) == "" {
+		return nil, errors.New("ClientID must not be empty")
+	}
+	if err := validateConfigURL(config.params, issuerKey, "Issuer", false); err != nil {
+		return nil, err
+	}
+	return config.params, nil
+}
+
+// ProviderConfigListOption is an option for the OIDCProviderConfigs() and SAMLProviderConfigs()
+// functions.
+type ProviderConfigListOption func(*providerConfigListConfig)
+
+type providerConfigListConfig struct {
+	enabledOnly bool
+}
+
+// WithEnabledOnly returns a ProviderConfigListOption that excludes the disabled provider
+// configurations from the results.
+//
+// The configurations are filtered by the iterator, after they are fetched from the backend. As a
+// result, a page of results may contain fewer configurations than the requested page size.
+func WithEnabledOnly() ProviderConfigListOption {
+	return func(conf *providerConfigListConfig) {
+		conf.enabledOnly = true
+	}
+}
+
+// OIDCProviderConfigIterator is an iterator over OIDC provider configurations.
+//
+// Also see: https://github.com/GoogleCloudPlatform/google-cloud-go/wiki/Iterator-Guidelines
+type OIDCProviderConfigIterator struct {
+	client   *Client
+	ctx      context.Context
+	nextFunc func() error
+	pageInfo *iterator.PageInfo
+	configs  []*OIDCProviderConfig
+	listConf providerConfigListConfig
+}
+
+// PageInfo supports pagination. See the google.golang.org/api/iterator package for details.
+func (it *OIDCProviderConfigIterator) PageInfo() *iterator.PageInfo { return it.pageInfo }
+
+// Next returns the next OIDCProviderConfig. Its second return value is [iterator.Done] if there
+// are no more results. Once Next returns [iterator.Done], all subsequent calls will return
+// [iterator.Done].
+func (it *OIDCProviderConfigIterator) Next() (*OIDCProviderConfig, error) {
+	if err := it.nextFunc(); err != nil {
+		return nil, err
+	}
+	config := it.configs[0]
+	it.configs = it.configs[1:]
+	return config, nil
+}
+
+func (it *OIDCProviderConfigIterator) fetch(pageSize int, pageToken string) (string, error) {
+	var resp struct {
+		Configs       []*oidcProviderConfigDAO `json:"oauthIdpConfigs"`
+		NextPageToken string                   `json:"nextPageToken"`
+	}
+	if err := it.client.listConfigs(it.ctx, oidcConfigsPath, pageSize, pageToken, &resp); err != nil {
+		return "", err
+	}
+	for _, config := range resp.Configs {
+		if it.listConf.enabledOnly && !config.Enabled {
+			continue
+		}
+		it.configs = append(it.configs, config.toOIDCProviderConfig())
+	}
+	it.pageInfo.Token = resp.NextPageToken
+	return resp.NextPageToken, nil
+}
+
+// SAMLProviderConfig is the SAML auth provider configuration.
+// See http://docs.oasis-open.org/security/saml/Post2.0/sstc-saml-tech-overview-2.0.html.
+type SAMLProviderConfig struct {
+	ID                    string
+	DisplayName           string
+	Enabled               bool
+	IDPEntityID           string
+	SSOURL                string
+	RequestSigningEnabled bool
+	X509Certificates      []string
+	RPEntityID            string
+	CallbackURL           string
+}
+
+// SAMLProviderConfigToCreate represents the options used to create a new SAMLProviderConfig.
+type SAMLProviderConfigToCreate struct {
+	id     string
+	params nestedMap
+}
+
+func (config *SAMLProviderConfigToCreate) set(key string, value interface{}) *SAMLProviderConfigToCreate {
+	if config.params == nil {
+		config.params = make(nestedMap)
+	}
+	config.params.set(key, value)
+	return config
+}
+
+// ID sets the provider ID of the new config. Must start with the "saml." prefix.
+func (config *SAMLProviderConfigToCreate) ID(id string) *SAMLProviderConfigToCreate {
+	config.id = id
+	return config
+}
+
+// IDPEntityID sets the IDPEntityID field of the new config.
+func (config *SAMLProviderConfigToCreate) IDPEntityID(entityID string) *SAMLProviderConfigToCreate {
+	return config.set(idpEntityIDKey, entityID)
+}
+
+// SSOURL sets the SSOURL field of the new config. Must be a valid URL.
+func (config *SAMLProviderConfigToCreate) SSOURL(url string) *SAMLProviderConfigToCreate {
+	return config.set(ssoURLKey, url)
+}
+
+// RequestSigningEnabled enables or disables the request signing support.
+func (config *SAMLProviderConfigToCreate) RequestSigningEnabled(enabled bool) *SAMLProviderConfigToCreate {
+	return config.set(signRequestKey, enabled)
+}
+
+// X509Certificates sets the certificates used by the IDP to sign SAML responses. At least one
+// certificate must be specified.
+func (config *SAMLProviderConfigToCreate) X509Certificates(certs []string) *SAMLProviderConfigToCreate {
+	return config.set(idpCertsKey, idpCertificates(certs))
+}
+
+// RPEntityID sets the RPEntityID field of the new config.
+func (config *SAMLProviderConfigToCreate) RPEntityID(entityID string) *SAMLProviderConfigToCreate {
+	return config.set(rpEntityIDKey, entityID)
+}
+
+// CallbackURL sets the CallbackURL field of the new config. Must be a valid URL.
+func (config *SAMLProviderConfigToCreate) CallbackURL(url string) *SAMLProviderConfigToCreate {
+	return config.set(callbackURLKey, url)
+}
+
+// DisplayName sets the DisplayName field of the new config.
+func (config *SAMLProviderConfigToCreate) DisplayName(name string) *SAMLProviderConfigToCreate {
+	return config.set(displayNameKey, name)
+}
+
+// Enabled enables or disables the new config.
+func (config *SAMLProviderConfigToCreate) Enabled(enabled bool) *SAMLProviderConfigToCreate {
+	return config.set(enabledKey, enabled)
+}
+
+func (config *SAMLProviderConfigToCreate) buildRequest() (nestedMap, string, error) {
+	if err := validateSAMLConfigID(config.id); err != nil {
+		return nil, "", err
+	}
+	if len(config.params) == 0 {
+		return nil, "", errors.New("no parameters specified in the create request")
+	}
+	if val, ok := config.params.get(idpEntityIDKey); !ok || val.(string) == "" {
+		return nil, "", errors.New("IDPEntityID must not be empty")
+	}
+	if err := validateConfigURL(config.params, ssoURLKey, "SSOURL", true); err != nil {
+		return nil, "", err
+	}
+	if val, ok := config.params.get(idpCertsKey); !ok || len(val.([]interface{})) == 0 {
+		return nil, "", errors.New("X509Certificates must not be empty")
+	} else if err := validateX509Certificates(val.([]interface{})); err != nil {
+		return nil, "", err
+	}
+	if val, ok := config.params.get(rpEntityIDKey); !ok || val.(string) == "" {
+		return nil, "", errors.New("RPEntityID must not be empty")
+	}
+	if err := validateConfigURL(config.params, callbackURLKey, "CallbackURL", true); err != nil {
+		return nil, "", err
+	}
+	return config.params, config.id, nil
+}
+
+// SAMLProviderConfigToUpdate represents the options used to update an existing
+// SAMLProviderConfig.
+type SAMLProviderConfigToUpdate struct {
+	params nestedMap
+}
+
+func (config *SAMLProviderConfigToUpdate) set(key string, value interface{}) *SAMLProviderConfigToUpdate {
+	if config.params == nil {
+		config.params = make(nestedMap)
+	}
+	config.params.set(key, value)
+	return config
+}
+
+// IDPEntityID updates the IDPEntityID field of the config.
+func (config *SAMLProviderConfigToUpdate) IDPEntityID(entityID string) *SAMLProviderConfigToUpdate {
+	return config.set(idpEntityIDKey, entityID)
+}
+
+// SSOURL updates the SSOURL field of the config. Must be a valid URL.
+func (config *SAMLProviderConfigToUpdate) SSOURL(url string) *SAMLProviderConfigToUpdate {
+	return config.set(ssoURLKey, url)
+}
+
+// RequestSigningEnabled enables or disables the request signing support.
+func (config *SAMLProviderConfigToUpdate) RequestSigningEnabled(enabled bool) *SAMLProviderConfigToUpdate {
+	return config.set(signRequestKey, enabled)
+}
+
+// X509Certificates updates the certificates used by the IDP to sign SAML responses.
+func (config *SAMLProviderConfigToUpdate) X509Certificates(certs []string) *SAMLProviderConfigToUpdate {
+	return config.set(idpCertsKey, idpCertificates(certs))
+}
+
+// RPEntityID updates the RPEntityID field of the config.
+func (config *SAMLProviderConfigToUpdate) RPEntityID(entityID string) *SAMLProviderConfigToUpdate {
+	return config.set(rpEntityIDKey, entityID)
+}
+
+// CallbackURL updates the CallbackURL field of the config. Must be a valid URL.
+func (config *SAMLProviderConfigToUpdate) CallbackURL(url string) *SAMLProviderConfigToUpdate {
+	return config.set(callbackURLKey, url)
+}
+
+// DisplayName updates the DisplayName field of the config.
+func (config *SAMLProviderConfigToUpdate) DisplayName(name string) *SAMLProviderConfigToUpdate {
+	return config.set(displayNameKey, name)
+}
+
+// Enabled enables or disables the config.
+func (config *SAMLProviderConfigToUpdate) Enabled(enabled bool) *SAMLProviderConfigToUpdate {
+	return config.set(enabledKey, enabled)
+}
+
+func (config *SAMLProviderConfigToUpdate) buildRequest() (nestedMap, error) {
+	if config == nil || len(config.params) == 0 {
+		return nil, errors.New("no parameters specified in the update request")
+	}
+	if val, ok := config.params.get(idpEntityIDKey); ok && val.(string) == "" {
+		return nil, errors.New("IDPEntityID must not be empty")
+	}
+	if err := validateConfigURL(config.params, ssoURLKey, "SSOURL", false); err != nil {
+		return nil, err
+	}
+	if val, ok := config.params.get(idpCertsKey); ok {
+		if len(val.([]interface{})) == 0 {
+			return nil, errors.New("X509Certificates must not be empty")
+		}
+		if err := validateX509Certificates(val.([]interface{})); err != nil {
+			return nil, err
+		}
+	}
+	if val, ok := config.params.get(rpEntityIDKey); ok && val.(string) == "" {
+		return nil, errors.New("RPEntityID must not be empty")
+	}
+	if err := validateConfigURL(config.params, callbackURLKey, "CallbackURL", false); err != nil {
+		return nil, err
+	}
+	return config.params, nil
+}
+
+// SAMLProviderConfigIterator is an iterator over SAML provider configurations.
+//
+// Also see: https://github.com/GoogleCloudPlatform/google-cloud-go/wiki/Iterator-Guidelines
+type SAMLProviderConfigIterator struct {
+	client   *Client
+	ctx      context.Context
+	nextFunc func() error
+	pageInfo *iterator.PageInfo
+	configs  []*SAMLProviderConfig
+	listConf providerConfigListConfig
+}
+
+// PageInfo supports pagination. See the google.golang.org/api/iterator package for details.
+func (it *SAMLProviderConfigIterator) PageInfo() *iterator.PageInfo { return it.pageInfo }
+
+// Next returns the next SAMLProviderConfig. Its second return value is [iterator.Done] if there
+// are no more results. Once Next returns [iterator.Done], all subsequent calls will return
+// [iterator.Done].
+func (it *SAMLProviderConfigIterator) Next() (*SAMLProviderConfig, error) {
+	if err := it.nextFunc(); err != nil {
+		return nil, err
+	}
+	config := it.configs[0]
+	it.configs = it.configs[1:]
+	return config, nil
+}
+
+func (it *SAMLProviderConfigIterator) fetch(pageSize int, pageToken string) (string, error) {
+	var resp struct {
+		Configs       []*samlProviderConfigDAO `json:"inboundSamlConfigs"`
+		NextPageToken string                   `json:"nextPageToken"`
+	}
+	if err := it.client.listConfigs(it.ctx, samlConfigsPath, pageSize, pageToken, &resp); err != nil {
+		return "", err
+	}
+	for _, config := range resp.Configs {
+		if it.listConf.enabledOnly && !config.Enabled {
+			continue
+		}
+		it.configs = append(it.configs, config.toSAMLProviderConfig())
+	}
+	it.pageInfo.Token = resp.NextPageToken
+	return resp.NextPageToken, nil
+}
+
+// OIDCProviderConfig returns the OIDCProviderConfig with the given ID.
+func (c *Client) OIDCProviderConfig(ctx context.Context, id string) (*OIDCProviderConfig, error) {
+	if err := validateOIDCConfigID(id); err != nil {
+		return nil, err
+	}
+
+	var result oidcProviderConfigDAO
+	if err := c.makeV2HTTPCall(ctx, http.MethodGet, oidcConfigsPath+"/"+id, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.toOIDCProviderConfig(), nil
+}
+
+// CreateOIDCProviderConfig creates a new OIDC provider config from the given parameters.
+//
+// The provider ID must start with the "oidc." prefix, and the client ID and issuer must be
+// specified. These are validated before any network call is made.
+func (c *Client) CreateOIDCProviderConfig(ctx context.Context, config *OIDCProviderConfigToCreate) (*OIDCProviderConfig, error) {
+	if config == nil {
+		return nil, errors.New("config must not be nil")
+	}
+	body, id, err := config.buildRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	var result oidcProviderConfigDAO
+	opt := internal.WithQueryParam(oidcConfigIDParam, id)
+	if err := c.makeV2HTTPCall(ctx, http.MethodPost, oidcConfigsPath, body, &result, opt); err != nil {
+		return nil, err
+	}
+	return result.toOIDCProviderConfig(), nil
+}
+
+// UpdateOIDCProviderConfig updates an existing OIDC provider config with the given parameters.
+//
+// Only the fields set on the OIDCProviderConfigToUpdate are modified.
+func (c *Client) UpdateOIDCProviderConfig(ctx context.Context, id string, config *OIDCProviderConfigToUpdate) (*OIDCProviderConfig, error) {
+	if err := validateOIDCConfigID(id); err != nil {
+		return nil, err
+	}
+	body, err := config.buildRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	var result oidcProviderConfigDAO
+	opt := internal.WithQueryParam("updateMask", body.updateMask())
+	if err := c.makeV2HTTPCall(ctx, http.MethodPatch, oidcConfigsPath+"/"+id, body, &result, opt); err != nil {
+		return nil, err
+	}
+	return result.toOIDCProviderConfig(), nil
+}
+
+// DeleteOIDCProviderConfig deletes the OIDCProviderConfig with the given ID.
+func (c *Client) DeleteOIDCProviderConfig(ctx context.Context, id string) error {
+	if err := validateOIDCConfigID(id); err != nil {
+		return err
+	}
+	return c.makeV2HTTPCall(ctx, http.MethodDelete, oidcConfigsPath+"/"+id, nil, nil)
+}
+
+// OIDCProviderConfigs returns an iterator over OIDC provider configurations.
+//
+// If nextPageToken is empty, the iterator will start at the beginning. If the nextPageToken is not
+// empty, the iterator starts after the token. Use WithEnabledOnly() to list only the enabled
+// provider configurations.
+func (c *Client) OIDCProviderConfigs(
+	ctx context.Context, nextPageToken string, opts ...ProviderConfigListOption) *OIDCProviderConfigIterator {
+	it := &OIDCProviderConfigIterator{
+		ctx:    ctx,
+		client: c,
+	}
+	for _, opt := range opts {
+		opt(&it.listConf)
+	}
+	it.pageInfo, it.nextFunc = iterator.NewPageInfo(
+		it.fetch,
+		func() int { return len(it.configs) },
+		func() interface{} { b := it.configs; it.configs = nil; return b })
+	it.pageInfo.MaxSize = maxConfigPageSize
+	it.pageInfo.Token = nextPageToken
+	return it
+}
+
+// SAMLProviderConfig returns the SAMLProviderConfig with the given ID.
+func (c *Client) SAMLProviderConfig(ctx context.Context, id string) (*SAMLProviderConfig, error) {
+	if err := validateSAMLConfigID(id); err != nil {
+		return nil, err
+	}
+
+	var result samlProviderConfigDAO
+	if err := c.makeV2HTTPCall(ctx, http.MethodGet, samlConfigsPath+"/"+id, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.toSAMLProviderConfig(), nil
+}
+
+// CreateSAMLProviderConfig creates a new SAML provider config from the given parameters.
+//
+// The provider ID must start with the "saml." prefix, and the IDP entity ID, SSO URL, X509
+// certificates, RP entity ID and callback URL must be specified. These are validated before any
+// network call is made.
+func (c *Client) CreateSAMLProviderConfig(ctx context.Context, config *SAMLProviderConfigToCreate) (*SAMLProviderConfig, error) {
+	if config == nil {
+		return nil, errors.New("config must not be nil")
+	}
+	body, id, err := config.buildRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	var result samlProviderConfigDAO
+	opt := internal.WithQueryParam(samlConfigIDParam, id)
+	if err := c.makeV2HTTPCall(ctx, http.MethodPost, samlConfigsPath, body, &result, opt); err != nil {
+		return nil, err
+	}
+	return result.toSAMLProviderConfig(), nil
+}
+
+// UpdateSAMLProviderConfig updates an existing SAML provider config with the given parameters.
+//
+// Only the fields set on the SAMLProviderConfigToUpdate are modified.
+func (c *Client) UpdateSAMLProviderConfig(ctx context.Context, id string, config *SAMLProviderConfigToUpdate) (*SAMLProviderConfig, error) {
+	if err := validateSAMLConfigID(id); err != nil {
+		return nil, err
+	}
+	body, err := config.buildRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	var result samlProviderConfigDAO
+	opt := internal.WithQueryParam("updateMask", body.updateMask())
+	if err := c.makeV2HTTPCall(ctx, http.MethodPatch, samlConfigsPath+"/"+id, body, &result, opt); err != nil {
+		return nil, err
+	}
+	return result.toSAMLProviderConfig(), nil
+}
+
+// DeleteSAMLProviderConfig deletes the SAMLProviderConfig with the given ID.
+func (c *Client) DeleteSAMLProviderConfig(ctx context.Context, id string) error {
+	if err := validateSAMLConfigID(id); err != nil {
+		return err
+	}
+	return c.makeV2HTTPCall(ctx, http.MethodDelete, samlConfigsPath+"/"+id, nil, nil)
+}
+
+// SAMLProviderConfigs returns an iterator over SAML provider configurations.
+//
+// If nextPageToken is empty, the iterator will start at the beginning. If the nextPageToken is not
+// empty, the iterator starts after the token. Use WithEnabledOnly() to list only the enabled
+// provider configurations.
+func (c *Client) SAMLProviderConfigs(
+	ctx context.Context, nextPageToken string, opts ...ProviderConfigListOption) *SAMLProviderConfigIterator {
+	it := &SAMLProviderConfigIterator{
+		ctx:    ctx,
+		client: c,
+	}
+	for _, opt := range opts {
+		opt(&it.listConf)
+	}
+	it.pageInfo, it.nextFunc = iterator.NewPageInfo(
+		it.fetch,
+		func() int { return len(it.configs) },
+		func() interface{} { b := it.configs; it.configs = nil; return b })
+	it.pageInfo.MaxSize = maxConfigPageSize
+	it.pageInfo.Token = nextPageToken
+	return it
+}
+
+func (c *Client) listConfigs(ctx context.Context, path string, pageSize int, pageToken string, v interface{}) error {
+	opts := []internal.HTTPOption{internal.WithQueryParam("pageSize", strconv.Itoa(pageSize))}
+	if pageToken != "" {
+		opts = append(opts, internal.WithQueryParam("pageToken", pageToken))
+	}
+	return c.makeV2HTTPCall(ctx, http.MethodGet, path, nil, v, opts...)
+}
+
+type oidcProviderConfigDAO struct {
+	Name        string `json:"name"`
+	ClientID    string `json:"clientId"`
+	Issuer      string `json:"issuer"`
+	DisplayName string `json:"displayName"`
+	Enabled     bool   `json:"enabled"`
+}
+
+func (dao *oidcProviderConfigDAO) toOIDCProviderConfig() *OIDCProviderConfig {
+	return &OIDCProviderConfig{
+		ID:          extractResourceID(dao.Name),
+		DisplayName: dao.DisplayName,
+		Enabled:     dao.Enabled,
+		ClientID:    dao.ClientID,
+		Issuer:      dao.Issuer,
+	}
+}
+
+type samlProviderConfigDAO struct {
+	Name      string `json:"name"`
+	IDPConfig struct {
+		IDPEntityID     string `json:"idpEntityId"`
+		SSOURL          string `json:"ssoUrl"`
+		IDPCertificates []struct {
+			X509Certificate string `json:"x509Certificate"`
+		} `json:"idpCertificates"`
+		SignRequest bool `json:"signRequest"`
+	} `json:"idpConfig"`
+	SPConfig struct {
+		SPEntityID  string `json:"spEntityId"`
+		CallbackURI string `json:"callbackUri"`
+	} `json:"spConfig"`
+	DisplayName string `json:"displayName"`
+	Enabled     bool   `json:"enabled"`
+}
+
+func (dao *samlProviderConfigDAO) toSAMLProviderConfig() *SAMLProviderConfig {
+	var certs []string
+	for _, cert := range dao.IDPConfig.IDPCertificates {
+		certs = append(certs, cert.X509Certificate)
+	}
+	return &SAMLProviderConfig{
+		ID:                    extractResourceID(dao.Name),
+		DisplayName:           dao.DisplayName,
+		Enabled:               dao.Enabled,
+		IDPEntityID:           dao.IDPConfig.IDPEntityID,
+		SSOURL:                dao.IDPConfig.SSOURL,
+		RequestSigningEnabled: dao.IDPConfig.SignRequest,
+		X509Certificates:      certs,
+		RPEntityID:            dao.SPConfig.SPEntityID,
+		CallbackURL:           dao.SPConfig.CallbackURI,
+	}
+}
+
+// idpCertificates converts the given certificates into the list of objects expected by the
+// backend.
+func idpCertificates(certs []string) []interface{} {
+	result := make([]interface{}, 0, len(certs))
+	for _, cert := range certs {
+		result = append(result, map[string]interface{}{"x509Certificate": cert})
+	}
+	return result
+}
+
+func validateOIDCConfigID(id string) error {
+	if !strings.HasPrefix(id, oidcProviderIDPrefix) {
+		return fmt.Errorf("invalid OIDC provider id: %q; must start with %q", id, oidcProviderIDPrefix)
+	}
+	return nil
+}
+
+func validateSAMLConfigID(id string) error {
+	if !strings.HasPrefix(id, samlProviderIDPrefix) {
+		return fmt.Errorf("invalid SAML provider id: %q; must start with %q", id, samlProviderIDPrefix)
+	}
+	return nil
+}
+
+func validateConfigURL(params nestedMap, key, name string, required bool) error {
+	val, ok := params.get(key)
+	if !ok {
+		if required {
+			return fmt.Errorf("%s must not be empty", name)
+		}
+		return nil
+	}
+	s := val.(string)
+	if s == "" {
+		return fmt.Errorf("%s must not be empty", name)
+	}
+	if _, err := url.ParseRequestURI(s); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", name, err)
+	}
+	return nil
+}
+
+func validateX509Certificates(certs []interface{}) error {
+	for _, cert := range certs {
+		if cert.(map[string]interface{})["x509Certificate"] == "" {
+			return errors.New("X509Certificates must not contain empty strings")
+		}
+	}
+	return nil
+}
+
+// extractResourceID returns the last segment of the given resource name.
+func extractResourceID(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
@@ -0,0 +1,519 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/iterator"
+)
+
+const oidcConfigResponse = `{
+	"name": "projects/mock-project-id/oauthIdpConfigs/oidc.provider",
+	"clientId": "CLIENT_ID",
+	"issuer": "https://oidc.com/issuer",
+	"displayName": "oidcProviderName",
+	"enabled": true
+}`
+
+const samlConfigResponse = `{
+	"name": "projects/mock-project-id/inboundSamlConfigs/saml.provider",
+	"idpConfig": {
+		"idpEntityId": "IDP_ENTITY_ID",
+		"ssoUrl": "https://example.com/login",
+		"signRequest": true,
+		"idpCertificates": [
+			{"x509Certificate": "CERT1"},
+			{"x509Certificate": "CERT2"}
+		]
+	},
+	"spConfig": {
+		"spEntityId": "RP_ENTITY_ID",
+		"callbackUri": "https://projectId.firebaseapp.com/__/auth/handler"
+	},
+	"displayName": "samlProviderName",
+	"enabled": true
+}`
+
+var oidcProviderConfig = &OIDCProviderConfig{
+	ID:          "oidc.provider",
+	DisplayName: "oidcProviderName",
+	Enabled:     true,
+	ClientID:    "CLIENT_ID",
+	Issuer:      "https://oidc.com/issuer",
+}
+
+var samlProviderConfig = &SAMLProviderConfig{
+	ID:                    "saml.provider",
+	DisplayName:           "samlProviderName",
+	Enabled:               true,
+	IDPEntityID:           "IDP_ENTITY_ID",
+	SSOURL:                "https://example.com/login",
+	RequestSigningEnabled: true,
+	X509Certificates:      []string{"CERT1", "CERT2"},
+	RPEntityID:            "RP_ENTITY_ID",
+	CallbackURL:           "https://projectId.firebaseapp.com/__/auth/handler",
+}
+
+func TestOIDCProviderConfig(t *testing.T) {
+	s := echoServer([]byte(oidcConfigResponse), t)
+	defer s.Close()
+
+	config, err := s.Client.OIDCProviderConfig(context.Background(), "oidc.provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, oidcProviderConfig) {
+		t.Errorf("OIDCProviderConfig() = %#v; want = %#v", config, oidcProviderConfig)
+	}
+	checkRequest(s, http.MethodGet, "/projects/mock-project-id/oauthIdpConfigs/oidc.provider", t)
+}
+
+func TestCreateOIDCProviderConfig(t *testing.T) {
+	s := echoServer([]byte(oidcConfigResponse), t)
+	defer s.Close()
+
+	options := (&OIDCProviderConfigToCreate{}).
+		ID(oidcProviderConfig.ID).
+		DisplayName(oidcProviderConfig.DisplayName).
+		Enabled(oidcProviderConfig.Enabled).
+		ClientID(oidcProviderConfig.ClientID).
+		Issuer(oidcProviderConfig.Issuer)
+	config, err := s.Client.CreateOIDCProviderConfig(context.Background(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, oidcProviderConfig) {
+		t.Errorf("CreateOIDCProviderConfig() = %#v; want = %#v", config, oidcProviderConfig)
+	}
+	checkRequest(s, http.MethodPost, "/projects/mock-project-id/oauthIdpConfigs", t)
+
+	want := map[string]interface{}{
+		"displayName": oidcProviderConfig.DisplayName,
+		"enabled":     oidcProviderConfig.Enabled,
+		"clientId":    oidcProviderConfig.ClientID,
+		"issuer":      oidcProviderConfig.Issuer,
+	}
+	checkConfigRequestBody(s, want, t)
+	if id := s.Req[0].URL.Query().Get("oauthIdpConfigId"); id != "oidc.provider" {
+		t.Errorf("CreateOIDCProviderConfig() oauthIdpConfigId = %q; want = %q", id, "oidc.provider")
+	}
+}
+
+func TestCreateOIDCProviderConfigInvalidInput(t *testing.T) {
+	cases := []struct {
+		name   string
+		config *OIDCProviderConfigToCreate
+	}{
+		{"NilConfig", nil},
+		{"EmptyID", (&OIDCProviderConfigToCreate{}).ClientID("CLIENT_ID").Issuer("https://oidc.com")},
+		{"InvalidID", (&OIDCProviderConfigToCreate{}).ID("saml.provider").ClientID("CLIENT_ID").Issuer("https://oidc.com")},
+		{"NoParams", (&OIDCProviderConfigToCreate{}).ID("oidc.provider")},
+		{"NoClientID", (&OIDCProviderConfigToCreate{}).ID("oidc.provider").Issuer("https://oidc.com")},
+		{"NoIssuer", (&OIDCProviderConfigToCreate{}).ID("oidc.provider").ClientID("CLIENT_ID")},
+		{"InvalidIssuer", (&OIDCProviderConfigToCreate{}).ID("oidc.provider").ClientID("CLIENT_ID").Issuer("not a url")},
+	}
+	for _, tc := range cases {
+		config, err := client.CreateOIDCProviderConfig(context.Background(), tc.config)
+		if config != nil || err == nil {
+			t.Errorf("CreateOIDCProviderConfig(%s) = (%v, %v); want = (nil, error)", tc.name, config, err)
+		}
+	}
+}
+
+func TestUpdateOIDCProviderConfig(t *testing.T) {
+	s := echoServer([]byte(oidcConfigResponse), t)
+	defer s.Close()
+
+	options := (&OIDCProviderConfigToUpdate{}).
+		DisplayName(oidcProviderConfig.DisplayName).
+		Enabled(oidcProviderConfig.Enabled).
+		Issuer(oidcProviderConfig.Issuer)
+	config, err := s.Client.UpdateOIDCProviderConfig(context.Background(), "oidc.provider", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, oidcProviderConfig) {
+		t.Errorf("UpdateOIDCProviderConfig() = %#v; want = %#v", config, oidcProviderConfig)
+	}
+	checkRequest(s, http.MethodPatch, "/projects/mock-project-id/oauthIdpConfigs/oidc.provider", t)
+
+	want := map[string]interface{}{
+		"displayName": oidcProviderConfig.DisplayName,
+		"enabled":     oidcProviderConfig.Enabled,
+		"issuer":      oidcProviderConfig.Issuer,
+	}
+	checkConfigRequestBody(s, want, t)
+	wantMask := "displayName,enabled,issuer"
+	if mask := s.Req[0].URL.Query().Get("updateMask"); mask != wantMask {
+		t.Errorf("UpdateOIDCProviderConfig() updateMask = %q; want = %q", mask, wantMask)
+	}
+}
+
+func TestUpdateOIDCProviderConfigInvalidInput(t *testing.T) {
+	cases := []struct {
+		name   string
+		id     string
+		config *OIDCProviderConfigToUpdate
+	}{
+		{"InvalidID", "saml.provider", (&OIDCProviderConfigToUpdate{}).Enabled(true)},
+		{"NilConfig", "oidc.provider", nil},
+		{"NoParams", "oidc.provider", &OIDCProviderConfigToUpdate{}},
+		{"EmptyClientID", "oidc.provider", (&OIDCProviderConfigToUpdate{}).ClientID("")},
+		{"InvalidIssuer", "oidc.provider", (&OIDCProviderConfigToUpdate{}).Issuer("not a url")},
+	}
+	for _, tc := range cases {
+		config, err := client.UpdateOIDCProviderConfig(context.Background(), tc.id, tc.config)
+		if config != nil || err == nil {
+			t.Errorf("UpdateOIDCProviderConfig(%s) = (%v, %v); want = (nil, error)", tc.name, config, err)
+		}
+	}
+}
+
+func TestDeleteOIDCProviderConfig(t *testing.T) {
+	s := echoServer([]byte("{}"), t)
+	defer s.Close()
+
+	if err := s.Client.DeleteOIDCProviderConfig(context.Background(), "oidc.provider"); err != nil {
+		t.Fatal(err)
+	}
+	checkRequest(s, http.MethodDelete, "/projects/mock-project-id/oauthIdpConfigs/oidc.provider", t)
+}
+
+func TestOIDCProviderConfigInvalidID(t *testing.T) {
+	for _, id := range []string{"", "oidc", "saml.provider"} {
+		if config, err := client.OIDCProviderConfig(context.Background(), id); config != nil || err == nil {
+			t.Errorf("OIDCProviderConfig(%q) = (%v, %v); want = (nil, error)", id, config, err)
+		}
+		if err := client.DeleteOIDCProviderConfig(context.Background(), id); err == nil {
+			t.Errorf("DeleteOIDCProviderConfig(%q) = nil; want = error", id)
+		}
+	}
+}
+
+func TestOIDCProviderConfigs(t *testing.T) {
+	resp := `{"oauthIdpConfigs": [` + oidcConfigResponse + `, ` + oidcConfigResponse + `]}`
+	s := echoServer([]byte(resp), t)
+	defer s.Close()
+
+	it := s.Client.OIDCProviderConfigs(context.Background(), "pageToken")
+	var configs []*OIDCProviderConfig
+	for {
+		config, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		configs = append(configs, config)
+	}
+	if len(configs) != 2 {
+		t.Fatalf("OIDCProviderConfigs() = %d; want = 2", len(configs))
+	}
+	for _, config := range configs {
+		if !reflect.DeepEqual(config, oidcProviderConfig) {
+			t.Errorf("OIDCProviderConfigs() = %#v; want = %#v", config, oidcProviderConfig)
+		}
+	}
+	checkRequest(s, http.MethodGet, "/projects/mock-project-id/oauthIdpConfigs", t)
+	checkListConfigsQuery(s, "pageToken", t)
+}
+
+func TestOIDCProviderConfigsEnabledOnly(t *testing.T) {
+	disabled := strings.Replace(oidcConfigResponse, `"enabled": true`, `"enabled": false`, 1)
+	pages := map[string]string{
+		"":   `{"oauthIdpConfigs": [` + disabled + `], "nextPageToken": "p2"}`,
+		"p2": `{"oauthIdpConfigs": [` + disabled + `, ` + oidcConfigResponse + `], "nextPageToken": "p3"}`,
+		"p3": `{"oauthIdpConfigs": [` + disabled + `]}`,
+	}
+	var tokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("pageToken")
+		tokens = append(tokens, token)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(pages[token]))
+	}))
+	defer srv.Close()
+	c := *client
+	c.v2URL = srv.URL
+
+	it := c.OIDCProviderConfigs(context.Background(), "", WithEnabledOnly())
+	config, err := it.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, oidcProviderConfig) {
+		t.Errorf("OIDCProviderConfigs() = %#v; want = %#v", config, oidcProviderConfig)
+	}
+	if config, err := it.Next(); config != nil || err != iterator.Done {
+		t.Errorf("OIDCProviderConfigs() = (%v, %v); want = (nil, %v)", config, err, iterator.Done)
+	}
+	if want := []string{"", "p2", "p3"}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("pageTokens = %v; want = %v", tokens, want)
+	}
+
+	tokens = nil
+	var count int
+	it = c.OIDCProviderConfigs(context.Background(), "")
+	for {
+		if _, err := it.Next(); err == iterator.Done {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		count++
+	}
+	if count != 4 {
+		t.Errorf("OIDCProviderConfigs() without options = %d configs; want = 4", count)
+	}
+}
+
+func TestSAMLProviderConfig(t *testing.T) {
+	s := echoServer([]byte(samlConfigResponse), t)
+	defer s.Close()
+
+	config, err := s.Client.SAMLProviderConfig(context.Background(), "saml.provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, samlProviderConfig) {
+		t.Errorf("SAMLProviderConfig() = %#v; want = %#v", config, samlProviderConfig)
+	}
+	checkRequest(s, http.MethodGet, "/projects/mock-project-id/inboundSamlConfigs/saml.provider", t)
+}
+
+func TestCreateSAMLProviderConfig(t *testing.T) {
+	s := echoServer([]byte(samlConfigResponse), t)
+	defer s.Close()
+
+	options := (&SAMLProviderConfigToCreate{}).
+		ID(samlProviderConfig.ID).
+		DisplayName(samlProviderConfig.DisplayName).
+		Enabled(samlProviderConfig.Enabled).
+		IDPEntityID(samlProviderConfig.IDPEntityID).
+		SSOURL(samlProviderConfig.SSOURL).
+		RequestSigningEnabled(samlProviderConfig.RequestSigningEnabled).
+		X509Certificates(samlProviderConfig.X509Certificates).
+		RPEntityID(samlProviderConfig.RPEntityID).
+		CallbackURL(samlProviderConfig.CallbackURL)
+	config, err := s.Client.CreateSAMLProviderConfig(context.Background(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, samlProviderConfig) {
+		t.Errorf("CreateSAMLProviderConfig() = %#v; want = %#v", config, samlProviderConfig)
+	}
+	checkRequest(s, http.MethodPost, "/projects/mock-project-id/inboundSamlConfigs", t)
+
+	want := map[string]interface{}{
+		"displayName": samlProviderConfig.DisplayName,
+		"enabled":     samlProviderConfig.Enabled,
+		"idpConfig": map[string]interface{}{
+			"idpEntityId": samlProviderConfig.IDPEntityID,
+			"ssoUrl":      samlProviderConfig.SSOURL,
+			"signRequest": samlProviderConfig.RequestSigningEnabled,
+			"idpCertificates": []interface{}{
+				map[string]interface{}{"x509Certificate": "CERT1"},
+				map[string]interface{}{"x509Certificate": "CERT2"},
+			},
+		},
+		"spConfig": map[string]interface{}{
+			"spEntityId":  samlProviderConfig.RPEntityID,
+			"callbackUri": samlProviderConfig.CallbackURL,
+		},
+	}
+	checkConfigRequestBody(s, want, t)
+	if id := s.Req[0].URL.Query().Get("inboundSamlConfigId"); id != "saml.provider" {
+		t.Errorf("CreateSAMLProviderConfig() inboundSamlConfigId = %q; want = %q", id, "saml.provider")
+	}
+}
+
+func TestCreateSAMLProviderConfigInvalidInput(t *testing.T) {
+	valid := func() *SAMLProviderConfigToCreate {
+		return (&SAMLProviderConfigToCreate{}).
+			ID("saml.provider").
+			IDPEntityID("IDP_ENTITY_ID").
+			SSOURL("https://example.com/login").
+			X509Certificates([]string{"CERT1"}).
+			RPEntityID("RP_ENTITY_ID").
+			CallbackURL("https://example.com/callback")
+	}
+	cases := []struct {
+		name   string
+		config *SAMLProviderConfigToCreate
+	}{
+		{"NilConfig", nil},
+		{"InvalidID", valid().ID("oidc.provider")},
+		{"NoParams", (&SAMLProviderConfigToCreate{}).ID("saml.provider")},
+		{"EmptyIDPEntityID", valid().IDPEntityID("")},
+		{"InvalidSSOURL", valid().SSOURL("not a url")},
+		{"NoCertificates", valid().X509Certificates(nil)},
+		{"EmptyCertificate", valid().X509Certificates([]string{""})},
+		{"EmptyRPEntityID", valid().RPEntityID("")},
+		{"InvalidCallbackURL", valid().CallbackURL("")},
+	}
+	for _, tc := range cases {
+		config, err := client.CreateSAMLProviderConfig(context.Background(), tc.config)
+		if config != nil || err == nil {
+			t.Errorf("CreateSAMLProviderConfig(%s) = (%v, %v); want = (nil, error)", tc.name, config, err)
+		}
+	}
+}
+
+func TestUpdateSAMLProviderConfig(t *testing.T) {
+	s := echoServer([]byte(samlConfigResponse), t)
+	defer s.Close()
+
+	options := (&SAMLProviderConfigToUpdate{}).
+		Enabled(samlProviderConfig.Enabled).
+		SSOURL(samlProviderConfig.SSOURL).
+		X509Certificates(samlProviderConfig.X509Certificates).
+		CallbackURL(samlProviderConfig.CallbackURL)
+	config, err := s.Client.UpdateSAMLProviderConfig(context.Background(), "saml.provider", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, samlProviderConfig) {
+		t.Errorf("UpdateSAMLProviderConfig() = %#v; want = %#v", config, samlProviderConfig)
+	}
+	checkRequest(s, http.MethodPatch, "/projects/mock-project-id/inboundSamlConfigs/saml.provider", t)
+
+	wantMask := "enabled,idpConfig.idpCertificates,idpConfig.ssoUrl,spConfig.callbackUri"
+	if mask := s.Req[0].URL.Query().Get("updateMask"); mask != wantMask {
+		t.Errorf("UpdateSAMLProviderConfig() updateMask = %q; want = %q", mask, wantMask)
+	}
+}
+
+func TestUpdateSAMLProviderConfigInvalidInput(t *testing.T) {
+	cases := []struct {
+		name   string
+		id     string
+		config *SAMLProviderConfigToUpdate
+	}{
+		{"InvalidID", "oidc.provider", (&SAMLProviderConfigToUpdate{}).Enabled(true)},
+		{"NilConfig", "saml.provider", nil},
+		{"NoParams", "saml.provider", &SAMLProviderConfigToUpdate{}},
+		{"EmptyIDPEntityID", "saml.provider", (&SAMLProviderConfigToUpdate{}).IDPEntityID("")},
+		{"InvalidSSOURL", "saml.provider", (&SAMLProviderConfigToUpdate{}).SSOURL("not a url")},
+		{"NoCertificates", "saml.provider", (&SAMLProviderConfigToUpdate{}).X509Certificates(nil)},
+		{"EmptyRPEntityID", "saml.provider", (&SAMLProviderConfigToUpdate{}).RPEntityID("")},
+		{"InvalidCallbackURL", "saml.provider", (&SAMLProviderConfigToUpdate{}).CallbackURL("")},
+	}
+	for _, tc := range cases {
+		config, err := client.UpdateSAMLProviderConfig(context.Background(), tc.id, tc.config)
+		if config != nil || err == nil {
+			t.Errorf("UpdateSAMLProviderConfig(%s) = (%v, %v); want = (nil, error)", tc.name, config, err)
+		}
+	}
+}
+
+func TestDeleteSAMLProviderConfig(t *testing.T) {
+	s := echoServer([]byte("{}"), t)
+	defer s.Close()
+
+	if err := s.Client.DeleteSAMLProviderConfig(context.Background(), "saml.provider"); err != nil {
+		t.Fatal(err)
+	}
+	checkRequest(s, http.MethodDelete, "/projects/mock-project-id/inboundSamlConfigs/saml.provider", t)
+}
+
+func TestSAMLProviderConfigInvalidID(t *testing.T) {
+	for _, id := range []string{"", "saml", "oidc.provider"} {
+		if config, err := client.SAMLProviderConfig(context.Background(), id); config != nil || err == nil {
+			t.Errorf("SAMLProviderConfig(%q) = (%v, %v); want = (nil, error)", id, config, err)
+		}
+		if err := client.DeleteSAMLProviderConfig(context.Background(), id); err == nil {
+			t.Errorf("DeleteSAMLProviderConfig(%q) = nil; want = error", id)
+		}
+	}
+}
+
+func TestSAMLProviderConfigsEnabledOnly(t *testing.T) {
+	disabled := strings.Replace(samlConfigResponse, `"enabled": true`, `"enabled": false`, 1)
+	resp := `{"inboundSamlConfigs": [` + disabled + `, ` + samlConfigResponse + `, ` + disabled + `]}`
+	s := echoServer([]byte(resp), t)
+	defer s.Close()
+
+	it := s.Client.SAMLProviderConfigs(context.Background(), "pageToken", WithEnabledOnly())
+	config, err := it.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, samlProviderConfig) {
+		t.Errorf("SAMLProviderConfigs() = %#v; want = %#v", config, samlProviderConfig)
+	}
+	if config, err := it.Next(); config != nil || err != iterator.Done {
+		t.Errorf("SAMLProviderConfigs() = (%v, %v); want = (nil, %v)", config, err, iterator.Done)
+	}
+	checkListConfigsQuery(s, "pageToken", t)
+}
+
+func TestSAMLProviderConfigs(t *testing.T) {
+	resp := `{"inboundSamlConfigs": [` + samlConfigResponse + `]}`
+	s := echoServer([]byte(resp), t)
+	defer s.Close()
+
+	it := s.Client.SAMLProviderConfigs(context.Background(), "pageToken")
+	config, err := it.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, samlProviderConfig) {
+		t.Errorf("SAMLProviderConfigs() = %#v; want = %#v", config, samlProviderConfig)
+	}
+	if config, err := it.Next(); config != nil || err != iterator.Done {
+		t.Errorf("SAMLProviderConfigs() = (%v, %v); want = (nil, %v)", config, err, iterator.Done)
+	}
+	checkRequest(s, http.MethodGet, "/projects/mock-project-id/inboundSamlConfigs", t)
+	checkListConfigsQuery(s, "pageToken", t)
+}
+
+func TestTenantProviderConfig(t *testing.T) {
+	s := echoServer([]byte(oidcConfigResponse), t)
+	defer s.Close()
+
+	tc := tenantClient(s.Client, t)
+	if _, err := tc.client.OIDCProviderConfig(context.Background(), "oidc.provider"); err != nil {
+		t.Fatal(err)
+	}
+	checkRequest(s, http.MethodGet, "/projects/mock-project-id/tenants/tenant-1/oauthIdpConfigs/oidc.provider", t)
+}
+
+func checkConfigRequestBody(s *mockAuthServer, want map[string]interface{}, t *testing.T) {
+	var got map[string]interface{}
+	if err := json.Unmarshal(s.Rbody, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Request body = %v; want = %v", got, want)
+	}
+}
+
+func checkListConfigsQuery(s *mockAuthServer, pageToken string, t *testing.T) {
+	query := s.Req[0].URL.Query()
+	if got := query.Get("pageSize"); got != "100" {
+		t.Errorf("pageSize = %q; want = %q", got, "100")
+	}
+	if got := query.Get("pageToken"); got != pageToken {
+		t.Errorf("pageToken = %q; want = %q", got, pageToken)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const redacted = "REDACTED"
+
+// sensitiveFields lists the JSON fields of the requests and responses of the Firebase Auth backend
+// that are redacted from logged HTTP dumps. Fields are matched by name at any level of nesting.
+var sensitiveFields = map[string]bool{
+	"accessToken":      true,
+	"clientSecret":     true,
+	"customAttributes": true,
+	"idToken":          true,
+	"oobCode":          true,
+	"oobLink":          true,
+	"password":         true,
+	"passwordHash":     true,
+	"private_key":      true,
+	"privateKey":       true,
+	"refreshToken":     true,
+	"salt":             true,
+	"saltSeparator":    true,
+	"sessionCookie":    true,
+	"signerKey":        true,
+}
+
+// sensitiveHeaders lists the HTTP headers that are redacted from logged HTTP dumps.
+var sensitiveHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+}
+
+// redactingTransport is an http.RoundTripper that logs a dump of each request and response, after
+// redacting the credentials, password hashes, tokens and custom claims they contain.
+//
+// JSON bodies are logged with the values of the sensitive fields replaced. Other bodies are only
+// logged by length, since their contents cannot be inspected. Query strings are not logged.
+type redactingTransport struct {
+	base http.RoundTripper
+	logf func(format string, v ...interface{})
+}
+
+func (t *redactingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	u := *r.URL
+	u.RawQuery = ""
+	op := r.Method + " " + u.String()
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		// Send a copy of the request, since a RoundTripper must not modify the original.
+		rc := *r
+		rc.Body = ioutil.NopCloser(bytes.NewReader(b))
+		r = &rc
+		t.logf("auth: request %s\n%s\n%s", op, redactHeader(r.Header), redactBody(b))
+	} else {
+		t.logf("auth: request %s\n%s\n", op, redactHeader(r.Header))
+	}
+
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		t.logf("auth: request %s failed: %v", op, err)
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.logf("auth: response %d %s failed: %v", resp.StatusCode, op, err)
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	t.logf("auth: response %d %s\n%s\n%s", resp.StatusCode, op, redactHeader(resp.Header), redactBody(b))
+	return resp, nil
+}
+
+// redactHeader formats the given HTTP headers, one per line in sorted order, with the values of the
+// sensitive headers replaced.
+func redactHeader(h http.Header) string {
+	var lines []string
+	for k, v := range h {
+		value := strings.Join(v, ", ")
+		if sensitiveHeaders[http.CanonicalHeaderKey(k)] {
+			value = redacted
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", k, value))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// redactBody formats the given HTTP body, with the values of the sensitive fields replaced.
+func redactBody(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return fmt.Sprintf("<%d bytes>", len(b))
+	}
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return fmt.Sprintf("<%d bytes>", len(b))
+	}
+	return string(out)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, e := range val {
+			if sensitiveFields[k] {
+				val[k] = redacted
+			} else {
+				val[k] = redactValue(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range val {
+			val[i] = redactValue(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"firebase.google.com/go/internal"
+	"google.golang.org/api/option"
+)
+
+func TestRedactBody(t *testing.T) {
+	cases := []struct {
+		body string
+		want string
+	}{
+		{"", ""},
+		{`{"localId":"uid1","password":"secret"}`, `{"localId":"uid1","password":"REDACTED"}`},
+		{`{"users":[{"localId":"uid1","passwordHash":"aGFzaA","salt":"c2FsdA"}]}`,
+			`{"users":[{"localId":"uid1","passwordHash":"REDACTED","salt":"REDACTED"}]}`},
+		{`{"customAttributes":"{\"admin\":true}"}`, `{"customAttributes":"REDACTED"}`},
+		{`{"signIn":{"hashConfig":{"algorithm":"SCRYPT","signerKey":"key"}}}`,
+			`{"signIn":{"hashConfig":{"algorithm":"SCRYPT","signerKey":"REDACTED"}}}`},
+		{`{"idToken":{"nested":"value"}}`, `{"idToken":"REDACTED"}`},
+		{`not json`, `<8 bytes>`},
+	}
+	for _, tc := range cases {
+		if got := redactBody([]byte(tc.body)); got != tc.want {
+			t.Errorf("redactBody(%q) = %q; want = %q", tc.body, got, tc.want)
+		}
+	}
+}
+
+func TestRedactHeader(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer secret")
+	h.Set("Content-Type", "application/json")
+	h.Add("X-Multi", "a")
+	h.Add("X-Multi", "b")
+	want := "Authorization: REDACTED\nContent-Type: application/json\nX-Multi: a, b"
+	if got := redactHeader(h); got != want {
+		t.Errorf("redactHeader() = %q; want = %q", got, want)
+	}
+}
+
+func TestWithRedactedLogging(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"localId": "uid1", "idToken": "secret-id-token"}`))
+	}))
+	defer srv.Close()
+
+	var logs []string
+	logf := func(format string, v ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, v...))
+	}
+	c, err := NewEmulatorClient(ctx, "mock-project-id", strings.TrimPrefix(srv.URL, "http://"), WithRedactedLogging(logf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := (&UserToCreate{}).UID("uid1").Password("secret-password")
+	if _, err := c.createUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body, "secret-password") {
+		t.Errorf("request body = %q; want = unredacted password", body)
+	}
+
+	if len(logs) != 2 {
+		t.Fatalf("logs = %v; want = 2 entries", logs)
+	}
+	for _, want := range []string{"auth: request POST ", "auth: response 200 POST "} {
+		found := false
+		for _, l := range logs {
+			found = found || strings.HasPrefix(l, want)
+		}
+		if !found {
+			t.Errorf("logs = %v; want = entry with prefix %q", logs, want)
+		}
+	}
+	for _, l := range logs {
+		for _, secret := range []string{"secret-password", "secret-id-token", "Bearer owner"} {
+			if strings.Contains(l, secret) {
+				t.Errorf("log = %q; want = %q redacted", l, secret)
+			}
+		}
+		if !strings.Contains(l, redacted) {
+			t.Errorf("log = %q; want = redacted field", l)
+		}
+	}
+}
+
+func TestWithRedactedLoggingSharedClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		http.ServeFile(w, r, "../testdata/public_certs.json")
+	}))
+	defer srv.Close()
+
+	var logs []string
+	logf := func(format string, v ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, v...))
+	}
+	shared := &http.Client{}
+	conf := &internal.AuthConfig{
+		Opts:      []option.ClientOption{option.WithHTTPClient(shared)},
+		ProjectID: "mock-project-id",
+	}
+	var c *Client
+	for i := 0; i < 2; i++ {
+		var err error
+		if c, err = NewClient(ctx, conf, WithRedactedLogging(logf)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, err := shared.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(logs) != 0 {
+		t.Errorf("logs = %v; want = []", logs)
+	}
+
+	c.ks = newHTTPKeySource(srv.URL, c.hc.Client)
+	if _, err := c.VerifyIDToken(ctx, testIDToken); err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 {
+		t.Errorf("logs = %v; want = 2 entries", logs)
+	}
+}
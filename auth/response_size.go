@@ -0,0 +1,81 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// defaultMaxResponseSize is the maximum size of an HTTP response body read by the Client, unless
+// a different limit is specified with WithMaxResponseSize().
+const defaultMaxResponseSize = 10 << 20
+
+// limitedTransport is an http.RoundTripper that rejects response bodies larger than limit bytes.
+//
+// Responses that declare a larger Content-Length are rejected up front. Other responses are read
+// until the limit is exceeded, at which point reading the body fails with an error.
+type limitedTransport struct {
+	base  http.RoundTripper
+	limit int64
+}
+
+func (t *limitedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ContentLength > t.limit {
+		resp.Body.Close()
+		return nil, responseTooLargeError(t.limit)
+	}
+	resp.Body = &limitedBody{rc: resp.Body, remaining: t.limit, limit: t.limit}
+	return resp, nil
+}
+
+// limitedBody is an io.ReadCloser that fails once more than limit bytes have been read from rc.
+type limitedBody struct {
+	rc        io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// Probe for a byte past the limit, to tell an oversized body apart from one that is
+		// exactly limit bytes long.
+		var probe [1]byte
+		n, err := b.rc.Read(probe[:])
+		if n == 0 {
+			return 0, err
+		}
+		return 0, responseTooLargeError(b.limit)
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.rc.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.rc.Close()
+}
+
+func responseTooLargeError(limit int64) error {
+	return fmt.Errorf("http response body exceeds the maximum size of %d bytes", limit)
+}
@@ -0,0 +1,60 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitedTransport(t *testing.T) {
+	body := strings.Repeat("a", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Flush before writing the body, so that the response is chunked without a Content-Length.
+		w.(http.Flusher).Flush()
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		limit int64
+		want  bool
+	}{
+		{99, false},
+		{100, true},
+		{101, true},
+	}
+	for _, tc := range cases {
+		hc := &http.Client{Transport: &limitedTransport{base: http.DefaultTransport, limit: tc.limit}}
+		resp, err := hc.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.ContentLength != -1 {
+			t.Fatalf("ContentLength = %d; want = -1", resp.ContentLength)
+		}
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if tc.want && (err != nil || string(b) != body) {
+			t.Errorf("ReadAll(limit = %d) = (%d bytes, %v); want = (100 bytes, nil)", tc.limit, len(b), err)
+		}
+		if !tc.want && err == nil {
+			t.Errorf("ReadAll(limit = %d) = nil; want = error", tc.limit)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/api/googleapi"
+)
+
+// RetryConfig specifies how the Client retries Identity Toolkit calls that fail with a transient
+// error.
+//
+// Calls are retried when the backend responds with HTTP status 429 (Too Many Requests) or 503
+// (Service Unavailable), or with a QuotaExceededError regardless of the status. Calls that can be
+// repeated without changing their effect, such as looking up, updating or deleting users, are
+// also retried on HTTP status 500 (Internal Server Error). Calls that create resources (e.g.
+// CreateUser() and ImportUsers()) are not, since the backend may have applied them before
+// failing. All other errors are returned immediately.
+//
+// MaxAttempts is the total number of attempts made for each call, including the first one. Values
+// less than 2 disable retries. BaseDelay is the delay before the first retry, which is doubled for
+// each subsequent retry. MaxDelay, if positive, caps the delay between two attempts. Jitter is a
+// fraction in the range [0, 1], by which each delay is randomly increased or decreased to prevent
+// many clients from retrying in lockstep.
+//
+// If the backend responds with a Retry-After header, the Client waits for at least the duration
+// indicated in the header, or in the RetryAfter field of a QuotaExceededError. Retries never
+// extend past the deadline of the context passed to the API call: if the next attempt cannot be
+// made before the deadline, the last error is returned.
+//
+// When no RetryConfig is specified, only the calls that fail with a QuotaExceededError are
+// retried, as specified by defaultQuotaRetryConfig.
+type RetryConfig struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64
+}
+
+// defaultQuotaRetryConfig is used for retrying the calls that fail with a QuotaExceededError, when
+// the Client has no RetryConfig.
+var defaultQuotaRetryConfig = &RetryConfig{
+	MaxAttempts: 3,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+	Jitter:      0.1,
+}
+
+var retryableStatus = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusServiceUnavailable: true,
+}
+
+// idempotentPostMethods are the suffixes of the identitytoolkit REST methods that are called with
+// POST, and can nevertheless be repeated without changing their effect.
+var idempotentPostMethods = []string{":batchDelete", ":batchGet", ":delete", ":lookup", ":query", ":update"}
+
+// isIdempotent checks whether the identitytoolkit REST call with the given method and URL can be
+// repeated without changing its effect.
+func isIdempotent(method, url string) bool {
+	if method != http.MethodPost {
+		return true
+	}
+	if i := strings.IndexByte(url, '?'); i >= 0 {
+		url = url[:i]
+	}
+	for _, suffix := range idempotentPostMethods {
+		if strings.HasSuffix(url, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryFunc makes a single attempt of a backend call. It returns the HTTP status code and the
+// headers of the response, if one was received, along with any error.
+type retryFunc func() (status int, header http.Header, err error)
+
+// retry calls fn until it returns a non-retryable status, the attempts specified by the
+// RetryConfig of the Client run out, or the context deadline does not allow another attempt.
+// HTTP status 500 is only retried if the call is idempotent. The result of the last attempt is
+// returned.
+func (c *Client) retry(ctx context.Context, idempotent bool, fn retryFunc) error {
+	for attempt := 1; ; attempt++ {
+		status, header, err := fn()
+		rc := c.retryConfig
+		qe, quota := err.(*QuotaExceededError)
+		if rc == nil && quota {
+			rc = defaultQuotaRetryConfig
+		}
+		retryable := retryableStatus[status] || (idempotent && status == http.StatusInternalServerError)
+		if rc == nil || attempt >= rc.MaxAttempts || (!retryable && !quota) {
+			return err
+		}
+
+		delay := rc.backoff(attempt)
+		if after := retryAfter(header, c.clock.Now()); after > delay {
+			delay = after
+		}
+		if quota && qe.RetryAfter > delay {
+			delay = qe.RetryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && c.clock.Now().Add(delay).After(deadline) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retryGoogleAPI calls fn with retry, where fn makes a call using the Identity Toolkit v3
+// service, and reports the failures of the call as googleapi errors. Depending on the version of
+// the googleapi package, the response headers may not be available in the error, in which case
+// the Retry-After header is not taken into account.
+func (c *Client) retryGoogleAPI(ctx context.Context, idempotent bool, fn func() error) error {
+	return c.retry(ctx, idempotent, func() (int, http.Header, error) {
+		err := fn()
+		if gerr, ok := err.(*googleapi.Error); ok {
+			if qe := newQuotaExceededError([]byte(gerr.Body), gerr.Header, c.clock.Now(), gerr.Error()); qe != nil {
+				return gerr.Code, gerr.Header, qe
+			}
+			return gerr.Code, gerr.Header, err
+		}
+		return 0, nil, err
+	})
+}
+
+// backoff returns the delay before the retry that follows the specified attempt.
+func (rc *RetryConfig) backoff(attempt int) time.Duration {
+	delay := float64(rc.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if rc.MaxDelay > 0 && delay > float64(rc.MaxDelay) {
+		delay = float64(rc.MaxDelay)
+	}
+	if rc.Jitter > 0 {
+		delay += delay * rc.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// retryAfter parses the Retry-After header, which may contain either a number of seconds or an
+// HTTP date. Returns zero if the header is absent or malformed.
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// QuotaExceededError is the error returned when a call to the Identity Toolkit backend fails
+// because a quota of the project has been exceeded (e.g. when importing or deleting many users in
+// a short time).
+//
+// Metric is the name of the exceeded quota metric, if reported by the backend. RetryAfter is the
+// duration after which the call may be retried, as indicated by the Retry-After header or the
+// retry info of the response, or zero if not indicated. Calls failing with a QuotaExceededError
+// are retried with backoff, even when no RetryConfig is specified with WithRetryConfig(). The
+// error is returned once the attempts run out.
+type QuotaExceededError struct {
+	Metric     string
+	RetryAfter time.Duration
+	msg        string
+}
+
+func (e *QuotaExceededError) Error() string {
+	return e.msg
+}
+
+// IsQuotaExceeded checks if the given error was due to an exceeded quota of the project.
+func IsQuotaExceeded(err error) bool {
+	_, ok := err.(*QuotaExceededError)
+	return ok
+}
+
+// newQuotaExceededError parses the given error response of the Identity Toolkit backend. Returns
+// nil unless the response reports an exceeded quota.
+func newQuotaExceededError(body []byte, header http.Header, now time.Time, msg string) *QuotaExceededError {
+	var resp struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+			Errors  []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+			Details []struct {
+				Type       string            `json:"@type"`
+				Metadata   map[string]string `json:"metadata"`
+				RetryDelay string            `json:"retryDelay"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil
+	}
+	quota := strings.HasPrefix(resp.Error.Message, "QUOTA_EXCEEDED") || resp.Error.Status == "RESOURCE_EXHAUSTED"
+	for _, item := range resp.Error.Errors {
+		if item.Reason == "quotaExceeded" || item.Reason == "rateLimitExceeded" {
+			quota = true
+		}
+	}
+	if !quota {
+		return nil
+	}
+
+	qe := &QuotaExceededError{RetryAfter: retryAfter(header, now), msg: msg}
+	for _, d := range resp.Error.Details {
+		switch {
+		case strings.HasSuffix(d.Type, "google.rpc.ErrorInfo"):
+			qe.Metric = d.Metadata["quota_metric"]
+		case strings.HasSuffix(d.Type, "google.rpc.RetryInfo"):
+			if delay, err := time.ParseDuration(d.RetryDelay); err == nil && delay > qe.RetryAfter {
+				qe.RetryAfter = delay
+			}
+		}
+	}
+	return qe
+}
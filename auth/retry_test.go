@@ -0,0 +1,379 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"firebase.google.com/go/internal"
+	"google.golang.org/api/identitytoolkit/v3"
+)
+
+var testRetryConfig = &RetryConfig{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+}
+
+// retryServer responds to the i-th request with the i-th status in statuses, and with the last
+// status once the list is exhausted.
+type retryServer struct {
+	Srv      *httptest.Server
+	Client   *Client
+	statuses []int
+	header   http.Header
+	errBody  string
+	mu       sync.Mutex
+	requests int
+}
+
+func newRetryServer(rc *RetryConfig, statuses []int, t *testing.T) *retryServer {
+	s := &retryServer{statuses: statuses, header: http.Header{}}
+	s.Srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		status := s.statuses[len(s.statuses)-1]
+		if s.requests < len(s.statuses) {
+			status = s.statuses[s.requests]
+		}
+		s.requests++
+		s.mu.Unlock()
+
+		for k, v := range s.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte("{}"))
+		} else if s.errBody != "" {
+			w.Write([]byte(s.errBody))
+		} else {
+			w.Write([]byte(`{"error": {"message": "TEST_ERROR"}}`))
+		}
+	}))
+
+	is, err := identitytoolkit.New(http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	is.BasePath = s.Srv.URL + "/"
+	c := *client
+	c.hc = &internal.HTTPClient{Client: http.DefaultClient}
+	c.is = newIdentitytoolkitClient(is, c.version)
+	c.url = s.Srv.URL
+	c.retryConfig = rc
+	s.Client = &c
+	return s
+}
+
+func (s *retryServer) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *retryServer) Close() {
+	s.Srv.Close()
+}
+
+func TestRetry(t *testing.T) {
+	cases := []struct {
+		name     string
+		rc       *RetryConfig
+		statuses []int
+		wantErr  bool
+		wantReqs int
+	}{
+		{"Success", testRetryConfig, []int{200}, false, 1},
+		{"ServiceUnavailable", testRetryConfig, []int{503, 200}, false, 2},
+		{"TooManyRequests", testRetryConfig, []int{429, 500, 200}, false, 3},
+		{"AttemptsExhausted", testRetryConfig, []int{503}, true, 3},
+		{"BadRequest", testRetryConfig, []int{400, 200}, true, 1},
+		{"Conflict", testRetryConfig, []int{409, 200}, true, 1},
+		{"NoRetryConfig", nil, []int{503, 200}, true, 1},
+		{"SingleAttempt", &RetryConfig{MaxAttempts: 1}, []int{503, 200}, true, 1},
+	}
+	for _, tc := range cases {
+		for _, tenant := range []bool{false, true} {
+			s := newRetryServer(tc.rc, tc.statuses, t)
+			if tenant {
+				s.Client.tenantID = testTenantID
+			}
+			err := s.Client.DeleteUser(ctx, "uid1")
+			if (err != nil) != tc.wantErr {
+				t.Errorf("DeleteUser(%s, tenant: %v) = %v; want error: %v", tc.name, tenant, err, tc.wantErr)
+			}
+			if got := s.Requests(); got != tc.wantReqs {
+				t.Errorf("DeleteUser(%s, tenant: %v) requests = %d; want = %d", tc.name, tenant, got, tc.wantReqs)
+			}
+			s.Close()
+		}
+	}
+}
+
+func TestRetryUpdateUser(t *testing.T) {
+	s := newRetryServer(testRetryConfig, []int{500, 200}, t)
+	defer s.Close()
+
+	if err := s.Client.updateUser(ctx, "uid1", (&UserToUpdate{}).DisplayName("name")); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Requests(); got != 2 {
+		t.Errorf("updateUser() requests = %d; want = 2", got)
+	}
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	cases := []struct {
+		name     string
+		statuses []int
+		wantErr  bool
+		wantReqs int
+	}{
+		{"InternalServerError", []int{500, 200}, true, 1},
+		{"ServiceUnavailable", []int{503, 200}, false, 2},
+		{"TooManyRequests", []int{429, 200}, false, 2},
+	}
+	for _, tc := range cases {
+		for _, tenant := range []bool{false, true} {
+			s := newRetryServer(testRetryConfig, tc.statuses, t)
+			if tenant {
+				s.Client.tenantID = testTenantID
+			}
+			_, err := s.Client.createUser(ctx, (&UserToCreate{}).UID("uid1"))
+			if (err != nil) != tc.wantErr {
+				t.Errorf("createUser(%s, tenant: %v) = %v; want error: %v", tc.name, tenant, err, tc.wantErr)
+			}
+			if got := s.Requests(); got != tc.wantReqs {
+				t.Errorf("createUser(%s, tenant: %v) requests = %d; want = %d", tc.name, tenant, got, tc.wantReqs)
+			}
+			s.Close()
+		}
+	}
+}
+
+func TestIsIdempotent(t *testing.T) {
+	cases := []struct {
+		method, url string
+		want        bool
+	}{
+		{http.MethodGet, "/config", true},
+		{http.MethodPatch, "/tenants/tenant1", true},
+		{http.MethodDelete, "/tenants/tenant1", true},
+		{http.MethodPost, "/accounts:lookup", true},
+		{http.MethodPost, "/accounts:update", true},
+		{http.MethodPost, "/accounts:delete", true},
+		{http.MethodPost, "/accounts:batchDelete", true},
+		{http.MethodPost, "/accounts", false},
+		{http.MethodPost, "/accounts:batchCreate", false},
+		{http.MethodPost, "/tenants", false},
+		{http.MethodPost, "/accounts:batchCreate?key=value:update", false},
+	}
+	for _, tc := range cases {
+		if got := isIdempotent(tc.method, tc.url); got != tc.want {
+			t.Errorf("isIdempotent(%s %s) = %v; want = %v", tc.method, tc.url, got, tc.want)
+		}
+	}
+}
+
+func TestRetryAfterExceedsDeadline(t *testing.T) {
+	s := newRetryServer(testRetryConfig, []int{503, 200}, t)
+	defer s.Close()
+	s.header.Set("Retry-After", "60")
+	s.Client.tenantID = testTenantID
+
+	cctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := s.Client.DeleteUser(cctx, "uid1"); err == nil {
+		t.Errorf("DeleteUser() = nil; want = error")
+	}
+	if got := s.Requests(); got != 1 {
+		t.Errorf("DeleteUser() requests = %d; want = 1", got)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("DeleteUser() took %v; want immediate failure", elapsed)
+	}
+}
+
+func TestRetryCancelledContext(t *testing.T) {
+	s := newRetryServer(&RetryConfig{MaxAttempts: 3, BaseDelay: time.Minute}, []int{503, 200}, t)
+	defer s.Close()
+
+	cctx, cancel := context.WithCancel(ctx)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if err := s.Client.DeleteUser(cctx, "uid1"); err == nil {
+		t.Errorf("DeleteUser() = nil; want = error")
+	}
+	if got := s.Requests(); got != 1 {
+		t.Errorf("DeleteUser() requests = %d; want = 1", got)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	rc := &RetryConfig{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	want := []time.Duration{100, 200, 300, 300}
+	for i, w := range want {
+		if got := rc.backoff(i + 1); got != w*time.Millisecond {
+			t.Errorf("backoff(%d) = %v; want = %v", i+1, got, w*time.Millisecond)
+		}
+	}
+
+	rc.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := rc.backoff(1); got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Errorf("backoff(1) = %v; want in range [50ms, 150ms]", got)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{now.Add(time.Minute).Format(http.TimeFormat), time.Minute},
+		{"not a duration", 0},
+	}
+	for _, tc := range cases {
+		header := http.Header{}
+		if tc.value != "" {
+			header.Set("Retry-After", tc.value)
+		}
+		if got := retryAfter(header, now); got != tc.want {
+			t.Errorf("retryAfter(%q) = %v; want = %v", tc.value, got, tc.want)
+		}
+	}
+}
+
+const quotaErrorBody = `{
+	"error": {
+		"code": 400,
+		"message": "QUOTA_EXCEEDED : Exceeded quota for deleting accounts.",
+		"status": "RESOURCE_EXHAUSTED",
+		"details": [
+			{
+				"@type": "type.googleapis.com/google.rpc.ErrorInfo",
+				"reason": "RATE_LIMIT_EXCEEDED",
+				"metadata": {"quota_metric": "identitytoolkit.googleapis.com/account_deletions"}
+			},
+			{
+				"@type": "type.googleapis.com/google.rpc.RetryInfo",
+				"retryDelay": "0.01s"
+			}
+		]
+	}
+}`
+
+func TestQuotaExceeded(t *testing.T) {
+	for _, tenant := range []bool{false, true} {
+		s := newRetryServer(&RetryConfig{MaxAttempts: 1}, []int{400}, t)
+		s.errBody = quotaErrorBody
+		if tenant {
+			s.Client.tenantID = testTenantID
+		}
+		err := s.Client.DeleteUser(ctx, "uid1")
+		if !IsQuotaExceeded(err) {
+			t.Errorf("DeleteUser(tenant: %v) = %v; want = QuotaExceededError", tenant, err)
+		}
+		qe, ok := err.(*QuotaExceededError)
+		if ok {
+			if want := "identitytoolkit.googleapis.com/account_deletions"; qe.Metric != want {
+				t.Errorf("Metric(tenant: %v) = %q; want = %q", tenant, qe.Metric, want)
+			}
+			if want := 10 * time.Millisecond; qe.RetryAfter != want {
+				t.Errorf("RetryAfter(tenant: %v) = %v; want = %v", tenant, qe.RetryAfter, want)
+			}
+		}
+		if IsUnknown(err) {
+			t.Errorf("IsUnknown(tenant: %v) = true; want = false", tenant)
+		}
+		s.Close()
+	}
+}
+
+func TestQuotaExceededDefaultRetry(t *testing.T) {
+	rc := defaultQuotaRetryConfig
+	defaultQuotaRetryConfig = testRetryConfig
+	defer func() { defaultQuotaRetryConfig = rc }()
+
+	s := newRetryServer(nil, []int{400, 200}, t)
+	defer s.Close()
+	s.errBody = quotaErrorBody
+	if err := s.Client.DeleteUser(ctx, "uid1"); err != nil {
+		t.Errorf("DeleteUser() = %v; want = nil", err)
+	}
+	if got := s.Requests(); got != 2 {
+		t.Errorf("Requests = %d; want = 2", got)
+	}
+
+	s = newRetryServer(nil, []int{503, 200}, t)
+	defer s.Close()
+	if err := s.Client.DeleteUser(ctx, "uid1"); err == nil {
+		t.Errorf("DeleteUser() = nil; want = error")
+	}
+	if got := s.Requests(); got != 1 {
+		t.Errorf("Requests = %d; want = 1", got)
+	}
+}
+
+func TestQuotaExceededRetry(t *testing.T) {
+	for _, tenant := range []bool{false, true} {
+		s := newRetryServer(testRetryConfig, []int{400, 400, 200}, t)
+		s.errBody = quotaErrorBody
+		if tenant {
+			s.Client.tenantID = testTenantID
+		}
+		if err := s.Client.DeleteUser(ctx, "uid1"); err != nil {
+			t.Errorf("DeleteUser(tenant: %v) = %v; want = nil", tenant, err)
+		}
+		if got := s.Requests(); got != 3 {
+			t.Errorf("DeleteUser(tenant: %v) requests = %d; want = 3", tenant, got)
+		}
+		s.Close()
+	}
+}
+
+func TestNewQuotaExceededError(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "30")
+	cases := []struct {
+		body string
+		want *QuotaExceededError
+	}{
+		{`{"error": {"message": "QUOTA_EXCEEDED"}}`, &QuotaExceededError{RetryAfter: 30 * time.Second, msg: "msg"}},
+		{`{"error": {"errors": [{"reason": "rateLimitExceeded"}]}}`, &QuotaExceededError{RetryAfter: 30 * time.Second, msg: "msg"}},
+		{`{"error": {"message": "USER_NOT_FOUND"}}`, nil},
+		{`not json`, nil},
+	}
+	for _, tc := range cases {
+		got := newQuotaExceededError([]byte(tc.body), header, time.Now(), "msg")
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("newQuotaExceededError(%q) = %#v; want = %#v", tc.body, got, tc.want)
+		}
+	}
+}
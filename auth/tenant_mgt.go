@@ -0,0 +1,475 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"firebase.google.com/go/internal"
+	"golang.org/x/net/context"
+
+	"google.golang.org/api/iterator"
+)
+
+const maxTenantPageSize = 100
+
+// tenantDisplayNamePattern matches display names accepted by the backend: 4 to 20 characters,
+// starting with a letter, consisting of letters, digits and hyphens.
+var tenantDisplayNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]{3,19}$`)
+
+// TenantManager is the interface used to manage tenants in a multi-tenant Google Cloud Identity
+// Platform project, and to obtain tenant-scoped auth clients.
+//
+// TenantManager instances should be obtained by calling the TenantManager() function on a Client.
+type TenantManager struct {
+	base *Client
+}
+
+// TenantManager returns the TenantManager associated with this Client.
+func (c *Client) TenantManager() *TenantManager {
+	return &TenantManager{base: c}
+}
+
+// AuthForTenant returns a TenantClient that performs all operations in the scope of the tenant
+// identified by tenantID.
+//
+// The tenant ID is not validated against the backend. Operations performed with a TenantClient
+// of a non-existing tenant fail with an error that can be checked with IsTenantNotFound().
+func (tm *TenantManager) AuthForTenant(tenantID string) (*TenantClient, error) {
+	if tenantID == "" {
+		return nil, errors.New("tenant id must be a non-empty string")
+	}
+	c := *tm.base
+	c.tenantID = tenantID
+	return &TenantClient{client: &c}, nil
+}
+
+// TenantClient is used for managing the users of a specific tenant, and for minting and verifying
+// tokens in the scope of that tenant.
+//
+// TenantClient offers the same user management and token functions as Client. All user accounts
+// created, retrieved or modified through a TenantClient belong to its tenant. Custom tokens minted
+// by a TenantClient carry the tenant ID, so that the client SDK signs the user into the correct
+// tenant. VerifyIDToken() additionally checks that the ID token was issued for the same tenant,
+// and returns an error that can be checked with IsTenantIDMismatch() otherwise.
+type TenantClient struct {
+	client *Client
+}
+
+// TenantID returns the ID of the tenant to which this TenantClient is scoped.
+func (tc *TenantClient) TenantID() string {
+	return tc.client.tenantID
+}
+
+// CustomToken creates a signed custom authentication token with the specified user ID, for the
+// tenant of this TenantClient.
+func (tc *TenantClient) CustomToken(ctx context.Context, uid string) (string, error) {
+	return tc.client.CustomToken(ctx, uid)
+}
+
+// CustomTokenWithClaims is similar to CustomToken, but in addition to the user ID, it also encodes
+// all the key-value pairs in the provided map as claims in the resulting JWT.
+func (tc *TenantClient) CustomTokenWithClaims(ctx context.Context, uid string, devClaims map[string]interface{}) (string, error) {
+	return tc.client.CustomTokenWithClaims(ctx, uid, devClaims)
+}
+
+// CustomTokenWithExpiry is similar to CustomToken, but in addition to the token, it also returns the
+// time at which the token expires.
+func (tc *TenantClient) CustomTokenWithExpiry(ctx context.Context, uid string) (string, time.Time, error) {
+	return tc.client.CustomTokenWithExpiry(ctx, uid)
+}
+
+// CustomTokenWithClaimsAndExpiry is similar to CustomTokenWithClaims, but in addition it allows
+// specifying the lifetime of the resulting JWT.
+func (tc *TenantClient) CustomTokenWithClaimsAndExpiry(ctx context.Context, uid string, devClaims map[string]interface{}, expiry time.Duration) (string, error) {
+	return tc.client.CustomTokenWithClaimsAndExpiry(ctx, uid, devClaims, expiry)
+}
+
+// VerifyIDToken verifies the signature and payload of the provided ID token, and checks that it
+// was issued for the tenant of this TenantClient.
+func (tc *TenantClient) VerifyIDToken(ctx context.Context, idToken string) (*Token, error) {
+	return tc.client.VerifyIDToken(ctx, idToken)
+}
+
+// DecodeVerifiedSignature decodes the provided ID token using Client.DecodeVerifiedSignature().
+// A token issued for a different tenant is reported with a ValidationError.
+func (tc *TenantClient) DecodeVerifiedSignature(ctx context.Context, idToken string) (*Token, []ValidationError, error) {
+	return tc.client.DecodeVerifiedSignature(ctx, idToken)
+}
+
+// VerifyIDTokenAllowExpired verifies the provided ID token using
+// Client.VerifyIDTokenAllowExpired(), and checks that it was issued for the tenant of this
+// TenantClient.
+func (tc *TenantClient) VerifyIDTokenAllowExpired(ctx context.Context, idToken string) (*Token, bool, error) {
+	return tc.client.VerifyIDTokenAllowExpired(ctx, idToken)
+}
+
+// VerifyIDTokenForAudience verifies an ID token issued for the specified Firebase project, and
+// checks that it belongs to the tenant of this TenantClient.
+func (tc *TenantClient) VerifyIDTokenForAudience(ctx context.Context, idToken, expectedAudience string) (*Token, error) {
+	return tc.client.VerifyIDTokenForAudience(ctx, idToken, expectedAudience)
+}
+
+// VerifyIDTokenFreshAuth verifies the provided ID token using Client.VerifyIDTokenFreshAuth(), and
+// checks that it was issued for the tenant of this TenantClient.
+func (tc *TenantClient) VerifyIDTokenFreshAuth(ctx context.Context, idToken string, maxAge time.Duration) (*Token, error) {
+	return tc.client.VerifyIDTokenFreshAuth(ctx, idToken, maxAge)
+}
+
+// VerifyIDTokenAndCheckRevoked verifies the provided ID token using VerifyIDToken(), and checks
+// that it has not been revoked. The corresponding user is looked up in the tenant of this
+// TenantClient.
+func (tc *TenantClient) VerifyIDTokenAndCheckRevoked(ctx context.Context, idToken string) (*Token, error) {
+	return tc.client.VerifyIDTokenAndCheckRevoked(ctx, idToken)
+}
+
+// VerifyIDTokensAndCheckRevoked verifies a batch of ID tokens using
+// Client.VerifyIDTokensAndCheckRevoked(). The corresponding users are looked up in the tenant of
+// this TenantClient.
+func (tc *TenantClient) VerifyIDTokensAndCheckRevoked(ctx context.Context, idTokens []string) ([]*Token, []error) {
+	return tc.client.VerifyIDTokensAndCheckRevoked(ctx, idTokens)
+}
+
+// RevokeRefreshTokens revokes all refresh tokens issued to a user of the tenant.
+func (tc *TenantClient) RevokeRefreshTokens(ctx context.Context, uid string) error {
+	return tc.client.RevokeRefreshTokens(ctx, uid)
+}
+
+// CreateUser creates a new user in the tenant with the specified properties.
+func (tc *TenantClient) CreateUser(ctx context.Context, user *UserToCreate) (*UserRecord, error) {
+	return tc.client.CreateUser(ctx, user)
+}
+
+// UpdateUser updates an existing user account of the tenant with the specified properties.
+func (tc *TenantClient) UpdateUser(ctx context.Context, uid string, user *UserToUpdate) (*UserRecord, error) {
+	return tc.client.UpdateUser(ctx, uid, user)
+}
+
+// DisableUser disables the user account of the tenant corresponding to the specified user ID.
+func (tc *TenantClient) DisableUser(ctx context.Context, uid string) (*UserRecord, error) {
+	return tc.client.DisableUser(ctx, uid)
+}
+
+// EnableUser re-enables the user account of the tenant corresponding to the specified user ID.
+func (tc *TenantClient) EnableUser(ctx context.Context, uid string) (*UserRecord, error) {
+	return tc.client.EnableUser(ctx, uid)
+}
+
+// DeleteUser deletes the user of the tenant with the given UID.
+func (tc *TenantClient) DeleteUser(ctx context.Context, uid string) error {
+	return tc.client.DeleteUser(ctx, uid)
+}
+
+// DeleteUsers deletes the users of the tenant specified by the given identifiers.
+func (tc *TenantClient) DeleteUsers(ctx context.Context, uids []string) (*DeleteUsersResult, error) {
+	return tc.client.DeleteUsers(ctx, uids)
+}
+
+// GetUser gets the data of the tenant user corresponding to the specified user ID.
+func (tc *TenantClient) GetUser(ctx context.Context, uid string) (*UserRecord, error) {
+	return tc.client.GetUser(ctx, uid)
+}
+
+// IsDisabled reports whether the tenant user corresponding to the specified user ID is disabled.
+func (tc *TenantClient) IsDisabled(ctx context.Context, uid string) (bool, error) {
+	return tc.client.IsDisabled(ctx, uid)
+}
+
+// GetUserByEmail gets the data of the tenant user corresponding to the specified email.
+func (tc *TenantClient) GetUserByEmail(ctx context.Context, email string) (*UserRecord, error) {
+	return tc.client.GetUserByEmail(ctx, email)
+}
+
+// GetUserByPhoneNumber gets the data of the tenant user corresponding to the specified phone
+// number.
+func (tc *TenantClient) GetUserByPhoneNumber(ctx context.Context, phone string) (*UserRecord, error) {
+	return tc.client.GetUserByPhoneNumber(ctx, phone)
+}
+
+// GetUsers gets the user data of the tenant users corresponding to the specified identifiers.
+func (tc *TenantClient) GetUsers(ctx context.Context, identifiers []UserIdentifier) (*GetUsersResult, error) {
+	return tc.client.GetUsers(ctx, identifiers)
+}
+
+// Users returns an iterator over the users of the tenant.
+func (tc *TenantClient) Users(ctx context.Context, nextPageToken string) *UserIterator {
+	return tc.client.Users(ctx, nextPageToken)
+}
+
+// UsersWithClaim returns the users of the tenant whose custom claim with the given key has the
+// specified value.
+func (tc *TenantClient) UsersWithClaim(ctx context.Context, key string, value interface{}, opts ...UsersWithClaimOption) ([]*ExportedUserRecord, error) {
+	return tc.client.UsersWithClaim(ctx, key, value, opts...)
+}
+
+// SetCustomUserClaims sets additional claims on an existing user account of the tenant.
+func (tc *TenantClient) SetCustomUserClaims(ctx context.Context, uid string, customClaims map[string]interface{}) error {
+	return tc.client.SetCustomUserClaims(ctx, uid, customClaims)
+}
+
+// HashConfig returns the parameters of the password hash algorithm used for the user accounts of
+// the tenant.
+func (tc *TenantClient) HashConfig(ctx context.Context) (*HashConfig, error) {
+	return tc.client.HashConfig(ctx)
+}
+
+// MigrateUID moves an existing user account of the tenant from oldUID to newUID.
+func (tc *TenantClient) MigrateUID(ctx context.Context, oldUID, newUID string, opts ...MigrateUIDOption) (*MigrateUIDResult, error) {
+	return tc.client.MigrateUID(ctx, oldUID, newUID, opts...)
+}
+
+// UnlinkProvider unlinks the specified provider from an existing user account of the tenant.
+func (tc *TenantClient) UnlinkProvider(ctx context.Context, uid, providerID string) error {
+	return tc.client.UnlinkProvider(ctx, uid, providerID)
+}
+
+// Tenant represents a tenant in a multi-tenant Google Cloud Identity Platform project.
+type Tenant struct {
+	ID                    string
+	DisplayName           string
+	AllowPasswordSignUp   bool
+	EnableEmailLinkSignIn bool
+}
+
+type tenantResponse struct {
+	Name                  string `json:"name"`
+	DisplayName           string `json:"displayName"`
+	AllowPasswordSignUp   bool   `json:"allowPasswordSignup"`
+	EnableEmailLinkSignIn bool   `json:"enableEmailLinkSignin"`
+}
+
+func (r *tenantResponse) tenant() *Tenant {
+	return &Tenant{
+		ID:                    extractResourceID(r.Name),
+		DisplayName:           r.DisplayName,
+		AllowPasswordSignUp:   r.AllowPasswordSignUp,
+		EnableEmailLinkSignIn: r.EnableEmailLinkSignIn,
+	}
+}
+
+func validateTenantParams(params nestedMap) error {
+	if name, ok := params.get("displayName"); ok {
+		if s := name.(string); !tenantDisplayNamePattern.MatchString(s) {
+			return fmt.Errorf("display name must be 4 to 20 characters long, start with a letter and "+
+				"contain only letters, digits and hyphens; got %q", s)
+		}
+	}
+	return nil
+}
+
+// TenantToCreate is the parameter struct for the CreateTenant function.
+type TenantToCreate struct {
+	params nestedMap
+}
+
+func (t *TenantToCreate) set(key string, value interface{}) *TenantToCreate {
+	if t.params == nil {
+		t.params = make(nestedMap)
+	}
+	t.params.set(key, value)
+	return t
+}
+
+// DisplayName setter.
+func (t *TenantToCreate) DisplayName(name string) *TenantToCreate {
+	return t.set("displayName", name)
+}
+
+// AllowPasswordSignUp enables or disables email sign-in provider.
+func (t *TenantToCreate) AllowPasswordSignUp(allow bool) *TenantToCreate {
+	return t.set("allowPasswordSignup", allow)
+}
+
+// EnableEmailLinkSignIn enables or disables email link sign-in.
+//
+// Disabling this makes the password required for email sign-in.
+func (t *TenantToCreate) EnableEmailLinkSignIn(enable bool) *TenantToCreate {
+	return t.set("enableEmailLinkSignin", enable)
+}
+
+// TenantToUpdate is the parameter struct for the UpdateTenant function.
+type TenantToUpdate struct {
+	params nestedMap
+}
+
+func (t *TenantToUpdate) set(key string, value interface{}) *TenantToUpdate {
+	if t.params == nil {
+		t.params = make(nestedMap)
+	}
+	t.params.set(key, value)
+	return t
+}
+
+// DisplayName setter.
+func (t *TenantToUpdate) DisplayName(name string) *TenantToUpdate {
+	return t.set("displayName", name)
+}
+
+// AllowPasswordSignUp enables or disables email sign-in provider.
+func (t *TenantToUpdate) AllowPasswordSignUp(allow bool) *TenantToUpdate {
+	return t.set("allowPasswordSignup", allow)
+}
+
+// EnableEmailLinkSignIn enables or disables email link sign-in.
+//
+// Disabling this makes the password required for email sign-in.
+func (t *TenantToUpdate) EnableEmailLinkSignIn(enable bool) *TenantToUpdate {
+	return t.set("enableEmailLinkSignin", enable)
+}
+
+// CreateTenant creates a new tenant with the given properties.
+//
+// The display name, if specified, is validated before the request is sent: it must be 4 to 20
+// characters long, start with a letter and contain only letters, digits and hyphens.
+func (tm *TenantManager) CreateTenant(ctx context.Context, tenant *TenantToCreate) (*Tenant, error) {
+	if tenant == nil {
+		tenant = &TenantToCreate{}
+	}
+	if err := validateTenantParams(tenant.params); err != nil {
+		return nil, err
+	}
+
+	var payload interface{} = tenant.params
+	if tenant.params == nil {
+		payload = map[string]interface{}{}
+	}
+	var resp tenantResponse
+	if err := tm.makeHTTPCall(ctx, http.MethodPost, "", payload, &resp); err != nil {
+		return nil, err
+	}
+	return resp.tenant(), nil
+}
+
+// Tenant returns the tenant with the given ID.
+//
+// If the specified tenant does not exist, Tenant returns an error that can be checked with
+// IsTenantNotFound().
+func (tm *TenantManager) Tenant(ctx context.Context, tenantID string) (*Tenant, error) {
+	if tenantID == "" {
+		return nil, errors.New("tenant id must be a non-empty string")
+	}
+
+	var resp tenantResponse
+	if err := tm.makeHTTPCall(ctx, http.MethodGet, "/"+tenantID, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.tenant(), nil
+}
+
+// UpdateTenant updates the tenant with the given ID, and returns the updated tenant.
+//
+// Only the properties set on the TenantToUpdate are modified.
+func (tm *TenantManager) UpdateTenant(ctx context.Context, tenantID string, tenant *TenantToUpdate) (*Tenant, error) {
+	if tenantID == "" {
+		return nil, errors.New("tenant id must be a non-empty string")
+	}
+	if tenant == nil || len(tenant.params) == 0 {
+		return nil, errors.New("update parameters must not be nil or empty")
+	}
+	if err := validateTenantParams(tenant.params); err != nil {
+		return nil, err
+	}
+
+	var resp tenantResponse
+	opt := internal.WithQueryParam("updateMask", tenant.params.updateMask())
+	if err := tm.makeHTTPCall(ctx, http.MethodPatch, "/"+tenantID, tenant.params, &resp, opt); err != nil {
+		return nil, err
+	}
+	return resp.tenant(), nil
+}
+
+// DeleteTenant deletes the tenant with the given ID.
+//
+// Deleting a tenant also deletes all the user accounts of the tenant.
+func (tm *TenantManager) DeleteTenant(ctx context.Context, tenantID string) error {
+	if tenantID == "" {
+		return errors.New("tenant id must be a non-empty string")
+	}
+	return tm.makeHTTPCall(ctx, http.MethodDelete, "/"+tenantID, nil, nil)
+}
+
+// Tenants returns an iterator over the tenants of the project.
+//
+// If nextPageToken is empty, the iterator will start at the beginning. If the nextPageToken is not
+// empty, the iterator starts after the token.
+func (tm *TenantManager) Tenants(ctx context.Context, nextPageToken string) *TenantIterator {
+	it := &TenantIterator{
+		ctx: ctx,
+		tm:  tm,
+	}
+	it.pageInfo, it.nextFunc = iterator.NewPageInfo(
+		it.fetch,
+		func() int { return len(it.tenants) },
+		func() interface{} { b := it.tenants; it.tenants = nil; return b })
+	it.pageInfo.MaxSize = maxTenantPageSize
+	it.pageInfo.Token = nextPageToken
+	return it
+}
+
+// TenantIterator is an iterator over tenants.
+//
+// Also see: https://github.com/GoogleCloudPlatform/google-cloud-go/wiki/Iterator-Guidelines
+type TenantIterator struct {
+	tm       *TenantManager
+	ctx      context.Context
+	nextFunc func() error
+	pageInfo *iterator.PageInfo
+	tenants  []*Tenant
+}
+
+// PageInfo supports pagination. See the google.golang.org/api/iterator package for details.
+func (it *TenantIterator) PageInfo() *iterator.PageInfo { return it.pageInfo }
+
+// Next returns the next result. Its second return value is [iterator.Done] if there are no more
+// results. Once Next returns [iterator.Done], all subsequent calls will return [iterator.Done].
+func (it *TenantIterator) Next() (*Tenant, error) {
+	if err := it.nextFunc(); err != nil {
+		return nil, err
+	}
+	tenant := it.tenants[0]
+	it.tenants = it.tenants[1:]
+	return tenant, nil
+}
+
+func (it *TenantIterator) fetch(pageSize int, pageToken string) (string, error) {
+	opts := []internal.HTTPOption{internal.WithQueryParam("pageSize", strconv.Itoa(pageSize))}
+	if pageToken != "" {
+		opts = append(opts, internal.WithQueryParam("pageToken", pageToken))
+	}
+
+	var resp struct {
+		Tenants       []*tenantResponse `json:"tenants"`
+		NextPageToken string            `json:"nextPageToken"`
+	}
+	if err := it.tm.makeHTTPCall(it.ctx, http.MethodGet, "", nil, &resp, opts...); err != nil {
+		return "", err
+	}
+	for _, t := range resp.Tenants {
+		it.tenants = append(it.tenants, t.tenant())
+	}
+	it.pageInfo.Token = resp.NextPageToken
+	return resp.NextPageToken, nil
+}
+
+// makeHTTPCall sends a request to the tenant management endpoints of the identitytoolkit v2 API.
+// The path is appended to the resource name of the tenants collection of the current project.
+func (tm *TenantManager) makeHTTPCall(ctx context.Context, method, path string, payload, v interface{}, opts ...internal.HTTPOption) error {
+	return tm.base.makeV2HTTPCall(ctx, method, "/tenants"+path, payload, v, opts...)
+}
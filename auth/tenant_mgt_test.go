@@ -0,0 +1,541 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/iterator"
+)
+
+const testTenantID = "tenant-1"
+
+func tenantClient(c *Client, t *testing.T) *TenantClient {
+	tc, err := c.TenantManager().AuthForTenant(testTenantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tc
+}
+
+func TestAuthForTenant(t *testing.T) {
+	tc := tenantClient(client, t)
+	if tc.TenantID() != testTenantID {
+		t.Errorf("TenantID() = %q; want = %q", tc.TenantID(), testTenantID)
+	}
+	if client.tenantID != "" {
+		t.Errorf("Client.tenantID = %q; want = %q", client.tenantID, "")
+	}
+}
+
+func TestAuthForTenantEmptyID(t *testing.T) {
+	if tc, err := client.TenantManager().AuthForTenant(""); tc != nil || err == nil {
+		t.Errorf("AuthForTenant('') = (%v, %v); want = (nil, error)", tc, err)
+	}
+}
+
+func TestTenantGetUser(t *testing.T) {
+	s := echoServer(testGetUserResponse, t)
+	defer s.Close()
+
+	user, err := tenantClient(s.Client, t).GetUser(context.Background(), "ignored_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(user, testUser) {
+		t.Errorf("GetUser() = %#v; want = %#v", user, testUser)
+	}
+
+	want := `{"localId":["ignored_id"]}`
+	if string(s.Rbody) != want {
+		t.Errorf("GetUser() Req = %s; want = %s", string(s.Rbody), want)
+	}
+	wantURL := "/projects/mock-project-id/tenants/tenant-1/accounts:lookup"
+	if s.Req[0].URL.Path != wantURL {
+		t.Errorf("GetUser() URL = %q; want = %q", s.Req[0].URL.Path, wantURL)
+	}
+}
+
+func TestTenantGetUsers(t *testing.T) {
+	s := echoServer(testGetUsersResponse, t)
+	defer s.Close()
+
+	result, err := tenantClient(s.Client, t).GetUsers(context.Background(), []UserIdentifier{UIDIdentifier{"uid1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Users) != 3 || len(result.NotFound) != 0 {
+		t.Errorf("GetUsers() = (%d, %d); want = (3, 0)", len(result.Users), len(result.NotFound))
+	}
+	checkRequest(s, http.MethodPost, "/projects/mock-project-id/tenants/tenant-1/accounts:lookup", t)
+}
+
+func TestTenantGetNonExistingUser(t *testing.T) {
+	s := echoServer([]byte(`{"kind": "identitytoolkit#GetAccountInfoResponse"}`), t)
+	defer s.Close()
+
+	user, err := tenantClient(s.Client, t).GetUserByEmail(context.Background(), "test@email.com")
+	if user != nil || !IsUserNotFound(err) {
+		t.Errorf("GetUserByEmail() = (%v, %v); want = (nil, user-not-found)", user, err)
+	}
+}
+
+func TestTenantCreateUser(t *testing.T) {
+	s := echoServer([]byte(`{"localId": "testuid"}`), t)
+	defer s.Close()
+
+	uid, err := tenantClient(s.Client, t).client.createUser(context.Background(), (&UserToCreate{}).UID("testuid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uid != "testuid" {
+		t.Errorf("createUser() = %q; want = %q", uid, "testuid")
+	}
+	var req map[string]interface{}
+	if err := json.Unmarshal(s.Rbody, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req["tenantId"] != testTenantID {
+		t.Errorf("createUser() tenantId = %v; want = %q", req["tenantId"], testTenantID)
+	}
+}
+
+func TestTenantUpdateUser(t *testing.T) {
+	s := echoServer([]byte(`{"localId": "testuid"}`), t)
+	defer s.Close()
+
+	tc := tenantClient(s.Client, t)
+	if err := tc.client.updateUser(context.Background(), "testuid", (&UserToUpdate{}).Disabled(true)); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"disableUser":true,"localId":"testuid"}`
+	if string(s.Rbody) != want {
+		t.Errorf("updateUser() Req = %s; want = %s", string(s.Rbody), want)
+	}
+	wantURL := "/projects/mock-project-id/tenants/tenant-1/accounts:update"
+	if s.Req[0].URL.Path != wantURL {
+		t.Errorf("updateUser() URL = %q; want = %q", s.Req[0].URL.Path, wantURL)
+	}
+}
+
+func TestTenantRevokeRefreshTokens(t *testing.T) {
+	s := echoServer([]byte(`{"localId": "testuid"}`), t)
+	defer s.Close()
+
+	before := time.Now().Unix()
+	if err := tenantClient(s.Client, t).RevokeRefreshTokens(context.Background(), "testuid"); err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now().Unix()
+
+	var req struct {
+		LocalID    string `json:"localId"`
+		ValidSince int64  `json:"validSince,string"`
+	}
+	if err := json.Unmarshal(s.Rbody, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.LocalID != "testuid" {
+		t.Errorf("RevokeRefreshTokens() localId = %q; want = %q", req.LocalID, "testuid")
+	}
+	if req.ValidSince < before || req.ValidSince > after {
+		t.Errorf("RevokeRefreshTokens() validSince = %d; want between %d and %d", req.ValidSince, before, after)
+	}
+	checkRequest(s, http.MethodPost, "/projects/mock-project-id/tenants/tenant-1/accounts:update", t)
+}
+
+func TestTenantVerifyIDTokenAndCheckRevoked(t *testing.T) {
+	s := echoServer(testGetUserResponse, t)
+	defer s.Close()
+
+	tc := tenantClient(s.Client, t)
+	idToken := getIDToken(mockIDTokenPayload{
+		"firebase": map[string]interface{}{"tenant": testTenantID},
+	})
+	ft, err := tc.VerifyIDTokenAndCheckRevoked(context.Background(), idToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ft.Firebase().Tenant != testTenantID {
+		t.Errorf("Firebase().Tenant = %q; want = %q", ft.Firebase().Tenant, testTenantID)
+	}
+	checkRequest(s, http.MethodPost, "/projects/mock-project-id/tenants/tenant-1/accounts:lookup", t)
+
+	idToken = getIDToken(mockIDTokenPayload{
+		"iat":      1970,
+		"firebase": map[string]interface{}{"tenant": testTenantID},
+	})
+	if ft, err := tc.VerifyIDTokenAndCheckRevoked(context.Background(), idToken); ft != nil || !IsIDTokenRevoked(err) {
+		t.Errorf("VerifyIDTokenAndCheckRevoked() = (%v, %v); want = (nil, id-token-revoked)", ft, err)
+	}
+}
+
+func TestTenantDeleteUser(t *testing.T) {
+	s := echoServer([]byte(`{}`), t)
+	defer s.Close()
+
+	if err := tenantClient(s.Client, t).DeleteUser(context.Background(), "testuid"); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"localId":"testuid"}`
+	if string(s.Rbody) != want {
+		t.Errorf("DeleteUser() Req = %s; want = %s", string(s.Rbody), want)
+	}
+	wantURL := "/projects/mock-project-id/tenants/tenant-1/accounts:delete"
+	if s.Req[0].URL.Path != wantURL {
+		t.Errorf("DeleteUser() URL = %q; want = %q", s.Req[0].URL.Path, wantURL)
+	}
+}
+
+func TestTenantDeleteUserError(t *testing.T) {
+	s := echoServer([]byte(`{"error": {"message": "TENANT_NOT_FOUND"}}`), t)
+	defer s.Close()
+	s.Status = http.StatusBadRequest
+
+	if err := tenantClient(s.Client, t).DeleteUser(context.Background(), "testuid"); !IsTenantNotFound(err) {
+		t.Errorf("DeleteUser() = %v; want = tenant-not-found", err)
+	}
+}
+
+func TestTenantListUsers(t *testing.T) {
+	s := echoServer(testListUsersResponse, t)
+	defer s.Close()
+
+	it := tenantClient(s.Client, t).Users(context.Background(), "pageToken")
+	count := 0
+	for {
+		_, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		count++
+	}
+	if count != 3 {
+		t.Errorf("Users() = %d; want = 3", count)
+	}
+
+	req := s.Req[0]
+	if req.Method != http.MethodGet {
+		t.Errorf("Users() Method = %q; want = %q", req.Method, http.MethodGet)
+	}
+	wantURL := "/projects/mock-project-id/tenants/tenant-1/accounts:batchGet"
+	if req.URL.Path != wantURL {
+		t.Errorf("Users() URL = %q; want = %q", req.URL.Path, wantURL)
+	}
+	if got := req.URL.Query().Get("maxResults"); got != "1000" {
+		t.Errorf("Users() maxResults = %q; want = %q", got, "1000")
+	}
+	if got := req.URL.Query().Get("nextPageToken"); got != "pageToken" {
+		t.Errorf("Users() nextPageToken = %q; want = %q", got, "pageToken")
+	}
+}
+
+func TestTenantCustomToken(t *testing.T) {
+	token, err := tenantClient(client, t).CustomToken(ctx, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &jwtHeader{}
+	p := &customToken{}
+	if err := decodeToken(ctx, token, client.ks, h, p); err != nil {
+		t.Fatal(err)
+	}
+	if p.TenantID != testTenantID {
+		t.Errorf("CustomToken() tenant_id = %q; want = %q", p.TenantID, testTenantID)
+	}
+	if p.UID != "user1" {
+		t.Errorf("CustomToken() uid = %q; want = %q", p.UID, "user1")
+	}
+}
+
+func TestCustomTokenWithoutTenant(t *testing.T) {
+	token, err := client.CustomToken(ctx, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &jwtHeader{}
+	p := &customToken{}
+	if err := decodeToken(ctx, token, client.ks, h, p); err != nil {
+		t.Fatal(err)
+	}
+	if p.TenantID != "" {
+		t.Errorf("CustomToken() tenant_id = %q; want = %q", p.TenantID, "")
+	}
+}
+
+func TestTenantVerifyIDToken(t *testing.T) {
+	idToken := getIDToken(mockIDTokenPayload{
+		"firebase": map[string]interface{}{"tenant": testTenantID},
+	})
+	ft, err := tenantClient(client, t).VerifyIDToken(ctx, idToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ft.Firebase().Tenant != testTenantID {
+		t.Errorf("Firebase().Tenant = %q; want = %q", ft.Firebase().Tenant, testTenantID)
+	}
+
+	// Tenant tokens are accepted by the project-level client.
+	if _, err := client.VerifyIDToken(ctx, idToken); err != nil {
+		t.Errorf("Client.VerifyIDToken() = %v; want = nil", err)
+	}
+}
+
+func TestTenantVerifyIDTokenMismatch(t *testing.T) {
+	cases := []struct {
+		name  string
+		token string
+	}{
+		{"NoTenant", testIDToken},
+		{"OtherTenant", getIDToken(mockIDTokenPayload{
+			"firebase": map[string]interface{}{"tenant": "other-tenant"},
+		})},
+	}
+	tc := tenantClient(client, t)
+	for _, c := range cases {
+		ft, err := tc.VerifyIDToken(ctx, c.token)
+		if ft != nil || !IsTenantIDMismatch(err) {
+			t.Errorf("VerifyIDToken(%s) = (%v, %v); want = (nil, tenant-id-mismatch)", c.name, ft, err)
+		}
+		ft, expired, err := tc.VerifyIDTokenAllowExpired(ctx, c.token)
+		if ft != nil || expired || !IsTenantIDMismatch(err) {
+			t.Errorf("VerifyIDTokenAllowExpired(%s) = (%v, %v, %v); want = (nil, false, tenant-id-mismatch)",
+				c.name, ft, expired, err)
+		}
+		ft, errs, err := tc.DecodeVerifiedSignature(ctx, c.token)
+		if ft == nil || err != nil || len(errs) != 1 || !IsTenantIDMismatch(errs[0].Err) {
+			t.Errorf("DecodeVerifiedSignature(%s) = (%v, %v, %v); want = (token, [tenant-id-mismatch], nil)",
+				c.name, ft, errs, err)
+		}
+	}
+}
+
+const testTenantResponse = `{
+	"name": "projects/mock-project-id/tenants/tenant-1",
+	"displayName": "Test-Tenant",
+	"allowPasswordSignup": true,
+	"enableEmailLinkSignin": true
+}`
+
+var testTenant = &Tenant{
+	ID:                    testTenantID,
+	DisplayName:           "Test-Tenant",
+	AllowPasswordSignUp:   true,
+	EnableEmailLinkSignIn: true,
+}
+
+func TestTenant(t *testing.T) {
+	s := echoServer([]byte(testTenantResponse), t)
+	defer s.Close()
+
+	tenant, err := s.Client.TenantManager().Tenant(context.Background(), testTenantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tenant, testTenant) {
+		t.Errorf("Tenant() = %#v; want = %#v", tenant, testTenant)
+	}
+	checkRequest(s, http.MethodGet, "/projects/mock-project-id/tenants/tenant-1", t)
+}
+
+func TestTenantNotFound(t *testing.T) {
+	s := echoServer([]byte(`{"error": {"message": "TENANT_NOT_FOUND"}}`), t)
+	defer s.Close()
+	s.Status = http.StatusNotFound
+
+	tenant, err := s.Client.TenantManager().Tenant(context.Background(), testTenantID)
+	if tenant != nil || !IsTenantNotFound(err) {
+		t.Errorf("Tenant() = (%v, %v); want = (nil, tenant-not-found)", tenant, err)
+	}
+}
+
+func TestCreateTenant(t *testing.T) {
+	s := echoServer([]byte(testTenantResponse), t)
+	defer s.Close()
+
+	params := (&TenantToCreate{}).
+		DisplayName("Test-Tenant").
+		AllowPasswordSignUp(true).
+		EnableEmailLinkSignIn(true)
+	tenant, err := s.Client.TenantManager().CreateTenant(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tenant, testTenant) {
+		t.Errorf("CreateTenant() = %#v; want = %#v", tenant, testTenant)
+	}
+	checkRequest(s, http.MethodPost, "/projects/mock-project-id/tenants", t)
+
+	want := `{"allowPasswordSignup":true,"displayName":"Test-Tenant","enableEmailLinkSignin":true}`
+	if string(s.Rbody) != want {
+		t.Errorf("CreateTenant() Req = %s; want = %s", string(s.Rbody), want)
+	}
+}
+
+func TestCreateTenantNoParams(t *testing.T) {
+	s := echoServer([]byte(testTenantResponse), t)
+	defer s.Close()
+
+	if _, err := s.Client.TenantManager().CreateTenant(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if string(s.Rbody) != "{}" {
+		t.Errorf("CreateTenant() Req = %s; want = {}", string(s.Rbody))
+	}
+}
+
+func TestInvalidTenantDisplayName(t *testing.T) {
+	cases := []string{
+		"",
+		"abc",
+		"1abc",
+		"abc_def",
+		"a-display-name-too-long",
+	}
+	tm := client.TenantManager()
+	for _, name := range cases {
+		tenant, err := tm.CreateTenant(context.Background(), (&TenantToCreate{}).DisplayName(name))
+		if tenant != nil || err == nil {
+			t.Errorf("CreateTenant(%q) = (%v, %v); want = (nil, error)", name, tenant, err)
+		}
+		tenant, err = tm.UpdateTenant(context.Background(), testTenantID, (&TenantToUpdate{}).DisplayName(name))
+		if tenant != nil || err == nil {
+			t.Errorf("UpdateTenant(%q) = (%v, %v); want = (nil, error)", name, tenant, err)
+		}
+	}
+}
+
+func TestUpdateTenant(t *testing.T) {
+	s := echoServer([]byte(testTenantResponse), t)
+	defer s.Close()
+
+	params := (&TenantToUpdate{}).DisplayName("Test-Tenant").AllowPasswordSignUp(true)
+	tenant, err := s.Client.TenantManager().UpdateTenant(context.Background(), testTenantID, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tenant, testTenant) {
+		t.Errorf("UpdateTenant() = %#v; want = %#v", tenant, testTenant)
+	}
+	checkRequest(s, http.MethodPatch, "/projects/mock-project-id/tenants/tenant-1", t)
+
+	want := `{"allowPasswordSignup":true,"displayName":"Test-Tenant"}`
+	if string(s.Rbody) != want {
+		t.Errorf("UpdateTenant() Req = %s; want = %s", string(s.Rbody), want)
+	}
+	wantMask := "allowPasswordSignup,displayName"
+	if mask := s.Req[0].URL.Query().Get("updateMask"); mask != wantMask {
+		t.Errorf("UpdateTenant() updateMask = %q; want = %q", mask, wantMask)
+	}
+}
+
+func TestInvalidUpdateTenant(t *testing.T) {
+	tm := client.TenantManager()
+	cases := []struct {
+		name     string
+		tenantID string
+		params   *TenantToUpdate
+	}{
+		{"EmptyID", "", (&TenantToUpdate{}).DisplayName("Test-Tenant")},
+		{"NilParams", testTenantID, nil},
+		{"EmptyParams", testTenantID, &TenantToUpdate{}},
+	}
+	for _, tc := range cases {
+		tenant, err := tm.UpdateTenant(context.Background(), tc.tenantID, tc.params)
+		if tenant != nil || err == nil {
+			t.Errorf("UpdateTenant(%s) = (%v, %v); want = (nil, error)", tc.name, tenant, err)
+		}
+	}
+}
+
+func TestDeleteTenant(t *testing.T) {
+	s := echoServer([]byte("{}"), t)
+	defer s.Close()
+
+	if err := s.Client.TenantManager().DeleteTenant(context.Background(), testTenantID); err != nil {
+		t.Fatal(err)
+	}
+	checkRequest(s, http.MethodDelete, "/projects/mock-project-id/tenants/tenant-1", t)
+}
+
+func TestInvalidTenantID(t *testing.T) {
+	tm := client.TenantManager()
+	if tenant, err := tm.Tenant(context.Background(), ""); tenant != nil || err == nil {
+		t.Errorf("Tenant('') = (%v, %v); want = (nil, error)", tenant, err)
+	}
+	if err := tm.DeleteTenant(context.Background(), ""); err == nil {
+		t.Errorf("DeleteTenant('') = nil; want = error")
+	}
+}
+
+func TestTenants(t *testing.T) {
+	resp := `{
+		"tenants": [
+			` + testTenantResponse + `,
+			` + testTenantResponse + `
+		],
+		"nextPageToken": ""
+	}`
+	s := echoServer([]byte(resp), t)
+	defer s.Close()
+
+	it := s.Client.TenantManager().Tenants(context.Background(), "pageToken")
+	var tenants []*Tenant
+	for {
+		tenant, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		tenants = append(tenants, tenant)
+	}
+	if len(tenants) != 2 {
+		t.Fatalf("Tenants() = %d; want = 2", len(tenants))
+	}
+	for _, tenant := range tenants {
+		if !reflect.DeepEqual(tenant, testTenant) {
+			t.Errorf("Tenants() = %#v; want = %#v", tenant, testTenant)
+		}
+	}
+	checkRequest(s, http.MethodGet, "/projects/mock-project-id/tenants", t)
+
+	query := s.Req[0].URL.Query()
+	if got := query.Get("pageSize"); got != "100" {
+		t.Errorf("Tenants() pageSize = %q; want = %q", got, "100")
+	}
+	if got := query.Get("pageToken"); got != "pageToken" {
+		t.Errorf("Tenants() pageToken = %q; want = %q", got, "pageToken")
+	}
+}
+
+func checkRequest(s *mockAuthServer, method, path string, t *testing.T) {
+	req := s.Req[0]
+	if req.Method != method {
+		t.Errorf("Method = %q; want = %q", req.Method, method)
+	}
+	if req.URL.Path != path {
+		t.Errorf("URL = %q; want = %q", req.URL.Path, path)
+	}
+}
@@ -18,8 +18,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"firebase.google.com/go/internal"
@@ -33,18 +35,18 @@ import (
 const (
 	maxReturnedResults = 1000
 	maxLenPayloadCC    = 1000
+	maxDeleteBatchSize = 1000
+	claimsConcurrency  = 10
 	defaultProviderID  = "firebase"
 )
 
-// Create a new interface
-type identitytoolkitCall interface {
-	Header() http.Header
-}
+// emailPattern matches a non-empty local part and a domain made up of non-empty, dot-separated
+// labels. Whitespace and additional '@' characters are not allowed in either part.
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s.]+(\.[^@\s.]+)*$`)
 
-// set header
-func (c *Client) setHeader(ic identitytoolkitCall) {
-	ic.Header().Set("X-Client-Version", c.version)
-}
+// phonePattern matches E.164 phone numbers: a leading '+' followed by at most 15 digits, with no
+// spaces, dashes or other separators.
+var phonePattern = regexp.MustCompile(`^\+[0-9]{1,15}$`)
 
 // UserInfo is a collection of standard profile information for a user.
 type UserInfo struct {
@@ -78,6 +80,10 @@ type UserRecord struct {
 }
 
 // ExportedUserRecord is the returned user value used when listing all the users.
+//
+// PasswordHash and PasswordSalt are base64url-encoded, as returned by the backend. They are only
+// set for users with a password, and when the credentials of the Client have permission to read
+// password hashes. Use HashConfig() to obtain the parameters of the hash algorithm.
 type ExportedUserRecord struct {
 	*UserRecord
 	PasswordHash string
@@ -97,12 +103,14 @@ type UserIterator struct {
 
 // UserToCreate is the parameter struct for the CreateUser function.
 type UserToCreate struct {
-	createReq   *identitytoolkit.IdentitytoolkitRelyingpartySignupNewUserRequest
-	uid         bool
-	displayName bool
-	email       bool
-	photoURL    bool
-	phoneNumber bool
+	createReq    *identitytoolkit.IdentitytoolkitRelyingpartySignupNewUserRequest
+	claims       map[string]interface{}
+	uid          bool
+	displayName  bool
+	email        bool
+	photoURL     bool
+	phoneNumber  bool
+	customClaims bool
 }
 
 func (u *UserToCreate) request() *identitytoolkit.IdentitytoolkitRelyingpartySignupNewUserRequest {
@@ -144,9 +152,23 @@ func (u *UserToCreate) validatedRequest() (*identitytoolkit.IdentitytoolkitRelyi
 			return nil, err
 		}
 	}
+	if u.customClaims {
+		if _, err := marshalCustomClaims(u.claims); err != nil {
+			return nil, err
+		}
+	}
 	return req, nil
 }
 
+// CustomClaims setter. The claims are subject to the same restrictions as the claims set with
+// SetCustomUserClaims().
+func (u *UserToCreate) CustomClaims(claims map[string]interface{}) *UserToCreate {
+	u.request() // force initialization of the request for later use
+	u.claims = claims
+	u.customClaims = true
+	return u
+}
+
 // Disabled setter.
 func (u *UserToCreate) Disabled(disabled bool) *UserToCreate {
 	req := u.request()
@@ -334,7 +356,19 @@ func (u *UserToUpdate) revokeRefreshTokens() *UserToUpdate {
 	return u
 }
 
+// deleteProvider unlinks the specified provider from the user account.
+func (u *UserToUpdate) deleteProvider(providerID string) *UserToUpdate {
+	req := u.request()
+	req.DeleteProvider = append(req.DeleteProvider, providerID)
+	return u
+}
+
 // CreateUser creates a new user with the specified properties.
+//
+// When custom claims are specified, the user is created in the disabled state, and is only enabled
+// (unless the UserToCreate requests a disabled user) by the same call that sets the claims. The
+// user therefore cannot sign in without bearing the claims. If the claims cannot be set, the new
+// user is deleted, and CreateUser returns an error.
 func (c *Client) CreateUser(ctx context.Context, user *UserToCreate) (*UserRecord, error) {
 	uid, err := c.createUser(ctx, user)
 	if err != nil {
@@ -353,6 +387,18 @@ func (c *Client) UpdateUser(ctx context.Context, uid string, user *UserToUpdate)
 	return c.GetUser(ctx, uid)
 }
 
+// DisableUser disables the user account corresponding to the specified user ID, and returns the
+// updated UserRecord. A disabled user cannot sign in, and cannot refresh their existing ID tokens.
+func (c *Client) DisableUser(ctx context.Context, uid string) (*UserRecord, error) {
+	return c.UpdateUser(ctx, uid, (&UserToUpdate{}).Disabled(true))
+}
+
+// EnableUser re-enables the user account corresponding to the specified user ID, and returns the
+// updated UserRecord.
+func (c *Client) EnableUser(ctx context.Context, uid string) (*UserRecord, error) {
+	return c.UpdateUser(ctx, uid, (&UserToUpdate{}).Disabled(false))
+}
+
 // DeleteUser deletes the user by the given UID.
 func (c *Client) DeleteUser(ctx context.Context, uid string) error {
 	if err := validateUID(uid); err != nil {
@@ -361,16 +407,85 @@ func (c *Client) DeleteUser(ctx context.Context, uid string) error {
 	request := &identitytoolkit.IdentitytoolkitRelyingpartyDeleteAccountRequest{
 		LocalId: uid,
 	}
+	if c.tenantID != "" {
+		return c.makeHTTPCall(ctx, http.MethodPost, "/accounts:delete", request, nil)
+	}
 
-	call := c.is.Relyingparty.DeleteAccount(request)
-	c.setHeader(call)
-	if _, err := call.Context(ctx).Do(); err != nil {
+	err := c.retryGoogleAPI(ctx, true, func() error {
+		_, err := c.is.deleteAccount(ctx, request)
+		return err
+	})
+	if err != nil {
 		return handleServerError(err)
 	}
 	return nil
 }
 
+// DeleteUsersResult represents the result of a DeleteUsers() call.
+type DeleteUsersResult struct {
+	SuccessCount int
+	FailureCount int
+	Errors       []*DeleteUsersErrorInfo
+}
+
+// DeleteUsersErrorInfo represents an error encountered while deleting a user account.
+//
+// The Index field corresponds to the index of the failed user in the uids slice that was passed
+// to DeleteUsers().
+type DeleteUsersErrorInfo struct {
+	Index  int    `json:"index"`
+	UID    string `json:"localId"`
+	Reason string `json:"message"`
+}
+
+// DeleteUsers deletes the users specified by the given identifiers.
+//
+// Deleting a non-existing user does not generate an error (the operation is idempotent).
+// Non-existing users are considered to be successfully deleted, and are therefore included in
+// the DeleteUsersResult.SuccessCount value. UIDs are sent to the backend in batches of at most
+// 1000. All UIDs are validated before any network call is made, so an invalid UID in the input
+// fails the entire operation.
+//
+// If a batch request after the first one fails, DeleteUsers stops and returns the error together
+// with a DeleteUsersResult that covers only the batches already processed. The first
+// SuccessCount+FailureCount UIDs have been handled, while the remaining UIDs were not sent to the
+// backend.
+func (c *Client) DeleteUsers(ctx context.Context, uids []string) (*DeleteUsersResult, error) {
+	for _, uid := range uids {
+		if err := validateUID(uid); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &DeleteUsersResult{}
+	for start := 0; start < len(uids); start += maxDeleteBatchSize {
+		end := start + maxDeleteBatchSize
+		if end > len(uids) {
+			end = len(uids)
+		}
+		errs, err := c.deleteUsers(ctx, uids[start:end])
+		if err != nil {
+			if start == 0 {
+				return nil, err
+			}
+			result.FailureCount = len(result.Errors)
+			result.SuccessCount = start - result.FailureCount
+			return result, err
+		}
+		for _, e := range errs {
+			e.Index += start
+			result.Errors = append(result.Errors, e)
+		}
+	}
+	result.FailureCount = len(result.Errors)
+	result.SuccessCount = len(uids) - result.FailureCount
+	return result, nil
+}
+
 // GetUser gets the user data corresponding to the specified user ID.
+//
+// If the specified user does not exist, GetUser returns an error that can be checked with
+// IsUserNotFound().
 func (c *Client) GetUser(ctx context.Context, uid string) (*UserRecord, error) {
 	if err := validateUID(uid); err != nil {
 		return nil, err
@@ -382,6 +497,9 @@ func (c *Client) GetUser(ctx context.Context, uid string) (*UserRecord, error) {
 }
 
 // GetUserByPhoneNumber gets the user data corresponding to the specified user phone number.
+//
+// If no user exists with the given phone number, returns an error that can be checked with
+// IsUserNotFound().
 func (c *Client) GetUserByPhoneNumber(ctx context.Context, phone string) (*UserRecord, error) {
 	if err := validatePhone(phone); err != nil {
 		return nil, err
@@ -393,6 +511,9 @@ func (c *Client) GetUserByPhoneNumber(ctx context.Context, phone string) (*UserR
 }
 
 // GetUserByEmail gets the user data corresponding to the specified email.
+//
+// If no user exists with the given email, returns an error that can be checked with
+// IsUserNotFound().
 func (c *Client) GetUserByEmail(ctx context.Context, email string) (*UserRecord, error) {
 	if err := validateEmail(email); err != nil {
 		return nil, err
@@ -403,10 +524,40 @@ func (c *Client) GetUserByEmail(ctx context.Context, email string) (*UserRecord,
 	return c.getUser(ctx, request)
 }
 
+// IsDisabled reports whether the user account corresponding to the specified user ID is disabled.
+//
+// Unlike the claims of an ID token, which may be out of date, IsDisabled looks up the user account
+// in the Firebase Auth backend, and therefore makes a network call every time it is invoked. If no
+// user exists with the given ID, returns an error that can be checked with IsUserNotFound().
+func (c *Client) IsDisabled(ctx context.Context, uid string) (bool, error) {
+	if err := validateUID(uid); err != nil {
+		return false, err
+	}
+	request := &identitytoolkit.IdentitytoolkitRelyingpartyGetAccountInfoRequest{
+		LocalId: []string{uid},
+	}
+	resp, err := c.getAccountInfo(ctx, request)
+	if err != nil {
+		return false, err
+	}
+	if len(resp.Users) == 0 {
+		return false, internal.Errorf(userNotFound, "cannot find user from uid: %q", uid)
+	}
+	return resp.Users[0].Disabled, nil
+}
+
 // Users returns an iterator over Users.
 //
 // If nextPageToken is empty, the iterator will start at the beginning.
 // If the nextPageToken is not empty, the iterator starts after the token.
+//
+// The iterator fetches users from the backend in pages of up to 1000 users, and transparently
+// requests subsequent pages as needed. Callers that need page-level control can wrap the returned
+// iterator with iterator.NewPager(), which also determines the page size:
+//
+//	pager := iterator.NewPager(client.Users(ctx, ""), 100, "")
+//	var users []*auth.ExportedUserRecord
+//	nextPageToken, err := pager.NextPage(&users)
 func (c *Client) Users(ctx context.Context, nextPageToken string) *UserIterator {
 	it := &UserIterator{
 		ctx:    ctx,
@@ -426,11 +577,9 @@ func (it *UserIterator) fetch(pageSize int, pageToken string) (string, error) {
 		MaxResults:    int64(pageSize),
 		NextPageToken: pageToken,
 	}
-	call := it.client.is.Relyingparty.DownloadAccount(request)
-	it.client.setHeader(call)
-	resp, err := call.Context(it.ctx).Do()
+	resp, err := it.client.downloadAccount(it.ctx, request)
 	if err != nil {
-		return "", handleServerError(err)
+		return "", err
 	}
 
 	for _, u := range resp.Users {
@@ -460,6 +609,59 @@ func (it *UserIterator) Next() (*ExportedUserRecord, error) {
 	return user, nil
 }
 
+// UsersWithClaimOption is an option for the UsersWithClaim() function.
+type UsersWithClaimOption func(*usersWithClaimConfig)
+
+type usersWithClaimConfig struct {
+	maxMatches int
+	limited    bool
+}
+
+// WithMaxMatches returns a UsersWithClaimOption that stops the search once n matching users have
+// been found. n must be positive.
+func WithMaxMatches(n int) UsersWithClaimOption {
+	return func(conf *usersWithClaimConfig) {
+		conf.maxMatches = n
+		conf.limited = true
+	}
+}
+
+// UsersWithClaim returns the users whose custom claim with the given key has the specified value,
+// or is an array that contains it (e.g. all the users with the claim role=admin).
+//
+// Values are matched in the same way as Token.HasClaim(). Since the backend does not index custom
+// claims, the users are streamed with the Users() iterator and filtered by the SDK, which makes
+// this function O(all users) in both time and requests, unless a WithMaxMatches() option ends the
+// search early. Users are returned in the order in which they are listed by the backend.
+func (c *Client) UsersWithClaim(ctx context.Context, key string, value interface{}, opts ...UsersWithClaimOption) ([]*ExportedUserRecord, error) {
+	if key == "" {
+		return nil, fmt.Errorf("claim key must be a non-empty string")
+	}
+	conf := &usersWithClaimConfig{}
+	for _, opt := range opts {
+		opt(conf)
+	}
+	if conf.limited && conf.maxMatches <= 0 {
+		return nil, fmt.Errorf("max matches must be positive: %d", conf.maxMatches)
+	}
+
+	var users []*ExportedUserRecord
+	it := c.Users(ctx, "")
+	for !conf.limited || len(users) < conf.maxMatches {
+		user, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hasClaim(user.CustomClaims, key, value) {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
 // SetCustomUserClaims sets additional claims on an existing user account.
 //
 // Custom claims set via this function can be used to define user roles and privilege levels.
@@ -467,7 +669,8 @@ func (it *UserIterator) Next() (*ExportedUserRecord, error) {
 // expiration or when token refresh is forced), and next time the user signs in. The claims
 // can be accessed via the user's ID token JWT. If a reserved OIDC claim is specified (sub, iat,
 // iss, etc), an error is thrown. Claims payload must also not be larger then 1000 characters
-// when serialized into a JSON string.
+// when serialized into a JSON string. Passing a nil or empty map removes all existing custom claims
+// from the user account.
 func (c *Client) SetCustomUserClaims(ctx context.Context, uid string, customClaims map[string]interface{}) error {
 	if customClaims == nil || len(customClaims) == 0 {
 		customClaims = map[string]interface{}{}
@@ -475,6 +678,102 @@ func (c *Client) SetCustomUserClaims(ctx context.Context, uid string, customClai
 	return c.updateUser(ctx, uid, (&UserToUpdate{}).CustomClaims(customClaims))
 }
 
+// ClaimUpdate specifies the custom claims to be set on a user account by
+// SetCustomUserClaimsBatch().
+type ClaimUpdate struct {
+	UID    string
+	Claims map[string]interface{}
+}
+
+// BatchResult represents the result of a SetCustomUserClaimsBatch() call.
+type BatchResult struct {
+	SuccessCount int
+	FailureCount int
+	Errors       []*BatchErrorInfo
+}
+
+// BatchErrorInfo represents an error encountered while applying one of the updates of a batch.
+//
+// The Index field corresponds to the index of the failed update in the slice that was passed to
+// SetCustomUserClaimsBatch().
+type BatchErrorInfo struct {
+	Index int
+	UID   string
+	Error error
+}
+
+// SetCustomUserClaimsBatch sets custom claims on several existing user accounts.
+//
+// Each update is applied as if by SetCustomUserClaims(), and the updates are applied up to 10 at
+// a time. Updates with an invalid UID, or with claims that are reserved or larger than 1000
+// characters when serialized, fail without making a network call. The failure of an update does
+// not prevent the others from being applied. Failed updates are reported in BatchResult.Errors,
+// ordered by index, and their errors can be checked with functions like IsUserNotFound().
+//
+// An error is returned, and no update is applied, if the same UID appears more than once among
+// the valid updates, since the order in which concurrent updates of a user account take effect is
+// not defined.
+func (c *Client) SetCustomUserClaimsBatch(ctx context.Context, updates []ClaimUpdate) (*BatchResult, error) {
+	errs := make([]error, len(updates))
+	seen := make(map[string]int, len(updates))
+	for i, u := range updates {
+		if err := validateUID(u.UID); err != nil {
+			errs[i] = err
+			continue
+		}
+		if _, err := marshalCustomClaims(u.Claims); err != nil {
+			errs[i] = err
+			continue
+		}
+		if j, ok := seen[u.UID]; ok {
+			return nil, fmt.Errorf("duplicate uid %q at indices %d and %d", u.UID, j, i)
+		}
+		seen[u.UID] = i
+	}
+
+	sem := make(chan struct{}, claimsConcurrency)
+	var wg sync.WaitGroup
+	for i, u := range updates {
+		if errs[i] != nil {
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, u ClaimUpdate) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = c.SetCustomUserClaims(ctx, u.UID, u.Claims)
+		}(i, u)
+	}
+	wg.Wait()
+
+	result := &BatchResult{}
+	for i, err := range errs {
+		if err != nil {
+			result.Errors = append(result.Errors, &BatchErrorInfo{Index: i, UID: updates[i].UID, Error: err})
+		}
+	}
+	result.FailureCount = len(result.Errors)
+	result.SuccessCount = len(updates) - result.FailureCount
+	return result, nil
+}
+
+// UnlinkProvider unlinks the specified provider (e.g. "google.com" or "phone") from an existing
+// user account. The providers currently linked to an account are listed in the ProviderUserInfo
+// field of its UserRecord.
+//
+// Unlinking the last provider of an account is permitted. The account is not deleted in that case,
+// but the user can no longer sign in with any of the unlinked providers. Unlinking the "phone"
+// provider also removes the phone number of the user.
+func (c *Client) UnlinkProvider(ctx context.Context, uid, providerID string) error {
+	if providerID == "" {
+		return fmt.Errorf("provider id must be a non-empty string")
+	}
+	return c.updateUser(ctx, uid, (&UserToUpdate{}).deleteProvider(providerID))
+}
+
 func marshalCustomClaims(claims map[string]interface{}) (string, error) {
 	for _, key := range reservedClaims {
 		if _, ok := claims[key]; ok {
@@ -491,7 +790,8 @@ func marshalCustomClaims(claims map[string]interface{}) (string, error) {
 		s = "{}"
 	}
 	if len(s) > maxLenPayloadCC {
-		return "", fmt.Errorf("serialized custom claims must not exceed %d characters", maxLenPayloadCC)
+		return "", fmt.Errorf("serialized custom claims must not exceed %d characters; got %d",
+			maxLenPayloadCC, len(s))
 	}
 	return s, nil
 }
@@ -499,16 +799,45 @@ func marshalCustomClaims(claims map[string]interface{}) (string, error) {
 // Error handlers.
 
 const (
+	authTimeTooOld           = "auth-time-too-old"
+	customTokenUsed          = "custom-token-used"
 	emailAlredyExists        = "email-already-exists"
 	idTokenRevoked           = "id-token-revoked"
 	insufficientPermission   = "insufficient-permission"
+	invalidAudience          = "invalid-audience"
+	invalidAuthHeader        = "invalid-authorization-header"
+	invalidIssuer            = "invalid-issuer"
+	invalidSignature         = "invalid-signature"
+	invalidToken             = "invalid-token"
+	malformedToken           = "malformed-token"
 	phoneNumberAlreadyExists = "phone-number-already-exists"
 	projectNotFound          = "project-not-found"
+	revocationCheckTimeout   = "revocation-check-timeout"
+	sessionCookieRevoked     = "session-cookie-revoked"
+	tenantIDMismatch         = "tenant-id-mismatch"
+	tenantNotFound           = "tenant-not-found"
+	tokenExpired             = "token-expired"
+	tokenUsedTooEarly        = "token-used-too-early"
 	uidAlreadyExists         = "uid-already-exists"
 	unknown                  = "unknown-error"
 	userNotFound             = "user-not-found"
 )
 
+// IsAuthTimeTooOld checks if the given error was due to an ID token for which the user
+// authenticated too long ago, as reported by VerifyIDTokenFreshAuth().
+func IsAuthTimeTooOld(err error) bool {
+	return internal.HasErrorCode(err, authTimeTooOld)
+}
+
+// IsCustomTokenError checks if the given error was due to a custom token being passed where an ID
+// token or a session cookie is expected. Custom tokens must be exchanged for an ID token by a
+// Firebase client SDK (e.g. with signInWithCustomToken()) before they can be verified.
+//
+// Errors for which IsCustomTokenError returns true are also reported by IsInvalidToken().
+func IsCustomTokenError(err error) bool {
+	return internal.HasErrorCode(err, customTokenUsed)
+}
+
 // IsEmailAlreadyExists checks if the given error was due to a duplicate email.
 func IsEmailAlreadyExists(err error) bool {
 	return internal.HasErrorCode(err, emailAlredyExists)
@@ -524,6 +853,42 @@ func IsInsufficientPermission(err error) bool {
 	return internal.HasErrorCode(err, insufficientPermission)
 }
 
+// IsInvalidAudience checks if the given error was due to an ID token or session cookie with an
+// 'aud' (audience) claim that does not match the current Firebase project.
+func IsInvalidAudience(err error) bool {
+	return internal.HasErrorCode(err, invalidAudience)
+}
+
+// IsInvalidAuthorizationHeader checks if the given error was due to an HTTP request without a
+// well-formed Bearer token in its Authorization header.
+func IsInvalidAuthorizationHeader(err error) bool {
+	return internal.HasErrorCode(err, invalidAuthHeader)
+}
+
+// IsInvalidIssuer checks if the given error was due to an ID token or session cookie with an
+// unexpected 'iss' (issuer) claim.
+func IsInvalidIssuer(err error) bool {
+	return internal.HasErrorCode(err, invalidIssuer)
+}
+
+// IsInvalidSignature checks if the given error was due to an ID token or session cookie whose
+// signature could not be verified with any of the available public keys.
+func IsInvalidSignature(err error) bool {
+	return internal.HasErrorCode(err, invalidSignature)
+}
+
+// IsInvalidToken checks if the given error was due to an ID token or session cookie with an
+// invalid header or subject claim, or due to a custom token being passed instead.
+func IsInvalidToken(err error) bool {
+	return internal.HasErrorCode(err, invalidToken) || internal.HasErrorCode(err, customTokenUsed)
+}
+
+// IsMalformedToken checks if the given error was due to an ID token or session cookie that is not a
+// well-formed JWT, such as a token without three segments, or with segments that cannot be decoded.
+func IsMalformedToken(err error) bool {
+	return internal.HasErrorCode(err, malformedToken)
+}
+
 // IsPhoneNumberAlreadyExists checks if the given error was due to a duplicate phone number.
 func IsPhoneNumberAlreadyExists(err error) bool {
 	return internal.HasErrorCode(err, phoneNumberAlreadyExists)
@@ -534,6 +899,40 @@ func IsProjectNotFound(err error) bool {
 	return internal.HasErrorCode(err, projectNotFound)
 }
 
+// IsRevocationCheckTimeout checks if the given error was due to the user lookup performed by
+// VerifyIDTokenAndCheckRevoked() or VerifySessionCookieAndCheckRevoked() exceeding the timeout set
+// with WithRevocationCheckTimeout().
+func IsRevocationCheckTimeout(err error) bool {
+	return internal.HasErrorCode(err, revocationCheckTimeout)
+}
+
+// IsSessionCookieRevoked checks if the given error was due to a revoked session cookie.
+func IsSessionCookieRevoked(err error) bool {
+	return internal.HasErrorCode(err, sessionCookieRevoked)
+}
+
+// IsTenantIDMismatch checks if the given error was due to an ID token issued for a different
+// tenant than the one the TenantClient is scoped to.
+func IsTenantIDMismatch(err error) bool {
+	return internal.HasErrorCode(err, tenantIDMismatch)
+}
+
+// IsTenantNotFound checks if the given error was due to a non-existing tenant.
+func IsTenantNotFound(err error) bool {
+	return internal.HasErrorCode(err, tenantNotFound)
+}
+
+// IsTokenExpired checks if the given error was due to an expired ID token or session cookie.
+func IsTokenExpired(err error) bool {
+	return internal.HasErrorCode(err, tokenExpired)
+}
+
+// IsTokenUsedTooEarly checks if the given error was due to an ID token or session cookie with an
+// 'iat' (issued at) claim in the future.
+func IsTokenUsedTooEarly(err error) bool {
+	return internal.HasErrorCode(err, tokenUsedTooEarly)
+}
+
 // IsUIDAlreadyExists checks if the given error was due to a duplicate uid.
 func IsUIDAlreadyExists(err error) bool {
 	return internal.HasErrorCode(err, uidAlreadyExists)
@@ -545,6 +944,9 @@ func IsUnknown(err error) bool {
 }
 
 // IsUserNotFound checks if the given error was due to non-existing user.
+//
+// All functions that look up, update or delete a user account by its UID, email or phone number
+// report a non-existing user with an error that can be checked with this function.
 func IsUserNotFound(err error) bool {
 	return internal.HasErrorCode(err, userNotFound)
 }
@@ -554,9 +956,12 @@ var serverError = map[string]string{
 	"DUPLICATE_EMAIL":         emailAlredyExists,
 	"DUPLICATE_LOCAL_ID":      uidAlreadyExists,
 	"EMAIL_EXISTS":            emailAlredyExists,
+	"EMAIL_NOT_FOUND":         userNotFound,
 	"INSUFFICIENT_PERMISSION": insufficientPermission,
 	"PHONE_NUMBER_EXISTS":     phoneNumberAlreadyExists,
 	"PROJECT_NOT_FOUND":       projectNotFound,
+	"TENANT_NOT_FOUND":        tenantNotFound,
+	"USER_NOT_FOUND":          userNotFound,
 }
 
 func handleServerError(err error) error {
@@ -573,6 +978,28 @@ func handleServerError(err error) error {
 	return internal.Error(clientCode, err.Error())
 }
 
+func handleHTTPError(resp *internal.Response) error {
+	var httpErr struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.Unmarshal(resp.Body, &httpErr) // ignore any json parse errors at this level
+	serverCode := httpErr.Error.Message
+	if idx := strings.Index(serverCode, ":"); idx != -1 {
+		serverCode = strings.TrimSpace(serverCode[:idx])
+	}
+	clientCode, ok := serverError[serverCode]
+	if !ok {
+		clientCode = unknown
+	}
+	return internal.Error(clientCode, httpErrorMessage(resp))
+}
+
+func httpErrorMessage(resp *internal.Response) string {
+	return fmt.Sprintf("http error status: %d; body: %s", resp.Status, string(resp.Body))
+}
+
 // Validators.
 
 func validateDisplayName(val string) error {
@@ -593,7 +1020,7 @@ func validateEmail(email string) error {
 	if email == "" {
 		return fmt.Errorf("email must be a non-empty string")
 	}
-	if parts := strings.Split(email, "@"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+	if !emailPattern.MatchString(email) {
 		return fmt.Errorf("malformed email string: %q", email)
 	}
 	return nil
@@ -620,7 +1047,7 @@ func validatePhone(phone string) error {
 	if phone == "" {
 		return fmt.Errorf("phone number must be a non-empty string")
 	}
-	if !regexp.MustCompile(`\+.*[0-9A-Za-z]`).MatchString(phone) {
+	if !phonePattern.MatchString(phone) {
 		return fmt.Errorf("phone number must be a valid, E.164 compliant identifier")
 	}
 	return nil
@@ -639,12 +1066,42 @@ func (c *Client) createUser(ctx context.Context, user *UserToCreate) (string, er
 	if err != nil {
 		return "", err
 	}
-	call := c.is.Relyingparty.SignupNewUser(request)
-	c.setHeader(call)
-	resp, err := call.Context(ctx).Do()
+	if !user.uid && c.uidGenerator != nil {
+		uid := c.uidGenerator()
+		if err := validateUID(uid); err != nil {
+			return "", fmt.Errorf("invalid generated uid: %v", err)
+		}
+		// Copy the request, so that the generated UID does not leak into the caller's UserToCreate.
+		r := *request
+		r.LocalId = uid
+		request = &r
+	}
+	if user.customClaims {
+		// signupNewUser does not accept custom claims, which are set by a subsequent update.
+		r := *request
+		r.Disabled = true
+		request = &r
+	}
+	request.TenantId = c.tenantID
+	var resp *identitytoolkit.SignupNewUserResponse
+	err = c.retryGoogleAPI(ctx, false, func() (err error) {
+		resp, err = c.is.signupNewUser(ctx, request)
+		return err
+	})
 	if err != nil {
 		return "", handleServerError(err)
 	}
+
+	if user.customClaims {
+		update := (&UserToUpdate{}).CustomClaims(user.claims).Disabled(user.request().Disabled)
+		if err := c.updateUser(ctx, resp.LocalId, update); err != nil {
+			if derr := c.DeleteUser(ctx, resp.LocalId); derr != nil {
+				return "", fmt.Errorf("failed to set custom claims: %v; failed to delete user %q: %v",
+					err, resp.LocalId, derr)
+			}
+			return "", err
+		}
+	}
 	return resp.LocalId, nil
 }
 
@@ -660,21 +1117,152 @@ func (c *Client) updateUser(ctx context.Context, uid string, user *UserToUpdate)
 		return err
 	}
 	request.LocalId = uid
-	call := c.is.Relyingparty.SetAccountInfo(request)
-	c.setHeader(call)
-	if _, err := call.Context(ctx).Do(); err != nil {
+	if c.tenantID != "" {
+		return c.makeHTTPCall(ctx, http.MethodPost, "/accounts:update", request, nil)
+	}
+
+	err = c.retryGoogleAPI(ctx, true, func() error {
+		_, err := c.is.setAccountInfo(ctx, request)
+		return err
+	})
+	if err != nil {
 		return handleServerError(err)
 	}
 	return nil
 }
 
-func (c *Client) getUser(ctx context.Context, request *identitytoolkit.IdentitytoolkitRelyingpartyGetAccountInfoRequest) (*UserRecord, error) {
-	call := c.is.Relyingparty.GetAccountInfo(request)
-	c.setHeader(call)
-	resp, err := call.Context(ctx).Do()
+func (c *Client) deleteUsers(ctx context.Context, uids []string) ([]*DeleteUsersErrorInfo, error) {
+	payload := map[string]interface{}{
+		"localIds": uids,
+		"force":    true,
+	}
+	var resp struct {
+		Errors []*DeleteUsersErrorInfo `json:"errors"`
+	}
+	if err := c.makeHTTPCall(ctx, http.MethodPost, "/accounts:batchDelete", payload, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Errors, nil
+}
+
+// makeHTTPCall sends a request to the identitytoolkit REST endpoints that are not covered by the
+// identitytoolkit.Service, and unmarshals the JSON response into v. The path is appended to the
+// resource name returned by resourceName().
+func (c *Client) makeHTTPCall(ctx context.Context, method, path string, payload, v interface{}) error {
+	if c.projectID == "" {
+		return fmt.Errorf("project id not available")
+	}
+	return c.sendRequest(ctx, method, c.url+c.resourceName()+path, payload, v)
+}
+
+// makeV2HTTPCall is similar to makeHTTPCall, but sends the request to the identitytoolkit v2 API.
+func (c *Client) makeV2HTTPCall(ctx context.Context, method, path string, payload, v interface{}, opts ...internal.HTTPOption) error {
+	if c.projectID == "" {
+		return fmt.Errorf("project id not available")
+	}
+	return c.sendRequest(ctx, method, c.v2URL+c.resourceName()+path, payload, v, opts...)
+}
+
+// resourceName returns the path of the current project, or of the current tenant if the Client is
+// scoped to one.
+func (c *Client) resourceName() string {
+	if c.tenantID != "" {
+		return fmt.Sprintf("/projects/%s/tenants/%s", c.projectID, c.tenantID)
+	}
+	return "/projects/" + c.projectID
+}
+
+// sendRequest sends a JSON request to the given identitytoolkit URL, and unmarshals the JSON
+// response into v. Non-200 responses are converted into errors by handleHTTPError.
+func (c *Client) sendRequest(ctx context.Context, method, url string, payload, v interface{}, opts ...internal.HTTPOption) error {
+	req := &internal.Request{
+		Method: method,
+		URL:    url,
+		Opts:   append([]internal.HTTPOption{internal.WithHeader("X-Client-Version", c.version)}, opts...),
+	}
+	if payload != nil {
+		req.Body = internal.NewJSONEntity(payload)
+	}
+	var resp *internal.Response
+	err := c.retry(ctx, isIdempotent(method, url), func() (int, http.Header, error) {
+		var err error
+		resp, err = c.hc.Do(ctx, req)
+		if err != nil {
+			return 0, nil, err
+		}
+		if resp.Status != http.StatusOK {
+			if qe := newQuotaExceededError(resp.Body, resp.Header, c.clock.Now(), httpErrorMessage(resp)); qe != nil {
+				return resp.Status, resp.Header, qe
+			}
+		}
+		return resp.Status, resp.Header, nil
+	})
+	if err != nil {
+		return err
+	}
+	if resp.Status != http.StatusOK {
+		return handleHTTPError(resp)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Body, v)
+}
+
+// The identitytoolkit v3 service does not support tenant-scoped account lookups, updates and
+// deletions. When the Client is scoped to a tenant, these operations are sent to the equivalent
+// tenant-scoped REST endpoints via makeHTTPCall instead.
+
+func (c *Client) getAccountInfo(ctx context.Context, request *identitytoolkit.IdentitytoolkitRelyingpartyGetAccountInfoRequest) (*identitytoolkit.GetAccountInfoResponse, error) {
+	if c.tenantID != "" {
+		var resp identitytoolkit.GetAccountInfoResponse
+		if err := c.makeHTTPCall(ctx, http.MethodPost, "/accounts:lookup", request, &resp); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	}
+
+	var resp *identitytoolkit.GetAccountInfoResponse
+	err := c.retryGoogleAPI(ctx, true, func() (err error) {
+		resp, err = c.is.getAccountInfo(ctx, request)
+		return err
+	})
 	if err != nil {
 		return nil, handleServerError(err)
 	}
+	return resp, nil
+}
+
+func (c *Client) downloadAccount(ctx context.Context, request *identitytoolkit.IdentitytoolkitRelyingpartyDownloadAccountRequest) (*identitytoolkit.DownloadAccountResponse, error) {
+	if c.tenantID != "" {
+		query := url.Values{}
+		query.Set("maxResults", fmt.Sprintf("%d", request.MaxResults))
+		if request.NextPageToken != "" {
+			query.Set("nextPageToken", request.NextPageToken)
+		}
+		var resp identitytoolkit.DownloadAccountResponse
+		if err := c.makeHTTPCall(ctx, http.MethodGet, "/accounts:batchGet?"+query.Encode(), nil, &resp); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	}
+
+	var resp *identitytoolkit.DownloadAccountResponse
+	err := c.retryGoogleAPI(ctx, true, func() (err error) {
+		resp, err = c.is.downloadAccount(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, handleServerError(err)
+	}
+	return resp, nil
+}
+
+func (c *Client) getUser(ctx context.Context, request *identitytoolkit.IdentitytoolkitRelyingpartyGetAccountInfoRequest) (*UserRecord, error) {
+	resp, err := c.getAccountInfo(ctx, request)
+	if err != nil {
+		return nil, err
+	}
 	if len(resp.Users) == 0 {
 		var msg string
 		if len(request.LocalId) == 1 {
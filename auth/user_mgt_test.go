@@ -23,6 +23,7 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -31,6 +32,7 @@ import (
 	"firebase.google.com/go/internal"
 
 	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/identitytoolkit/v3"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
@@ -88,6 +90,46 @@ func TestGetUser(t *testing.T) {
 	}
 }
 
+func TestIsDisabled(t *testing.T) {
+	cases := []struct {
+		resp string
+		want bool
+	}{
+		{`{"users": [{"localId": "testuser", "disabled": true}]}`, true},
+		{`{"users": [{"localId": "testuser"}]}`, false},
+	}
+	for _, tc := range cases {
+		s := echoServer([]byte(tc.resp), t)
+		disabled, err := s.Client.IsDisabled(context.Background(), "testuser")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if disabled != tc.want {
+			t.Errorf("IsDisabled(%s) = %v; want = %v", tc.resp, disabled, tc.want)
+		}
+		if want, got := `{"localId":["testuser"]}`, string(s.Rbody); got != want {
+			t.Errorf("IsDisabled() Req = %v; want = %v", got, want)
+		}
+		s.Close()
+	}
+}
+
+func TestIsDisabledUserNotFound(t *testing.T) {
+	s := echoServer([]byte(`{"kind": "identitytoolkit#GetAccountInfoResponse"}`), t)
+	defer s.Close()
+
+	disabled, err := s.Client.IsDisabled(context.Background(), "testuser")
+	if disabled || !IsUserNotFound(err) {
+		t.Errorf("IsDisabled() = (%v, %v); want = (false, user-not-found)", disabled, err)
+	}
+}
+
+func TestIsDisabledInvalidUID(t *testing.T) {
+	if _, err := client.IsDisabled(context.Background(), ""); err == nil {
+		t.Errorf("IsDisabled('') = nil; want error")
+	}
+}
+
 func TestGetUserByEmail(t *testing.T) {
 	s := echoServer(testGetUserResponse, t)
 	defer s.Close()
@@ -222,6 +264,142 @@ func TestListUsers(t *testing.T) {
 		"pageToken", map[string]interface{}{"maxResults": 1000, "nextPageToken": "pageToken"})
 }
 
+func TestListUsersPager(t *testing.T) {
+	s := echoServer(testListUsersResponse, t)
+	defer s.Close()
+
+	pager := iterator.NewPager(s.Client.Users(context.Background(), ""), 2, "")
+	var users []*ExportedUserRecord
+	token, err := pager.NextPage(&users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 3 || token != "" {
+		t.Errorf("NextPage() = (%d, %q); want = (3, \"\")", len(users), token)
+	}
+
+	want := `{"maxResults":2}`
+	if string(s.Rbody) != want {
+		t.Errorf("NextPage() Req = %s; want = %s", string(s.Rbody), want)
+	}
+}
+
+func TestUsersWithClaim(t *testing.T) {
+	resp := map[string]interface{}{
+		"users": []map[string]interface{}{
+			{"localId": "user1", "customAttributes": `{"role": "admin", "level": 2}`},
+			{"localId": "user2"},
+			{"localId": "user3", "customAttributes": `{"role": ["editor", "admin"]}`},
+			{"localId": "user4", "customAttributes": `{"role": "editor", "level": 1}`},
+		},
+	}
+	s := echoServer(resp, t)
+	defer s.Close()
+
+	cases := []struct {
+		key   string
+		value interface{}
+		opts  []UsersWithClaimOption
+		want  []string
+	}{
+		{"role", "admin", nil, []string{"user1", "user3"}},
+		{"role", "editor", nil, []string{"user3", "user4"}},
+		{"role", "admin", []UsersWithClaimOption{WithMaxMatches(1)}, []string{"user1"}},
+		{"level", 2, nil, []string{"user1"}},
+		{"role", "owner", nil, nil},
+		{"package", "gold", nil, nil},
+	}
+	for _, tc := range cases {
+		users, err := s.Client.UsersWithClaim(ctx, tc.key, tc.value, tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, u := range users {
+			got = append(got, u.UID)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("UsersWithClaim(%q, %v) = %v; want = %v", tc.key, tc.value, got, tc.want)
+		}
+	}
+
+	tc := tenantClient(s.Client, t)
+	users, err := tc.UsersWithClaim(ctx, "role", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 {
+		t.Errorf("UsersWithClaim() = %d users; want = 2", len(users))
+	}
+}
+
+func TestUsersWithClaimError(t *testing.T) {
+	s := echoServer(testListUsersResponse, t)
+	defer s.Close()
+
+	if users, err := s.Client.UsersWithClaim(ctx, "", "admin"); users != nil || err == nil {
+		t.Errorf("UsersWithClaim('') = (%v, %v); want = (nil, error)", users, err)
+	}
+	for _, n := range []int{0, -1} {
+		if users, err := s.Client.UsersWithClaim(ctx, "admin", true, WithMaxMatches(n)); users != nil || err == nil {
+			t.Errorf("UsersWithClaim(WithMaxMatches(%d)) = (%v, %v); want = (nil, error)", n, users, err)
+		}
+	}
+
+	s.Status = http.StatusInternalServerError
+	if users, err := s.Client.UsersWithClaim(ctx, "admin", true); users != nil || err == nil {
+		t.Errorf("UsersWithClaim() = (%v, %v); want = (nil, error)", users, err)
+	}
+}
+
+func TestValidatePhone(t *testing.T) {
+	valid := []string{
+		"+1",
+		"+15555550100",
+		"+442071838750",
+		"+123456789012345",
+	}
+	for _, phone := range valid {
+		if err := validatePhone(phone); err != nil {
+			t.Errorf("validatePhone(%q) = %v; want = nil", phone, err)
+		}
+	}
+
+	invalid := []string{
+		"+",
+		"1234",
+		"555-1234",
+		"+1 555 555 0100",
+		"+1-555-555-0100",
+		"+1(555)5550100",
+		"+1555555010a",
+		"+_!@#$",
+		"++15555550100",
+		"+1234567890123456",
+		" +15555550100",
+	}
+	want := "phone number must be a valid, E.164 compliant identifier"
+	for _, phone := range invalid {
+		if err := validatePhone(phone); err == nil || err.Error() != want {
+			t.Errorf("validatePhone(%q) = %v; want = %q", phone, err, want)
+		}
+	}
+}
+
+func TestGetUserByPhoneNumberInvalid(t *testing.T) {
+	s := echoServer(testGetUserResponse, t)
+	defer s.Close()
+
+	user, err := s.Client.GetUserByPhoneNumber(context.Background(), "555-1234")
+	want := "phone number must be a valid, E.164 compliant identifier"
+	if user != nil || err == nil || err.Error() != want {
+		t.Errorf("GetUserByPhoneNumber() = (%v, %v); want = (nil, %q)", user, err, want)
+	}
+	if len(s.Req) != 0 {
+		t.Errorf("Requests = %d; want = 0", len(s.Req))
+	}
+}
+
 func TestInvalidCreateUser(t *testing.T) {
 	cases := []struct {
 		params *UserToCreate
@@ -266,6 +444,24 @@ func TestInvalidCreateUser(t *testing.T) {
 		}, {
 			(&UserToCreate{}).Email("a@a@a"),
 			`malformed email string: "a@a@a"`,
+		}, {
+			(&UserToCreate{}).Email("a b@a"),
+			`malformed email string: "a b@a"`,
+		}, {
+			(&UserToCreate{}).Email("a@a..b"),
+			`malformed email string: "a@a..b"`,
+		}, {
+			(&UserToCreate{}).Email("a@.a"),
+			`malformed email string: "a@.a"`,
+		}, {
+			(&UserToCreate{}).Email("a@a."),
+			`malformed email string: "a@a."`,
+		}, {
+			(&UserToCreate{}).CustomClaims(map[string]interface{}{"sub": "uid"}),
+			`claim "sub" is reserved and must not be set`,
+		}, {
+			(&UserToCreate{}).CustomClaims(map[string]interface{}{"key": strings.Repeat("a", 1000)}),
+			"serialized custom claims must not exceed 1000 characters; got 1010",
 		},
 	}
 	for i, tc := range cases {
@@ -323,6 +519,10 @@ func TestCreateUser(t *testing.T) {
 			(&UserToCreate{}).Email("a@a"),
 			map[string]interface{}{"email": "a@a"},
 		},
+		{
+			(&UserToCreate{}).Email("first.last+tag@sub.example.com"),
+			map[string]interface{}{"email": "first.last+tag@sub.example.com"},
+		},
 		{
 			(&UserToCreate{}).Disabled(true),
 			map[string]interface{}{"disabled": true},
@@ -359,6 +559,179 @@ func TestCreateUser(t *testing.T) {
 	}
 }
 
+func TestCreateUserWithMockService(t *testing.T) {
+	is := &mockIdentitytoolkit{}
+	c := *client
+	c.is = is
+
+	user, err := c.CreateUser(ctx, (&UserToCreate{}).UID("uid1").Email("user@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.UID != "uid1" || user.Email != "user@example.com" {
+		t.Errorf("CreateUser() = (%q, %q); want = (%q, %q)", user.UID, user.Email, "uid1", "user@example.com")
+	}
+	if len(is.signupRequests) != 1 {
+		t.Fatalf("signupNewUser() calls = %d; want = 1", len(is.signupRequests))
+	}
+	if req := is.signupRequests[0]; req.LocalId != "uid1" || req.Email != "user@example.com" {
+		t.Errorf("signupNewUser() = (%q, %q); want = (%q, %q)", req.LocalId, req.Email, "uid1", "user@example.com")
+	}
+}
+
+func TestCreateUserWithMockServiceError(t *testing.T) {
+	is := &mockIdentitytoolkit{err: &googleapi.Error{Code: http.StatusBadRequest, Message: "EMAIL_EXISTS"}}
+	c := *client
+	c.is = is
+
+	user, err := c.CreateUser(ctx, (&UserToCreate{}).Email("user@example.com"))
+	if user != nil || !IsEmailAlreadyExists(err) {
+		t.Errorf("CreateUser() = (%v, %v); want = (nil, email-already-exists)", user, err)
+	}
+}
+
+func TestCreateUserWithCustomClaims(t *testing.T) {
+	cases := []bool{false, true}
+	for _, disabled := range cases {
+		is := &mockIdentitytoolkit{}
+		c := *client
+		c.is = is
+
+		toCreate := (&UserToCreate{}).UID("uid1").Disabled(disabled).CustomClaims(map[string]interface{}{"admin": true})
+		if _, err := c.CreateUser(ctx, toCreate); err != nil {
+			t.Fatal(err)
+		}
+		if len(is.signupRequests) != 1 || len(is.setRequests) != 1 {
+			t.Fatalf("CreateUser(%v) calls = (%d, %d); want = (1, 1)", disabled, len(is.signupRequests), len(is.setRequests))
+		}
+		if req := is.signupRequests[0]; !req.Disabled {
+			t.Errorf("signupNewUser(%v).Disabled = false; want = true", disabled)
+		}
+		req := is.setRequests[0]
+		if req.LocalId != "uid1" || req.CustomAttributes != `{"admin":true}` || req.DisableUser != disabled {
+			t.Errorf("setAccountInfo(%v) = (%q, %q, %v); want = (%q, %q, %v)",
+				disabled, req.LocalId, req.CustomAttributes, req.DisableUser, "uid1", `{"admin":true}`, disabled)
+		}
+		if toCreate.request().Disabled != disabled {
+			t.Errorf("UserToCreate.Disabled = %v; want = %v", toCreate.request().Disabled, disabled)
+		}
+	}
+}
+
+func TestCreateUserWithCustomClaimsError(t *testing.T) {
+	is := &mockIdentitytoolkit{setErr: &googleapi.Error{Code: http.StatusBadRequest, Message: "USER_NOT_FOUND"}}
+	c := *client
+	c.is = is
+
+	toCreate := (&UserToCreate{}).UID("uid1").CustomClaims(map[string]interface{}{"admin": true})
+	user, err := c.CreateUser(ctx, toCreate)
+	if user != nil || !IsUserNotFound(err) {
+		t.Errorf("CreateUser() = (%v, %v); want = (nil, user-not-found)", user, err)
+	}
+	if len(is.deleted) != 1 || is.deleted[0] != "uid1" {
+		t.Errorf("deleteAccount() = %v; want = [uid1]", is.deleted)
+	}
+}
+
+func TestCreateUserWithUIDGenerator(t *testing.T) {
+	is := &mockIdentitytoolkit{}
+	c := *client
+	c.is = is
+	WithUIDGenerator(func() string { return "generated-uid" })(&c)
+
+	toCreate := (&UserToCreate{}).Email("user@example.com")
+	user, err := c.CreateUser(ctx, toCreate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.UID != "generated-uid" {
+		t.Errorf("CreateUser().UID = %q; want = %q", user.UID, "generated-uid")
+	}
+	if toCreate.request().LocalId != "" {
+		t.Errorf("UserToCreate.LocalId = %q; want = %q", toCreate.request().LocalId, "")
+	}
+
+	// An explicit UID takes precedence over the generator.
+	user, err = c.CreateUser(ctx, (&UserToCreate{}).UID("uid1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.UID != "uid1" {
+		t.Errorf("CreateUser().UID = %q; want = %q", user.UID, "uid1")
+	}
+	if len(is.signupRequests) != 2 {
+		t.Fatalf("signupNewUser() calls = %d; want = 2", len(is.signupRequests))
+	}
+	if req := is.signupRequests[0]; req.LocalId != "generated-uid" || req.Email != "user@example.com" {
+		t.Errorf("signupNewUser() = (%q, %q); want = (%q, %q)", req.LocalId, req.Email, "generated-uid", "user@example.com")
+	}
+}
+
+func TestCreateUserWithInvalidGeneratedUID(t *testing.T) {
+	cases := []string{"", strings.Repeat("a", 129)}
+	for _, tc := range cases {
+		is := &mockIdentitytoolkit{}
+		c := *client
+		c.is = is
+		uid := tc
+		WithUIDGenerator(func() string { return uid })(&c)
+
+		user, err := c.CreateUser(ctx, nil)
+		if user != nil || err == nil {
+			t.Errorf("CreateUser(%q) = (%v, %v); want = (nil, error)", tc, user, err)
+		}
+		if len(is.signupRequests) != 0 {
+			t.Errorf("signupNewUser() calls = %d; want = 0", len(is.signupRequests))
+		}
+	}
+}
+
+func TestDisableUser(t *testing.T) {
+	is := &mockIdentitytoolkit{}
+	c := *client
+	c.is = is
+
+	user, err := c.DisableUser(ctx, "uid1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.UID != "uid1" || !user.Disabled {
+		t.Errorf("DisableUser() = (%q, %v); want = (%q, true)", user.UID, user.Disabled, "uid1")
+	}
+
+	user, err = c.EnableUser(ctx, "uid1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.UID != "uid1" || user.Disabled {
+		t.Errorf("EnableUser() = (%q, %v); want = (%q, false)", user.UID, user.Disabled, "uid1")
+	}
+
+	if len(is.setRequests) != 2 {
+		t.Fatalf("setAccountInfo() calls = %d; want = 2", len(is.setRequests))
+	}
+	if req := is.setRequests[0]; !req.DisableUser {
+		t.Errorf("DisableUser() disableUser = false; want = true")
+	}
+	wantForce := []string{"DisableUser"}
+	if req := is.setRequests[1]; req.DisableUser || !reflect.DeepEqual(req.ForceSendFields, wantForce) {
+		t.Errorf("EnableUser() = (%v, %v); want = (false, %v)", req.DisableUser, req.ForceSendFields, wantForce)
+	}
+}
+
+func TestDisableUserError(t *testing.T) {
+	is := &mockIdentitytoolkit{err: &googleapi.Error{Code: http.StatusBadRequest, Message: "INSUFFICIENT_PERMISSION"}}
+	c := *client
+	c.is = is
+
+	if user, err := c.DisableUser(ctx, "uid1"); user != nil || !IsInsufficientPermission(err) {
+		t.Errorf("DisableUser() = (%v, %v); want = (nil, insufficient-permission)", user, err)
+	}
+	if user, err := c.EnableUser(ctx, ""); user != nil || err == nil {
+		t.Errorf("EnableUser('') = (%v, %v); want = (nil, error)", user, err)
+	}
+}
+
 func TestInvalidUpdateUser(t *testing.T) {
 	cases := []struct {
 		params *UserToUpdate
@@ -381,7 +754,7 @@ func TestInvalidUpdateUser(t *testing.T) {
 			"phone number must be a valid, E.164 compliant identifier",
 		}, {
 			(&UserToUpdate{}).CustomClaims(map[string]interface{}{"a": strings.Repeat("a", 993)}),
-			"serialized custom claims must not exceed 1000 characters",
+			"serialized custom claims must not exceed 1000 characters; got 1001",
 		}, {
 			(&UserToUpdate{}).Password("short"),
 			"password must be a string at least 6 characters long",
@@ -513,6 +886,29 @@ func TestUpdateUser(t *testing.T) {
 		}
 	}
 }
+
+func TestUpdateUserReturnsRecord(t *testing.T) {
+	s := echoServer(testGetUserResponse, t)
+	defer s.Close()
+
+	params := (&UserToUpdate{}).DisplayName("").PhotoURL("")
+	user, err := s.Client.UpdateUser(context.Background(), "testuser", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(user, testUser) {
+		t.Errorf("UpdateUser() = %#v; want = %#v", user, testUser)
+	}
+	if len(s.Req) != 2 {
+		t.Fatalf("UpdateUser() sent %d requests; want = 2", len(s.Req))
+	}
+	for i, want := range []string{"/setAccountInfo", "/getAccountInfo"} {
+		if got := s.Req[i].URL.Path; got != want {
+			t.Errorf("UpdateUser() request[%d] = %q; want = %q", i, got, want)
+		}
+	}
+}
+
 func TestRevokeRefreshTokens(t *testing.T) {
 	resp := `{
 		"kind": "identitytoolkit#SetAccountInfoResponse",
@@ -549,6 +945,167 @@ func TestRevokeRefreshTokensInvalidUID(t *testing.T) {
 	}
 }
 
+type claimsIdentitytoolkit struct {
+	identitytoolkitService
+	mu     sync.Mutex
+	claims map[string]string
+}
+
+func (m *claimsIdentitytoolkit) setAccountInfo(
+	ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartySetAccountInfoRequest) (*identitytoolkit.SetAccountInfoResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if req.LocalId == "missing" {
+		return nil, &googleapi.Error{Code: http.StatusBadRequest, Message: "USER_NOT_FOUND"}
+	}
+	m.claims[req.LocalId] = req.CustomAttributes
+	return &identitytoolkit.SetAccountInfoResponse{LocalId: req.LocalId}, nil
+}
+
+func TestSetCustomUserClaimsBatch(t *testing.T) {
+	m := &claimsIdentitytoolkit{claims: map[string]string{}}
+	c := *client
+	c.is = m
+	var updates []ClaimUpdate
+	for i := 0; i < 25; i++ {
+		updates = append(updates, ClaimUpdate{
+			UID:    fmt.Sprintf("uid%d", i),
+			Claims: map[string]interface{}{"role": "editor"},
+		})
+	}
+	updates = append(updates,
+		ClaimUpdate{UID: "missing", Claims: map[string]interface{}{"role": "editor"}},
+		ClaimUpdate{UID: "", Claims: map[string]interface{}{"role": "editor"}},
+		ClaimUpdate{UID: "reserved", Claims: map[string]interface{}{"sub": "foo"}},
+		ClaimUpdate{UID: "large", Claims: map[string]interface{}{"a": strings.Repeat("a", 1000)}},
+		ClaimUpdate{UID: "cleared"},
+	)
+
+	result, err := c.SetCustomUserClaimsBatch(ctx, updates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SuccessCount != 26 || result.FailureCount != 4 || len(result.Errors) != 4 {
+		t.Fatalf("SetCustomUserClaimsBatch() = %d successes, %d failures; want = 26, 4",
+			result.SuccessCount, result.FailureCount)
+	}
+	wantIndices := []int{25, 26, 27, 28}
+	for i, e := range result.Errors {
+		if e.Index != wantIndices[i] || e.UID != updates[e.Index].UID || e.Error == nil {
+			t.Errorf("Errors[%d] = %#v; want Index = %d", i, e, wantIndices[i])
+		}
+	}
+	if !IsUserNotFound(result.Errors[0].Error) {
+		t.Errorf("Errors[0] = %v; want = UserNotFound", result.Errors[0].Error)
+	}
+
+	// Updates that fail validation are not sent.
+	if len(m.claims) != 26 {
+		t.Errorf("setAccountInfo() calls = %d; want = 26", len(m.claims))
+	}
+	for _, uid := range []string{"", "reserved", "large"} {
+		if _, ok := m.claims[uid]; ok {
+			t.Errorf("setAccountInfo(%q) called; want no call", uid)
+		}
+	}
+	if got, want := m.claims["uid0"], `{"role":"editor"}`; got != want {
+		t.Errorf("CustomAttributes = %q; want = %q", got, want)
+	}
+	if got, want := m.claims["cleared"], "{}"; got != want {
+		t.Errorf("CustomAttributes = %q; want = %q", got, want)
+	}
+}
+
+func TestSetCustomUserClaimsBatchDuplicateUID(t *testing.T) {
+	m := &claimsIdentitytoolkit{claims: map[string]string{}}
+	c := *client
+	c.is = m
+	updates := []ClaimUpdate{
+		{UID: "uid1", Claims: map[string]interface{}{"role": "editor"}},
+		{UID: "uid2", Claims: map[string]interface{}{"role": "editor"}},
+		{UID: "uid1", Claims: map[string]interface{}{"role": "viewer"}},
+	}
+	result, err := c.SetCustomUserClaimsBatch(ctx, updates)
+	we := `duplicate uid "uid1" at indices 0 and 2`
+	if result != nil || err == nil || err.Error() != we {
+		t.Errorf("SetCustomUserClaimsBatch() = (%v, %v); want = (nil, %q)", result, err, we)
+	}
+	if len(m.claims) != 0 {
+		t.Errorf("setAccountInfo() calls = %d; want = 0", len(m.claims))
+	}
+}
+
+func TestSetCustomUserClaimsBatchDuplicateInvalidUID(t *testing.T) {
+	m := &claimsIdentitytoolkit{claims: map[string]string{}}
+	c := *client
+	c.is = m
+	updates := []ClaimUpdate{
+		{UID: "", Claims: map[string]interface{}{"role": "editor"}},
+		{UID: "uid1", Claims: map[string]interface{}{"role": "editor"}},
+		{UID: ""},
+		{UID: "uid2", Claims: map[string]interface{}{"sub": "foo"}},
+		{UID: "uid2", Claims: map[string]interface{}{"role": "viewer"}},
+	}
+	result, err := c.SetCustomUserClaimsBatch(ctx, updates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SuccessCount != 2 || result.FailureCount != 3 || len(result.Errors) != 3 {
+		t.Fatalf("SetCustomUserClaimsBatch() = %d successes, %d failures; want = 2, 3",
+			result.SuccessCount, result.FailureCount)
+	}
+	for i, want := range []int{0, 2, 3} {
+		if e := result.Errors[i]; e.Index != want || e.Error == nil {
+			t.Errorf("Errors[%d] = %#v; want Index = %d", i, e, want)
+		}
+	}
+	if got, want := m.claims["uid2"], `{"role":"viewer"}`; got != want {
+		t.Errorf("CustomAttributes = %q; want = %q", got, want)
+	}
+}
+
+func TestSetCustomUserClaimsBatchEmpty(t *testing.T) {
+	result, err := client.SetCustomUserClaimsBatch(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SuccessCount != 0 || result.FailureCount != 0 || len(result.Errors) != 0 {
+		t.Errorf("SetCustomUserClaimsBatch(nil) = %#v; want = empty result", result)
+	}
+}
+
+func TestUnlinkProvider(t *testing.T) {
+	resp := `{
+		"kind": "identitytoolkit#SetAccountInfoResponse",
+		"localId": "expectedUserID"
+	}`
+	s := echoServer([]byte(resp), t)
+	defer s.Close()
+
+	if err := s.Client.UnlinkProvider(context.Background(), "some_uid", "google.com"); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"deleteProvider":["google.com"],"localId":"some_uid"}`
+	if got := string(s.Rbody); got != want {
+		t.Errorf("UnlinkProvider() Req = %v; want = %v", got, want)
+	}
+}
+
+func TestUnlinkProviderInvalidArgs(t *testing.T) {
+	cases := []struct {
+		uid, providerID, want string
+	}{
+		{"", "google.com", "uid must be a non-empty string"},
+		{"some_uid", "", "provider id must be a non-empty string"},
+	}
+	for _, tc := range cases {
+		err := client.UnlinkProvider(context.Background(), tc.uid, tc.providerID)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("UnlinkProvider(%q, %q) = %v; want = %q", tc.uid, tc.providerID, err, tc.want)
+		}
+	}
+}
+
 func TestInvalidSetCustomClaims(t *testing.T) {
 	cases := []struct {
 		cc   map[string]interface{}
@@ -556,7 +1113,7 @@ func TestInvalidSetCustomClaims(t *testing.T) {
 	}{
 		{
 			map[string]interface{}{"a": strings.Repeat("a", 993)},
-			"serialized custom claims must not exceed 1000 characters",
+			"serialized custom claims must not exceed 1000 characters; got 1001",
 		},
 		{
 			map[string]interface{}{"a": func() {}},
@@ -642,12 +1199,142 @@ func TestDeleteUser(t *testing.T) {
 	}
 }
 
+func TestDeleteUserNotFound(t *testing.T) {
+	s := echoServer([]byte(`{"error":{"message":"USER_NOT_FOUND"}}`), t)
+	defer s.Close()
+	s.Status = http.StatusBadRequest
+
+	if err := s.Client.DeleteUser(context.Background(), "uid"); err == nil || !IsUserNotFound(err) {
+		t.Errorf("DeleteUser() = %v; want = user-not-found error", err)
+	}
+}
+
 func TestInvalidDeleteUser(t *testing.T) {
 	if err := client.DeleteUser(context.Background(), ""); err == nil {
 		t.Errorf("DeleteUser('') = nil; want error")
 	}
 }
 
+func TestDeleteUsers(t *testing.T) {
+	resp := `{
+		"errors": [{"index": 1, "localId": "uid2", "message": "NOT_DISABLED : Disable the account before batch deletion."}]
+	}`
+	s := echoServer([]byte(resp), t)
+	defer s.Close()
+
+	result, err := s.Client.DeleteUsers(context.Background(), []string{"uid1", "uid2", "uid3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SuccessCount != 2 || result.FailureCount != 1 {
+		t.Errorf("DeleteUsers() = (%d, %d); want = (2, 1)", result.SuccessCount, result.FailureCount)
+	}
+	want := []*DeleteUsersErrorInfo{
+		{Index: 1, UID: "uid2", Reason: "NOT_DISABLED : Disable the account before batch deletion."},
+	}
+	if !reflect.DeepEqual(result.Errors, want) {
+		t.Errorf("DeleteUsers() Errors = %#v; want = %#v", result.Errors, want)
+	}
+
+	wantReq := `{"force":true,"localIds":["uid1","uid2","uid3"]}`
+	if string(s.Rbody) != wantReq {
+		t.Errorf("DeleteUsers() Req = %s; want = %s", string(s.Rbody), wantReq)
+	}
+	wantURL := "/projects/mock-project-id/accounts:batchDelete"
+	if s.Req[0].URL.Path != wantURL {
+		t.Errorf("DeleteUsers() URL = %q; want = %q", s.Req[0].URL.Path, wantURL)
+	}
+}
+
+func TestDeleteUsersBatching(t *testing.T) {
+	resp := `{
+		"errors": [{"index": 0, "localId": "uid1000", "message": "NOT_DISABLED"}]
+	}`
+	s := echoServer([]byte(resp), t)
+	defer s.Close()
+
+	var uids []string
+	for i := 0; i < 1500; i++ {
+		uids = append(uids, fmt.Sprintf("uid%d", i))
+	}
+	result, err := s.Client.DeleteUsers(context.Background(), uids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Req) != 2 {
+		t.Errorf("DeleteUsers() sent %d requests; want = 2", len(s.Req))
+	}
+	if result.SuccessCount != 1498 || result.FailureCount != 2 {
+		t.Errorf("DeleteUsers() = (%d, %d); want = (1498, 2)", result.SuccessCount, result.FailureCount)
+	}
+	if result.Errors[0].Index != 0 || result.Errors[1].Index != 1000 {
+		t.Errorf("DeleteUsers() Indices = (%d, %d); want = (0, 1000)", result.Errors[0].Index, result.Errors[1].Index)
+	}
+}
+
+func TestDeleteUsersPartialFailure(t *testing.T) {
+	s := newRetryServer(testRetryConfig, []int{200, 400}, t)
+	defer s.Close()
+
+	var uids []string
+	for i := 0; i < 1500; i++ {
+		uids = append(uids, fmt.Sprintf("uid%d", i))
+	}
+	result, err := s.Client.DeleteUsers(context.Background(), uids)
+	if err == nil {
+		t.Fatal("DeleteUsers() = nil; want error")
+	}
+	if result == nil {
+		t.Fatal("DeleteUsers() = nil result; want partial result")
+	}
+	if result.SuccessCount != 1000 || result.FailureCount != 0 {
+		t.Errorf("DeleteUsers() = (%d, %d); want = (1000, 0)", result.SuccessCount, result.FailureCount)
+	}
+	if s.Requests() != 2 {
+		t.Errorf("DeleteUsers() sent %d requests; want = 2", s.Requests())
+	}
+}
+
+func TestDeleteUsersEmpty(t *testing.T) {
+	result, err := client.DeleteUsers(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SuccessCount != 0 || result.FailureCount != 0 || len(result.Errors) != 0 {
+		t.Errorf("DeleteUsers(nil) = %#v; want = empty result", result)
+	}
+}
+
+func TestInvalidDeleteUsers(t *testing.T) {
+	s := echoServer([]byte("{}"), t)
+	defer s.Close()
+
+	cases := [][]string{
+		{"uid1", ""},
+		{strings.Repeat("a", 129), "uid2"},
+	}
+	for _, tc := range cases {
+		result, err := s.Client.DeleteUsers(context.Background(), tc)
+		if result != nil || err == nil {
+			t.Errorf("DeleteUsers(%v) = (%v, %v); want = (nil, error)", tc, result, err)
+		}
+	}
+	if len(s.Req) != 0 {
+		t.Errorf("DeleteUsers() sent %d requests; want = 0", len(s.Req))
+	}
+}
+
+func TestDeleteUsersHTTPError(t *testing.T) {
+	s := echoServer([]byte(`{"error":{"message":"INSUFFICIENT_PERMISSION"}}`), t)
+	defer s.Close()
+	s.Status = http.StatusForbidden
+
+	result, err := s.Client.DeleteUsers(context.Background(), []string{"uid1"})
+	if result != nil || err == nil || !IsInsufficientPermission(err) {
+		t.Errorf("DeleteUsers() = (%v, %v); want = (nil, insufficient-permission error)", result, err)
+	}
+}
+
 func TestMakeExportedUser(t *testing.T) {
 	rur := &identitytoolkit.UserInfo{
 		LocalId:          "testuser",
@@ -721,9 +1408,11 @@ func TestHTTPErrorWithCode(t *testing.T) {
 		"DUPLICATE_EMAIL":         IsEmailAlreadyExists,
 		"DUPLICATE_LOCAL_ID":      IsUIDAlreadyExists,
 		"EMAIL_EXISTS":            IsEmailAlreadyExists,
+		"EMAIL_NOT_FOUND":         IsUserNotFound,
 		"INSUFFICIENT_PERMISSION": IsInsufficientPermission,
 		"PHONE_NUMBER_EXISTS":     IsPhoneNumberAlreadyExists,
 		"PROJECT_NOT_FOUND":       IsProjectNotFound,
+		"USER_NOT_FOUND":          IsUserNotFound,
 	}
 	s := echoServer(nil, t)
 	defer s.Close()
@@ -819,10 +1508,13 @@ func echoServer(resp interface{}, t *testing.T) *mockAuthServer {
 
 	authClient, err := NewClient(ctx, conf)
 	authClient.ks = &fileKeySource{FilePath: "../testdata/public_certs.json"}
+	authClient.cookieKS = authClient.ks
 	if err != nil {
 		t.Fatal(err)
 	}
-	authClient.is.BasePath = s.Srv.URL + "/"
+	authClient.is.(*identitytoolkitClient).BasePath = s.Srv.URL + "/"
+	authClient.url = s.Srv.URL
+	authClient.v2URL = s.Srv.URL
 	s.Client = authClient
 	return &s
 }
@@ -831,6 +1523,60 @@ func (s *mockAuthServer) Close() {
 	s.Srv.Close()
 }
 
+// mockIdentitytoolkit is a fake identitytoolkitService, which records the requests it receives.
+// Methods not implemented here panic when called.
+type mockIdentitytoolkit struct {
+	identitytoolkitService
+	signupRequests []*identitytoolkit.IdentitytoolkitRelyingpartySignupNewUserRequest
+	setRequests    []*identitytoolkit.IdentitytoolkitRelyingpartySetAccountInfoRequest
+	deleted        []string
+	err            error
+	setErr         error
+}
+
+func (m *mockIdentitytoolkit) deleteAccount(
+	ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartyDeleteAccountRequest) (*identitytoolkit.DeleteAccountResponse, error) {
+	m.deleted = append(m.deleted, req.LocalId)
+	return &identitytoolkit.DeleteAccountResponse{}, nil
+}
+
+func (m *mockIdentitytoolkit) setAccountInfo(
+	ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartySetAccountInfoRequest) (*identitytoolkit.SetAccountInfoResponse, error) {
+	m.setRequests = append(m.setRequests, req)
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.setErr != nil {
+		return nil, m.setErr
+	}
+	return &identitytoolkit.SetAccountInfoResponse{LocalId: req.LocalId}, nil
+}
+
+func (m *mockIdentitytoolkit) signupNewUser(
+	ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartySignupNewUserRequest) (*identitytoolkit.SignupNewUserResponse, error) {
+	m.signupRequests = append(m.signupRequests, req)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &identitytoolkit.SignupNewUserResponse{LocalId: req.LocalId}, nil
+}
+
+func (m *mockIdentitytoolkit) getAccountInfo(
+	ctx context.Context, req *identitytoolkit.IdentitytoolkitRelyingpartyGetAccountInfoRequest) (*identitytoolkit.GetAccountInfoResponse, error) {
+	var users []*identitytoolkit.UserInfo
+	for _, r := range m.signupRequests {
+		if len(req.LocalId) == 1 && r.LocalId == req.LocalId[0] {
+			users = append(users, &identitytoolkit.UserInfo{LocalId: r.LocalId, Email: r.Email})
+		}
+	}
+	for _, r := range m.setRequests {
+		if len(req.LocalId) == 1 && r.LocalId == req.LocalId[0] {
+			users = []*identitytoolkit.UserInfo{{LocalId: r.LocalId, Disabled: r.DisableUser, ValidSince: r.ValidSince}}
+		}
+	}
+	return &identitytoolkit.GetAccountInfoResponse{Users: users}, nil
+}
+
 type mockTokenSource struct {
 	AccessToken string
 }
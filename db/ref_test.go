@@ -170,6 +170,21 @@ func TestGetWithStruct(t *testing.T) {
 	checkOnlyRequest(t, mock.Reqs, &testReq{Method: "GET", Path: "/peter.json"})
 }
 
+func TestGetNullWithStruct(t *testing.T) {
+	mock := &mockServer{Resp: nil}
+	srv := mock.Start(client)
+	defer srv.Close()
+
+	var got person
+	if err := testref.Get(context.Background(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if (person{}) != got {
+		t.Errorf("Get(null) = %v; want = %v", got, person{})
+	}
+	checkOnlyRequest(t, mock.Reqs, &testReq{Method: "GET", Path: "/peter.json"})
+}
+
 func TestGetShallow(t *testing.T) {
 	mock := &mockServer{}
 	srv := mock.Start(client)
@@ -32,6 +32,7 @@ import (
 	"firebase.google.com/go/iid"
 	"firebase.google.com/go/internal"
 	"firebase.google.com/go/messaging"
+	"firebase.google.com/go/remoteconfig"
 	"firebase.google.com/go/storage"
 
 	"golang.org/x/oauth2/google"
@@ -66,14 +67,17 @@ type Config struct {
 }
 
 // Auth returns an instance of auth.Client.
-func (a *App) Auth(ctx context.Context) (*auth.Client, error) {
+//
+// Optional auth.ClientOption values can be specified to customize the behavior of the returned
+// Client.
+func (a *App) Auth(ctx context.Context, opts ...auth.ClientOption) (*auth.Client, error) {
 	conf := &internal.AuthConfig{
 		Creds:     a.creds,
 		ProjectID: a.projectID,
 		Opts:      a.opts,
 		Version:   Version,
 	}
-	return auth.NewClient(ctx, conf)
+	return auth.NewClient(ctx, conf, opts...)
 }
 
 // Database returns an instance of db.Client.
@@ -88,10 +92,17 @@ func (a *App) Database(ctx context.Context) (*db.Client, error) {
 }
 
 // Storage returns a new instance of storage.Client.
+//
+// The default bucket of the returned client is the StorageBucket specified in firebase.Config. If
+// no bucket is configured, it defaults to the <projectID>.appspot.com bucket of the project.
 func (a *App) Storage(ctx context.Context) (*storage.Client, error) {
+	bucket := a.storageBucket
+	if bucket == "" && a.projectID != "" {
+		bucket = a.projectID + ".appspot.com"
+	}
 	conf := &internal.StorageConfig{
 		Opts:   a.opts,
-		Bucket: a.storageBucket,
+		Bucket: bucket,
 	}
 	return storage.NewClient(ctx, conf)
 }
@@ -124,6 +135,16 @@ func (a *App) Messaging(ctx context.Context) (*messaging.Client, error) {
 	return messaging.NewClient(ctx, conf)
 }
 
+// RemoteConfig returns an instance of remoteconfig.Client.
+func (a *App) RemoteConfig(ctx context.Context) (*remoteconfig.Client, error) {
+	conf := &internal.RemoteConfigConfig{
+		ProjectID: a.projectID,
+		Opts:      a.opts,
+		Version:   Version,
+	}
+	return remoteconfig.NewClient(ctx, conf)
+}
+
 // NewApp creates a new App from the provided config and client options.
 //
 // If the client options contain a valid credential (a service account file, a refresh token
@@ -282,6 +282,64 @@ func TestStorage(t *testing.T) {
 	}
 }
 
+func TestStorageDefaultBucket(t *testing.T) {
+	ctx := context.Background()
+	app, err := NewApp(ctx, nil, option.WithCredentialsFile("testdata/service_account.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := app.Storage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.DefaultBucket()
+	if b == nil || err != nil {
+		t.Fatalf("DefaultBucket() = (%v, %v); want (bucket, nil)", b, err)
+	}
+	if got, want := b.Object("obj").BucketName(), "mock-project-id.appspot.com"; got != want {
+		t.Errorf("DefaultBucket() = %q; want = %q", got, want)
+	}
+
+	conf := &Config{StorageBucket: "custom-bucket"}
+	if app, err = NewApp(ctx, conf, option.WithCredentialsFile("testdata/service_account.json")); err != nil {
+		t.Fatal(err)
+	}
+	if c, err = app.Storage(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = c.DefaultBucket(); b == nil || err != nil {
+		t.Fatalf("DefaultBucket() = (%v, %v); want (bucket, nil)", b, err)
+	}
+	if got, want := b.Object("obj").BucketName(), "custom-bucket"; got != want {
+		t.Errorf("DefaultBucket() = %q; want = %q", got, want)
+	}
+}
+
+func TestStorageWithNoProjectID(t *testing.T) {
+	varName := "GCLOUD_PROJECT"
+	current := os.Getenv(varName)
+
+	if err := os.Setenv(varName, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv(varName, current)
+
+	ctx := context.Background()
+	app, err := NewApp(ctx, nil, option.WithCredentialsFile("testdata/refresh_token.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := app.Storage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := c.DefaultBucket(); b != nil || err == nil {
+		t.Errorf("DefaultBucket() = (%v, %v); want (nil, error)", b, err)
+	}
+}
+
 func TestFirestore(t *testing.T) {
 	ctx := context.Background()
 	app, err := NewApp(ctx, nil, option.WithCredentialsFile("testdata/service_account.json"))
@@ -359,6 +417,18 @@ func TestMessaging(t *testing.T) {
 	}
 }
 
+func TestRemoteConfig(t *testing.T) {
+	ctx := context.Background()
+	app, err := NewApp(ctx, nil, option.WithCredentialsFile("testdata/service_account.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c, err := app.RemoteConfig(ctx); c == nil || err != nil {
+		t.Errorf("RemoteConfig() = (%v, %v); want (remoteconfig, nil)", c, err)
+	}
+}
+
 func TestCustomTokenSource(t *testing.T) {
 	ctx := context.Background()
 	ts := &testTokenSource{AccessToken: "mock-token-from-custom"}
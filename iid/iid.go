@@ -27,17 +27,72 @@ import (
 	"firebase.google.com/go/internal"
 )
 
-const iidEndpoint = "https://console.firebase.google.com/v1"
-
-var errorCodes = map[int]string{
-	http.StatusBadRequest:          "malformed instance id argument",
-	http.StatusUnauthorized:        "request not authorized",
-	http.StatusForbidden:           "project does not match instance ID or the client does not have sufficient privileges",
-	http.StatusNotFound:            "failed to find the instance id",
-	http.StatusConflict:            "already deleted",
-	http.StatusTooManyRequests:     "request throttled out by the backend server",
-	http.StatusInternalServerError: "internal server error",
-	http.StatusServiceUnavailable:  "backend servers are over capacity",
+const (
+	iidEndpoint = "https://console.firebase.google.com/v1"
+
+	alreadyDeleted         = "already-deleted"
+	insufficientPermission = "insufficient-permission"
+	internalError          = "internal-error"
+	invalidArgument        = "invalid-argument"
+	notFound               = "not-found"
+	serverUnavailable      = "server-unavailable"
+	tooManyRequests        = "too-many-requests"
+	unauthorized           = "unauthorized"
+)
+
+var errorCodes = map[int]struct{ Code, Msg string }{
+	http.StatusBadRequest:          {invalidArgument, "malformed instance id argument"},
+	http.StatusUnauthorized:        {unauthorized, "request not authorized"},
+	http.StatusForbidden:           {insufficientPermission, "project does not match instance ID or the client does not have sufficient privileges"},
+	http.StatusNotFound:            {notFound, "failed to find the instance id"},
+	http.StatusConflict:            {alreadyDeleted, "already deleted"},
+	http.StatusTooManyRequests:     {tooManyRequests, "request throttled out by the backend server"},
+	http.StatusInternalServerError: {internalError, "internal server error"},
+	http.StatusServiceUnavailable:  {serverUnavailable, "backend servers are over capacity"},
+}
+
+// IsAlreadyDeleted checks if the given error was due to an instance ID that has already been
+// deleted.
+func IsAlreadyDeleted(err error) bool {
+	return internal.HasErrorCode(err, alreadyDeleted)
+}
+
+// IsInsufficientPermission checks if the given error was due to the project not matching the
+// instance ID, or the client not having the required privileges.
+func IsInsufficientPermission(err error) bool {
+	return internal.HasErrorCode(err, insufficientPermission)
+}
+
+// IsInternal checks if the given error was due to an internal server error.
+func IsInternal(err error) bool {
+	return internal.HasErrorCode(err, internalError)
+}
+
+// IsInvalidArgument checks if the given error was due to a malformed instance ID.
+func IsInvalidArgument(err error) bool {
+	return internal.HasErrorCode(err, invalidArgument)
+}
+
+// IsNotFound checks if the given error was due to a non existing instance ID.
+func IsNotFound(err error) bool {
+	return internal.HasErrorCode(err, notFound)
+}
+
+// IsServerUnavailable checks if the given error was due to the backend server being temporarily
+// unavailable.
+func IsServerUnavailable(err error) bool {
+	return internal.HasErrorCode(err, serverUnavailable)
+}
+
+// IsTooManyRequests checks if the given error was due to the client sending too many requests
+// causing a server quota to exceed.
+func IsTooManyRequests(err error) bool {
+	return internal.HasErrorCode(err, tooManyRequests)
+}
+
+// IsUnauthorized checks if the given error was due to the request not being authorized.
+func IsUnauthorized(err error) bool {
+	return internal.HasErrorCode(err, unauthorized)
 }
 
 // Client is the interface for the Firebase Instance ID service.
@@ -84,8 +139,8 @@ func (c *Client) DeleteInstanceID(ctx context.Context, iid string) error {
 		return err
 	}
 
-	if msg, ok := errorCodes[resp.Status]; ok {
-		return fmt.Errorf("instance id %q: %s", iid, msg)
+	if info, ok := errorCodes[resp.Status]; ok {
+		return internal.Errorf(info.Code, "instance id %q: %s", iid, info.Msg)
 	}
 	return resp.CheckStatus(http.StatusOK)
 }
@@ -104,6 +104,17 @@ func TestDeleteInstanceIDError(t *testing.T) {
 	}
 	client.endpoint = ts.URL
 
+	errorHandlers := map[int]func(error) bool{
+		http.StatusBadRequest:          IsInvalidArgument,
+		http.StatusUnauthorized:        IsUnauthorized,
+		http.StatusForbidden:           IsInsufficientPermission,
+		http.StatusNotFound:            IsNotFound,
+		http.StatusConflict:            IsAlreadyDeleted,
+		http.StatusTooManyRequests:     IsTooManyRequests,
+		http.StatusInternalServerError: IsInternal,
+		http.StatusServiceUnavailable:  IsServerUnavailable,
+	}
+
 	for k, v := range errorCodes {
 		status = k
 		err := client.DeleteInstanceID(ctx, "test-iid")
@@ -111,10 +122,13 @@ func TestDeleteInstanceIDError(t *testing.T) {
 			t.Fatal("DeleteInstanceID() = nil; want = error")
 		}
 
-		want := fmt.Sprintf("instance id %q: %s", "test-iid", v)
+		want := fmt.Sprintf("instance id %q: %s", "test-iid", v.Msg)
 		if err.Error() != want {
 			t.Errorf("DeleteInstanceID() = %v; want = %v", err, want)
 		}
+		if check := errorHandlers[k]; check == nil || !check(err) {
+			t.Errorf("DeleteInstanceID() = %v; want error with code %q", err, v.Code)
+		}
 
 		if tr == nil {
 			t.Fatalf("Request = nil; want non-nil")
@@ -111,7 +111,7 @@ func TestVerifyIDTokenAndCheckRevoked(t *testing.T) {
 
 	vt, err = client.VerifyIDTokenAndCheckRevoked(ctx, idt)
 	we := "ID token has been revoked"
-	if vt != nil || err == nil || err.Error() != we {
+	if vt != nil || err == nil || !auth.IsIDTokenRevoked(err) {
 		t.Errorf("tok, err := VerifyIDTokenAndCheckRevoked(); got (%v, %s) ; want (%v, %v)",
 			vt, err, nil, we)
 	}
@@ -136,6 +136,48 @@ func TestVerifyIDTokenAndCheckRevoked(t *testing.T) {
 	}
 }
 
+func TestSessionCookie(t *testing.T) {
+	uid := "cookieuser"
+	ctx := context.Background()
+	ct, err := client.CustomToken(ctx, uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idt, err := signInWithCustomToken(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.DeleteUser(ctx, uid)
+
+	cookie, err := client.SessionCookie(ctx, idt, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vt, err := client.VerifySessionCookieAndCheckRevoked(ctx, cookie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vt.UID != uid {
+		t.Errorf("UID = %q; want UID = %q", vt.UID, uid)
+	}
+
+	// Wait one second before revoking, for the same reasons as in TestVerifyIDTokenAndCheckRevoked.
+	time.Sleep(time.Second)
+	if err = client.RevokeRefreshTokens(ctx, uid); err != nil {
+		t.Fatal(err)
+	}
+
+	vt, err = client.VerifySessionCookieAndCheckRevoked(ctx, cookie)
+	if vt != nil || err == nil || !auth.IsSessionCookieRevoked(err) {
+		t.Errorf("VerifySessionCookieAndCheckRevoked() = (%v, %v); want = (nil, session-cookie-revoked)", vt, err)
+	}
+
+	// Does not return error for revoked cookie.
+	if _, err = client.VerifySessionCookie(ctx, cookie); err != nil {
+		t.Errorf("VerifySessionCookie(); err = %s; want err = <nil>", err)
+	}
+}
+
 func TestCustomTokenWithClaims(t *testing.T) {
 	ct, err := client.CustomTokenWithClaims(context.Background(), "user2", map[string]interface{}{
 		"premium": true,
@@ -68,10 +68,20 @@ type MessagingConfig struct {
 	Version   string
 }
 
+// RemoteConfigConfig represents the configuration of Firebase Remote Config service.
+type RemoteConfigConfig struct {
+	Opts      []option.ClientOption
+	ProjectID string
+	Version   string
+}
+
 // FirebaseError is an error type containing an error code string.
+//
+// ServerCode is the error code reported by the backend server, for the errors that record it.
 type FirebaseError struct {
-	Code   string
-	String string
+	Code       string
+	String     string
+	ServerCode string
 }
 
 func (fe *FirebaseError) Error() string {
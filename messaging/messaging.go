@@ -33,6 +33,7 @@ import (
 
 const (
 	messagingEndpoint = "https://fcm.googleapis.com/v1"
+	batchEndpoint     = "https://fcm.googleapis.com/batch"
 	iidEndpoint       = "https://iid.googleapis.com"
 	iidSubscribe      = "iid/v1:batchAdd"
 	iidUnsubscribe    = "iid/v1:batchRemove"
@@ -87,6 +88,10 @@ var (
 			mismatchedCredential,
 			"sender id does not match regisration token; code: " + mismatchedCredential,
 		},
+		"THIRD_PARTY_AUTH_ERROR": {
+			invalidAPNSCredentials,
+			"apns certificate or web push auth key was invalid; code: " + invalidAPNSCredentials,
+		},
 		"QUOTA_EXCEEDED": {
 			messageRateExceeded,
 			"messaging service quota exceeded; code: " + messageRateExceeded,
@@ -101,6 +106,16 @@ var (
 		},
 	}
 
+	// legacyErrorCodes maps the canonical error codes reported by older versions of the FCM
+	// backend to the equivalent FCM v1 error codes.
+	legacyErrorCodes = map[string]ErrorCode{
+		"APNS_AUTH_ERROR":    ErrThirdPartyAuth,
+		"NOT_FOUND":          ErrUnregistered,
+		"PERMISSION_DENIED":  ErrSenderIDMismatch,
+		"RESOURCE_EXHAUSTED": ErrQuotaExceeded,
+		"UNAUTHENTICATED":    ErrThirdPartyAuth,
+	}
+
 	iidErrorCodes = map[string]struct{ Code, Msg string }{
 		"INVALID_ARGUMENT": {
 			invalidArgument,
@@ -123,11 +138,12 @@ var (
 
 // Client is the interface for the Firebase Cloud Messaging (FCM) service.
 type Client struct {
-	fcmEndpoint string // to enable testing against arbitrary endpoints
-	iidEndpoint string // to enable testing against arbitrary endpoints
-	client      *internal.HTTPClient
-	project     string
-	version     string
+	fcmEndpoint   string // to enable testing against arbitrary endpoints
+	batchEndpoint string // to enable testing against arbitrary endpoints
+	iidEndpoint   string // to enable testing against arbitrary endpoints
+	client        *internal.HTTPClient
+	project       string
+	version       string
 }
 
 // Message to be sent via Firebase Cloud Messaging.
@@ -215,6 +231,7 @@ type AndroidNotification struct {
 	BodyLocArgs  []string `json:"body_loc_args,omitempty"`
 	TitleLocKey  string   `json:"title_loc_key,omitempty"`
 	TitleLocArgs []string `json:"title_loc_args,omitempty"`
+	ChannelID    string   `json:"channel_id,omitempty"` // notification channel to post to on Android O and later
 }
 
 // WebpushConfig contains messaging options specific to the WebPush protocol.
@@ -225,6 +242,12 @@ type WebpushConfig struct {
 	Headers      map[string]string    `json:"headers,omitempty"`
 	Data         map[string]string    `json:"data,omitempty"`
 	Notification *WebpushNotification `json:"notification,omitempty"`
+	FcmOptions   *WebpushFcmOptions   `json:"fcm_options,omitempty"`
+}
+
+// WebpushFcmOptions contains additional options for features provided by the FCM web SDK.
+type WebpushFcmOptions struct {
+	Link string `json:"link,omitempty"` // HTTPS URL to open when the user clicks on the notification
 }
 
 // WebpushNotification is a notification to send via WebPush protocol.
@@ -389,11 +412,12 @@ func NewClient(ctx context.Context, c *internal.MessagingConfig) (*Client, error
 	}
 
 	return &Client{
-		fcmEndpoint: messagingEndpoint,
-		iidEndpoint: iidEndpoint,
-		client:      &internal.HTTPClient{Client: hc},
-		project:     c.ProjectID,
-		version:     "Go/Admin/" + c.Version,
+		fcmEndpoint:   messagingEndpoint,
+		batchEndpoint: batchEndpoint,
+		iidEndpoint:   iidEndpoint,
+		client:        &internal.HTTPClient{Client: hc},
+		project:       c.ProjectID,
+		version:       "Go/Admin/" + c.Version,
 	}, nil
 }
 
@@ -445,6 +469,40 @@ func (c *Client) UnsubscribeFromTopic(ctx context.Context, tokens []string, topi
 	return c.makeTopicManagementRequest(ctx, req)
 }
 
+// ErrorCode is an error code defined by the FCM v1 API.
+type ErrorCode string
+
+// Error codes reported by the FCM v1 API for failed Send() calls. See
+// https://firebase.google.com/docs/reference/fcm/rest/v1/ErrorCode for their meaning, and for
+// how to handle each of them.
+//
+// Errors reported by older versions of the backend with canonical error codes (e.g. NOT_FOUND or
+// APNS_AUTH_ERROR) are reported with the equivalent FCM v1 error code.
+const (
+	ErrInternal         ErrorCode = "INTERNAL"
+	ErrInvalidArgument  ErrorCode = "INVALID_ARGUMENT"
+	ErrQuotaExceeded    ErrorCode = "QUOTA_EXCEEDED"
+	ErrSenderIDMismatch ErrorCode = "SENDER_ID_MISMATCH"
+	ErrThirdPartyAuth   ErrorCode = "THIRD_PARTY_AUTH_ERROR"
+	ErrUnavailable      ErrorCode = "UNAVAILABLE"
+	ErrUnregistered     ErrorCode = "UNREGISTERED"
+)
+
+// ErrorCodeOf returns the FCM v1 error code of the given error, as returned by Send() and the other
+// functions that send messages. Returns an empty string if the error does not carry an FCM v1
+// error code, e.g. because it was not returned by the FCM v1 backend, or the backend reported an
+// error code unknown to the SDK.
+func ErrorCodeOf(err error) ErrorCode {
+	fe, ok := err.(*internal.FirebaseError)
+	if !ok {
+		return ""
+	}
+	if code, ok := legacyErrorCodes[fe.ServerCode]; ok {
+		return code
+	}
+	return ErrorCode(fe.ServerCode)
+}
+
 // IsInternal checks if the given error was due to an internal server error.
 func IsInternal(err error) bool {
 	return internal.HasErrorCode(err, internalError)
@@ -484,6 +542,16 @@ func IsServerUnavailable(err error) bool {
 	return internal.HasErrorCode(err, serverUnavailable)
 }
 
+// IsSenderIDMismatch checks if the given error has the FCM v1 error code ErrSenderIDMismatch.
+func IsSenderIDMismatch(err error) bool {
+	return ErrorCodeOf(err) == ErrSenderIDMismatch
+}
+
+// IsThirdPartyAuthError checks if the given error has the FCM v1 error code ErrThirdPartyAuth.
+func IsThirdPartyAuthError(err error) bool {
+	return ErrorCodeOf(err) == ErrThirdPartyAuth
+}
+
 // IsTooManyTopics checks if the given error was due to the client exceeding the allowed number
 // of topics.
 func IsTooManyTopics(err error) bool {
@@ -549,7 +617,11 @@ func (c *Client) makeSendRequest(ctx context.Context, req *fcmRequest) (string,
 		err := json.Unmarshal(resp.Body, &result)
 		return result.Name, err
 	}
+	return "", handleFCMError(resp)
+}
 
+// handleFCMError converts an unsuccessful FCM v1 response into an error with a client error code.
+func handleFCMError(resp *internal.Response) error {
 	var fe fcmError
 	json.Unmarshal(resp.Body, &fe) // ignore any json parse errors at this level
 	var serverCode string
@@ -574,7 +646,11 @@ func (c *Client) makeSendRequest(ctx context.Context, req *fcmRequest) (string,
 	if fe.Error.Message != "" {
 		msg += "; details: " + fe.Error.Message
 	}
-	return "", internal.Errorf(clientCode, "http error status: %d; reason: %s", resp.Status, msg)
+	err := internal.Errorf(clientCode, "http error status: %d; reason: %s", resp.Status, msg)
+	if ok {
+		err.ServerCode = serverCode
+	}
+	return err
 }
 
 func (c *Client) makeTopicManagementRequest(ctx context.Context, req *iidRequest) (*TopicManagementResponse, error) {
@@ -0,0 +1,487 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package messaging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+var testMessages = []*Message{
+	{Topic: "topic1"},
+	{Topic: "topic2"},
+}
+
+var testMulticastMessage = &MulticastMessage{
+	Tokens: []string{"token1", "token2"},
+}
+
+var testSuccessResponse = []fcmResponse{
+	{
+		Name: "projects/test-project/messages/1",
+	},
+	{
+		Name: "projects/test-project/messages/2",
+	},
+}
+
+const wantMime = "multipart/mixed; boundary=__END_OF_PART__"
+const wantSendURL = "/v1/projects/test-project/messages:send"
+
+func TestMulticastNil(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(ctx, testMessagingConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	br, err := client.SendMulticast(ctx, nil)
+	if err == nil {
+		t.Errorf("SendMulticast(nil) = (%v, %v); want = (nil, error)", br, err)
+	}
+
+	br, err = client.SendMulticastDryRun(ctx, nil)
+	if err == nil {
+		t.Errorf("SendMulticastDryRun(nil) = (%v, %v); want = (nil, error)", br, err)
+	}
+}
+
+func TestMulticastInvalidTokens(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(ctx, testMessagingConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tooMany []string
+	for i := 0; i < 501; i++ {
+		tooMany = append(tooMany, fmt.Sprintf("token%d", i))
+	}
+	cases := []struct {
+		tokens []string
+		want   string
+	}{
+		{nil, "tokens must not be nil or empty"},
+		{[]string{}, "tokens must not be nil or empty"},
+		{tooMany, "tokens must not contain more than 500 elements"},
+	}
+	for _, tc := range cases {
+		br, err := client.SendMulticast(ctx, &MulticastMessage{Tokens: tc.tokens})
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("SendMulticast(%d tokens) = (%v, %v); want = (nil, %q)", len(tc.tokens), br, err, tc.want)
+		}
+	}
+}
+
+func TestSendAllEmptyArray(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(ctx, testMessagingConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "messages must not be nil or empty"
+	br, err := client.SendAll(ctx, nil)
+	if err == nil || err.Error() != want {
+		t.Errorf("SendAll(nil) = (%v, %v); want = (nil, %q)", br, err, want)
+	}
+
+	br, err = client.SendAll(ctx, []*Message{})
+	if err == nil || err.Error() != want {
+		t.Errorf("SendAll([]) = (%v, %v); want = (nil, %q)", br, err, want)
+	}
+}
+
+func TestSendAllTooManyMessages(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(ctx, testMessagingConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []*Message
+	for i := 0; i < 501; i++ {
+		messages = append(messages, &Message{Topic: "test-topic"})
+	}
+
+	want := "messages must not contain more than 500 elements"
+	br, err := client.SendAll(ctx, messages)
+	if err == nil || err.Error() != want {
+		t.Errorf("SendAll() = (%v, %v); want = (nil, %q)", br, err, want)
+	}
+}
+
+func TestSendAllInvalidMessage(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(ctx, testMessagingConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := []*Message{{Topic: "test-topic"}, {}}
+	want := "invalid message at index 1: exactly one of token, topic or condition must be specified"
+	br, err := client.SendAll(ctx, messages)
+	if err == nil || err.Error() != want {
+		t.Errorf("SendAll() = (%v, %v); want = (nil, %q)", br, err, want)
+	}
+}
+
+func TestSendAll(t *testing.T) {
+	resp, err := createMultipartResponse(testSuccessResponse, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req []byte
+	var mime string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ = ioutil.ReadAll(r.Body)
+		mime = r.Header.Get("Content-Type")
+		w.Header().Set("Content-Type", wantMime)
+		w.Write(resp)
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	client, err := NewClient(ctx, testMessagingConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.batchEndpoint = ts.URL
+
+	br, err := client.SendAll(ctx, testMessages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkSuccessfulBatchResponse(br); err != nil {
+		t.Errorf("SendAll() = %v", err)
+	}
+	if err := checkMultipartRequest(req, mime, testMessages, false); err != nil {
+		t.Errorf("MultipartRequest: %v", err)
+	}
+}
+
+func TestSendAllDryRun(t *testing.T) {
+	resp, err := createMultipartResponse(testSuccessResponse, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req []byte
+	var mime string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ = ioutil.ReadAll(r.Body)
+		mime = r.Header.Get("Content-Type")
+		w.Header().Set("Content-Type", wantMime)
+		w.Write(resp)
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	client, err := NewClient(ctx, testMessagingConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.batchEndpoint = ts.URL
+
+	br, err := client.SendAllDryRun(ctx, testMessages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkSuccessfulBatchResponse(br); err != nil {
+		t.Errorf("SendAllDryRun() = %v", err)
+	}
+	if err := checkMultipartRequest(req, mime, testMessages, true); err != nil {
+		t.Errorf("MultipartRequest: %v", err)
+	}
+}
+
+func TestSendMulticast(t *testing.T) {
+	resp, err := createMultipartResponse(testSuccessResponse, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req []byte
+	var mime string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ = ioutil.ReadAll(r.Body)
+		mime = r.Header.Get("Content-Type")
+		w.Header().Set("Content-Type", wantMime)
+		w.Write(resp)
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	client, err := NewClient(ctx, testMessagingConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.batchEndpoint = ts.URL
+
+	br, err := client.SendMulticast(ctx, testMulticastMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkSuccessfulBatchResponse(br); err != nil {
+		t.Errorf("SendMulticast() = %v", err)
+	}
+	want := []*Message{{Token: "token1"}, {Token: "token2"}}
+	if err := checkMultipartRequest(req, mime, want, false); err != nil {
+		t.Errorf("MultipartRequest: %v", err)
+	}
+}
+
+func TestSendMulticastPartialFailure(t *testing.T) {
+	failures := []string{
+		`{"error": {"status": "INVALID_ARGUMENT", "message": "test error"}}`,
+		`{"error": {"status": "INVALID_ARGUMENT", "message": "test error", "details": [` +
+			`{"@type": "type.googleapis.com/google.firebase.fcm.v1.FcmErrorCode", "errorCode": "UNREGISTERED"}]}}`,
+	}
+	cases := []struct {
+		check func(error) bool
+		want  string
+	}{
+		{
+			check: IsInvalidArgument,
+			want: "http error status: 400; reason: request contains an invalid argument; code: invalid-argument; " +
+				"details: test error",
+		},
+		{
+			check: IsRegistrationTokenNotRegistered,
+			want: "http error status: 400; reason: app instance has been unregistered; code: registration-token-not-registered; " +
+				"details: test error",
+		},
+	}
+
+	for idx, failure := range failures {
+		resp, err := createMultipartResponse(testSuccessResponse[:1], []string{failure})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", wantMime)
+			w.Write(resp)
+		}))
+
+		ctx := context.Background()
+		client, err := NewClient(ctx, testMessagingConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.batchEndpoint = ts.URL
+
+		br, err := client.SendMulticast(ctx, testMulticastMessage)
+		ts.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if br.SuccessCount != 1 || br.FailureCount != 1 || len(br.Responses) != 2 {
+			t.Fatalf("SendMulticast() = %v; want = 1 success and 1 failure", br)
+		}
+		if r := br.Responses[0]; !r.Success || r.MessageID != testSuccessResponse[0].Name || r.Error != nil {
+			t.Errorf("Responses[0] = %v; want = success", r)
+		}
+		r := br.Responses[1]
+		if r.Success || r.MessageID != "" || r.Error == nil {
+			t.Fatalf("Responses[1] = %v; want = failure", r)
+		}
+		if r.Error.Error() != cases[idx].want || !cases[idx].check(r.Error) {
+			t.Errorf("Responses[1].Error = %q; want = %q", r.Error.Error(), cases[idx].want)
+		}
+	}
+}
+
+func TestSendAllBatchError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error": {"status": "UNAVAILABLE", "message": "test error"}}`))
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	client, err := NewClient(ctx, testMessagingConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.batchEndpoint = ts.URL
+
+	want := "http error status: 503; reason: backend servers are temporarily unavailable; code: server-unavailable; " +
+		"details: test error"
+	br, err := client.SendAll(ctx, testMessages)
+	if err == nil || err.Error() != want || !IsServerUnavailable(err) {
+		t.Errorf("SendAll() = (%v, %v); want = (nil, %q)", br, err, want)
+	}
+}
+
+func checkSuccessfulBatchResponse(br *BatchResponse) error {
+	if br.SuccessCount != 2 {
+		return fmt.Errorf("SuccessCount = %d; want = 2", br.SuccessCount)
+	}
+	if br.FailureCount != 0 {
+		return fmt.Errorf("FailureCount = %d; want = 0", br.FailureCount)
+	}
+	if len(br.Responses) != 2 {
+		return fmt.Errorf("len(Responses) = %d; want = 2", len(br.Responses))
+	}
+
+	for idx, r := range br.Responses {
+		if err := checkSuccessfulSendResponse(r, testSuccessResponse[idx].Name); err != nil {
+			return fmt.Errorf("Responses[%d]: %v", idx, err)
+		}
+	}
+	return nil
+}
+
+func checkSuccessfulSendResponse(r *SendResponse, wantID string) error {
+	if !r.Success {
+		return fmt.Errorf("Success = false; want = true")
+	}
+	if r.Error != nil {
+		return fmt.Errorf("Error = %v; want = nil", r.Error)
+	}
+	if r.MessageID != wantID {
+		return fmt.Errorf("MessageID = %q; want = %q", r.MessageID, wantID)
+	}
+	return nil
+}
+
+func checkMultipartRequest(b []byte, contentType string, messages []*Message, dryRun bool) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return err
+	}
+	if mediaType != "multipart/mixed" {
+		return fmt.Errorf("Content-Type = %q; want = %q", mediaType, "multipart/mixed")
+	}
+
+	mr := multipart.NewReader(bytes.NewBuffer(b), params["boundary"])
+	count := 0
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if count >= len(messages) {
+			return fmt.Errorf("too many parts in the request; want = %d", len(messages))
+		}
+		if err := checkRequestPart(part, messages[count], dryRun); err != nil {
+			return fmt.Errorf("[%d] %v", count, err)
+		}
+		count++
+	}
+
+	if count != len(messages) {
+		return fmt.Errorf("PartsCount = %d; want = %d", count, len(messages))
+	}
+	return nil
+}
+
+func checkRequestPart(part *multipart.Part, message *Message, dryRun bool) error {
+	if ct := part.Header.Get("Content-Type"); ct != "application/http" {
+		return fmt.Errorf("Content-Type = %q; want = %q", ct, "application/http")
+	}
+
+	r, err := http.ReadRequest(bufio.NewReader(part))
+	if err != nil {
+		return err
+	}
+	if r.Method != http.MethodPost {
+		return fmt.Errorf("Method = %q; want = %q", r.Method, http.MethodPost)
+	}
+	if r.RequestURI != wantSendURL {
+		return fmt.Errorf("URL = %q; want = %q", r.RequestURI, wantSendURL)
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	var got struct {
+		ValidateOnly bool            `json:"validate_only"`
+		Message      json.RawMessage `json:"message"`
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		return err
+	}
+	if got.ValidateOnly != dryRun {
+		return fmt.Errorf("ValidateOnly = %v; want = %v", got.ValidateOnly, dryRun)
+	}
+	want, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got.Message, want) {
+		return fmt.Errorf("Message = %s; want = %s", string(got.Message), string(want))
+	}
+	return nil
+}
+
+func createMultipartResponse(success []fcmResponse, failure []string) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+	writer.SetBoundary("__END_OF_PART__")
+	for idx, data := range success {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeResponsePart(writer, idx, http.StatusOK, b); err != nil {
+			return nil, err
+		}
+	}
+	for idx, data := range failure {
+		if err := writeResponsePart(writer, len(success)+idx, http.StatusBadRequest, []byte(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Close()
+	return buffer.Bytes(), nil
+}
+
+func writeResponsePart(writer *multipart.Writer, idx, status int, body []byte) error {
+	header := make(map[string][]string)
+	header["Content-Type"] = []string{"application/http"}
+	header["Content-Id"] = []string{fmt.Sprintf("response-%d", idx+1)}
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	resp := fmt.Sprintf("HTTP/1.1 %d %s\r\n"+
+		"Content-Type: application/json; charset=UTF-8\r\n"+
+		"Content-Length: %d\r\n\r\n%s", status, http.StatusText(status), len(body), string(body))
+	_, err = part.Write([]byte(resp))
+	return err
+}
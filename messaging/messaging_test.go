@@ -16,6 +16,8 @@ package messaging
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -148,6 +150,7 @@ var validMessages = []struct {
 					TitleLocArgs: []string{"t1", "t2"},
 					BodyLocKey:   "blk",
 					BodyLocArgs:  []string{"b1", "b2"},
+					ChannelID:    "c",
 				},
 				TTL: &ttlWithNanos,
 			},
@@ -165,6 +168,7 @@ var validMessages = []struct {
 					"title_loc_args": []interface{}{"t1", "t2"},
 					"body_loc_key":   "blk",
 					"body_loc_args":  []interface{}{"b1", "b2"},
+					"channel_id":     "c",
 				},
 				"ttl": "1.500000000s",
 			},
@@ -203,6 +207,9 @@ var validMessages = []struct {
 					Body:  "b",
 					Icon:  "i",
 				},
+				FcmOptions: &WebpushFcmOptions{
+					Link: "https://link.com",
+				},
 			},
 			Topic: "test-topic",
 		},
@@ -211,6 +218,7 @@ var validMessages = []struct {
 				"headers":      map[string]interface{}{"h1": "v1", "h2": "v2"},
 				"data":         map[string]interface{}{"k1": "v1", "k2": "v2"},
 				"notification": map[string]interface{}{"title": "t", "body": "b", "icon": "i"},
+				"fcm_options":  map[string]interface{}{"link": "https://link.com"},
 			},
 			"topic": "test-topic",
 		},
@@ -463,6 +471,18 @@ var invalidMessages = []struct {
 		},
 		want: "bodyLocKey is required when specifying bodyLocArgs",
 	},
+	{
+		name: "InvalidWebpushFcmOptionsLink",
+		req: &Message{
+			Webpush: &WebpushConfig{
+				FcmOptions: &WebpushFcmOptions{
+					Link: "http://link.com",
+				},
+			},
+			Topic: "topic",
+		},
+		want: "fcm options link must be an HTTPS URL",
+	},
 	{
 		name: "APNSMultipleAlerts",
 		req: &Message{
@@ -711,6 +731,59 @@ func TestSendError(t *testing.T) {
 	}
 }
 
+func TestSendErrorCode(t *testing.T) {
+	var resp string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(resp))
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	client, err := NewClient(ctx, testMessagingConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.fcmEndpoint = ts.URL
+
+	detail := `{"error": {"status": "INVALID_ARGUMENT", "details": [` +
+		`{"@type": "type.googleapis.com/google.firebase.fcm.v1.FcmErrorCode", "errorCode": "%s"}]}}`
+	cases := []struct {
+		resp  string
+		want  ErrorCode
+		check func(error) bool
+	}{
+		{fmt.Sprintf(detail, "UNREGISTERED"), ErrUnregistered, IsRegistrationTokenNotRegistered},
+		{`{"error": {"status": "NOT_FOUND"}}`, ErrUnregistered, IsRegistrationTokenNotRegistered},
+		{fmt.Sprintf(detail, "SENDER_ID_MISMATCH"), ErrSenderIDMismatch, IsSenderIDMismatch},
+		{`{"error": {"status": "PERMISSION_DENIED"}}`, ErrSenderIDMismatch, IsSenderIDMismatch},
+		{fmt.Sprintf(detail, "QUOTA_EXCEEDED"), ErrQuotaExceeded, IsMessageRateExceeded},
+		{`{"error": {"status": "RESOURCE_EXHAUSTED"}}`, ErrQuotaExceeded, IsMessageRateExceeded},
+		{fmt.Sprintf(detail, "UNAVAILABLE"), ErrUnavailable, IsServerUnavailable},
+		{fmt.Sprintf(detail, "INTERNAL"), ErrInternal, IsInternal},
+		{fmt.Sprintf(detail, "THIRD_PARTY_AUTH_ERROR"), ErrThirdPartyAuth, IsThirdPartyAuthError},
+		{`{"error": {"status": "APNS_AUTH_ERROR"}}`, ErrThirdPartyAuth, IsThirdPartyAuthError},
+		{`{"error": {"status": "INVALID_ARGUMENT"}}`, ErrInvalidArgument, IsInvalidArgument},
+		{`{"error": {"status": "UNSPECIFIED_ERROR"}}`, "", IsUnknown},
+	}
+	for _, tc := range cases {
+		resp = tc.resp
+		_, err := client.Send(ctx, &Message{Topic: "topic"})
+		if got := ErrorCodeOf(err); got != tc.want || !tc.check(err) {
+			t.Errorf("ErrorCodeOf(%s) = %q; want = %q", tc.resp, got, tc.want)
+		}
+	}
+
+	resp = fmt.Sprintf(detail, "THIRD_PARTY_AUTH_ERROR")
+	if _, err := client.Send(ctx, &Message{Topic: "topic"}); !IsInvalidAPNSCredentials(err) {
+		t.Errorf("IsInvalidAPNSCredentials(THIRD_PARTY_AUTH_ERROR) = false; want = true")
+	}
+	if got := ErrorCodeOf(errors.New("not an fcm error")); got != "" {
+		t.Errorf("ErrorCodeOf(non-fcm error) = %q; want = %q", got, "")
+	}
+}
+
 func TestInvalidMessage(t *testing.T) {
 	ctx := context.Background()
 	client, err := NewClient(ctx, testMessagingConfig)
@@ -852,6 +925,9 @@ func TestTopicManagementError(t *testing.T) {
 		if err == nil || err.Error() != tc.want || !tc.check(err) {
 			t.Errorf("SubscribeToTopic() = (%q, %v); want = (%q, %q)", tmr, err, "", tc.want)
 		}
+		if code := ErrorCodeOf(err); code != "" {
+			t.Errorf("ErrorCodeOf(SubscribeToTopic()) = %q; want = %q", code, "")
+		}
 	}
 	for _, tc := range cases {
 		resp = tc.resp
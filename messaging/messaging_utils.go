@@ -16,6 +16,7 @@ package messaging
 
 import (
 	"fmt"
+	"net/url"
 	"regexp"
 	"strings"
 )
@@ -48,6 +49,11 @@ func validateMessage(message *Message) error {
 		return err
 	}
 
+	// validate WebpushConfig
+	if err := validateWebpushConfig(message.Webpush); err != nil {
+		return err
+	}
+
 	// validate APNSConfig
 	return validateAPNSConfig(message.APNS)
 }
@@ -83,6 +89,17 @@ func validateAndroidNotification(notification *AndroidNotification) error {
 	return nil
 }
 
+func validateWebpushConfig(config *WebpushConfig) error {
+	if config == nil || config.FcmOptions == nil || config.FcmOptions.Link == "" {
+		return nil
+	}
+	link, err := url.Parse(config.FcmOptions.Link)
+	if err != nil || link.Scheme != "https" {
+		return fmt.Errorf("fcm options link must be an HTTPS URL")
+	}
+	return nil
+}
+
 func validateAPNSConfig(config *APNSConfig) error {
 	if config != nil {
 		return validateAPNSPayload(config.Payload)
@@ -0,0 +1,327 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remoteconfig contains functions for managing Firebase Remote Config templates.
+package remoteconfig // import "firebase.google.com/go/remoteconfig"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/api/transport"
+
+	"firebase.google.com/go/internal"
+)
+
+const (
+	remoteConfigEndpoint = "https://firebaseremoteconfig.googleapis.com/v1"
+
+	concurrentUpdate       = "concurrent-update"
+	insufficientPermission = "insufficient-permission"
+	internalError          = "internal-error"
+	invalidArgument        = "invalid-argument"
+	notFound               = "not-found"
+	serverUnavailable      = "server-unavailable"
+	tooManyRequests        = "too-many-requests"
+	unknownError           = "unknown-error"
+)
+
+var errorCodes = map[int]struct{ Code, Msg string }{
+	http.StatusBadRequest:          {invalidArgument, "request contains an invalid argument"},
+	http.StatusUnauthorized:        {insufficientPermission, "request not authorized"},
+	http.StatusForbidden:           {insufficientPermission, "client does not have sufficient privileges"},
+	http.StatusNotFound:            {notFound, "requested resource not found"},
+	http.StatusConflict:            {concurrentUpdate, "template etag does not match the current template"},
+	http.StatusPreconditionFailed:  {concurrentUpdate, "template etag does not match the current template"},
+	http.StatusTooManyRequests:     {tooManyRequests, "request throttled out by the backend server"},
+	http.StatusInternalServerError: {internalError, "internal server error"},
+	http.StatusServiceUnavailable:  {serverUnavailable, "backend servers are temporarily unavailable"},
+}
+
+// Client is the interface for the Firebase Remote Config service.
+type Client struct {
+	// To enable testing against arbitrary endpoints.
+	endpoint string
+	client   *internal.HTTPClient
+	project  string
+	version  string
+}
+
+// NewClient creates a new instance of the Firebase Remote Config Client.
+//
+// This function can only be invoked from within the SDK. Client applications should access the
+// the Remote Config service through firebase.App.
+func NewClient(ctx context.Context, c *internal.RemoteConfigConfig) (*Client, error) {
+	if c.ProjectID == "" {
+		return nil, errors.New("project id is required to access remote config client")
+	}
+
+	hc, _, err := transport.NewHTTPClient(ctx, c.Opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		endpoint: remoteConfigEndpoint,
+		client:   &internal.HTTPClient{Client: hc},
+		project:  c.ProjectID,
+		version:  "Go/Admin/" + c.Version,
+	}, nil
+}
+
+// Template represents a Remote Config template.
+//
+// A template consists of a set of parameters, and the conditions used to select conditional
+// values of those parameters. ETag identifies the version of the template it was read from, and
+// is used by PublishTemplate() to detect concurrent modifications.
+//
+// Version describes the version of the template held by the server. It is populated in templates
+// returned by the Client, and is ignored when publishing a template.
+type Template struct {
+	Conditions []*Condition          `json:"conditions,omitempty"`
+	Parameters map[string]*Parameter `json:"parameters,omitempty"`
+	ETag       string                `json:"-"`
+	Version    *Version              `json:"-"`
+}
+
+// Condition is a named expression that selects the conditional values of parameters.
+type Condition struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+	TagColor   string `json:"tagColor,omitempty"`
+}
+
+// Parameter is a Remote Config parameter.
+//
+// ConditionalValues are keyed by the names of the conditions that select them. If none of the
+// conditions evaluate to true, DefaultValue is used.
+type Parameter struct {
+	DefaultValue      *ParameterValue            `json:"defaultValue,omitempty"`
+	ConditionalValues map[string]*ParameterValue `json:"conditionalValues,omitempty"`
+	Description       string                     `json:"description,omitempty"`
+}
+
+// ParameterValue is a value of a Remote Config parameter.
+//
+// If UseInAppDefault is true, clients use the default value specified in the app instead of
+// Value.
+type ParameterValue struct {
+	Value           string
+	UseInAppDefault bool
+}
+
+// MarshalJSON marshals a ParameterValue into JSON (for internal use only).
+func (v *ParameterValue) MarshalJSON() ([]byte, error) {
+	if v.UseInAppDefault {
+		return json.Marshal(map[string]bool{"useInAppDefault": true})
+	}
+	return json.Marshal(map[string]string{"value": v.Value})
+}
+
+// UnmarshalJSON unmarshals a JSON string into a ParameterValue (for internal use only).
+func (v *ParameterValue) UnmarshalJSON(b []byte) error {
+	var temp struct {
+		Value           string `json:"value"`
+		UseInAppDefault bool   `json:"useInAppDefault"`
+	}
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return err
+	}
+	v.Value = temp.Value
+	v.UseInAppDefault = temp.UseInAppDefault
+	return nil
+}
+
+// PublishOption is an option for configuring the behavior of PublishTemplate().
+type PublishOption func(*publishConfig)
+
+type publishConfig struct {
+	validateOnly bool
+	force        bool
+}
+
+// ValidateOnly returns a PublishOption that only validates the template on the server, without
+// publishing it.
+func ValidateOnly() PublishOption {
+	return func(c *publishConfig) {
+		c.validateOnly = true
+	}
+}
+
+// Force returns a PublishOption that publishes the template regardless of its ETag, overwriting
+// any changes made to the project's template since it was read.
+func Force() PublishOption {
+	return func(c *publishConfig) {
+		c.force = true
+	}
+}
+
+// GetTemplate retrieves the currently active Remote Config template of the project.
+func (c *Client) GetTemplate(ctx context.Context) (*Template, error) {
+	request := &internal.Request{
+		Method: http.MethodGet,
+		URL:    c.templateURL(),
+	}
+	return c.makeTemplateRequest(ctx, request)
+}
+
+// PublishTemplate publishes the given template, and returns the newly active template.
+//
+// The ETag of the given template is sent to the server in the If-Match header. If the project's
+// template has been modified since the given template was read, the server rejects the update, and
+// PublishTemplate returns an error that can be checked with IsConcurrentUpdate(). In that case,
+// fetch the latest template with GetTemplate() and reapply the changes. Templates without an ETag
+// can only be published with the Force() option.
+func (c *Client) PublishTemplate(ctx context.Context, t *Template, opts ...PublishOption) (*Template, error) {
+	if t == nil {
+		return nil, errors.New("template must not be nil")
+	}
+
+	conf := &publishConfig{}
+	for _, o := range opts {
+		o(conf)
+	}
+	etag := t.ETag
+	if conf.force {
+		etag = "*"
+	} else if etag == "" {
+		return nil, errors.New("template etag must not be empty")
+	}
+
+	httpOpts := []internal.HTTPOption{internal.WithHeader("If-Match", etag)}
+	if conf.validateOnly {
+		httpOpts = append(httpOpts, internal.WithQueryParam("validateOnly", "true"))
+	}
+	request := &internal.Request{
+		Method: http.MethodPut,
+		URL:    c.templateURL(),
+		Body:   internal.NewJSONEntity(t),
+		Opts:   httpOpts,
+	}
+	return c.makeTemplateRequest(ctx, request)
+}
+
+// IsConcurrentUpdate checks if the given error was due to the template being modified since it
+// was read, i.e. an ETag mismatch.
+func IsConcurrentUpdate(err error) bool {
+	return internal.HasErrorCode(err, concurrentUpdate)
+}
+
+// IsInsufficientPermission checks if the given error was due to the client not having the
+// required privileges.
+func IsInsufficientPermission(err error) bool {
+	return internal.HasErrorCode(err, insufficientPermission)
+}
+
+// IsInternal checks if the given error was due to an internal server error.
+func IsInternal(err error) bool {
+	return internal.HasErrorCode(err, internalError)
+}
+
+// IsInvalidArgument checks if the given error was due to an invalid argument in the request.
+func IsInvalidArgument(err error) bool {
+	return internal.HasErrorCode(err, invalidArgument)
+}
+
+// IsNotFound checks if the given error was due to a non existing resource.
+func IsNotFound(err error) bool {
+	return internal.HasErrorCode(err, notFound)
+}
+
+// IsServerUnavailable checks if the given error was due to the backend server being temporarily
+// unavailable.
+func IsServerUnavailable(err error) bool {
+	return internal.HasErrorCode(err, serverUnavailable)
+}
+
+// IsTooManyRequests checks if the given error was due to the client sending too many requests
+// causing a server quota to exceed.
+func IsTooManyRequests(err error) bool {
+	return internal.HasErrorCode(err, tooManyRequests)
+}
+
+// IsUnknown checks if the given error was due to unknown error returned by the backend server.
+func IsUnknown(err error) bool {
+	return internal.HasErrorCode(err, unknownError)
+}
+
+func (c *Client) templateURL() string {
+	return fmt.Sprintf("%s/projects/%s/remoteConfig", c.endpoint, c.project)
+}
+
+func (c *Client) makeTemplateRequest(ctx context.Context, r *internal.Request) (*Template, error) {
+	resp, err := c.send(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	var t Template
+	if err := json.Unmarshal(resp.Body, &t); err != nil {
+		return nil, err
+	}
+	var v struct {
+		Version *versionResponse `json:"version"`
+	}
+	if err := json.Unmarshal(resp.Body, &v); err != nil {
+		return nil, err
+	}
+	if v.Version != nil {
+		if t.Version, err = v.Version.version(); err != nil {
+			return nil, err
+		}
+	}
+	t.ETag = resp.Header.Get("ETag")
+	return &t, nil
+}
+
+func (c *Client) send(ctx context.Context, r *internal.Request) (*internal.Response, error) {
+	r.Opts = append(r.Opts, internal.WithHeader("X-Client-Version", c.version))
+	resp, err := c.client.Do(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != http.StatusOK {
+		return nil, handleError(resp)
+	}
+	return resp, nil
+}
+
+type rcError struct {
+	Error struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func handleError(resp *internal.Response) error {
+	var re rcError
+	json.Unmarshal(resp.Body, &re) // ignore any json parse errors at this level
+
+	var clientCode, msg string
+	info, ok := errorCodes[resp.Status]
+	if ok {
+		clientCode, msg = info.Code, info.Msg
+	} else {
+		clientCode = unknownError
+		msg = fmt.Sprintf("server responded with an unknown error; response: %s", string(resp.Body))
+	}
+	if re.Error.Message != "" {
+		msg += "; details: " + re.Error.Message
+	}
+	return internal.Errorf(clientCode, "http error status: %d; reason: %s", resp.Status, msg)
+}
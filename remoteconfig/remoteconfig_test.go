@@ -0,0 +1,305 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remoteconfig
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/api/option"
+
+	"firebase.google.com/go/internal"
+)
+
+const (
+	testETag         = "etag-123456789012-1"
+	testTemplatePath = "/projects/test-project/remoteConfig"
+	testTemplateJSON = `{
+		"conditions": [{"name": "ios", "expression": "device.os == 'ios'", "tagColor": "BLUE"}],
+		"parameters": {
+			"welcome_message": {
+				"defaultValue": {"value": "hello"},
+				"conditionalValues": {"ios": {"useInAppDefault": true}},
+				"description": "Welcome message"
+			}
+		}
+	}`
+)
+
+var (
+	testRemoteConfigConfig = &internal.RemoteConfigConfig{
+		ProjectID: "test-project",
+		Opts: []option.ClientOption{
+			option.WithTokenSource(&internal.MockTokenSource{AccessToken: "test-token"}),
+		},
+		Version: "test-version",
+	}
+
+	testTemplate = &Template{
+		Conditions: []*Condition{
+			{Name: "ios", Expression: "device.os == 'ios'", TagColor: "BLUE"},
+		},
+		Parameters: map[string]*Parameter{
+			"welcome_message": {
+				DefaultValue: &ParameterValue{Value: "hello"},
+				ConditionalValues: map[string]*ParameterValue{
+					"ios": {UseInAppDefault: true},
+				},
+				Description: "Welcome message",
+			},
+		},
+		ETag: testETag,
+	}
+)
+
+type mockServer struct {
+	Resp   string
+	Status int
+	Header map[string]string
+	Req    []*http.Request
+	Body   []byte
+}
+
+func (s *mockServer) start(t *testing.T) (*Client, *httptest.Server) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Req = append(s.Req, r)
+		s.Body, _ = ioutil.ReadAll(r.Body)
+		for k, v := range s.Header {
+			w.Header().Set(k, v)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if s.Status != 0 {
+			w.WriteHeader(s.Status)
+		}
+		w.Write([]byte(s.Resp))
+	}))
+	client, err := NewClient(context.Background(), testRemoteConfigConfig)
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+	client.endpoint = ts.URL
+	return client, ts
+}
+
+func TestNoProjectID(t *testing.T) {
+	client, err := NewClient(context.Background(), &internal.RemoteConfigConfig{})
+	if client != nil || err == nil {
+		t.Errorf("NewClient() = (%v, %v); want = (nil, error)", client, err)
+	}
+}
+
+func TestGetTemplate(t *testing.T) {
+	s := &mockServer{
+		Resp:   testTemplateJSON,
+		Header: map[string]string{"ETag": testETag},
+	}
+	client, ts := s.start(t)
+	defer ts.Close()
+
+	template, err := client.GetTemplate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(template, testTemplate) {
+		t.Errorf("GetTemplate() = %#v; want = %#v", template, testTemplate)
+	}
+	checkRequest(t, s, http.MethodGet, testTemplatePath)
+}
+
+func TestPublishTemplate(t *testing.T) {
+	s := &mockServer{
+		Resp:   testTemplateJSON,
+		Header: map[string]string{"ETag": "etag-123456789012-2"},
+	}
+	client, ts := s.start(t)
+	defer ts.Close()
+
+	template, err := client.PublishTemplate(context.Background(), testTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if template.ETag != "etag-123456789012-2" {
+		t.Errorf("PublishTemplate().ETag = %q; want = %q", template.ETag, "etag-123456789012-2")
+	}
+	checkRequest(t, s, http.MethodPut, testTemplatePath)
+	if h := s.Req[0].Header.Get("If-Match"); h != testETag {
+		t.Errorf("If-Match = %q; want = %q", h, testETag)
+	}
+	if q := s.Req[0].URL.Query().Get("validateOnly"); q != "" {
+		t.Errorf("validateOnly = %q; want = %q", q, "")
+	}
+	checkTemplateBody(t, s.Body)
+}
+
+func TestPublishTemplateValidateOnly(t *testing.T) {
+	s := &mockServer{
+		Resp:   testTemplateJSON,
+		Header: map[string]string{"ETag": testETag + "-0"},
+	}
+	client, ts := s.start(t)
+	defer ts.Close()
+
+	if _, err := client.PublishTemplate(context.Background(), testTemplate, ValidateOnly()); err != nil {
+		t.Fatal(err)
+	}
+	checkRequest(t, s, http.MethodPut, testTemplatePath)
+	if q := s.Req[0].URL.Query().Get("validateOnly"); q != "true" {
+		t.Errorf("validateOnly = %q; want = %q", q, "true")
+	}
+}
+
+func TestPublishTemplateForce(t *testing.T) {
+	s := &mockServer{
+		Resp:   testTemplateJSON,
+		Header: map[string]string{"ETag": testETag},
+	}
+	client, ts := s.start(t)
+	defer ts.Close()
+
+	template := &Template{Parameters: testTemplate.Parameters}
+	if _, err := client.PublishTemplate(context.Background(), template, Force()); err != nil {
+		t.Fatal(err)
+	}
+	checkRequest(t, s, http.MethodPut, testTemplatePath)
+	if h := s.Req[0].Header.Get("If-Match"); h != "*" {
+		t.Errorf("If-Match = %q; want = %q", h, "*")
+	}
+}
+
+func TestPublishTemplateInvalidArgs(t *testing.T) {
+	client, err := NewClient(context.Background(), testRemoteConfigConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []*Template{nil, {Parameters: testTemplate.Parameters}}
+	for _, tc := range cases {
+		if template, err := client.PublishTemplate(context.Background(), tc); template != nil || err == nil {
+			t.Errorf("PublishTemplate(%v) = (%v, %v); want = (nil, error)", tc, template, err)
+		}
+	}
+}
+
+func TestPublishTemplateConcurrentUpdate(t *testing.T) {
+	s := &mockServer{
+		Resp:   `{"error": {"status": "ABORTED", "message": "etag mismatch"}}`,
+		Status: http.StatusConflict,
+	}
+	client, ts := s.start(t)
+	defer ts.Close()
+
+	template, err := client.PublishTemplate(context.Background(), testTemplate)
+	want := "http error status: 409; reason: template etag does not match the current template; details: etag mismatch"
+	if template != nil || err == nil || err.Error() != want || !IsConcurrentUpdate(err) {
+		t.Errorf("PublishTemplate() = (%v, %v); want = (nil, %q)", template, err, want)
+	}
+}
+
+func TestTemplateError(t *testing.T) {
+	s := &mockServer{}
+	client, ts := s.start(t)
+	defer ts.Close()
+
+	cases := []struct {
+		status int
+		check  func(error) bool
+	}{
+		{http.StatusBadRequest, IsInvalidArgument},
+		{http.StatusUnauthorized, IsInsufficientPermission},
+		{http.StatusForbidden, IsInsufficientPermission},
+		{http.StatusNotFound, IsNotFound},
+		{http.StatusConflict, IsConcurrentUpdate},
+		{http.StatusPreconditionFailed, IsConcurrentUpdate},
+		{http.StatusTooManyRequests, IsTooManyRequests},
+		{http.StatusInternalServerError, IsInternal},
+		{http.StatusServiceUnavailable, IsServerUnavailable},
+		{http.StatusNetworkAuthenticationRequired, IsUnknown},
+	}
+	for _, tc := range cases {
+		s.Status = tc.status
+		s.Resp = "{}"
+		template, err := client.GetTemplate(context.Background())
+		if template != nil || err == nil || !tc.check(err) {
+			t.Errorf("GetTemplate(%d) = (%v, %v); want = (nil, error)", tc.status, template, err)
+		}
+	}
+}
+
+func TestParameterValueJSON(t *testing.T) {
+	cases := []struct {
+		value *ParameterValue
+		want  string
+	}{
+		{&ParameterValue{Value: "foo"}, `{"value":"foo"}`},
+		{&ParameterValue{Value: ""}, `{"value":""}`},
+		{&ParameterValue{UseInAppDefault: true}, `{"useInAppDefault":true}`},
+	}
+	for _, tc := range cases {
+		b, err := json.Marshal(tc.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tc.want {
+			t.Errorf("Marshal(%v) = %s; want = %s", tc.value, string(b), tc.want)
+		}
+
+		var got ParameterValue
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != *tc.value {
+			t.Errorf("Unmarshal(%s) = %v; want = %v", string(b), got, *tc.value)
+		}
+	}
+}
+
+func checkRequest(t *testing.T, s *mockServer, method, path string) {
+	if len(s.Req) != 1 {
+		t.Fatalf("Requests = %d; want = 1", len(s.Req))
+	}
+	r := s.Req[0]
+	if r.Method != method {
+		t.Errorf("Method = %q; want = %q", r.Method, method)
+	}
+	if r.URL.Path != path {
+		t.Errorf("Path = %q; want = %q", r.URL.Path, path)
+	}
+	if h := r.Header.Get("Authorization"); h != "Bearer test-token" {
+		t.Errorf("Authorization = %q; want = %q", h, "Bearer test-token")
+	}
+	if h := r.Header.Get("X-Client-Version"); h != "Go/Admin/test-version" {
+		t.Errorf("X-Client-Version = %q; want = %q", h, "Go/Admin/test-version")
+	}
+}
+
+func checkTemplateBody(t *testing.T, b []byte) {
+	var got, want map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(testTemplateJSON), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Body = %v; want = %v", got, want)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remoteconfig
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/api/iterator"
+
+	"firebase.google.com/go/internal"
+)
+
+const maxVersionPageSize = 300
+
+// Version is the metadata of a published Remote Config template.
+type Version struct {
+	VersionNumber  int64
+	UpdateTime     time.Time
+	UpdateOrigin   string
+	UpdateType     string
+	UpdateUser     *User
+	Description    string
+	RollbackSource int64
+	IsLegacy       bool
+}
+
+// User is the user who published a Remote Config template.
+type User struct {
+	Email    string `json:"email,omitempty"`
+	Name     string `json:"name,omitempty"`
+	ImageURL string `json:"imageUrl,omitempty"`
+}
+
+type versionResponse struct {
+	VersionNumber  string `json:"versionNumber"`
+	UpdateTime     string `json:"updateTime"`
+	UpdateOrigin   string `json:"updateOrigin"`
+	UpdateType     string `json:"updateType"`
+	UpdateUser     *User  `json:"updateUser"`
+	Description    string `json:"description"`
+	RollbackSource string `json:"rollbackSource"`
+	IsLegacy       bool   `json:"isLegacy"`
+}
+
+func (r *versionResponse) version() (*Version, error) {
+	v := &Version{
+		UpdateOrigin: r.UpdateOrigin,
+		UpdateType:   r.UpdateType,
+		UpdateUser:   r.UpdateUser,
+		Description:  r.Description,
+		IsLegacy:     r.IsLegacy,
+	}
+
+	var err error
+	if v.VersionNumber, err = parseVersionNumber(r.VersionNumber); err != nil {
+		return nil, err
+	}
+	if v.RollbackSource, err = parseVersionNumber(r.RollbackSource); err != nil {
+		return nil, err
+	}
+	if r.UpdateTime != "" {
+		if v.UpdateTime, err = time.Parse(time.RFC3339Nano, r.UpdateTime); err != nil {
+			return nil, fmt.Errorf("invalid update time: %q", r.UpdateTime)
+		}
+	}
+	return v, nil
+}
+
+func parseVersionNumber(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid version number: %q", s)
+	}
+	return n, nil
+}
+
+// ListVersionsOptions specifies the versions returned by ListVersions().
+//
+// All fields are optional. Versions are listed in reverse chronological order, starting at
+// EndVersionNumber if specified. StartTime and EndTime restrict the listed versions to those
+// published within the given time range.
+type ListVersionsOptions struct {
+	EndVersionNumber int64
+	StartTime        time.Time
+	EndTime          time.Time
+	PageToken        string
+}
+
+// ListVersions returns an iterator over the published versions of the Remote Config template.
+//
+// Versions are listed in reverse chronological order, i.e. the most recently published version
+// first. Opts may be nil to list all available versions.
+func (c *Client) ListVersions(ctx context.Context, opts *ListVersionsOptions) *VersionIterator {
+	if opts == nil {
+		opts = &ListVersionsOptions{}
+	}
+	it := &VersionIterator{
+		client: c,
+		ctx:    ctx,
+		opts:   opts,
+	}
+	it.pageInfo, it.nextFunc = iterator.NewPageInfo(
+		it.fetch,
+		func() int { return len(it.versions) },
+		func() interface{} { b := it.versions; it.versions = nil; return b })
+	it.pageInfo.MaxSize = maxVersionPageSize
+	it.pageInfo.Token = opts.PageToken
+	return it
+}
+
+// VersionIterator is an iterator over Remote Config template versions.
+//
+// Also see: https://github.com/GoogleCloudPlatform/google-cloud-go/wiki/Iterator-Guidelines
+type VersionIterator struct {
+	client   *Client
+	ctx      context.Context
+	opts     *ListVersionsOptions
+	nextFunc func() error
+	pageInfo *iterator.PageInfo
+	versions []*Version
+}
+
+// PageInfo supports pagination. See the google.golang.org/api/iterator package for details.
+func (it *VersionIterator) PageInfo() *iterator.PageInfo { return it.pageInfo }
+
+// Next returns the next result. Its second return value is [iterator.Done] if there are no more
+// results. Once Next returns [iterator.Done], all subsequent calls will return [iterator.Done].
+func (it *VersionIterator) Next() (*Version, error) {
+	if err := it.nextFunc(); err != nil {
+		return nil, err
+	}
+	version := it.versions[0]
+	it.versions = it.versions[1:]
+	return version, nil
+}
+
+func (it *VersionIterator) fetch(pageSize int, pageToken string) (string, error) {
+	versions, nextPageToken, err := it.client.listVersions(it.ctx, it.opts, pageSize, pageToken)
+	if err != nil {
+		return "", err
+	}
+	it.versions = append(it.versions, versions...)
+	it.pageInfo.Token = nextPageToken
+	return nextPageToken, nil
+}
+
+// Rollback restores the template of the given version, and returns the newly active template.
+//
+// Rolling back publishes a copy of the template of the given version as a new version. Rollback
+// returns an error that can be checked with IsNotFound() if the given version does not exist.
+func (c *Client) Rollback(ctx context.Context, versionNumber int64) (*Template, error) {
+	if versionNumber <= 0 {
+		return nil, errors.New("version number must be a positive integer")
+	}
+
+	opts := &ListVersionsOptions{EndVersionNumber: versionNumber}
+	versions, _, err := c.listVersions(ctx, opts, 1, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 || versions[0].VersionNumber != versionNumber {
+		return nil, internal.Errorf(notFound, "remote config template version %d not found", versionNumber)
+	}
+
+	request := &internal.Request{
+		Method: http.MethodPost,
+		URL:    c.templateURL() + ":rollback",
+		Body: internal.NewJSONEntity(map[string]string{
+			"versionNumber": strconv.FormatInt(versionNumber, 10),
+		}),
+	}
+	return c.makeTemplateRequest(ctx, request)
+}
+
+func (c *Client) listVersions(
+	ctx context.Context, opts *ListVersionsOptions, pageSize int, pageToken string) ([]*Version, string, error) {
+
+	httpOpts := []internal.HTTPOption{internal.WithQueryParam("pageSize", strconv.Itoa(pageSize))}
+	if pageToken != "" {
+		httpOpts = append(httpOpts, internal.WithQueryParam("pageToken", pageToken))
+	}
+	if opts.EndVersionNumber != 0 {
+		httpOpts = append(httpOpts, internal.WithQueryParam(
+			"endVersionNumber", strconv.FormatInt(opts.EndVersionNumber, 10)))
+	}
+	if !opts.StartTime.IsZero() {
+		httpOpts = append(httpOpts, internal.WithQueryParam(
+			"startTime", opts.StartTime.UTC().Format(time.RFC3339Nano)))
+	}
+	if !opts.EndTime.IsZero() {
+		httpOpts = append(httpOpts, internal.WithQueryParam(
+			"endTime", opts.EndTime.UTC().Format(time.RFC3339Nano)))
+	}
+
+	request := &internal.Request{
+		Method: http.MethodGet,
+		URL:    c.templateURL() + ":listVersions",
+		Opts:   httpOpts,
+	}
+	resp, err := c.send(ctx, request)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var result struct {
+		Versions      []*versionResponse `json:"versions"`
+		NextPageToken string             `json:"nextPageToken"`
+	}
+	if err := resp.Unmarshal(http.StatusOK, &result); err != nil {
+		return nil, "", err
+	}
+
+	var versions []*Version
+	for _, vr := range result.Versions {
+		v, err := vr.version()
+		if err != nil {
+			return nil, "", err
+		}
+		versions = append(versions, v)
+	}
+	return versions, result.NextPageToken, nil
+}
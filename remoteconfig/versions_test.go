@@ -0,0 +1,260 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remoteconfig
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/api/iterator"
+)
+
+const testVersionJSON = `{
+	"versionNumber": "6",
+	"updateTime": "2020-01-01T10:20:30.123456Z",
+	"updateOrigin": "ADMIN_SDK_NODE",
+	"updateType": "ROLLBACK",
+	"updateUser": {"email": "admin@example.com", "name": "Admin", "imageUrl": "https://example.com/a.png"},
+	"description": "rolled back",
+	"rollbackSource": "4"
+}`
+
+var testVersion = &Version{
+	VersionNumber: 6,
+	UpdateTime:    time.Date(2020, time.January, 1, 10, 20, 30, 123456000, time.UTC),
+	UpdateOrigin:  "ADMIN_SDK_NODE",
+	UpdateType:    "ROLLBACK",
+	UpdateUser: &User{
+		Email:    "admin@example.com",
+		Name:     "Admin",
+		ImageURL: "https://example.com/a.png",
+	},
+	Description:    "rolled back",
+	RollbackSource: 4,
+}
+
+type versionsServer struct {
+	Resp map[string]string
+	Req  []*http.Request
+	Body [][]byte
+}
+
+func (s *versionsServer) start(t *testing.T) (*Client, *httptest.Server) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Req = append(s.Req, r)
+		b, _ := ioutil.ReadAll(r.Body)
+		s.Body = append(s.Body, b)
+		resp, ok := s.Resp[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", testETag)
+		w.Write([]byte(resp))
+	}))
+	client, err := NewClient(context.Background(), testRemoteConfigConfig)
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+	client.endpoint = ts.URL
+	return client, ts
+}
+
+func TestGetTemplateWithVersion(t *testing.T) {
+	s := &mockServer{
+		Resp:   `{"parameters": {}, "version": ` + testVersionJSON + `}`,
+		Header: map[string]string{"ETag": testETag},
+	}
+	client, ts := s.start(t)
+	defer ts.Close()
+
+	template, err := client.GetTemplate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(template.Version, testVersion) {
+		t.Errorf("GetTemplate().Version = %#v; want = %#v", template.Version, testVersion)
+	}
+}
+
+func TestListVersions(t *testing.T) {
+	s := &mockServer{
+		Resp: `{"versions": [` + testVersionJSON + `, {"versionNumber": "5", "isLegacy": true}]}`,
+	}
+	client, ts := s.start(t)
+	defer ts.Close()
+
+	it := client.ListVersions(context.Background(), nil)
+	var versions []*Version
+	for {
+		v, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, v)
+	}
+
+	want := []*Version{testVersion, {VersionNumber: 5, IsLegacy: true}}
+	if !reflect.DeepEqual(versions, want) {
+		t.Errorf("ListVersions() = %v; want = %v", versions, want)
+	}
+	checkRequest(t, s, http.MethodGet, testTemplatePath+":listVersions")
+	q := s.Req[0].URL.Query()
+	if q.Get("pageSize") != "300" || q.Get("pageToken") != "" {
+		t.Errorf("Query = %v; want = {pageSize: 300}", q)
+	}
+}
+
+func TestListVersionsPaged(t *testing.T) {
+	s := &mockServer{
+		Resp: `{"versions": [{"versionNumber": "3"}, {"versionNumber": "2"}], "nextPageToken": "next"}`,
+	}
+	client, ts := s.start(t)
+	defer ts.Close()
+
+	opts := &ListVersionsOptions{
+		EndVersionNumber: 3,
+		StartTime:        time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+		EndTime:          time.Date(2020, time.February, 1, 0, 0, 0, 0, time.UTC),
+	}
+	pager := iterator.NewPager(client.ListVersions(context.Background(), opts), 2, "start")
+	var versions []*Version
+	token, err := pager.NextPage(&versions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "next" || len(versions) != 2 || versions[0].VersionNumber != 3 || versions[1].VersionNumber != 2 {
+		t.Errorf("NextPage() = (%v, %q); want = ([3 2], %q)", versions, token, "next")
+	}
+
+	want := map[string]string{
+		"pageSize":         "2",
+		"pageToken":        "start",
+		"endVersionNumber": "3",
+		"startTime":        "2020-01-01T00:00:00Z",
+		"endTime":          "2020-02-01T00:00:00Z",
+	}
+	q := s.Req[0].URL.Query()
+	for k, v := range want {
+		if got := q.Get(k); got != v {
+			t.Errorf("Query(%q) = %q; want = %q", k, got, v)
+		}
+	}
+}
+
+func TestListVersionsError(t *testing.T) {
+	s := &mockServer{
+		Resp:   `{"error": {"status": "INVALID_ARGUMENT", "message": "invalid page token"}}`,
+		Status: http.StatusBadRequest,
+	}
+	client, ts := s.start(t)
+	defer ts.Close()
+
+	v, err := client.ListVersions(context.Background(), &ListVersionsOptions{PageToken: "token"}).Next()
+	if v != nil || !IsInvalidArgument(err) {
+		t.Errorf("ListVersions() = (%v, %v); want = (nil, invalid-argument)", v, err)
+	}
+	if q := s.Req[0].URL.Query().Get("pageToken"); q != "token" {
+		t.Errorf("pageToken = %q; want = %q", q, "token")
+	}
+}
+
+func TestRollback(t *testing.T) {
+	s := &versionsServer{
+		Resp: map[string]string{
+			testTemplatePath + ":listVersions": `{"versions": [{"versionNumber": "4"}]}`,
+			testTemplatePath + ":rollback":     `{"parameters": {}, "version": ` + testVersionJSON + `}`,
+		},
+	}
+	client, ts := s.start(t)
+	defer ts.Close()
+
+	template, err := client.Rollback(context.Background(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if template.ETag != testETag || !reflect.DeepEqual(template.Version, testVersion) {
+		t.Errorf("Rollback() = %v; want = {ETag: %q, Version: %v}", template, testETag, testVersion)
+	}
+
+	if len(s.Req) != 2 {
+		t.Fatalf("Requests = %d; want = 2", len(s.Req))
+	}
+	q := s.Req[0].URL.Query()
+	if q.Get("endVersionNumber") != "4" || q.Get("pageSize") != "1" {
+		t.Errorf("Query = %v; want = {endVersionNumber: 4, pageSize: 1}", q)
+	}
+	if r := s.Req[1]; r.Method != http.MethodPost || r.URL.Path != testTemplatePath+":rollback" {
+		t.Errorf("Request = %s %s; want = POST %s", r.Method, r.URL.Path, testTemplatePath+":rollback")
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(s.Body[1], &body); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"versionNumber": "4"}; !reflect.DeepEqual(body, want) {
+		t.Errorf("Body = %v; want = %v", body, want)
+	}
+}
+
+func TestRollbackVersionNotFound(t *testing.T) {
+	cases := []string{
+		`{}`,
+		`{"versions": [{"versionNumber": "3"}]}`,
+	}
+	for _, tc := range cases {
+		s := &versionsServer{
+			Resp: map[string]string{
+				testTemplatePath + ":listVersions": tc,
+				testTemplatePath + ":rollback":     `{}`,
+			},
+		}
+		client, ts := s.start(t)
+
+		template, err := client.Rollback(context.Background(), 4)
+		ts.Close()
+		want := "remote config template version 4 not found"
+		if template != nil || err == nil || err.Error() != want || !IsNotFound(err) {
+			t.Errorf("Rollback() = (%v, %v); want = (nil, %q)", template, err, want)
+		}
+		if len(s.Req) != 1 {
+			t.Errorf("Requests = %d; want = 1", len(s.Req))
+		}
+	}
+}
+
+func TestRollbackInvalidVersionNumber(t *testing.T) {
+	client, err := NewClient(context.Background(), testRemoteConfigConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int64{0, -1} {
+		if template, err := client.Rollback(context.Background(), n); template != nil || err == nil {
+			t.Errorf("Rollback(%d) = (%v, %v); want = (nil, error)", n, template, err)
+		}
+	}
+}
@@ -121,7 +121,7 @@ func verifyIDTokenAndCheckRevoked(ctx context.Context, app *firebase.App, idToke
 	}
 	token, err := client.VerifyIDTokenAndCheckRevoked(ctx, idToken)
 	if err != nil {
-		if err.Error() == "ID token has been revoked" {
+		if auth.IsIDTokenRevoked(err) {
 			// Token is revoked. Inform the user to reauthenticate or signOut() the user.
 		} else {
 			// Token is invalid
@@ -44,8 +44,8 @@ func NewClient(ctx context.Context, c *internal.StorageConfig) (*Client, error)
 
 // DefaultBucket returns a handle to the default Cloud Storage bucket.
 //
-// To use this method, the default bucket name must be specified via firebase.Config when
-// initializing the App.
+// The default bucket name is the StorageBucket specified via firebase.Config when initializing
+// the App. If that is not set, the <projectID>.appspot.com bucket of the project is used.
 func (c *Client) DefaultBucket() (*storage.BucketHandle, error) {
 	return c.Bucket(c.bucket)
 }